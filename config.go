@@ -7,7 +7,64 @@ type flags struct {
 	ResyncPeriodS string
 	ResyncPeriod  time.Duration
 	StatusAddr    string
+	PprofAddr     string
 	AllowAll      bool
 	LogLevel      string
 	LogFormat     string
+
+	ReplicationRulesFile string
+	ReplicationRuleCRD   bool
+	RulePrecedence       string
+	ConsumerDiscovery    bool
+	QuarantineThreshold  int
+
+	MaxReplicatedObjectBytes int
+	MaxFanout                int
+	MaintenanceWindow        string
+	SourceHash               bool
+	Mode                     string
+	ReadOnly                 bool
+	Environment              string
+	ReplicaExtraAnnotations  string
+	ReplicaMetadataDenylist  string
+	VerifyWrites             bool
+	ReadyAnnotation          bool
+	DeleteBeforeCreate       bool
+	SweepDeletedSources      bool
+	WriteStrategy            string
+	FeatureGates             string
+	ExternalSink             string
+	ExternalSinkPath         string
+	TransformPluginAddr      string
+	PreDeleteHookURL         string
+	StallThresholdS          string
+	ReplicaMetadataTemplate  string
+	FailureStatusConfigMap   string
+
+	NamespaceEndpointPrefixes string
+	NamespaceEndpointRefreshS string
+	NameCollisionStrategy     string
+	OtelEndpoint              string
+	MinReconcileIntervalS     string
+	NamespaceMapping          string
+	ConfigDebounceS           string
+	CreatorLabel              string
+	BatchWindowS              string
+	OrphanDeleteGraceS        string
+	BatchConcurrency          int
+
+	EnableSecretReplication      bool
+	EnableConfigMapReplication   bool
+	EnableRoleReplication        bool
+	EnableRoleBindingReplication bool
+	EnableIngressReplication     bool
+
+	ClientQPS      float64
+	ClientBurst    int
+	ClientTimeoutS string
+	ClientTimeout  time.Duration
+	ListPageSize   int64
+
+	MetricsNamespace string
+	MetricsSubsystem string
 }
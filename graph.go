@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+type listFunc func(ctx context.Context, client kubernetes.Interface) (runtime.Object, error)
+
+// graphKinds are all kinds of resources that are included in the replication graph
+var graphKinds = []struct {
+	Kind string
+	List listFunc
+}{
+	{"Secret", func(ctx context.Context, client kubernetes.Interface) (runtime.Object, error) {
+		return client.CoreV1().Secrets("").List(ctx, metav1.ListOptions{})
+	}},
+	{"ConfigMap", func(ctx context.Context, client kubernetes.Interface) (runtime.Object, error) {
+		return client.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{})
+	}},
+	{"Role", func(ctx context.Context, client kubernetes.Interface) (runtime.Object, error) {
+		return client.RbacV1().Roles("").List(ctx, metav1.ListOptions{})
+	}},
+	{"RoleBinding", func(ctx context.Context, client kubernetes.Interface) (runtime.Object, error) {
+		return client.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{})
+	}},
+	{"Ingress", func(ctx context.Context, client kubernetes.Interface) (runtime.Object, error) {
+		return client.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	}},
+}
+
+// exportGraph writes the current replication graph of all kinds to w in the
+// Graphviz DOT format.
+func exportGraph(ctx context.Context, client kubernetes.Interface, w io.Writer) error {
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not list namespaces")
+	}
+
+	replications := make(map[string][]common.Replication)
+	for _, k := range graphKinds {
+		list, err := k.List(ctx, client)
+		if err != nil {
+			return errors.Wrapf(err, "could not list %s resources", k.Kind)
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return errors.Wrapf(err, "could not extract %s resources", k.Kind)
+		}
+
+		objects := make([]metav1.Object, len(items))
+		for i := range items {
+			objects[i] = common.MustGetObject(items[i])
+		}
+
+		replications[k.Kind] = common.Replications(k.Kind, objects, namespaces.Items)
+	}
+
+	return writeDOT(w, replications)
+}
+
+// writeDOT renders replications as a directed graph with one cluster per kind
+func writeDOT(w io.Writer, replications map[string][]common.Replication) error {
+	out := bufio.NewWriter(w)
+	node := func(kind string, key string) string {
+		return strconv.Quote(kind + "/" + key)
+	}
+
+	fmt.Fprintln(out, "digraph replication {")
+	fmt.Fprintln(out, "  rankdir=LR;")
+	fmt.Fprintln(out, "  node [shape=box];")
+
+	for _, k := range graphKinds {
+		edges := replications[k.Kind]
+		if len(edges) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "\n  subgraph %s {\n", strconv.Quote("cluster_"+k.Kind))
+		fmt.Fprintf(out, "    label=%s;\n", strconv.Quote(k.Kind))
+
+		nodes := make(map[string]struct{})
+		for _, e := range edges {
+			for _, key := range []string{e.Source, e.Target} {
+				if _, ok := nodes[key]; !ok {
+					nodes[key] = struct{}{}
+					fmt.Fprintf(out, "    %s [label=%s];\n", node(k.Kind, key), strconv.Quote(key))
+				}
+			}
+		}
+
+		for _, e := range edges {
+			fmt.Fprintf(out, "    %s -> %s [label=%s];\n", node(k.Kind, e.Source), node(k.Kind, e.Target), strconv.Quote(e.ReplicatedAt))
+		}
+
+		fmt.Fprintln(out, "  }")
+	}
+
+	fmt.Fprintln(out, "}")
+
+	return out.Flush()
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/mittwald/kubernetes-replicator/replicate/common"
 	"net/http"
+	"time"
 )
 
 type response struct {
@@ -26,6 +27,11 @@ func (h *Handler) notReadyComponents() []string {
 		if !synced {
 			notReady = append(notReady, fmt.Sprintf("%T", h.Replicators[i]))
 		}
+
+		// hanging operations block the replicator, so it needs to be restarted
+		if running, stalled := h.Replicators[i].Stalled(); stalled {
+			notReady = append(notReady, fmt.Sprintf("%T (stalled for %s)", h.Replicators[i], running.Round(time.Second)))
+		}
 	}
 
 	return notReady
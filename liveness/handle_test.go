@@ -6,12 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type MockReplicator struct {
-	synced bool
+	synced  bool
+	stalled bool
 }
 
 func (r *MockReplicator) Run() {
@@ -21,11 +23,23 @@ func (r *MockReplicator) Synced() bool {
 	return r.synced
 }
 
+func (r *MockReplicator) Stalled() (time.Duration, bool) {
+	if r.stalled {
+		return 10 * time.Minute, true
+	}
+	return 0, false
+}
+
 //noinspection GoUnusedParameter
 func (r *MockReplicator) NamespaceAdded(ns *v1.Namespace) {
 	// Do nothing
 }
 
+//noinspection GoUnusedParameter
+func (r *MockReplicator) Status(namespace string, name string) (*common.SourceStatus, bool) {
+	return nil, false
+}
+
 func buildReqRes(t *testing.T) (*http.Request, *httptest.ResponseRecorder) {
 	req, err := http.NewRequest("GET", "/status", nil)
 	res := httptest.NewRecorder()
@@ -63,3 +77,19 @@ func TestReturns503IfOneReplicatorIsNotSynced(t *testing.T) {
 
 	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
 }
+
+func TestReturns503IfOneReplicatorIsStalled(t *testing.T) {
+	req, res := buildReqRes(t)
+
+	handler := Handler{
+		Replicators: []common.Replicator{
+			&MockReplicator{synced: true},
+			&MockReplicator{synced: true, stalled: true},
+		},
+	}
+
+	handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Contains(t, res.Body.String(), "stalled for 10m0s")
+}
@@ -1,20 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/mittwald/kubernetes-replicator/replicate"
 	"github.com/mittwald/kubernetes-replicator/replicate/common"
-	"github.com/mittwald/kubernetes-replicator/replicate/configmap"
-	"github.com/mittwald/kubernetes-replicator/replicate/role"
-	"github.com/mittwald/kubernetes-replicator/replicate/rolebinding"
-	"github.com/mittwald/kubernetes-replicator/replicate/secret"
+	"github.com/mittwald/kubernetes-replicator/replicate/transform"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/mittwald/kubernetes-replicator/liveness"
+	"github.com/mittwald/kubernetes-replicator/status"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -22,14 +27,67 @@ import (
 
 var f flags
 
-func init() {
+// parseFlags parses the command line into f. It is called by main instead of
+// an init function, so that the tests of this package can define their own
+// flags.
+func parseFlags() {
 	var err error
 	flag.StringVar(&f.Kubeconfig, "kubeconfig", "", "path to Kubernetes config file")
 	flag.StringVar(&f.ResyncPeriodS, "resync-period", "30m", "resynchronization period")
 	flag.StringVar(&f.StatusAddr, "status-addr", ":9102", "listen address for status and monitoring server")
+	flag.StringVar(&f.MetricsNamespace, "metrics-namespace", common.DefaultMetricsNamespace, "prefix of the names of all metrics, including the workqueue_*, go_* and process_* metrics, to tell them apart from those of other controllers")
+	flag.StringVar(&f.MetricsSubsystem, "metrics-subsystem", "", "optional second prefix of the names of all metrics, added after -metrics-namespace")
+	flag.StringVar(&f.PprofAddr, "pprof-addr", "", "listen address for the pprof profiling server, e.g. 'localhost:6060' (disabled if empty)")
 	flag.StringVar(&f.LogLevel, "log-level", "info", "Log level (trace, debug, info, warn, error)")
 	flag.StringVar(&f.LogFormat, "log-format", "plain", "Log format (plain, json)")
 	flag.BoolVar(&f.AllowAll, "allow-all", false, "allow replication of all secrets (CAUTION: only use when you know what you're doing)")
+	flag.StringVar(&f.ReplicationRulesFile, "replication-rules", "", "path to a file with replication rules that apply in addition to replicate-to annotations")
+	flag.BoolVar(&f.ReplicationRuleCRD, "replication-rule-crd", false, "apply the replication rules of ReplicationRule resources (requires the ReplicationRule custom resource definition)")
+	flag.StringVar(&f.RulePrecedence, "rule-precedence", common.RulePrecedenceMerge, "how the replicate-to annotation of a source is combined with matching replication rules: 'merge' (replicate to the namespaces of both), 'rules' (ignore the annotation) or 'annotations' (ignore the rules)")
+	flag.IntVar(&f.QuarantineThreshold, "quarantine-after", 0, "stop retrying a resource version after this many failed replications (0 to disable)")
+	flag.IntVar(&f.MaxReplicatedObjectBytes, "max-replicated-object-bytes", 0, "refuse to replicate objects larger than this many bytes (0 to disable)")
+	flag.IntVar(&f.MaxFanout, "max-fanout", 0, "refuse to push sources into more than this many namespaces (0 to disable)")
+	flag.StringVar(&f.MaintenanceWindow, "maintenance-window", "", "semicolon separated list of maintenance windows during which all writes are deferred, e.g. 'Sat-Sun 22:00-04:00' (UTC) or '<RFC3339 start>/<RFC3339 end>'")
+	flag.BoolVar(&f.SourceHash, "source-hash", false, "annotate replicas with a checksum of the content they received from their source")
+	flag.StringVar(&f.Mode, "mode", "normal", "operating mode; 'shadow' reports the differences between the intended and the actual state of the cluster without changing it")
+	flag.BoolVar(&f.ReadOnly, "read-only", false, "only read from the cluster: writes are logged, but not sent to the API server, not even as dry runs; the transformation plugin and the trace exporter are not used")
+	flag.StringVar(&f.Environment, "environment", "", "only process sources whose replicator.v1.mittwald.de/environment annotation has this value")
+	flag.StringVar(&f.ReplicaExtraAnnotations, "replica-extra-annotations", "", "comma separated list of key=value annotations added to all replicas, e.g. 'sidecar.istio.io/inject=false' (commas in values are escaped with a backslash)")
+	flag.StringVar(&f.ReplicaMetadataDenylist, "replica-metadata-denylist", common.DefaultReplicaMetadataDenylist, "comma separated list of label and annotation key prefixes that are never copied from sources to replicas")
+	flag.BoolVar(&f.VerifyWrites, "verify-writes", false, "read replicas back after writing them and warn if their content differs, e.g. because of admission webhooks")
+	flag.BoolVar(&f.ReadyAnnotation, "ready-annotation", false, "annotate pushed replicas with replicator.v1.mittwald.de/ready once they were written (and verified, if -verify-writes is enabled)")
+	flag.BoolVar(&f.DeleteBeforeCreate, "delete-before-create", false, "remove replicas from namespaces that are not targeted any more before creating or updating replicas")
+	flag.StringVar(&f.OrphanDeleteGraceS, "orphan-delete-grace", "0s", "only remove replicas from namespaces that are not targeted any more once they stayed untargeted for this long, to avoid flapping deletes while annotations are edited (0 to remove them right away)")
+	flag.BoolVar(&f.SweepDeletedSources, "sweep-deleted-sources", false, "on startup, delete replicas whose source was deleted while the replicator was not running")
+	flag.StringVar(&f.WriteStrategy, "write-strategy", common.WriteStrategyUpdate, "how secret and config map replicas are written: 'update', 'merge-patch' (JSON merge patch) or 'apply' (server-side apply)")
+	flag.StringVar(&f.FeatureGates, "feature-gates", "", "<namespace>/<name> of a config map with feature gates that sources can be gated by using the replicator.v1.mittwald.de/gated-by annotation")
+	flag.StringVar(&f.ExternalSink, "external-sink", "", "export secret replicas to an external store, e.g. 'file:/var/lib/replicator/secrets'")
+	flag.StringVar(&f.ExternalSinkPath, "external-sink-path", common.DefaultExternalSinkPath, "template of the path that secret replicas are exported to in the external sink")
+	flag.StringVar(&f.TransformPluginAddr, "transform-plugin-addr", "", "address of a gRPC transformation plugin that changes replicas before they are written, e.g. 'localhost:9200' or 'unix:///var/run/transform.sock' (disabled if empty)")
+	flag.StringVar(&f.PreDeleteHookURL, "pre-delete-hook-url", "", "URL that is called with a POST request before each replica is deleted; the replica is only deleted if it responds with 200 OK, otherwise the deletion is retried later (disabled if empty)")
+	flag.StringVar(&f.StallThresholdS, "stall-threshold", "0s", "fail the liveness probe if processing a single event takes longer than this, e.g. because of a hanging API call (0 to disable)")
+	flag.StringVar(&f.ReplicaMetadataTemplate, "replica-metadata-template", "", "path to a file with templates of labels and annotations that are set on replicas, derived from the metadata of their target namespace")
+	flag.StringVar(&f.NamespaceEndpointPrefixes, "namespace-endpoint-prefixes", "", "comma separated list of URL prefixes (scheme, host and leading path segments) that replicator.v1.mittwald.de/replicate-to-url annotations may point to, e.g. 'https://teams.internal/' (disabled if empty)")
+	flag.StringVar(&f.NamespaceEndpointRefreshS, "namespace-endpoint-refresh", common.DefaultNamespaceEndpointRefresh.String(), "interval after which the namespaces listed by replicate-to-url endpoints are fetched again")
+	flag.StringVar(&f.NameCollisionStrategy, "name-collision-strategy", common.NameCollisionOverwrite, "how pushed replicas handle objects with the same name that were not written by the replicator: 'overwrite', 'skip', 'suffix' (replicate to a name with a suffix) or 'error'")
+	flag.StringVar(&f.OtelEndpoint, "otel-endpoint", "", "OTLP/gRPC endpoint of an OpenTelemetry collector that traces of reconciles are exported to, e.g. 'http://otel-collector:4317' (disabled if empty)")
+	flag.StringVar(&f.MinReconcileIntervalS, "min-reconcile-interval", "0s", "minimum interval between two reconciles of the same resource; changes within the interval are coalesced (0 to disable)")
+	flag.StringVar(&f.NamespaceMapping, "namespace-mapping", "", "<namespace>/<name> of a config map that maps logical namespace names used in replicate-to annotations to host namespace names, e.g. for virtual clusters")
+	flag.StringVar(&f.ConfigDebounceS, "config-debounce", "2s", "apply changes of the -feature-gates and -namespace-mapping config maps only once they were not changed again for this long (0 to apply every change right away)")
+	flag.StringVar(&f.CreatorLabel, "creator-label", common.DefaultCreatorLabel, "namespace label that identifies the provisioner that created a namespace, used by the replicator.v1.mittwald.de/replicate-to-creator annotation")
+	flag.StringVar(&f.BatchWindowS, "batch-window", "0s", "collect writes of push-based replication for this long and issue them grouped by target namespace, coalescing repeated writes of the same source (0 to disable)")
+	flag.IntVar(&f.BatchConcurrency, "batch-concurrency", common.DefaultBatchConcurrency, "number of target namespaces whose batched writes are issued in parallel")
+	flag.BoolVar(&f.ConsumerDiscovery, "consumer-discovery", false, "watch deployments to replicate secrets with the replicator.v1.mittwald.de/replicate-to-consumers annotation into all namespaces whose deployments reference them")
+	flag.StringVar(&f.FailureStatusConfigMap, "failure-status-configmap", "", "name of a config map in the namespace of each source that replication failures are written to, e.g. 'replicator-status'")
+	flag.BoolVar(&f.EnableSecretReplication, "enable-secret-replication", true, "replicate secrets")
+	flag.BoolVar(&f.EnableConfigMapReplication, "enable-configmap-replication", true, "replicate config maps")
+	flag.BoolVar(&f.EnableRoleReplication, "enable-role-replication", true, "replicate roles")
+	flag.BoolVar(&f.EnableRoleBindingReplication, "enable-rolebinding-replication", true, "replicate role bindings")
+	flag.BoolVar(&f.EnableIngressReplication, "enable-ingress-replication", false, "replicate ingresses")
+	flag.Float64Var(&f.ClientQPS, "client-qps", 5, "maximum number of queries per second sent to the Kubernetes API server")
+	flag.IntVar(&f.ClientBurst, "client-burst", 10, "maximum burst of queries sent to the Kubernetes API server")
+	flag.StringVar(&f.ClientTimeoutS, "client-timeout", "0s", "timeout for requests to the Kubernetes API server; watches are restarted when they exceed it (0 to disable)")
+	flag.Int64Var(&f.ListPageSize, "list-page-size", 0, "number of objects requested per page when listing all objects of a kind at startup or on relists, to bound memory usage in large clusters (0 to use the client-go default)")
 	flag.Parse()
 
 	switch strings.ToUpper(strings.TrimSpace(f.LogLevel)) {
@@ -57,15 +115,232 @@ func init() {
 		panic(err)
 	}
 
+	f.ClientTimeout, err = time.ParseDuration(f.ClientTimeoutS)
+	if err != nil {
+		panic(err)
+	}
+
+	common.Options.StallThreshold, err = time.ParseDuration(f.StallThresholdS)
+	if err != nil {
+		panic(err)
+	}
+	if common.Options.StallThreshold < 0 {
+		panic(fmt.Errorf("stall-threshold must not be negative, got %s", common.Options.StallThreshold))
+	}
+
+	if f.ClientQPS <= 0 {
+		panic(fmt.Errorf("client-qps must be positive, got %v", f.ClientQPS))
+	}
+	if f.ClientBurst <= 0 {
+		panic(fmt.Errorf("client-burst must be positive, got %d", f.ClientBurst))
+	}
+	if f.ListPageSize < 0 {
+		panic(fmt.Errorf("list-page-size must not be negative, got %d", f.ListPageSize))
+	}
+	common.Options.ListPageSize = f.ListPageSize
+	if err = common.ValidateMetricsPrefix(f.MetricsNamespace, f.MetricsSubsystem); err != nil {
+		panic(err)
+	}
+	if f.ClientTimeout < 0 {
+		panic(fmt.Errorf("client-timeout must not be negative, got %s", f.ClientTimeout))
+	}
+
+	if !f.EnableSecretReplication && !f.EnableConfigMapReplication && !f.EnableRoleReplication && !f.EnableRoleBindingReplication && !f.EnableIngressReplication {
+		panic(fmt.Errorf("replication of all kinds is disabled"))
+	}
+
+	if f.FeatureGates != "" && len(strings.SplitN(f.FeatureGates, "/", 2)) < 2 {
+		panic(fmt.Errorf("invalid feature-gates expected '<namespace>/<name>', got '%s'", f.FeatureGates))
+	}
+
+	if f.NamespaceMapping != "" && len(strings.SplitN(f.NamespaceMapping, "/", 2)) < 2 {
+		panic(fmt.Errorf("invalid namespace-mapping expected '<namespace>/<name>', got '%s'", f.NamespaceMapping))
+	}
+
+	if f.Mode != "normal" && f.Mode != "shadow" {
+		panic(fmt.Errorf("unknown mode '%s'", f.Mode))
+	}
+
+	common.Options.QuarantineThreshold = f.QuarantineThreshold
+	common.Options.MaxReplicatedObjectBytes = f.MaxReplicatedObjectBytes
+	common.Options.MaxFanout = f.MaxFanout
+	common.Options.SourceHash = f.SourceHash
+	common.Options.Environment = f.Environment
+	// writes are not persisted in shadow or read-only mode, so verifying them
+	// would always fail
+	common.Options.VerifyWrites = f.VerifyWrites && f.Mode != "shadow" && !f.ReadOnly
+	common.Options.ReadOnly = f.ReadOnly
+	common.Options.ReadyAnnotation = f.ReadyAnnotation
+	common.Options.DeleteBeforeCreate = f.DeleteBeforeCreate
+	common.Options.SweepDeletedSources = f.SweepDeletedSources
+	common.Options.OrphanDeleteGrace, err = time.ParseDuration(f.OrphanDeleteGraceS)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.WriteStrategy, err = common.ParseWriteStrategy(f.WriteStrategy)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.NameCollisionStrategy, err = common.ParseNameCollisionStrategy(f.NameCollisionStrategy)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.RulePrecedence, err = common.ParseRulePrecedence(f.RulePrecedence)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.MinReconcileInterval, err = time.ParseDuration(f.MinReconcileIntervalS)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.BatchWindow, err = time.ParseDuration(f.BatchWindowS)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.ConfigDebounce, err = time.ParseDuration(f.ConfigDebounceS)
+	if err != nil {
+		panic(err)
+	}
+	if f.BatchConcurrency < 1 {
+		panic(fmt.Errorf("batch-concurrency must be at least 1, got %d", f.BatchConcurrency))
+	}
+	common.Options.BatchConcurrency = f.BatchConcurrency
+	common.Options.CreatorLabel = f.CreatorLabel
+	common.Options.FailureStatusConfigMap = f.FailureStatusConfigMap
+	// replicas are not deleted in shadow or read-only mode, so consumers
+	// must not be drained either
+	if f.Mode != "shadow" && !f.ReadOnly {
+		common.Options.PreDeleteHookURL = f.PreDeleteHookURL
+	}
+	common.Options.NamespaceEndpointPrefixes = common.ParseNamespaceEndpointPrefixes(f.NamespaceEndpointPrefixes)
+	common.Options.NamespaceEndpointRefresh, err = time.ParseDuration(f.NamespaceEndpointRefreshS)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.MaintenanceWindows, err = common.ParseMaintenanceWindows(f.MaintenanceWindow)
+	if err != nil {
+		panic(err)
+	}
+	common.Options.ReplicaMetadataDenylist = common.ParseMetadataDenylist(f.ReplicaMetadataDenylist)
+	common.Options.ReplicaExtraAnnotations, err = common.ParseExtraAnnotations(f.ReplicaExtraAnnotations)
+	if err != nil {
+		panic(err)
+	}
+	if f.ReplicaMetadataTemplate != "" {
+		common.Options.ReplicaMetadataTemplate, err = common.LoadReplicaMetadataTemplate(f.ReplicaMetadataTemplate)
+		if err != nil {
+			panic(err)
+		}
+	}
+	common.Options.ExternalSinkPath, err = common.ParseExternalSinkPath(f.ExternalSinkPath)
+	if err != nil {
+		panic(err)
+	}
+	// replicas are not written in shadow or read-only mode, so they aren't
+	// exported either
+	if f.ExternalSink != "" && f.Mode != "shadow" && !f.ReadOnly {
+		common.Options.ExternalSink, err = common.ParseExternalSink(f.ExternalSink)
+		if err != nil {
+			panic(err)
+		}
+	}
+	common.Options.TransformPlugin, err = dialTransformPlugin()
+	if err != nil {
+		panic(err)
+	}
+
 	log.Debugf("using flag values %#v", f)
 }
 
+// dialTransformPlugin connects to the transformation plugin, if one is
+// configured. In read-only mode, the plugin is not used: nothing may leave the
+// replicator, and the replicas that it would transform are never written.
+func dialTransformPlugin() (common.ReplicaTransformer, error) {
+	if f.TransformPluginAddr == "" {
+		return nil, nil
+	}
+	if f.ReadOnly {
+		log.Infof("read-only mode: not using the transformation plugin at %s", f.TransformPluginAddr)
+		return nil, nil
+	}
+
+	plugin, err := transform.Dial(f.TransformPluginAddr)
+	if err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+// enabledKinds returns which kinds are replicated according to the flags
+func enabledKinds() map[string]bool {
+	return map[string]bool{
+		"Secret":      f.EnableSecretReplication,
+		"ConfigMap":   f.EnableConfigMapReplication,
+		"Role":        f.EnableRoleReplication,
+		"RoleBinding": f.EnableRoleBindingReplication,
+		"Ingress":     f.EnableIngressReplication,
+	}
+}
+
+// watchConfiguration starts watching all configuration of the replicator that
+// is stored in the cluster, as enabled by the flags. It blocks until the
+// configuration was read once.
+func watchConfiguration(config *rest.Config, client kubernetes.Interface) {
+	if f.ReplicationRuleCRD {
+		if err := common.WatchReplicationRuleResources(dynamic.NewForConfigOrDie(config), client, f.ResyncPeriod); err != nil {
+			log.WithError(err).Fatal("could not watch replication rule resources")
+		}
+	}
+
+	if f.FeatureGates != "" {
+		gates := strings.SplitN(f.FeatureGates, "/", 2)
+		if err := common.WatchFeatureGates(client, gates[0], gates[1], f.ResyncPeriod); err != nil {
+			log.WithError(err).Fatal("could not watch feature gates")
+		}
+	}
+
+	if f.NamespaceMapping != "" {
+		mapping := strings.SplitN(f.NamespaceMapping, "/", 2)
+		if err := common.WatchNamespaceMapping(client, mapping[0], mapping[1], f.ResyncPeriod); err != nil {
+			log.WithError(err).Fatal("could not watch namespace mapping")
+		}
+	}
+
+	if f.ConsumerDiscovery {
+		if err := common.WatchSecretConsumers(client, f.ResyncPeriod); err != nil {
+			log.WithError(err).Fatal("could not watch secret consumers")
+		}
+	}
+}
+
 func main() {
+	parseFlags()
 
 	var config *rest.Config
 	var err error
 	var client kubernetes.Interface
 
+	if f.ReplicationRulesFile != "" {
+		if err := loadReplicationRules(f.ReplicationRulesFile); err != nil {
+			log.WithError(err).Fatal("could not load replication rules")
+		}
+	}
+
+	if flag.Arg(0) == "print-rbac" {
+		if err := printRBAC(os.Stdout, flag.Args()[1:]); err != nil {
+			log.WithError(err).Fatal("could not print RBAC rules")
+		}
+		return
+	}
+
+	// simulations don't need a cluster
+	if flag.Arg(0) == "simulate" {
+		if err := simulate(os.Stdout, flag.Args()[1:]); err != nil {
+			log.WithError(err).Fatal("simulation failed")
+		}
+		return
+	}
+
 	if f.Kubeconfig == "" {
 		log.Info("using in-cluster configuration")
 		config, err = rest.InClusterConfig()
@@ -78,29 +353,83 @@ func main() {
 		panic(err)
 	}
 
+	config.QPS = float32(f.ClientQPS)
+	config.Burst = f.ClientBurst
+	config.Timeout = f.ClientTimeout
+	log.Infof("using client configuration qps=%v burst=%d timeout=%s list-page-size=%d", config.QPS, config.Burst, config.Timeout, f.ListPageSize)
+
+	if f.ReadOnly {
+		log.Info("running in read-only mode; no writes will be sent to the cluster")
+		config.Wrap(common.ReadOnlyTransport)
+	} else if f.Mode == "shadow" {
+		log.Info("running in shadow mode; no changes will be made to the cluster")
+		config.Wrap(common.ShadowTransport)
+	}
+
+	// the command creates its own client to record which targets it writes
+	if flag.Arg(0) == "reconcile-once" {
+		if err := reconcileOnce(os.Stdout, config, flag.Args()[1:]); err != nil {
+			log.WithError(err).Fatal("reconcile failed")
+		}
+		return
+	}
+
 	client = kubernetes.NewForConfigOrDie(config)
 
-	secretRepl := secret.NewReplicator(client, f.ResyncPeriod, f.AllowAll)
-	configMapRepl := configmap.NewReplicator(client, f.ResyncPeriod, f.AllowAll)
-	roleRepl := role.NewReplicator(client, f.ResyncPeriod, f.AllowAll)
-	roleBindingRepl := rolebinding.NewReplicator(client, f.ResyncPeriod, f.AllowAll)
+	switch flag.Arg(0) {
+	case "":
+	case "export-graph":
+		if err := exportGraph(context.Background(), client, os.Stdout); err != nil {
+			log.WithError(err).Fatal("could not export replication graph")
+		}
+		return
+	default:
+		log.Fatalf("unknown command '%s'", flag.Arg(0))
+	}
+
+	// traces are not exported in read-only mode, so that nothing leaves the
+	// replicator
+	if f.OtelEndpoint != "" && f.ReadOnly {
+		log.Infof("read-only mode: not exporting traces to %s", f.OtelEndpoint)
+	} else if f.OtelEndpoint != "" {
+		if err := startTracing(context.Background(), f.OtelEndpoint); err != nil {
+			log.WithError(err).Fatal("could not start tracing")
+		}
+	}
+
+	if f.ReplicationRulesFile != "" {
+		go watchReplicationRules(f.ReplicationRulesFile, replicationRulesCheckInterval)
+	}
 
-	go secretRepl.Run()
+	watchConfiguration(config, client)
 
-	go configMapRepl.Run()
+	h := liveness.Handler{}
+	s := status.Handler{
+		Replicators: replicate.Start(client, enabledKinds(), f.ResyncPeriod, f.AllowAll),
+	}
 
-	go roleRepl.Run()
+	for _, k := range replicate.Kinds {
+		if repl, ok := s.Replicators[k.Kind]; ok {
+			h.Replicators = append(h.Replicators, repl)
+		}
+	}
 
-	go roleBindingRepl.Run()
+	go watchSummarySignal(s.Replicators)
 
-	h := liveness.Handler{
-		Replicators: []common.Replicator{secretRepl, configMapRepl, roleRepl, roleBindingRepl},
+	if f.PprofAddr != "" {
+		go servePprof(f.PprofAddr)
 	}
 
 	log.Infof("starting liveness monitor at %s", f.StatusAddr)
 
-	http.Handle("/healthz", &h)
-	err = http.ListenAndServe(f.StatusAddr, nil)
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", &h)
+	mux.Handle("/status", &s)
+	registry := prometheus.NewRegistry()
+	registerer := common.RegisterMetrics(registry, f.MetricsNamespace, f.MetricsSubsystem)
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(registerer,
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+	err = http.ListenAndServe(f.StatusAddr, mux)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/mittwald/kubernetes-replicator/replicate/secret"
+	"github.com/mittwald/kubernetes-replicator/replicate/transform"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type transformerServer interface {
+	Transform(ctx context.Context, req *transform.TransformRequest) (*transform.TransformResponse, error)
+}
+
+// countingPlugin is a transformation plugin that leaves replicas unchanged
+// and counts how often it was called
+type countingPlugin struct {
+	calls int32
+}
+
+func (p *countingPlugin) Transform(_ context.Context, _ *transform.TransformRequest) (*transform.TransformResponse, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return &transform.TransformResponse{}, nil
+}
+
+// transformServiceDesc registers a Transformer server without generated gRPC
+// code
+var transformServiceDesc = grpc.ServiceDesc{
+	ServiceName: "replicator.transform.v1.Transformer",
+	HandlerType: (*transformerServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Transform",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(transform.TransformRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return srv.(transformerServer).Transform(ctx, req)
+		},
+	}},
+}
+
+func TestReadOnlyModeNeverCallsTransformPlugin(t *testing.T) {
+	defer func(flags flags) { f = flags }(f)
+	defer func(plugin common.ReplicaTransformer) { common.Options.TransformPlugin = plugin }(common.Options.TransformPlugin)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	plugin := &countingPlugin{}
+	server := grpc.NewServer()
+	server.RegisterService(&transformServiceDesc, plugin)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "transformed",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.ReplicateTo: "team-a"},
+		},
+		Data: map[string][]byte{"foo": []byte("bar")},
+	}
+	replicate := func() {
+		repl := secret.NewReplicator(fake.NewSimpleClientset(), 60*time.Second, false).(*secret.Replicator)
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}))
+	}
+
+	f.TransformPluginAddr = listener.Addr().String()
+	f.ReadOnly = true
+	common.Options.TransformPlugin, err = dialTransformPlugin()
+	require.NoError(t, err)
+	require.Nil(t, common.Options.TransformPlugin)
+
+	replicate()
+	require.Zero(t, atomic.LoadInt32(&plugin.calls))
+
+	// the same replication calls the plugin once writes are enabled
+	f.ReadOnly = false
+	common.Options.TransformPlugin, err = dialTransformPlugin()
+	require.NoError(t, err)
+	defer common.Options.TransformPlugin.(*transform.Client).Close()
+
+	replicate()
+	require.Equal(t, int32(1), atomic.LoadInt32(&plugin.calls))
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// servePprof serves the profiling endpoints of net/http/pprof at addr. They
+// are kept off the status server, since profiles may contain secrets that are
+// held in memory and shouldn't be reachable wherever metrics are scraped.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	log.Infof("starting pprof server at %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.WithError(err).Fatal("pprof server failed")
+	}
+}
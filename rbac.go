@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultServiceAccount is the service account of the manifests in deploy/
+const defaultServiceAccount = "kube-system/replicator-kubernetes-replicator"
+
+// printRBAC writes the ClusterRole, Roles and their bindings that the
+// replicator needs with the current flags to w, as multi-document YAML. The
+// optional argument is the <namespace>/<name> of the service account the
+// replicator runs as.
+func printRBAC(w io.Writer, args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: print-rbac [<namespace>/<service account>]")
+	}
+
+	account := defaultServiceAccount
+	if len(args) == 1 {
+		account = args[0]
+	}
+	parts := strings.SplitN(account, "/", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return errors.Errorf("invalid service account expected '<namespace>/<name>', got '%s'", account)
+	}
+	namespace, name := parts[0], parts[1]
+
+	config := replicate.RBACConfig{
+		Enabled:            enabledKinds(),
+		FailureStatus:      f.FailureStatusConfigMap != "",
+		ReplicationRuleCRD: f.ReplicationRuleCRD,
+		ConsumerDiscovery:  f.ConsumerDiscovery,
+	}
+	if f.FeatureGates != "" {
+		config.FeatureGatesNamespace = strings.SplitN(f.FeatureGates, "/", 2)[0]
+	}
+	if f.NamespaceMapping != "" {
+		config.NamespaceMappingNamespace = strings.SplitN(f.NamespaceMapping, "/", 2)[0]
+	}
+
+	cluster, namespaced := replicate.RBACRules(config)
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name}}
+
+	objects := []interface{}{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules:      cluster,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects:   subjects,
+		},
+	}
+
+	roleNamespaces := make([]string, 0, len(namespaced))
+	for roleNamespace := range namespaced {
+		roleNamespaces = append(roleNamespaces, roleNamespace)
+	}
+	sort.Strings(roleNamespaces)
+
+	for _, roleNamespace := range roleNamespaces {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: roleNamespace, Name: name},
+				Rules:      namespaced[roleNamespace],
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: roleNamespace, Name: name},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+				Subjects:   subjects,
+			},
+		)
+	}
+
+	for i, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return errors.Wrapf(err, "could not encode %T", obj)
+		}
+		if i > 0 {
+			if _, err := fmt.Fprintln(w, "---"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// reconciler is implemented by all replicators, since they embed the generic replicator
+type reconciler interface {
+	ReconcileOnce(ctx context.Context, key string) error
+}
+
+// reconcileOnce reconciles a single object against the cluster and writes
+// all writes to replicated kinds that the reconcile made to w, followed by
+// its outcome. Other objects are not processed.
+func reconcileOnce(w io.Writer, config *rest.Config, args []string) error {
+	flags := flag.NewFlagSet("reconcile-once", flag.ContinueOnError)
+	kindName := flags.String("kind", "", "kind of the object, e.g. 'Secret'")
+	namespace := flags.String("namespace", "", "namespace of the object")
+	name := flags.String("name", "", "name of the object")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *kindName == "" || *namespace == "" || *name == "" || flags.NArg() > 0 {
+		return errors.New("usage: reconcile-once -kind <kind> -namespace <namespace> -name <name>")
+	}
+
+	var kind *replicate.Kind
+	for i := range replicate.Kinds {
+		if strings.EqualFold(replicate.Kinds[i].Kind, *kindName) {
+			kind = &replicate.Kinds[i]
+		}
+	}
+	if kind == nil {
+		return errors.Errorf("unknown kind '%s'", *kindName)
+	}
+
+	recorder := &common.WriteRecorder{}
+	config = rest.CopyConfig(config)
+	config.Wrap(recorder.Transport)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return errors.Wrap(err, "could not create client")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a static list of namespaces keeps the replicator from replicating
+	// other sources into namespaces that are added by the namespace watcher
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not list namespaces")
+	}
+	common.UseStaticNamespaces(namespaces.Items)
+	watchConfiguration(config, client)
+
+	// batched writes would only be issued after the command has exited
+	common.Options.BatchWindow = 0
+
+	key := *namespace + "/" + *name
+	repl := kind.NewReplicator(client, 0, f.AllowAll)
+	failure := repl.(reconciler).ReconcileOnce(ctx, key)
+
+	writes := recorder.Writes()
+	if len(writes) == 0 {
+		fmt.Fprintln(w, "no changes")
+	}
+	for _, write := range writes {
+		fmt.Fprintln(w, write)
+	}
+
+	if failure != nil {
+		return errors.Wrapf(failure, "reconcile of %s %s failed", kind.Kind, key)
+	}
+	fmt.Fprintf(w, "reconciled %s %s\n", kind.Kind, key)
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package common
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// DefaultBatchConcurrency is the number of target namespaces whose batched
+// writes are issued in parallel if ControllerOptions.BatchConcurrency is not
+// set
+const DefaultBatchConcurrency = 4
+
+// delayedBatchFlush is the work item that flushes the pending writes of the
+// current batch window
+type delayedBatchFlush struct{}
+
+// writeBatch collects the writes of push-based replication during a batch
+// window, grouped by target namespace. Writes of the same source into the
+// same namespace within one window are coalesced into a single write of the
+// latest version of the source.
+type writeBatch struct {
+	lock    sync.Mutex
+	pending map[string]map[string]struct{}
+}
+
+// batchWrite adds a write to the current batch window and schedules the
+// flush of the window when its first write is added. It returns false if
+// batching is disabled, in which case the caller writes immediately.
+func (r *GenericReplicator) batchWrite(item delayedReplication) bool {
+	if Options.BatchWindow <= 0 {
+		return false
+	}
+
+	r.batch.lock.Lock()
+	defer r.batch.lock.Unlock()
+
+	if r.batch.pending == nil {
+		r.batch.pending = make(map[string]map[string]struct{})
+		r.DelayQueue.AddAfter(delayedBatchFlush{}, Options.BatchWindow)
+	}
+
+	sources, ok := r.batch.pending[item.Namespace]
+	if !ok {
+		sources = make(map[string]struct{})
+		r.batch.pending[item.Namespace] = sources
+	}
+
+	if _, ok := sources[item.SourceKey]; ok {
+		CoalescedWritesTotal.WithLabelValues(r.Kind).Inc()
+		return true
+	}
+
+	sources[item.SourceKey] = struct{}{}
+	BatchedWritesTotal.WithLabelValues(r.Kind).Inc()
+	return true
+}
+
+// batchedWrite is a write of a flushed batch whose source and target were
+// looked up
+type batchedWrite struct {
+	item      delayedReplication
+	obj       interface{}
+	namespace *v1.Namespace
+}
+
+// flushBatch issues all writes of the current batch window. The sources and
+// targets of the writes are looked up one after another, like any other
+// operation of the replicator. Then, the writes into each namespace are issued
+// one after another, while up to ControllerOptions.BatchConcurrency namespaces
+// are written in parallel; client-go multiplexes these requests over a single
+// connection to the API server. Each write replicates the latest version of
+// its source. Failed writes are recorded for their source like failures of
+// immediate writes.
+func (r *GenericReplicator) flushBatch() {
+	r.batch.lock.Lock()
+	pending := r.batch.pending
+	r.batch.pending = nil
+	r.batch.lock.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	concurrency := Options.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	namespaces := make([]string, 0, len(pending))
+	for namespace := range pending {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	writes := 0
+	batches := make([][]batchedWrite, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		sourceKeys := make([]string, 0, len(pending[namespace]))
+		for sourceKey := range pending[namespace] {
+			sourceKeys = append(sourceKeys, sourceKey)
+		}
+		sort.Strings(sourceKeys)
+
+		var batch []batchedWrite
+		for _, sourceKey := range sourceKeys {
+			item := delayedReplication{SourceKey: sourceKey, Namespace: namespace}
+			if obj, target, ok := r.delayedTarget(item); ok {
+				batch = append(batch, batchedWrite{item: item, obj: obj, namespace: target})
+			}
+		}
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+			writes += len(batch)
+		}
+	}
+
+	start := time.Now()
+	log.WithField("kind", r.Kind).Debugf("flushing %d batched writes into %d namespaces", writes, len(batches))
+
+	var failuresLock sync.Mutex
+	failures := make(map[string]*multierror.Error)
+	sources := make(map[string]interface{})
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(batch []batchedWrite) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			for _, write := range batch {
+				if err := r.writeDelayed(write.item, write.obj, write.namespace); err != nil {
+					log.WithField("kind", r.Kind).WithField("source", write.item.SourceKey).WithField("target", write.item.Namespace).WithError(err).
+						Errorf("Failed to replicate %s %s -> %s: %v", r.Kind, write.item.SourceKey, write.item.Namespace, err)
+
+					failuresLock.Lock()
+					failures[write.item.SourceKey] = multierror.Append(failures[write.item.SourceKey], err)
+					sources[write.item.SourceKey] = write.obj
+					failuresLock.Unlock()
+				}
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	for sourceKey, err := range failures {
+		r.recordFailure(sources[sourceKey], err)
+	}
+
+	log.WithField("kind", r.Kind).Infof("flushed %d batched writes into %d namespaces in %s", writes, len(batches), time.Since(start))
+}
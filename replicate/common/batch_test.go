@@ -0,0 +1,169 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// pushedToTeams are the annotations of sources that are pushed into all team
+// namespaces
+var pushedToTeams = map[string]string{ReplicateTo: "team-.*"}
+
+// newBatchReplicator returns a replicator that pushes the given sources into
+// namespaces and calls write for every replica it writes
+func newBatchReplicator(t testing.TB, namespaces []string, write func(source *v1.Secret, target *v1.Namespace)) *GenericReplicator {
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range namespaces {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	r := newTestReplicator(t, "BatchedSecret")
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		write(source.(*v1.Secret), target)
+		return nil
+	}
+
+	return r
+}
+
+func TestBatchedWritesAreCoalescedPerNamespace(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	defer func(window time.Duration) { Options.BatchWindow = window }(Options.BatchWindow)
+	Options.BatchWindow = time.Hour
+
+	var lock sync.Mutex
+	var written []string
+	r := newBatchReplicator(t, []string{"default", "team-a", "team-b"}, func(source *v1.Secret, target *v1.Namespace) {
+		lock.Lock()
+		defer lock.Unlock()
+		written = append(written, fmt.Sprintf("%s@%s -> %s", MustGetKey(source), source.ResourceVersion, target.Name))
+	})
+
+	targets := []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}}
+	for _, version := range []string{"1", "2"} {
+		source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", ResourceVersion: version, Annotations: pushedToTeams}}
+		require.NoError(t, r.Store.Update(source))
+		r.ReplicateToList[MustGetKey(source)] = struct{}{}
+
+		_, _, err := r.replicateResourceToNamespaces(context.Background(), source, targets)
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, written, "writes are not issued before the window ends")
+	assert.Len(t, r.batch.pending, 2, "writes are grouped by target namespace")
+
+	r.flushBatch()
+	sort.Strings(written)
+	assert.Equal(t, []string{"default/foo@2 -> team-a", "default/foo@2 -> team-b"}, written)
+
+	written = nil
+	r.flushBatch()
+	assert.Empty(t, written, "flushed writes are not issued again")
+}
+
+func TestBatchingIsDisabledByDefault(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+
+	var written []string
+	r := newBatchReplicator(t, []string{"default", "team-a"}, func(source *v1.Secret, target *v1.Namespace) {
+		written = append(written, target.Name)
+	})
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	_, _, err := r.replicateResourceToNamespaces(context.Background(), source, []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"team-a"}, written)
+	assert.Nil(t, r.batch.pending)
+}
+
+// BenchmarkBatchedWrites simulates a config change that updates 100 sources
+// twice in quick succession, each of which is pushed into 10 namespaces. Each
+// write takes writeLatency, like a round trip to the API server.
+func BenchmarkBatchedWrites(b *testing.B) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	defer func(window time.Duration) { Options.BatchWindow = window }(Options.BatchWindow)
+
+	const sources, targets, updates = 100, 10, 2
+	const writeLatency = time.Millisecond
+
+	namespaces := []string{"default"}
+	var targetNamespaces []v1.Namespace
+	for i := 0; i < targets; i++ {
+		name := fmt.Sprintf("team-%d", i)
+		namespaces = append(namespaces, name)
+		targetNamespaces = append(targetNamespaces, v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+
+	run := func(b *testing.B, window time.Duration) {
+		Options.BatchWindow = window
+
+		var lock sync.Mutex
+		writes := 0
+		r := newBatchReplicator(b, namespaces, func(source *v1.Secret, target *v1.Namespace) {
+			time.Sleep(writeLatency)
+			lock.Lock()
+			writes++
+			lock.Unlock()
+		})
+
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for u := 0; u < updates; u++ {
+				for i := 0; i < sources; i++ {
+					source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("source-%d", i), ResourceVersion: fmt.Sprint(u), Annotations: pushedToTeams}}
+					_ = r.Store.Update(source)
+					r.ReplicateToList[MustGetKey(source)] = struct{}{}
+
+					if _, _, err := r.replicateResourceToNamespaces(context.Background(), source, targetNamespaces); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			r.flushBatch()
+		}
+
+		b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+	}
+
+	b.Run("unbatched", func(b *testing.B) { run(b, 0) })
+	b.Run("batched", func(b *testing.B) { run(b, time.Hour) })
+}
+
+func TestBatchedWritesArePendingAndRecordFailures(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	defer func(window time.Duration) { Options.BatchWindow = window }(Options.BatchWindow)
+	Options.BatchWindow = time.Hour
+
+	r := newBatchReplicator(t, []string{"default", "team-a"}, nil)
+	r.Quarantine = NewQuarantine("BatchedSecret", 1)
+	r.Recorder = record.NewFakeRecorder(10)
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		return errors.New("forbidden")
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", ResourceVersion: "1", Annotations: pushedToTeams}}
+	require.NoError(t, r.Store.Add(source))
+	r.ReplicateToList[MustGetKey(source)] = struct{}{}
+
+	replicated, pending, err := r.replicateResourceToNamespaces(context.Background(), source, []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}})
+	require.NoError(t, err)
+	assert.Empty(t, replicated)
+	assert.Len(t, pending, 1, "batched writes are reported as pending")
+
+	r.flushBatch()
+	assert.Len(t, r.recentErrors.list(), 1, "failed batched writes are recorded for their source")
+	assert.True(t, r.Quarantine.IsQuarantined("default/foo", "1"), "failed batched writes count towards the quarantine")
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+var namespaceExpressionEnv *cel.Env
+
+func init() {
+	var err error
+	namespaceExpressionEnv, err = cel.NewEnv(
+		cel.Variable("metadata", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NamespaceExpression is a compiled CEL expression that is evaluated against the
+// metadata of a namespace (e.g. `metadata.name.startsWith("team-")`).
+type NamespaceExpression struct {
+	Expression string
+	program    cel.Program
+}
+
+// ParseNamespaceExpression compiles a CEL expression. The expression must
+// evaluate to a boolean.
+func ParseNamespaceExpression(expression string) (*NamespaceExpression, error) {
+	ast, issues := namespaceExpressionEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Wrapf(issues.Err(), "invalid expression '%s'", expression)
+	}
+
+	if ast.OutputType() != cel.BoolType {
+		return nil, errors.Errorf("expression '%s' must evaluate to bool, not %s", expression, ast.OutputType())
+	}
+
+	program, err := namespaceExpressionEnv.Program(ast)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not build program for expression '%s'", expression)
+	}
+
+	return &NamespaceExpression{Expression: expression, program: program}, nil
+}
+
+// Matches evaluates the expression against the metadata of the given namespace
+func (e *NamespaceExpression) Matches(ns *v1.Namespace) (bool, error) {
+	labels := ns.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	annotations := ns.Annotations
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	out, _, err := e.program.Eval(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        ns.Name,
+			"labels":      labels,
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "could not evaluate expression '%s' for namespace %s", e.Expression, ns.Name)
+	}
+
+	matches, ok := out.Value().(bool)
+	if !ok {
+		return false, errors.Errorf("expression '%s' did not evaluate to bool for namespace %s", e.Expression, ns.Name)
+	}
+
+	return matches, nil
+}
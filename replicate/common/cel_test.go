@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceExpressionMatches(t *testing.T) {
+	expression, err := ParseNamespaceExpression(
+		`metadata.name.startsWith("team-") && "tier" in metadata.annotations && metadata.annotations["tier"] == "gold"`)
+	require.NoError(t, err)
+
+	gold := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		Annotations: map[string]string{"tier": "gold"},
+	}}
+	silver := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-b",
+		Annotations: map[string]string{"tier": "silver"},
+	}}
+	unannotated := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}}
+	other := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "other",
+		Annotations: map[string]string{"tier": "gold"},
+	}}
+
+	for ns, expected := range map[*v1.Namespace]bool{gold: true, silver: false, unannotated: false, other: false} {
+		matches, err := expression.Matches(ns)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, matches, ns.Name)
+	}
+}
+
+func TestNamespaceExpressionLabels(t *testing.T) {
+	expression, err := ParseNamespaceExpression(`metadata.labels["env"] == "prod"`)
+	require.NoError(t, err)
+
+	matches, err := expression.Matches(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "prod",
+		Labels: map[string]string{"env": "prod"},
+	}})
+	assert.NoError(t, err)
+	assert.True(t, matches)
+
+	_, err = expression.Matches(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled"}})
+	assert.Error(t, err, "accessing a missing key should fail")
+}
+
+func TestParseNamespaceExpressionRejectsInvalidExpressions(t *testing.T) {
+	_, err := ParseNamespaceExpression(`metadata.name.startsWith(`)
+	assert.Error(t, err)
+
+	_, err = ParseNamespaceExpression(`metadata.name`)
+	assert.Error(t, err, "non-boolean expressions should be rejected")
+
+	_, err = ParseNamespaceExpression(`unknown == "foo"`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Values of Options.NameCollisionStrategy and the NameCollision annotation
+const (
+	NameCollisionOverwrite = "overwrite"
+	NameCollisionSkip      = "skip"
+	NameCollisionSuffix    = "suffix"
+	NameCollisionError     = "error"
+)
+
+// maxReplicaNameLength is the maximum length of the name of a replica with a
+// suffix, which must be a valid DNS subdomain
+const maxReplicaNameLength = 253
+
+// ParseNameCollisionStrategy validates the global name collision strategy
+func ParseNameCollisionStrategy(strategy string) (string, error) {
+	switch strategy {
+	case NameCollisionOverwrite, NameCollisionSkip, NameCollisionSuffix, NameCollisionError:
+		return strategy, nil
+	}
+
+	return "", errors.Errorf("invalid name collision strategy '%s': expected %s, %s, %s or %s",
+		strategy, NameCollisionOverwrite, NameCollisionSkip, NameCollisionSuffix, NameCollisionError)
+}
+
+// nameCollisionStrategy returns how a source handles targets that have the
+// same name as one of its replicas, but were not written by the replicator.
+// The NameCollision annotation of the source overrides
+// Options.NameCollisionStrategy.
+func nameCollisionStrategy(source metav1.Object) string {
+	if value, ok := source.GetAnnotations()[NameCollision]; ok {
+		if strategy, err := ParseNameCollisionStrategy(value); err == nil {
+			return strategy
+		}
+		log.WithField("source", MustGetKey(source)).Warnf("invalid %s annotation '%s', using the default", NameCollision, value)
+	}
+
+	if Options.NameCollisionStrategy == "" {
+		return NameCollisionOverwrite
+	}
+	return Options.NameCollisionStrategy
+}
+
+// SuffixedReplicaName returns the name of the replicas of a source that are
+// renamed to avoid a name collision. The suffix is derived from the key of the
+// source, so that the name is the same in every namespace and can be found
+// again when the source is deleted.
+func SuffixedReplicaName(source metav1.Object) string {
+	sum := sha256.Sum256([]byte(MustGetKey(source)))
+	suffix := hex.EncodeToString(sum[:])[:8]
+
+	name := source.GetName()
+	if len(name) > maxReplicaNameLength-len(suffix)-1 {
+		name = strings.TrimRight(name[:maxReplicaNameLength-len(suffix)-1], ".-")
+	}
+
+	return name + "-" + suffix
+}
+
+// replicaKeys returns the keys that the replicas of a source may have in a
+// namespace
+func replicaKeys(source metav1.Object, namespace string) []string {
+	return []string{
+		fmt.Sprintf("%s/%s", namespace, source.GetName()),
+		fmt.Sprintf("%s/%s", namespace, SuffixedReplicaName(source)),
+	}
+}
+
+// isForeignObject checks if an object was neither written by the replicator
+// nor asked to be written by it
+func isForeignObject(object metav1.Object) bool {
+	annotations := object.GetAnnotations()
+	_, replicated := annotations[ReplicatedAtAnnotation]
+	_, pullTarget := annotations[ReplicateFromAnnotation]
+
+	return !replicated && !pullTarget && ReplicaSource(object) == ""
+}
+
+// ReplicaName returns the name of the replica of a source in a namespace.
+// If the namespace contains an object with the name of the source that was
+// not written by the replicator, the name collision strategy of the source
+// decides whether the object is overwritten, the replica is skipped (in which
+// case skip is true), an error is returned, or the replica is given a
+// suffixed name instead. Once a suffixed replica exists, it keeps its name.
+func (r *GenericReplicator) ReplicaName(source metav1.Object, namespace string) (name string, skip bool, err error) {
+	strategy := nameCollisionStrategy(source)
+
+	suffixed := SuffixedReplicaName(source)
+	if strategy == NameCollisionSuffix {
+		if _, exists, err := r.Store.GetByKey(fmt.Sprintf("%s/%s", namespace, suffixed)); err == nil && exists {
+			return suffixed, false, nil
+		}
+	}
+
+	targetKey := fmt.Sprintf("%s/%s", namespace, source.GetName())
+	target, exists, err := r.Store.GetByKey(targetKey)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "Could not get %s from cache!", targetKey)
+	}
+	if !exists || !isForeignObject(MustGetObject(target)) {
+		return source.GetName(), false, nil
+	}
+
+	logger := log.WithField("kind", r.Kind).WithField("source", MustGetKey(source)).WithField("target", targetKey)
+
+	switch strategy {
+	case NameCollisionSkip:
+		logger.Infof("%s %s was not written by the replicator, skipping it", r.Kind, targetKey)
+		r.SkipReconcile(SkipReasonNameCollision)
+		return "", true, nil
+	case NameCollisionSuffix:
+		logger.Infof("%s %s was not written by the replicator, replicating to %s/%s instead", r.Kind, targetKey, namespace, suffixed)
+		return suffixed, false, nil
+	case NameCollisionError:
+		r.SkipReconcile(SkipReasonNameCollision)
+		r.Recorder.Eventf(source.(runtime.Object), v1.EventTypeWarning, "NameCollision",
+			"%s %s was not written by the replicator; not overwriting it", r.Kind, targetKey)
+		return "", false, errors.Errorf("%s %s was not written by the replicator", r.Kind, targetKey)
+	}
+
+	return source.GetName(), false, nil
+}
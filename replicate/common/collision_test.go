@@ -0,0 +1,114 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSuffixedReplicaName(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	other := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "foo"}}
+
+	assert.Equal(t, SuffixedReplicaName(source), SuffixedReplicaName(source.DeepCopy()))
+	assert.NotEqual(t, SuffixedReplicaName(source), SuffixedReplicaName(other))
+	assert.True(t, strings.HasPrefix(SuffixedReplicaName(source), "foo-"))
+
+	long := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: strings.Repeat("a", 243) + "." + strings.Repeat("b", 9)}}
+	assert.Empty(t, validation.IsDNS1123Subdomain(SuffixedReplicaName(long)))
+}
+
+func TestReplicaName(t *testing.T) {
+	defer func(strategy string) { Options.NameCollisionStrategy = strategy }(Options.NameCollisionStrategy)
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{}}}
+	suffixed := SuffixedReplicaName(source)
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "foreign", Name: "foo"}}))
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "replica", Name: "foo", Labels: map[string]string{
+		SourceNamespaceLabel: "default",
+		SourceNameLabel:      "foo",
+	}}}))
+
+	for _, strategy := range []string{NameCollisionOverwrite, NameCollisionSkip, NameCollisionSuffix, NameCollisionError} {
+		Options.NameCollisionStrategy = strategy
+
+		for _, namespace := range []string{"empty", "replica"} {
+			name, skip, err := r.ReplicaName(source, namespace)
+			require.NoError(t, err)
+			assert.False(t, skip)
+			assert.Equal(t, "foo", name, "%s in namespace %s", strategy, namespace)
+		}
+	}
+
+	Options.NameCollisionStrategy = NameCollisionOverwrite
+	name, skip, err := r.ReplicaName(source, "foreign")
+	assert.NoError(t, err)
+	assert.False(t, skip)
+	assert.Equal(t, "foo", name)
+
+	Options.NameCollisionStrategy = NameCollisionSkip
+	_, skip, err = r.ReplicaName(source, "foreign")
+	assert.NoError(t, err)
+	assert.True(t, skip)
+
+	Options.NameCollisionStrategy = NameCollisionError
+	_, _, err = r.ReplicaName(source, "foreign")
+	assert.Error(t, err)
+	assert.Contains(t, <-recorder.Events, "NameCollision")
+
+	Options.NameCollisionStrategy = NameCollisionSuffix
+	name, skip, err = r.ReplicaName(source, "foreign")
+	assert.NoError(t, err)
+	assert.False(t, skip)
+	assert.Equal(t, suffixed, name)
+
+	t.Run("the annotation overrides the global strategy", func(t *testing.T) {
+		Options.NameCollisionStrategy = NameCollisionSuffix
+		annotated := source.DeepCopy()
+		annotated.Annotations[NameCollision] = NameCollisionSkip
+
+		_, skip, err := r.ReplicaName(annotated, "foreign")
+		assert.NoError(t, err)
+		assert.True(t, skip)
+	})
+
+	t.Run("suffixed replicas keep their name", func(t *testing.T) {
+		Options.NameCollisionStrategy = NameCollisionSuffix
+		require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "renamed", Name: suffixed}}))
+
+		name, _, err := r.ReplicaName(source, "renamed")
+		assert.NoError(t, err)
+		assert.Equal(t, suffixed, name)
+	})
+}
+
+func TestDeleteResourceReleasesSuffixedReplicas(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	replicaLabels := map[string]string{SourceNamespaceLabel: "default", SourceNameLabel: "foo"}
+
+	r := newTestReplicator(t, "Secret")
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "foreign", Name: "foo"}}))
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "foreign", Name: SuffixedReplicaName(source), Labels: replicaLabels}}))
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "replica", Name: "foo", Labels: replicaLabels}}))
+
+	r.DeleteResource(v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foreign"}}, source)
+	r.DeleteResource(v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "replica"}}, source)
+
+	assert.Equal(t, []string{"foreign/" + SuffixedReplicaName(source), "replica/foo"}, deleted)
+}
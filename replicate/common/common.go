@@ -4,12 +4,15 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"strings"
+	"time"
 )
 
 type Replicator interface {
 	Run()
 	Synced() bool
+	Stalled() (time.Duration, bool)
 	NamespaceAdded(ns *v1.Namespace)
+	Status(namespace string, name string) (*SourceStatus, bool)
 }
 
 func PreviouslyPresentKeys(object *metav1.ObjectMeta) (map[string]struct{}, bool) {
@@ -28,6 +31,59 @@ func PreviouslyPresentKeys(object *metav1.ObjectMeta) (map[string]struct{}, bool
 	return out, true
 }
 
+// IsAugmented checks if a target only receives additional keys from its source
+// instead of being owned by the replicator.
+func IsAugmented(object *metav1.ObjectMeta) bool {
+	return object.Annotations[Augment] == "true"
+}
+
+// IsUpdateOnly checks if a source is only replicated to targets that already
+// exist instead of creating them.
+func IsUpdateOnly(object *metav1.ObjectMeta) bool {
+	return object.Annotations[UpdateOnly] == "true"
+}
+
+// IsImmutable checks if the replicas of a source are created as immutable
+// objects, which are recreated whenever the source changes.
+func IsImmutable(object *metav1.ObjectMeta) bool {
+	return object.Annotations[Immutable] == "true"
+}
+
+// IsPlaceholderOnly checks if a source only creates empty placeholders of its
+// replicas, which are left alone once they exist.
+func IsPlaceholderOnly(object *metav1.ObjectMeta) bool {
+	return object.Annotations[PlaceholderOnly] == "true"
+}
+
+// PreviouslyAugmentedKeys returns the keys that were added to an augmented target
+func PreviouslyAugmentedKeys(object *metav1.ObjectMeta) map[string]struct{} {
+	out := make(map[string]struct{})
+
+	keyList, ok := object.Annotations[AugmentedKeysAnnotation]
+	if !ok || keyList == "" {
+		return out
+	}
+
+	for _, k := range strings.Split(keyList, ",") {
+		out[k] = struct{}{}
+	}
+
+	return out
+}
+
+// SanitizeForCopy clears all fields that are managed by the API server, so that
+// a copied object can be created as a new object.
+func SanitizeForCopy(object metav1.Object) {
+	object.SetManagedFields(nil)
+	object.SetResourceVersion("")
+	object.SetUID("")
+	object.SetCreationTimestamp(metav1.Time{})
+	object.SetGeneration(0)
+	object.SetSelfLink("")
+	object.SetDeletionTimestamp(nil)
+	object.SetDeletionGracePeriodSeconds(nil)
+}
+
 func BuildStrictRegex(regex string) string {
 	reg := strings.TrimSpace(regex)
 	if !strings.HasPrefix(reg, "^") {
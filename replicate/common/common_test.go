@@ -0,0 +1,48 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSanitizeForCopy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	gracePeriod := int64(30)
+	deletedAt := metav1.NewTime(time.Now())
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:                       "foo",
+			Namespace:                  "target",
+			ResourceVersion:            "42",
+			UID:                        "d6f3c2b4-4a0c-4a4e-9a53-0c3f2a1d9e11",
+			Generation:                 3,
+			CreationTimestamp:          metav1.NewTime(time.Now()),
+			DeletionTimestamp:          &deletedAt,
+			DeletionGracePeriodSeconds: &gracePeriod,
+			ManagedFields:              []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			Annotations:                map[string]string{ReplicatedAtAnnotation: "now"},
+		},
+		Data: map[string][]byte{"foo": []byte("bar")},
+	}
+
+	SanitizeForCopy(secret)
+	require.Empty(t, secret.ResourceVersion)
+	require.Zero(t, secret.Generation)
+	require.Nil(t, secret.DeletionGracePeriodSeconds)
+
+	created, err := client.CoreV1().Secrets(secret.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Empty(t, created.ManagedFields)
+	require.Empty(t, created.UID)
+	require.True(t, created.CreationTimestamp.IsZero())
+	require.Nil(t, created.DeletionTimestamp)
+	require.Equal(t, "now", created.Annotations[ReplicatedAtAnnotation])
+	require.Equal(t, []byte("bar"), created.Data["foo"])
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// IgnoredKeys is a set of keys that are managed by someone else on the
+// replicas of a source. They are excluded when a replica is compared with its
+// source, and their values in existing replicas are never overwritten.
+// Entries may be glob patterns (e.g. "tmp-*") that ignore all matching keys.
+type IgnoredKeys map[string]struct{}
+
+// IgnoredKeysFor parses the ComparisonIgnoreKeys annotation of a source
+func IgnoredKeysFor(annotations map[string]string) IgnoredKeys {
+	ignored := make(IgnoredKeys)
+	for _, key := range strings.Split(annotations[ComparisonIgnoreKeys], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			ignored[key] = struct{}{}
+		}
+	}
+	return ignored
+}
+
+// Ignores checks if a key is ignored, either by name or by a glob pattern.
+// Patterns use the syntax of path.Match; invalid patterns only match
+// themselves.
+func (k IgnoredKeys) Ignores(key string) bool {
+	if _, ok := k[key]; ok {
+		return true
+	}
+
+	for pattern := range k {
+		if matched, err := path.Match(pattern, key); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepBinaryValues restores the values of ignored keys that the current
+// replica already has in the updated replica
+func (k IgnoredKeys) KeepBinaryValues(current map[string][]byte, updated map[string][]byte) {
+	if updated == nil {
+		return
+	}
+	for key, value := range current {
+		if k.Ignores(key) {
+			updated[key] = value
+		}
+	}
+}
+
+// KeepStringValues restores the values of ignored keys that the current
+// replica already has in the updated replica
+func (k IgnoredKeys) KeepStringValues(current map[string]string, updated map[string]string) {
+	if updated == nil {
+		return
+	}
+	for key, value := range current {
+		if k.Ignores(key) {
+			updated[key] = value
+		}
+	}
+}
+
+// BinaryMapsEqual compares two maps without the ignored keys
+func (k IgnoredKeys) BinaryMapsEqual(a map[string][]byte, b map[string][]byte) bool {
+	for key, value := range a {
+		if k.Ignores(key) {
+			continue
+		}
+		if other, ok := b[key]; !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	for key := range b {
+		if k.Ignores(key) {
+			continue
+		}
+		if _, ok := a[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// StringMapsEqual compares two maps without the ignored keys
+func (k IgnoredKeys) StringMapsEqual(a map[string]string, b map[string]string) bool {
+	for key, value := range a {
+		if k.Ignores(key) {
+			continue
+		}
+		if other, ok := b[key]; !ok || value != other {
+			return false
+		}
+	}
+	for key := range b {
+		if k.Ignores(key) {
+			continue
+		}
+		if _, ok := a[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
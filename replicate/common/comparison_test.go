@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoredKeys(t *testing.T) {
+	ignored := IgnoredKeysFor(map[string]string{ComparisonIgnoreKeys: "token, ,nonce"})
+	assert.Equal(t, IgnoredKeys{"token": {}, "nonce": {}}, ignored)
+	assert.Empty(t, IgnoredKeysFor(nil))
+
+	assert.True(t, ignored.StringMapsEqual(
+		map[string]string{"foo": "bar", "token": "a"},
+		map[string]string{"foo": "bar", "token": "b", "nonce": "c"},
+	))
+	assert.False(t, ignored.StringMapsEqual(map[string]string{"foo": "bar"}, map[string]string{"foo": "baz"}))
+	assert.False(t, ignored.BinaryMapsEqual(map[string][]byte{"foo": []byte("bar")}, map[string][]byte{}))
+	assert.True(t, ignored.BinaryMapsEqual(nil, map[string][]byte{"token": []byte("a")}))
+
+	updated := map[string][]byte{"foo": []byte("new"), "token": []byte("source")}
+	ignored.KeepBinaryValues(map[string][]byte{"foo": []byte("old"), "token": []byte("rotated")}, updated)
+	assert.Equal(t, map[string][]byte{"foo": []byte("new"), "token": []byte("rotated")}, updated)
+
+	t.Run("glob patterns ignore dynamically named keys", func(t *testing.T) {
+		ignored := IgnoredKeysFor(map[string]string{ComparisonIgnoreKeys: "tmp-*,[invalid"})
+		assert.True(t, ignored.Ignores("tmp-abc123"))
+		assert.True(t, ignored.Ignores("[invalid"))
+		assert.False(t, ignored.Ignores("token"))
+
+		assert.True(t, ignored.BinaryMapsEqual(
+			map[string][]byte{"url": []byte("a")},
+			map[string][]byte{"url": []byte("a"), "tmp-abc123": []byte("external")},
+		))
+
+		updated := map[string]string{"url": "b"}
+		ignored.KeepStringValues(map[string]string{"url": "a", "tmp-abc123": "external"}, updated)
+		assert.Equal(t, map[string]string{"url": "b", "tmp-abc123": "external"}, updated)
+	})
+}
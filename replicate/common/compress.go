@@ -0,0 +1,57 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/pkg/errors"
+)
+
+// CompressionGzip is the only supported value of the Compress annotation
+const CompressionGzip = "gzip"
+
+// CompressionFor returns the compression that a source requests for the values
+// of its replicas, or an empty string if they are not compressed.
+func CompressionFor(annotations map[string]string) (string, error) {
+	compression, ok := annotations[Compress]
+	if !ok || compression == "" {
+		return "", nil
+	}
+
+	if compression != CompressionGzip {
+		return "", errors.Errorf("invalid %s annotation: unsupported compression '%s'", Compress, compression)
+	}
+
+	return compression, nil
+}
+
+// CompressValue gzips a value. The gzip header contains neither a name nor a
+// modification time, so the same value is always compressed to the same bytes
+// and unchanged values don't cause changes of the replica.
+func CompressValue(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SetCompressedAnnotation marks a replica whose values are compressed, so that
+// consumers know that they need to decompress them.
+func SetCompressedAnnotation(annotations map[string]string, compression string) {
+	if compression == "" {
+		delete(annotations, CompressedAnnotation)
+		return
+	}
+
+	annotations[CompressedAnnotation] = compression
+}
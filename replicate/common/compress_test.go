@@ -0,0 +1,42 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionFor(t *testing.T) {
+	compression, err := CompressionFor(map[string]string{Compress: "gzip"})
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionGzip, compression)
+
+	compression, err = CompressionFor(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", compression)
+
+	_, err = CompressionFor(map[string]string{Compress: "zstd"})
+	assert.Error(t, err)
+}
+
+func TestCompressValueIsDeterministic(t *testing.T) {
+	value := bytes.Repeat([]byte("Hello World\n"), 100)
+
+	compressed, err := CompressValue(value)
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(value))
+
+	again, err := CompressValue(value)
+	require.NoError(t, err)
+	assert.Equal(t, compressed, again)
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, value, decompressed)
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ConfigMapChangedFunc is called with the data of a watched config map, or
+// with nil once the config map was deleted
+type ConfigMapChangedFunc func(data map[string]string)
+
+// configMapWatch debounces the changes of a watched config map, so that a
+// series of quick edits is applied only once, and drops resyncs that don't
+// change its data
+type configMapWatch struct {
+	delay   time.Duration
+	changed ConfigMapChangedFunc
+
+	// applyLock serializes the calls of changed
+	applyLock sync.Mutex
+
+	lock       sync.Mutex
+	timer      *time.Timer
+	pending    map[string]string
+	hasPending bool
+	applied    map[string]string
+	hasApplied bool
+}
+
+// update records the latest data of the config map and applies it once no
+// other change followed for the debounce delay
+func (w *configMapWatch) update(data map[string]string) {
+	w.lock.Lock()
+	w.pending, w.hasPending = data, true
+
+	if w.delay <= 0 {
+		w.lock.Unlock()
+		w.flush()
+		return
+	}
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.delay, w.flush)
+	} else {
+		w.timer.Reset(w.delay)
+	}
+	w.lock.Unlock()
+}
+
+// flush applies the latest data right away, unless it was applied before
+func (w *configMapWatch) flush() {
+	w.applyLock.Lock()
+	defer w.applyLock.Unlock()
+
+	w.lock.Lock()
+	if !w.hasPending {
+		w.lock.Unlock()
+		return
+	}
+	data := w.pending
+	w.pending, w.hasPending = nil, false
+
+	if w.hasApplied && reflect.DeepEqual(data, w.applied) {
+		w.lock.Unlock()
+		return
+	}
+	w.applied, w.hasApplied = data, true
+	w.lock.Unlock()
+
+	w.changed(data)
+}
+
+// WatchConfigMap watches the config map <namespace>/<name> and calls changed
+// with its data whenever it changes. Changes within Options.ConfigDebounce of
+// each other are applied together. It blocks until the config map has been
+// read and applied once. kind names the watched configuration in logs.
+func WatchConfigMap(client kubernetes.Interface, kind string, namespace string, name string, resyncPeriod time.Duration, changed ConfigMapChangedFunc) error {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	w := &configMapWatch{delay: Options.ConfigDebounce, changed: changed}
+
+	_, controller := newInformer(
+		kind,
+		&cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				lo.FieldSelector = selector
+				return client.CoreV1().ConfigMaps(namespace).List(context.TODO(), lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				lo.FieldSelector = selector
+				return client.CoreV1().ConfigMaps(namespace).Watch(context.TODO(), lo)
+			},
+		},
+		&v1.ConfigMap{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				w.update(obj.(*v1.ConfigMap).Data)
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				w.update(new.(*v1.ConfigMap).Data)
+			},
+			DeleteFunc: func(obj interface{}) {
+				w.update(nil)
+			},
+		},
+	)
+
+	log.WithField("kind", kind).Infof("watching config map %s/%s", namespace, name)
+	go controller.Run(wait.NeverStop)
+
+	if !cache.WaitForCacheSync(wait.NeverStop, controller.HasSynced) {
+		return errors.Errorf("could not read config map %s/%s", namespace, name)
+	}
+
+	// the initial state is applied without delay
+	w.flush()
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type recordedConfigs struct {
+	lock    sync.Mutex
+	configs []map[string]string
+}
+
+func (r *recordedConfigs) record(data map[string]string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.configs = append(r.configs, data)
+}
+
+func (r *recordedConfigs) get() []map[string]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]map[string]string(nil), r.configs...)
+}
+
+func TestConfigMapChangesAreDebounced(t *testing.T) {
+	recorded := &recordedConfigs{}
+	w := &configMapWatch{delay: 50 * time.Millisecond, changed: recorded.record}
+
+	w.update(map[string]string{"gate": "false"})
+	w.update(map[string]string{"gate": "true", "other": "false"})
+	w.update(map[string]string{"gate": "true"})
+	assert.Empty(t, recorded.get(), "changes are applied after the delay")
+
+	require.Eventually(t, func() bool { return len(recorded.get()) > 0 }, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []map[string]string{{"gate": "true"}}, recorded.get(), "only the latest change is applied")
+
+	t.Run("unchanged data is not applied again", func(t *testing.T) {
+		w.update(map[string]string{"gate": "true"})
+		w.flush()
+		assert.Len(t, recorded.get(), 1)
+	})
+
+	t.Run("deleted config maps are applied as nil", func(t *testing.T) {
+		w.update(nil)
+		w.flush()
+		assert.Equal(t, []map[string]string{{"gate": "true"}, nil}, recorded.get())
+	})
+}
+
+func TestWatchConfigMap(t *testing.T) {
+	defer func(debounce time.Duration) { Options.ConfigDebounce = debounce }(Options.ConfigDebounce)
+	Options.ConfigDebounce = time.Hour
+
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "config"},
+		Data:       map[string]string{"gate": "true"},
+	})
+
+	recorded := &recordedConfigs{}
+	require.NoError(t, WatchConfigMap(client, "Test", "kube-system", "config", 0, recorded.record))
+	assert.Equal(t, []map[string]string{{"gate": "true"}}, recorded.get(), "the initial state is applied before returning")
+
+	_, err := client.CoreV1().ConfigMaps("kube-system").Update(context.TODO(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "config"},
+		Data:       map[string]string{"gate": "false"},
+	}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, recorded.get(), 1, "later changes wait for the debounce delay")
+}
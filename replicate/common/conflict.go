@@ -0,0 +1,96 @@
+package common
+
+import (
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// replicaLabels are the labels that tie a replica to its source
+var replicaLabels = []string{SourceNamespaceLabel, SourceNameLabel}
+
+// SetSourceLabels sets the labels that identify the source of a replica. Names
+// that are not valid label values (e.g. longer than 63 characters) are left
+// out; the source is then identified by the replication chain.
+func SetSourceLabels(labels map[string]string, source metav1.Object) {
+	for label, value := range map[string]string{
+		SourceNamespaceLabel: source.GetNamespace(),
+		SourceNameLabel:      source.GetName(),
+	} {
+		if len(validation.IsValidLabelValue(value)) == 0 {
+			labels[label] = value
+		}
+	}
+}
+
+// ReplicaSource returns the key of the source a replica was last written by,
+// or an empty string if the object is not a replica.
+func ReplicaSource(object metav1.Object) string {
+	labels := object.GetLabels()
+	namespace, hasNamespace := labels[SourceNamespaceLabel]
+	name, hasName := labels[SourceNameLabel]
+	if hasNamespace && hasName {
+		return namespace + "/" + name
+	}
+
+	if chain := ReplicationChain(object); len(chain) > 0 {
+		return chain[len(chain)-1]
+	}
+
+	return ""
+}
+
+// replicationPriority returns the value of the Priority annotation of a
+// source. Sources without a valid annotation have priority 0.
+func replicationPriority(source metav1.Object) int {
+	value, ok := source.GetAnnotations()[Priority]
+	if !ok {
+		return 0
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		log.WithField("source", MustGetKey(source)).Warnf("invalid %s annotation '%s', using 0", Priority, value)
+		return 0
+	}
+
+	return priority
+}
+
+// RefuseSourceConflict checks if an existing target was written by a
+// different source that still exists. Such a target is only taken over by a
+// source with a higher priority; otherwise the conflict is logged and
+// recorded as a warning event on the source, and true is returned.
+func (r *GenericReplicator) RefuseSourceConflict(source metav1.Object, target interface{}) bool {
+	sourceKey := MustGetKey(source)
+	targetKey := MustGetKey(target)
+
+	ownerKey := ReplicaSource(MustGetObject(target))
+	if ownerKey == "" || ownerKey == sourceKey {
+		return false
+	}
+
+	obj, exists, err := r.Store.GetByKey(ownerKey)
+	if err != nil || !exists {
+		return false
+	}
+	owner := MustGetObject(obj)
+
+	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey).WithField("target", targetKey)
+	priority, ownerPriority := replicationPriority(source), replicationPriority(owner)
+
+	if priority > ownerPriority {
+		logger.Infof("taking over %s %s from source %s with lower priority (%d < %d)", r.Kind, targetKey, ownerKey, ownerPriority, priority)
+		return false
+	}
+
+	logger.Warnf("not replicating to %s %s, which is owned by source %s with priority %d (source has priority %d)", r.Kind, targetKey, ownerKey, ownerPriority, priority)
+	r.SkipReconcile(SkipReasonSourceConflict)
+	r.Recorder.Eventf(source.(runtime.Object), v1.EventTypeWarning, "SourceConflict",
+		"%s %s is owned by source %s with priority %d; not overwriting it with priority %d", r.Kind, targetKey, ownerKey, ownerPriority, priority)
+	return true
+}
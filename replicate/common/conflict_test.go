@@ -0,0 +1,78 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSetSourceLabels(t *testing.T) {
+	labels := make(map[string]string)
+	SetSourceLabels(labels, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}})
+	assert.Equal(t, map[string]string{SourceNamespaceLabel: "default", SourceNameLabel: "foo"}, labels)
+
+	labels = make(map[string]string)
+	SetSourceLabels(labels, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: strings.Repeat("a", 64)}})
+	assert.Equal(t, map[string]string{SourceNamespaceLabel: "default"}, labels)
+}
+
+func TestReplicaSource(t *testing.T) {
+	assert.Equal(t, "default/foo", ReplicaSource(&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{SourceNamespaceLabel: "default", SourceNameLabel: "foo"},
+	}}))
+	assert.Equal(t, "b/foo", ReplicaSource(&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{ReplicationChainAnnotation: "a/foo,b/foo"},
+	}}))
+	assert.Equal(t, "", ReplicaSource(&v1.Secret{}))
+}
+
+func TestRefuseSourceConflict(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+
+	source := func(namespace string, priority string) *v1.Secret {
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "foo", Annotations: map[string]string{ReplicateTo: "target"}}}
+		if priority != "" {
+			secret.Annotations[Priority] = priority
+		}
+		return secret
+	}
+
+	owner := source("a", "1")
+	require.NoError(t, r.Store.Add(owner))
+
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "foo"}}
+	target.Labels = map[string]string{SourceNamespaceLabel: "a", SourceNameLabel: "foo"}
+
+	t.Run("owner may overwrite its replica", func(t *testing.T) {
+		assert.False(t, r.RefuseSourceConflict(owner, target))
+		assert.Empty(t, recorder.Events)
+	})
+
+	t.Run("source with lower priority is refused", func(t *testing.T) {
+		assert.True(t, r.RefuseSourceConflict(source("b", ""), target))
+		assert.Contains(t, <-recorder.Events, "SourceConflict")
+	})
+
+	t.Run("source with equal priority is refused", func(t *testing.T) {
+		assert.True(t, r.RefuseSourceConflict(source("b", "1"), target))
+		assert.Contains(t, <-recorder.Events, "SourceConflict")
+	})
+
+	t.Run("source with higher priority takes over", func(t *testing.T) {
+		assert.False(t, r.RefuseSourceConflict(source("b", "2"), target))
+		assert.Empty(t, recorder.Events)
+	})
+
+	t.Run("replicas of deleted sources are taken over", func(t *testing.T) {
+		require.NoError(t, r.Store.Delete(owner))
+		assert.False(t, r.RefuseSourceConflict(source("b", ""), target))
+		assert.Empty(t, recorder.Events)
+	})
+}
@@ -5,11 +5,69 @@ const (
 	ReplicateFromAnnotation         = "replicator.v1.mittwald.de/replicate-from"
 	ReplicatedAtAnnotation          = "replicator.v1.mittwald.de/replicated-at"
 	ReplicatedFromVersionAnnotation = "replicator.v1.mittwald.de/replicated-from-version"
+	ReplicatedFromUIDAnnotation     = "replicator.v1.mittwald.de/replicated-from-uid"
 	ReplicatedKeysAnnotation        = "replicator.v1.mittwald.de/replicated-keys"
 	ReplicationAllowed              = "replicator.v1.mittwald.de/replication-allowed"
 	ReplicationAllowedNamespaces    = "replicator.v1.mittwald.de/replication-allowed-namespaces"
+	ReplicationAllowedMatching      = "replicator.v1.mittwald.de/replication-allowed-namespaces-matching"
 	ReplicateTo                     = "replicator.v1.mittwald.de/replicate-to"
 	ReplicateToMatching             = "replicator.v1.mittwald.de/replicate-to-matching"
+	ReplicateToCEL                  = "replicator.v1.mittwald.de/replicate-to-cel"
+	ReplicateToLabelKey             = "replicator.v1.mittwald.de/replicate-to-label-key"
+	ReplicateToRelease              = "replicator.v1.mittwald.de/replicate-to-release"
+	ReplicateToURL                  = "replicator.v1.mittwald.de/replicate-to-url"
 	KeepOwnerReferences             = "replicator.v1.mittwald.de/keep-owner-references"
 	StripLabels                     = "replicator.v1.mittwald.de/strip-labels"
+	ReplicateDelay                  = "replicator.v1.mittwald.de/replicate-delay"
+	HostTemplate                    = "replicator.v1.mittwald.de/host-template"
+	Augment                         = "replicator.v1.mittwald.de/augment"
+	AugmentedKeysAnnotation         = "replicator.v1.mittwald.de/augmented-keys"
+	MaxReplicatedObjectBytes        = "replicator.v1.mittwald.de/max-replicated-object-bytes"
+	KeyNamespacesAnnotation         = "replicator.v1.mittwald.de/key-namespaces"
+	SourceHashAnnotation            = "replicator.v1.mittwald.de/source-hash"
+	Bundle                          = "replicator.v1.mittwald.de/bundle"
+	GenerateKeys                    = "replicator.v1.mittwald.de/generate-keys"
+	OnSourceDelete                  = "replicator.v1.mittwald.de/on-source-delete"
+	ReplicationChainAnnotation      = "replicator.v1.mittwald.de/replication-chain"
+	EnvironmentAnnotation           = "replicator.v1.mittwald.de/environment"
+	UpdateOnly                      = "replicator.v1.mittwald.de/update-only"
+	WriteStrategy                   = "replicator.v1.mittwald.de/write-strategy"
+	Priority                        = "replicator.v1.mittwald.de/priority"
+	Compress                        = "replicator.v1.mittwald.de/compress"
+	CompressedAnnotation            = "replicator.v1.mittwald.de/compressed"
+	GatedBy                         = "replicator.v1.mittwald.de/gated-by"
+	MaxFanout                       = "replicator.v1.mittwald.de/max-fanout"
+	PlaceholderOnly                 = "replicator.v1.mittwald.de/placeholder-only"
+	NameCollision                   = "replicator.v1.mittwald.de/name-collision"
+	DeleteOnEmpty                   = "replicator.v1.mittwald.de/delete-on-empty"
+	MinReconcileInterval            = "replicator.v1.mittwald.de/min-reconcile-interval"
+	ReplicateToCreator              = "replicator.v1.mittwald.de/replicate-to-creator"
+	ComparisonIgnoreKeys            = "replicator.v1.mittwald.de/comparison-ignore-keys"
+	ReadyAnnotation                 = "replicator.v1.mittwald.de/ready"
+	Immutable                       = "replicator.v1.mittwald.de/immutable"
+	ReplicateToConsumers            = "replicator.v1.mittwald.de/replicate-to-consumers"
+	AllowServiceAccountToken        = "replicator.v1.mittwald.de/allow-service-account-token"
+	PinVersion                      = "replicator.v1.mittwald.de/pin-version"
+	Validate                        = "replicator.v1.mittwald.de/validate"
+	MinStableAge                    = "replicator.v1.mittwald.de/min-stable-age"
+	After                           = "replicator.v1.mittwald.de/after"
+)
+
+// ReplicationOptOut is the label or annotation with which a namespace opts
+// out of push-based replication
+const ReplicationOptOut = "replicator.v1.mittwald.de/replication"
+
+// HelmReleaseLabel is the label that identifies the Helm release a namespace
+// belongs to
+const HelmReleaseLabel = "app.kubernetes.io/instance"
+
+// DefaultCreatorLabel is the default label that identifies the provisioner
+// that created a namespace
+const DefaultCreatorLabel = "created-by"
+
+// Labels that identify the source of a replica created by push-based
+// replication
+const (
+	SourceNamespaceLabel = "replicator.v1.mittwald.de/source-namespace"
+	SourceNameLabel      = "replicator.v1.mittwald.de/source-name"
 )
@@ -0,0 +1,252 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var secretConsumers = SecretConsumerIndex{
+	deployments: make(map[string]map[string]struct{}),
+	namespaces:  make(map[string]map[string]int),
+}
+
+type SecretConsumersChangedFunc func(names map[string]struct{})
+
+// SecretConsumerIndex holds the namespaces whose deployments reference a
+// secret, by secret name. It is only filled if consumer discovery is enabled.
+type SecretConsumerIndex struct {
+	lock    sync.RWMutex
+	enabled bool
+
+	// deployments are the names of the secrets referenced by each deployment
+	deployments map[string]map[string]struct{}
+
+	// namespaces count the deployments that reference a secret in each
+	// namespace
+	namespaces map[string]map[string]int
+
+	ChangedFuncs []SecretConsumersChangedFunc
+}
+
+// OnSecretConsumersChanged adds a function that is called with the names of
+// all secrets whose consuming namespaces changed
+func OnSecretConsumersChanged(changedFunc SecretConsumersChangedFunc) {
+	secretConsumers.lock.Lock()
+	defer secretConsumers.lock.Unlock()
+
+	secretConsumers.ChangedFuncs = append(secretConsumers.ChangedFuncs, changedFunc)
+}
+
+// replicatesToConsumers checks if an object is replicated into all namespaces
+// whose deployments reference a secret of its name
+func replicatesToConsumers(object metav1.Object) bool {
+	return object.GetAnnotations()[ReplicateToConsumers] == "true"
+}
+
+// secretConsumerNamespaces returns the namespaces with a deployment that
+// references a secret of the given name. ok is false if consumer discovery is
+// not enabled.
+func secretConsumerNamespaces(name string) (namespaces map[string]struct{}, ok bool) {
+	secretConsumers.lock.RLock()
+	defer secretConsumers.lock.RUnlock()
+
+	if !secretConsumers.enabled {
+		return nil, false
+	}
+
+	namespaces = make(map[string]struct{}, len(secretConsumers.namespaces[name]))
+	for namespace := range secretConsumers.namespaces[name] {
+		namespaces[namespace] = struct{}{}
+	}
+	return namespaces, true
+}
+
+// ReferencedSecrets returns the names of all secrets that a pod spec
+// references in volumes, environment variables and image pull secrets
+func ReferencedSecrets(spec *v1.PodSpec) map[string]struct{} {
+	names := make(map[string]struct{})
+	add := func(name string) {
+		if name != "" {
+			names[name] = struct{}{}
+		}
+	}
+
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			add(volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					add(source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	for _, secret := range spec.ImagePullSecrets {
+		add(secret.Name)
+	}
+
+	addContainer := func(envFrom []v1.EnvFromSource, env []v1.EnvVar) {
+		for _, source := range envFrom {
+			if source.SecretRef != nil {
+				add(source.SecretRef.Name)
+			}
+		}
+		for _, variable := range env {
+			if variable.ValueFrom != nil && variable.ValueFrom.SecretKeyRef != nil {
+				add(variable.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	for _, container := range spec.InitContainers {
+		addContainer(container.EnvFrom, container.Env)
+	}
+	for _, container := range spec.Containers {
+		addContainer(container.EnvFrom, container.Env)
+	}
+	for _, container := range spec.EphemeralContainers {
+		addContainer(container.EnvFrom, container.Env)
+	}
+
+	return names
+}
+
+// setDeployment records the secrets referenced by a deployment and notifies
+// all replicators about secrets whose consuming namespaces changed. nil names
+// remove the deployment.
+func (c *SecretConsumerIndex) setDeployment(namespace string, name string, names map[string]struct{}) {
+	key := namespace + "/" + name
+
+	c.lock.Lock()
+	previous := c.deployments[key]
+	if names == nil {
+		delete(c.deployments, key)
+	} else {
+		c.deployments[key] = names
+	}
+
+	changed := make(map[string]struct{})
+	for secret := range previous {
+		if _, ok := names[secret]; ok {
+			continue
+		}
+		if c.namespaces[secret][namespace]--; c.namespaces[secret][namespace] <= 0 {
+			delete(c.namespaces[secret], namespace)
+			changed[secret] = struct{}{}
+		}
+		if len(c.namespaces[secret]) == 0 {
+			delete(c.namespaces, secret)
+		}
+	}
+	for secret := range names {
+		if _, ok := previous[secret]; ok {
+			continue
+		}
+		if c.namespaces[secret] == nil {
+			c.namespaces[secret] = make(map[string]int)
+		}
+		if c.namespaces[secret][namespace]++; c.namespaces[secret][namespace] == 1 {
+			changed[secret] = struct{}{}
+		}
+	}
+	changedFuncs := c.ChangedFuncs
+	c.lock.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+
+	log.WithField("kind", "Deployment").WithField("resource", key).Debugf("consumers of %d secrets changed", len(changed))
+	for _, changedFunc := range changedFuncs {
+		changedFunc(changed)
+	}
+}
+
+// WatchSecretConsumers watches the deployments in all namespaces and records
+// the secrets they reference, for sources with the ReplicateToConsumers
+// annotation. Pods are not watched, so that the number of watched objects
+// stays small. It blocks until all deployments have been listed once.
+func WatchSecretConsumers(client kubernetes.Interface, resyncPeriod time.Duration) error {
+	deploymentChanged := func(obj interface{}) {
+		deployment := obj.(*appsv1.Deployment)
+		secretConsumers.setDeployment(deployment.Namespace, deployment.Name, ReferencedSecrets(&deployment.Spec.Template.Spec))
+	}
+
+	_, controller := newInformer(
+		"Deployment",
+		&cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().Deployments("").List(context.TODO(), lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().Deployments("").Watch(context.TODO(), lo)
+			},
+		},
+		&appsv1.Deployment{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: deploymentChanged,
+			UpdateFunc: func(old interface{}, new interface{}) {
+				deploymentChanged(new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				if deployment, ok := obj.(*appsv1.Deployment); ok {
+					secretConsumers.setDeployment(deployment.Namespace, deployment.Name, nil)
+				}
+			},
+		},
+	)
+
+	log.WithField("kind", "Deployment").Info("watching deployments for secret consumers")
+	go controller.Run(wait.NeverStop)
+
+	if !cache.WaitForCacheSync(wait.NeverStop, controller.HasSynced) {
+		return errors.New("could not list deployments")
+	}
+
+	secretConsumers.lock.Lock()
+	secretConsumers.enabled = true
+	secretConsumers.lock.Unlock()
+
+	return nil
+}
+
+// SecretConsumersChanged replicates all sources with the ReplicateToConsumers
+// annotation again whose name is one of the given secret names. Replicas in
+// namespaces that don't consume the secret any more are removed like other
+// stale replicas.
+func (r *GenericReplicator) SecretConsumersChanged(names map[string]struct{}) {
+	if r.Kind != "Secret" {
+		return
+	}
+
+	for sourceKey := range r.ReplicateToConsumersList {
+		obj, exists, err := r.Store.GetByKey(sourceKey)
+		if err != nil || !exists {
+			continue
+		}
+		if _, ok := names[MustGetObject(obj).GetName()]; !ok {
+			continue
+		}
+
+		log.WithField("kind", r.Kind).WithField("resource", sourceKey).Infof("consumers of %s changed, replicating again", sourceKey)
+		r.ResourceAdded(obj)
+	}
+}
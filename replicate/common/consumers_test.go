@@ -0,0 +1,148 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func resetSecretConsumers(t *testing.T, enabled bool) {
+	reset := func(enabled bool) {
+		secretConsumers.lock.Lock()
+		secretConsumers.enabled = enabled
+		secretConsumers.deployments = make(map[string]map[string]struct{})
+		secretConsumers.namespaces = make(map[string]map[string]int)
+		secretConsumers.lock.Unlock()
+	}
+	reset(enabled)
+
+	changedFuncs := secretConsumers.ChangedFuncs
+	t.Cleanup(func() {
+		reset(false)
+		secretConsumers.ChangedFuncs = changedFuncs
+	})
+}
+
+func TestReferencedSecrets(t *testing.T) {
+	spec := &v1.PodSpec{
+		Volumes: []v1.Volume{
+			{VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "volume"}}},
+			{VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+				{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "projected"}}},
+				{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: v1.LocalObjectReference{Name: "config"}}},
+			}}}},
+			{VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "config"}}}},
+		},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+		InitContainers: []v1.Container{{
+			EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "init"}}}},
+		}},
+		Containers: []v1.Container{{
+			Env: []v1.EnvVar{
+				{Name: "PLAIN", Value: "value"},
+				{Name: "PASSWORD", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "env"}, Key: "password"}}},
+			},
+		}},
+	}
+
+	assert.Equal(t, map[string]struct{}{
+		"volume":    {},
+		"projected": {},
+		"registry":  {},
+		"init":      {},
+		"env":       {},
+	}, ReferencedSecrets(spec))
+}
+
+func TestSecretConsumerIndex(t *testing.T) {
+	resetSecretConsumers(t, true)
+
+	var changed []map[string]struct{}
+	secretConsumers.ChangedFuncs = []SecretConsumersChangedFunc{func(names map[string]struct{}) {
+		changed = append(changed, names)
+	}}
+
+	secretConsumers.setDeployment("team-a", "web", map[string]struct{}{"registry": {}, "db": {}})
+	assert.Equal(t, []map[string]struct{}{{"registry": {}, "db": {}}}, changed)
+
+	changed = nil
+	secretConsumers.setDeployment("team-a", "worker", map[string]struct{}{"registry": {}})
+	assert.Empty(t, changed, "namespace already consumed the secret")
+
+	secretConsumers.setDeployment("team-a", "web", map[string]struct{}{"registry": {}})
+	assert.Equal(t, []map[string]struct{}{{"db": {}}}, changed)
+
+	changed = nil
+	secretConsumers.setDeployment("team-a", "web", nil)
+	assert.Empty(t, changed, "worker still consumes the secret")
+
+	namespaces, ok := secretConsumerNamespaces("registry")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]struct{}{"team-a": {}}, namespaces)
+
+	secretConsumers.setDeployment("team-a", "worker", nil)
+	assert.Equal(t, []map[string]struct{}{{"registry": {}}}, changed)
+
+	namespaces, _ = secretConsumerNamespaces("registry")
+	assert.Empty(t, namespaces)
+}
+
+func TestReplicateToConsumers(t *testing.T) {
+	resetSecretConsumers(t, true)
+	secretConsumers.ChangedFuncs = nil
+
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "team-a", "team-b"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+
+	var replicated []string
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		replicated = append(replicated, target.Name)
+		return nil
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "registry",
+		Annotations: map[string]string{ReplicateToConsumers: "true"},
+	}}
+	require.NoError(t, r.Store.Add(source))
+
+	secretConsumers.setDeployment("default", "web", map[string]struct{}{"registry": {}})
+	secretConsumers.setDeployment("team-a", "web", map[string]struct{}{"registry": {}})
+
+	require.NoError(t, r.replicateResourceToTargets(context.Background(), source))
+	assert.Equal(t, []string{"team-a"}, replicated)
+	assert.True(t, r.isPushSource("default/registry"))
+	assert.Equal(t, map[string]struct{}{"team-a": {}}, pushTargets("Secret", source, namespacesFromStore()))
+
+	t.Run("replicates when a deployment starts consuming the secret", func(t *testing.T) {
+		replicated = nil
+		secretConsumers.setDeployment("team-b", "api", map[string]struct{}{"registry": {}})
+		assert.ElementsMatch(t, []string{"team-a", "team-b"}, replicated)
+	})
+
+	t.Run("refuses the annotation without consumer discovery", func(t *testing.T) {
+		secretConsumers.lock.Lock()
+		secretConsumers.enabled = false
+		secretConsumers.lock.Unlock()
+
+		replicated = nil
+		assert.Error(t, r.replicateResourceToTargets(context.Background(), source))
+		assert.Empty(t, replicated)
+		assert.Contains(t, <-recorder.Events, "ConsumerDiscoveryDisabled")
+		assert.False(t, r.isPushSource("default/registry"))
+	})
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maintenanceCheckInterval is the interval in which deferred operations are
+// checked for whether they can be run
+const maintenanceCheckInterval = 10 * time.Second
+
+// objectEvent is the key of a deferred operation that processes the latest
+// state of an object
+type objectEvent string
+
+// deferredOperation is an operation that was deferred because of a
+// maintenance window
+type deferredOperation struct {
+	key interface{}
+	run func()
+}
+
+// deferredOperations holds operations that were deferred because of a
+// maintenance window, in the order in which they arrived. Operations with a
+// key are coalesced: an operation whose key is already deferred replaces it
+// in place, so that e.g. all events of an object during a window are
+// processed once, with the state of the object when the window has ended.
+type deferredOperations struct {
+	lock       sync.Mutex
+	operations []*deferredOperation
+	keys       map[interface{}]*deferredOperation
+}
+
+// whenWritable runs op, unless a maintenance window is active. In that case, op
+// is deferred until the window has ended. Operations that arrive while
+// deferred operations are pending are deferred as well, so that the order of
+// events is preserved. A non-nil key identifies what op operates on; it must
+// be comparable. If an operation with the same key is deferred already, it is
+// replaced by op.
+func (r *GenericReplicator) whenWritable(key interface{}, op func()) {
+	r.deferred.lock.Lock()
+	if len(r.deferred.operations) > 0 || Options.MaintenanceWindows.Active(time.Now()) {
+		if deferred, ok := r.deferred.keys[key]; ok && key != nil {
+			deferred.run = op
+			r.deferred.lock.Unlock()
+			return
+		}
+
+		deferred := &deferredOperation{key: key, run: op}
+		r.deferred.operations = append(r.deferred.operations, deferred)
+		if key != nil {
+			if r.deferred.keys == nil {
+				r.deferred.keys = make(map[interface{}]*deferredOperation)
+			}
+			r.deferred.keys[key] = deferred
+		}
+		DeferredOperations.WithLabelValues(r.Kind).Set(float64(len(r.deferred.operations)))
+		r.deferred.lock.Unlock()
+		return
+	}
+	r.deferred.lock.Unlock()
+
+	r.runOperation(op)
+}
+
+// runDeferredOperations periodically runs all deferred operations once no
+// maintenance window is active any more.
+func (r *GenericReplicator) runDeferredOperations() {
+	for range time.Tick(maintenanceCheckInterval) {
+		r.drainDeferredOperations()
+	}
+}
+
+// drainDeferredOperations runs deferred operations in order until none are left
+// or a maintenance window begins. An operation is only removed from the queue
+// after it ran, so that new events keep being deferred until then; its key is
+// released before it runs, so that an event arriving meanwhile is not lost.
+func (r *GenericReplicator) drainDeferredOperations() {
+	logged := false
+
+	for !Options.MaintenanceWindows.Active(time.Now()) {
+		r.deferred.lock.Lock()
+		if len(r.deferred.operations) == 0 {
+			r.deferred.lock.Unlock()
+			return
+		}
+		deferred := r.deferred.operations[0]
+		if deferred.key != nil && r.deferred.keys[deferred.key] == deferred {
+			delete(r.deferred.keys, deferred.key)
+		}
+		op := deferred.run
+		if !logged {
+			log.WithField("kind", r.Kind).Infof("maintenance window ended, running %d deferred operations", len(r.deferred.operations))
+			logged = true
+		}
+		r.deferred.lock.Unlock()
+
+		r.runOperation(op)
+
+		r.deferred.lock.Lock()
+		r.deferred.operations[0] = nil
+		r.deferred.operations = r.deferred.operations[1:]
+		DeferredOperations.WithLabelValues(r.Kind).Set(float64(len(r.deferred.operations)))
+		r.deferred.lock.Unlock()
+	}
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReplicationDelay maps a namespace pattern to the delay with which a resource
+// should be replicated into matching namespaces.
+type ReplicationDelay struct {
+	Pattern *regexp.Regexp
+	Delay   time.Duration
+}
+
+// ReplicationDelays is an ordered list of delays parsed from a ReplicateDelay
+// annotation. The first matching pattern wins.
+type ReplicationDelays []ReplicationDelay
+
+// delayedReplication is the work item that is put into the delay queue
+type delayedReplication struct {
+	SourceKey string
+	Namespace string
+}
+
+// ParseReplicationDelays parses a list in the format <pattern>=<duration>,...
+// (e.g. "prod-canary=0s,prod-main=300s"). Invalid entries are logged and skipped.
+func ParseReplicationDelays(list string) ReplicationDelays {
+	result := make(ReplicationDelays, 0)
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		delay, err := parseReplicationDelay(entry)
+		if err != nil {
+			log.WithError(err).Errorf("Invalid delay '%s' in delay string %s: %v", entry, list, err)
+			continue
+		}
+
+		result = append(result, delay)
+	}
+
+	return result
+}
+
+func parseReplicationDelay(entry string) (ReplicationDelay, error) {
+	v := strings.SplitN(entry, "=", 2)
+	if len(v) < 2 {
+		return ReplicationDelay{}, errors.Errorf("expected '<namespace>=<duration>', got '%s'", entry)
+	}
+
+	pattern, err := regexp.Compile(BuildStrictRegex(v[0]))
+	if err != nil {
+		return ReplicationDelay{}, errors.Wrapf(err, "invalid namespace pattern '%s'", v[0])
+	}
+
+	delay, err := time.ParseDuration(strings.TrimSpace(v[1]))
+	if err != nil {
+		return ReplicationDelay{}, errors.Wrapf(err, "invalid duration '%s'", v[1])
+	}
+
+	if delay < 0 {
+		return ReplicationDelay{}, errors.Errorf("negative duration '%s'", v[1])
+	}
+
+	return ReplicationDelay{Pattern: pattern, Delay: delay}, nil
+}
+
+// For returns the delay for the given namespace. Namespaces that do not match
+// any pattern are replicated without delay.
+func (d ReplicationDelays) For(namespace string) time.Duration {
+	for _, delay := range d {
+		if delay.Pattern.MatchString(namespace) {
+			return delay.Delay
+		}
+	}
+
+	return 0
+}
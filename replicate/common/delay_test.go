@@ -0,0 +1,74 @@
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseReplicationDelays(t *testing.T) {
+	delays := ParseReplicationDelays("prod-canary=0s, prod-main=300s,team-[0-9]+=1m")
+
+	assert.Len(t, delays, 3)
+	assert.Equal(t, time.Duration(0), delays.For("prod-canary"))
+	assert.Equal(t, 300*time.Second, delays.For("prod-main"))
+	assert.Equal(t, time.Minute, delays.For("team-42"))
+	assert.Equal(t, time.Duration(0), delays.For("prod-main-2"))
+	assert.Equal(t, time.Duration(0), delays.For("other"))
+}
+
+func TestParseReplicationDelaysSkipsInvalidEntries(t *testing.T) {
+	delays := ParseReplicationDelays("missing-duration,bad=forever,neg=-1s,ok=5s,")
+
+	assert.Len(t, delays, 1)
+	assert.Equal(t, 5*time.Second, delays.For("ok"))
+}
+
+func TestDelayedReplicationIsDroppedForNamespacesNoLongerTargeted(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+
+	var written []string
+	r := newBatchReplicator(t, []string{"default", "team-a", "team-b"}, func(source *v1.Secret, target *v1.Namespace) {
+		written = append(written, target.Name)
+	})
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{ReplicateTo: "team-a"}}}
+	require.NoError(t, r.Store.Add(source))
+	r.ReplicateToList[MustGetKey(source)] = struct{}{}
+
+	r.replicateDelayed(delayedReplication{SourceKey: "default/foo", Namespace: "team-b"})
+	assert.Empty(t, written, "team-b was removed from the annotation while the replication was waiting")
+
+	r.replicateDelayed(delayedReplication{SourceKey: "default/foo", Namespace: "team-a"})
+	assert.Equal(t, []string{"team-a"}, written)
+}
+
+func TestOperationsAreSerialized(t *testing.T) {
+	r := newTestReplicator(t, "Test")
+
+	var running, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runOperation(func() {
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+		}()
+	}
+	wg.Wait()
+
+	assert.Zero(t, overlaps, "operations of a replicator never run concurrently")
+}
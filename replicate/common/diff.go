@@ -0,0 +1,76 @@
+package common
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyDiff lists the names of the keys that differ between two versions of an
+// object's data
+type KeyDiff struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// DiffBinaryMaps compares the keys of two versions of binary data
+func DiffBinaryMaps(old map[string][]byte, new map[string][]byte) KeyDiff {
+	var diff KeyDiff
+	for key, value := range new {
+		if oldValue, ok := old[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		} else if !bytes.Equal(oldValue, value) {
+			diff.Modified = append(diff.Modified, key)
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	return diff.sorted()
+}
+
+// DiffStringMaps compares the keys of two versions of string data
+func DiffStringMaps(old map[string]string, new map[string]string) KeyDiff {
+	var diff KeyDiff
+	for key, value := range new {
+		if oldValue, ok := old[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		} else if oldValue != value {
+			diff.Modified = append(diff.Modified, key)
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	return diff.sorted()
+}
+
+// Merge combines two diffs, e.g. of the data and binary data of a config map
+func (d KeyDiff) Merge(other KeyDiff) KeyDiff {
+	return KeyDiff{
+		Added:    append(append([]string{}, d.Added...), other.Added...),
+		Modified: append(append([]string{}, d.Modified...), other.Modified...),
+		Removed:  append(append([]string{}, d.Removed...), other.Removed...),
+	}.sorted()
+}
+
+func (d KeyDiff) sorted() KeyDiff {
+	sort.Strings(d.Added)
+	sort.Strings(d.Modified)
+	sort.Strings(d.Removed)
+	return d
+}
+
+// TraceKeyDiff logs the names of the changed keys at trace level. Values are
+// never logged, since they may contain secrets.
+func TraceKeyDiff(logger *log.Entry, diff KeyDiff) {
+	logger.Tracef("changed keys: added=[%s] modified=[%s] removed=[%s]",
+		strings.Join(diff.Added, ","), strings.Join(diff.Modified, ","), strings.Join(diff.Removed, ","))
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffBinaryMaps(t *testing.T) {
+	diff := DiffBinaryMaps(
+		map[string][]byte{"kept": []byte("a"), "changed": []byte("a"), "gone": []byte("a")},
+		map[string][]byte{"kept": []byte("a"), "changed": []byte("b"), "new-b": nil, "new-a": nil},
+	)
+
+	assert.Equal(t, []string{"new-a", "new-b"}, diff.Added)
+	assert.Equal(t, []string{"changed"}, diff.Modified)
+	assert.Equal(t, []string{"gone"}, diff.Removed)
+}
+
+func TestDiffStringMaps(t *testing.T) {
+	diff := DiffStringMaps(nil, map[string]string{"b": "1", "a": "2"})
+
+	assert.Equal(t, []string{"a", "b"}, diff.Added)
+	assert.Empty(t, diff.Modified)
+	assert.Empty(t, diff.Removed)
+}
+
+func TestKeyDiffMerge(t *testing.T) {
+	diff := KeyDiff{Added: []string{"c"}, Removed: []string{"x"}}.Merge(KeyDiff{Added: []string{"a"}, Modified: []string{"m"}})
+
+	assert.Equal(t, []string{"a", "c"}, diff.Added)
+	assert.Equal(t, []string{"m"}, diff.Modified)
+	assert.Equal(t, []string{"x"}, diff.Removed)
+}
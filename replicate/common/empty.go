@@ -0,0 +1,61 @@
+package common
+
+import (
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hasNoData checks if a secret or config map source has no keys. Other kinds
+// always have data.
+func hasNoData(obj interface{}) bool {
+	switch o := obj.(type) {
+	case *v1.Secret:
+		return len(o.Data) == 0 && len(o.StringData) == 0
+	case *v1.ConfigMap:
+		return len(o.Data) == 0 && len(o.BinaryData) == 0
+	}
+
+	return false
+}
+
+// deletesOnEmpty checks if the replicas of a source are deleted instead of
+// emptied while the source has no data
+func deletesOnEmpty(source metav1.Object) bool {
+	return source.GetAnnotations()[DeleteOnEmpty] == "true"
+}
+
+// replicateObjectTo writes the replica of a source into a namespace. Sources
+// with the DeleteOnEmpty annotation and no data delete their replica instead.
+func (r *GenericReplicator) replicateObjectTo(obj interface{}, namespace *v1.Namespace) error {
+	if objectMeta := MustGetObject(obj); deletesOnEmpty(objectMeta) && hasNoData(obj) {
+		return r.deleteEmptyReplica(objectMeta, namespace.Name)
+	}
+
+	return r.UpdateFuncs.ReplicateObjectTo(obj, namespace)
+}
+
+// deleteEmptyReplica deletes the replica of an empty source in a namespace.
+// Only replicas that were written by the source itself are deleted.
+func (r *GenericReplicator) deleteEmptyReplica(source metav1.Object, namespace string) error {
+	sourceKey := MustGetKey(source)
+	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
+
+	for _, targetKey := range replicaKeys(source, namespace) {
+		target, exists, err := r.Store.GetByKey(targetKey)
+		if err != nil {
+			return errors.Wrapf(err, "Could not get %s from cache!", targetKey)
+		}
+		if !exists || ReplicaSource(MustGetObject(target)) != sourceKey {
+			continue
+		}
+
+		logger.Infof("%s %s has no data, deleting %s", r.Kind, sourceKey, targetKey)
+		if err := r.deleteReplicatedResource(sourceKey, target); err != nil {
+			return errors.Wrapf(err, "Failed to delete %s %s: %v", r.Kind, targetKey, err)
+		}
+	}
+
+	return nil
+}
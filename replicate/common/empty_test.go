@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestEmptySources(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	replicaLabels := map[string]string{SourceNamespaceLabel: "default", SourceNameLabel: "foo"}
+	targets := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "replica"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "foreign"}},
+	}
+
+	newReplicator := func(operations *[]string) *GenericReplicator {
+		r := newTestReplicator(t, "Secret")
+		r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+			*operations = append(*operations, "replicate "+target.Name)
+			return nil
+		}
+		r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+			*operations = append(*operations, "delete "+MustGetKey(target))
+			return nil
+		}
+
+		require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "replica", Name: "foo", Labels: replicaLabels}}))
+		require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "foreign", Name: "foo"}}))
+		return r
+	}
+
+	t.Run("empty sources are replicated by default", func(t *testing.T) {
+		var operations []string
+		source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+
+		_, _, err := newReplicator(&operations).replicateResourceToNamespaces(context.Background(), source, targets)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"replicate replica", "replicate foreign"}, operations)
+	})
+
+	t.Run("empty sources delete their replicas if configured", func(t *testing.T) {
+		var operations []string
+		source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{
+			DeleteOnEmpty: "true",
+		}}}
+
+		_, _, err := newReplicator(&operations).replicateResourceToNamespaces(context.Background(), source, targets)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"delete replica/foo"}, operations)
+	})
+
+	t.Run("sources with data are replicated if configured", func(t *testing.T) {
+		var operations []string
+		source := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{
+				DeleteOnEmpty: "true",
+			}},
+			BinaryData: map[string][]byte{"foo": {0}},
+		}
+
+		_, _, err := newReplicator(&operations).replicateResourceToNamespaces(context.Background(), source, targets)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"replicate replica", "replicate foreign"}, operations)
+	})
+}
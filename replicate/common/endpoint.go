@@ -0,0 +1,249 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// DefaultNamespaceEndpointRefresh is the default interval after which the
+// namespaces of a "replicate-to-url" endpoint are fetched again
+const DefaultNamespaceEndpointRefresh = 5 * time.Minute
+
+// maxNamespaceEndpointBytes limits the size of an endpoint's response
+const maxNamespaceEndpointBytes = 1 << 20
+
+var namespaceEndpoints = namespaceEndpointCache{
+	client:   &http.Client{Timeout: 10 * time.Second},
+	entries:  make(map[string]namespaceEndpoint),
+	fetching: make(map[string]*namespaceEndpointFetch),
+}
+
+// namespaceEndpoint is the last namespace list that was fetched successfully
+// from an endpoint
+type namespaceEndpoint struct {
+	Namespaces map[string]struct{}
+	FetchedAt  time.Time
+}
+
+// namespaceEndpointFetch is a running fetch of an endpoint; done is closed
+// once namespaces or err are set
+type namespaceEndpointFetch struct {
+	done       chan struct{}
+	namespaces map[string]struct{}
+	err        error
+}
+
+// namespaceEndpointCache holds the namespace lists of all endpoints by URL. It
+// is shared by all replicators, so that an endpoint is fetched once per
+// refresh interval regardless of the number of sources and kinds using it.
+// Endpoints are fetched without holding the lock; concurrent fetches of the
+// same URL wait for the one that is running.
+type namespaceEndpointCache struct {
+	lock     sync.Mutex
+	client   *http.Client
+	entries  map[string]namespaceEndpoint
+	fetching map[string]*namespaceEndpointFetch
+}
+
+// delayedEndpointRefresh is put into the delay queue to fetch the namespaces
+// of a source's "replicate-to-url" endpoint again
+type delayedEndpointRefresh struct {
+	SourceKey string
+}
+
+// ParseNamespaceEndpointPrefixes parses a comma separated list of URL prefixes
+// that "replicate-to-url" annotations may point to
+func ParseNamespaceEndpointPrefixes(prefixes string) []string {
+	result := make([]string, 0)
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			result = append(result, prefix)
+		}
+	}
+
+	return result
+}
+
+// namespaceEndpointAllowed checks if endpoint is covered by one of the
+// prefixes in Options.NamespaceEndpointPrefixes: the scheme and host
+// (including the port) must be equal, and the path of the prefix must be a
+// prefix of whole path segments. Without prefixes, no endpoint is allowed.
+func namespaceEndpointAllowed(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.User != nil {
+		return false
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	for _, prefix := range Options.NamespaceEndpointPrefixes {
+		p, err := url.Parse(prefix)
+		if err != nil || !p.IsAbs() || p.Host == "" {
+			continue
+		}
+		if !strings.EqualFold(u.Scheme, p.Scheme) || !strings.EqualFold(u.Host, p.Host) {
+			continue
+		}
+
+		prefixPath := strings.TrimSuffix(p.Path, "/")
+		if prefixPath == "" || u.Path == prefixPath || strings.HasPrefix(u.Path, prefixPath+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func namespaceEndpointRefresh() time.Duration {
+	if Options.NamespaceEndpointRefresh <= 0 {
+		return DefaultNamespaceEndpointRefresh
+	}
+	return Options.NamespaceEndpointRefresh
+}
+
+// cachedEndpointNamespaces returns the last namespace list fetched from url
+// without contacting the endpoint
+func cachedEndpointNamespaces(url string) (map[string]struct{}, bool) {
+	namespaceEndpoints.lock.Lock()
+	defer namespaceEndpoints.lock.Unlock()
+
+	entry, ok := namespaceEndpoints.entries[url]
+	return entry.Namespaces, ok
+}
+
+// endpointNamespaces returns the namespaces listed by the endpoint at url. The
+// endpoint is only contacted if its list is older than the refresh interval.
+// If the endpoint fails, the last list that was fetched successfully is
+// returned; an error is only returned if there is no such list. As the
+// endpoint may be slow, this must only be called from the delay queue.
+func endpointNamespaces(url string) (map[string]struct{}, error) {
+	namespaceEndpoints.lock.Lock()
+	entry, cached := namespaceEndpoints.entries[url]
+	if cached && time.Since(entry.FetchedAt) < namespaceEndpointRefresh() {
+		namespaceEndpoints.lock.Unlock()
+		return entry.Namespaces, nil
+	}
+
+	fetch, running := namespaceEndpoints.fetching[url]
+	if !running {
+		fetch = &namespaceEndpointFetch{done: make(chan struct{})}
+		namespaceEndpoints.fetching[url] = fetch
+	}
+	namespaceEndpoints.lock.Unlock()
+
+	if running {
+		<-fetch.done
+	} else {
+		fetch.namespaces, fetch.err = fetchEndpointNamespaces(namespaceEndpoints.client, url)
+
+		namespaceEndpoints.lock.Lock()
+		delete(namespaceEndpoints.fetching, url)
+		if fetch.err == nil {
+			namespaceEndpoints.entries[url] = namespaceEndpoint{Namespaces: fetch.namespaces, FetchedAt: time.Now()}
+		}
+		namespaceEndpoints.lock.Unlock()
+		close(fetch.done)
+	}
+
+	if fetch.err != nil {
+		if !cached {
+			return nil, fetch.err
+		}
+
+		log.WithField("url", url).WithError(fetch.err).Warnf("could not fetch namespaces from %s, keeping the last known list: %v", url, fetch.err)
+		return entry.Namespaces, nil
+	}
+
+	return fetch.namespaces, nil
+}
+
+// fetchEndpointNamespaces fetches a JSON list of namespace names from url
+func fetchEndpointNamespaces(client *http.Client, url string) (map[string]struct{}, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch namespaces from %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("could not fetch namespaces from %s: unexpected status %s", url, resp.Status)
+	}
+
+	var names []string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxNamespaceEndpointBytes)).Decode(&names); err != nil {
+		return nil, errors.Wrapf(err, "could not parse namespaces from %s: %v", url, err)
+	}
+
+	namespaces := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		namespaces[name] = struct{}{}
+	}
+
+	return namespaces, nil
+}
+
+// getNamespacesListedByEndpoint returns the namespaces that are contained in
+// the list of an endpoint, except myNs
+func getNamespacesListedByEndpoint(myNs string, listed map[string]struct{}, namespaces []v1.Namespace) []v1.Namespace {
+	replicateTo := make([]v1.Namespace, 0)
+	for _, namespace := range namespaces {
+		if _, ok := listed[namespace.Name]; ok && namespace.Name != myNs {
+			replicateTo = append(replicateTo, namespace)
+		}
+	}
+
+	return replicateTo
+}
+
+// refreshEndpointDelayed fetches the namespaces of a source's endpoint again
+// and replicates the source if they changed since it was last replicated, or
+// if they were not known at that time. Otherwise, the next refresh is
+// scheduled. The endpoint is fetched before the replicator's worker is
+// entered, so that a slow endpoint does not hold up its other operations.
+func (r *GenericReplicator) refreshEndpointDelayed(item delayedEndpointRefresh) {
+	logger := log.WithField("kind", r.Kind).WithField("resource", item.SourceKey)
+
+	obj, exists, err := r.Store.GetByKey(item.SourceKey)
+	if err != nil {
+		logger.WithError(err).Error("error fetching object from store")
+		return
+	} else if !exists {
+		return
+	}
+
+	url, ok := MustGetObject(obj).GetAnnotations()[ReplicateToURL]
+	if !ok || !namespaceEndpointAllowed(url) {
+		logger.Debugf("%s %s is no longer replicated by endpoint, dropping refresh", r.Kind, item.SourceKey)
+		return
+	}
+
+	namespaces, err := endpointNamespaces(url)
+	if err != nil {
+		logger.WithError(err).Warnf("could not refresh namespaces of %s: %v", item.SourceKey, err)
+	}
+
+	r.whenWritable(item, func() {
+		if err == nil {
+			if previous, ok := r.ReplicateToURLList[item.SourceKey]; !ok || !reflect.DeepEqual(namespaces, previous) {
+				logger.Infof("namespaces listed by %s changed, replicating again", url)
+				r.ResourceAdded(obj)
+				return
+			}
+		}
+
+		r.DelayQueue.AddAfter(item, namespaceEndpointRefresh())
+	})
+}
@@ -0,0 +1,244 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// namespaceEndpointServer serves the current value of body, or fails if it is empty
+type namespaceEndpointServer struct {
+	lock sync.Mutex
+	body string
+}
+
+func (s *namespaceEndpointServer) set(body string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.body = body
+}
+
+func (s *namespaceEndpointServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.body == "" {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, s.body)
+}
+
+func resetNamespaceEndpoints(t *testing.T) {
+	namespaceEndpoints.lock.Lock()
+	namespaceEndpoints.entries = make(map[string]namespaceEndpoint)
+	namespaceEndpoints.lock.Unlock()
+
+	t.Cleanup(func() {
+		namespaceEndpoints.lock.Lock()
+		namespaceEndpoints.entries = make(map[string]namespaceEndpoint)
+		namespaceEndpoints.lock.Unlock()
+	})
+}
+
+func TestEndpointNamespacesKeepsLastKnownList(t *testing.T) {
+	defer func(refresh time.Duration) { Options.NamespaceEndpointRefresh = refresh }(Options.NamespaceEndpointRefresh)
+	Options.NamespaceEndpointRefresh = time.Nanosecond
+	resetNamespaceEndpoints(t)
+
+	endpoint := &namespaceEndpointServer{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	_, err := endpointNamespaces(server.URL)
+	assert.Error(t, err, "no list is known before the first successful fetch")
+
+	endpoint.set(`["team-a", "team-b"]`)
+	namespaces, err := endpointNamespaces(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"team-a": {}, "team-b": {}}, namespaces)
+
+	endpoint.set("")
+	namespaces, err = endpointNamespaces(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"team-a": {}, "team-b": {}}, namespaces)
+
+	endpoint.set(`{"not": "a list"}`)
+	namespaces, err = endpointNamespaces(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"team-a": {}, "team-b": {}}, namespaces)
+
+	endpoint.set(`["team-c"]`)
+	namespaces, err = endpointNamespaces(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"team-c": {}}, namespaces)
+}
+
+func TestEndpointNamespacesAreCached(t *testing.T) {
+	defer func(refresh time.Duration) { Options.NamespaceEndpointRefresh = refresh }(Options.NamespaceEndpointRefresh)
+	Options.NamespaceEndpointRefresh = time.Hour
+	resetNamespaceEndpoints(t)
+
+	endpoint := &namespaceEndpointServer{body: `["team-a"]`}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	_, err := endpointNamespaces(server.URL)
+	require.NoError(t, err)
+
+	endpoint.set(`["team-b"]`)
+	namespaces, err := endpointNamespaces(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"team-a": {}}, namespaces)
+}
+
+func TestNamespaceEndpointAllowed(t *testing.T) {
+	defer func(prefixes []string) { Options.NamespaceEndpointPrefixes = prefixes }(Options.NamespaceEndpointPrefixes)
+
+	Options.NamespaceEndpointPrefixes = nil
+	assert.False(t, namespaceEndpointAllowed("https://teams.internal/namespaces"))
+
+	Options.NamespaceEndpointPrefixes = ParseNamespaceEndpointPrefixes(" https://teams.internal/, ,http://localhost:8080/")
+	assert.Equal(t, []string{"https://teams.internal/", "http://localhost:8080/"}, Options.NamespaceEndpointPrefixes)
+	assert.True(t, namespaceEndpointAllowed("https://teams.internal/namespaces"))
+	assert.True(t, namespaceEndpointAllowed("http://localhost:8080/teams/a"))
+	assert.False(t, namespaceEndpointAllowed("https://teams.internal.example.com/"))
+	assert.False(t, namespaceEndpointAllowed("http://169.254.169.254/latest/meta-data"))
+
+	Options.NamespaceEndpointPrefixes = []string{"https://teams.internal", "http://localhost:8080/teams/"}
+	assert.True(t, namespaceEndpointAllowed("https://TEAMS.internal/namespaces"))
+	assert.True(t, namespaceEndpointAllowed("http://localhost:8080/teams"))
+	assert.True(t, namespaceEndpointAllowed("http://localhost:8080/teams/a?env=prod"))
+	assert.False(t, namespaceEndpointAllowed("https://teams.internal.attacker.example/"))
+	assert.False(t, namespaceEndpointAllowed("https://teams.internal@attacker.example/"))
+	assert.False(t, namespaceEndpointAllowed("https://teams.internal:8443/"))
+	assert.False(t, namespaceEndpointAllowed("http://teams.internal/"))
+	assert.False(t, namespaceEndpointAllowed("http://localhost:8080/teamsx"))
+	assert.False(t, namespaceEndpointAllowed("http://localhost:8080/teams/../admin"))
+	assert.False(t, namespaceEndpointAllowed("/teams/a"))
+}
+
+func TestEndpointIsFetchedOnceByConcurrentCallers(t *testing.T) {
+	defer func(refresh time.Duration) { Options.NamespaceEndpointRefresh = refresh }(Options.NamespaceEndpointRefresh)
+	Options.NamespaceEndpointRefresh = time.Hour
+	resetNamespaceEndpoints(t)
+
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		fmt.Fprint(w, `["team-a"]`)
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			namespaces, err := endpointNamespaces(server.URL)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]struct{}{"team-a": {}}, namespaces)
+		}()
+	}
+
+	// the cache stays usable while the endpoint is fetched
+	_, known := cachedEndpointNamespaces(server.URL)
+	assert.False(t, known)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestReplicateToURL(t *testing.T) {
+	defer func(prefixes []string) { Options.NamespaceEndpointPrefixes = prefixes }(Options.NamespaceEndpointPrefixes)
+	defer func(refresh time.Duration) { Options.NamespaceEndpointRefresh = refresh }(Options.NamespaceEndpointRefresh)
+	Options.NamespaceEndpointRefresh = time.Nanosecond
+	resetNamespaceEndpoints(t)
+
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "team-a", "team-b", "team-c"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	endpoint := &namespaceEndpointServer{body: `["default", "team-a", "team-b", "unknown"]`}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	Options.NamespaceEndpointPrefixes = []string{server.URL + "/"}
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+
+	var replicated []string
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		replicated = append(replicated, target.Name)
+		return nil
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "teams",
+		Annotations: map[string]string{ReplicateToURL: server.URL + "/namespaces"},
+	}}
+	require.NoError(t, r.Store.Add(source))
+
+	require.NoError(t, r.replicateResourceToTargets(context.Background(), source))
+	assert.Empty(t, replicated, "the endpoint is not fetched while replicating")
+
+	item, _ := r.DelayQueue.Get()
+	require.Equal(t, delayedEndpointRefresh{SourceKey: "default/teams"}, item)
+	r.DelayQueue.Done(item)
+	r.refreshEndpointDelayed(item.(delayedEndpointRefresh))
+	assert.ElementsMatch(t, []string{"team-a", "team-b"}, replicated)
+	assert.True(t, r.isPushSource("default/teams"))
+	assert.Equal(t, map[string]struct{}{"team-a": {}, "team-b": {}}, pushTargets("Secret", source, namespacesFromStore()))
+
+	t.Run("replicates into new namespaces listed by the endpoint", func(t *testing.T) {
+		replicated = nil
+		r.NamespaceAdded(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unknown"}})
+		r.NamespaceAdded(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}})
+		assert.Equal(t, []string{"unknown"}, replicated)
+	})
+
+	t.Run("keeps replicating to the last known list if the endpoint fails", func(t *testing.T) {
+		endpoint.set("")
+		replicated = nil
+		require.NoError(t, r.replicateResourceToTargets(context.Background(), source))
+		assert.ElementsMatch(t, []string{"team-a", "team-b"}, replicated)
+	})
+
+	t.Run("replicates again when the list changes", func(t *testing.T) {
+		endpoint.set(`["team-c"]`)
+		replicated = nil
+		r.refreshEndpointDelayed(delayedEndpointRefresh{SourceKey: "default/teams"})
+		assert.Equal(t, []string{"team-c"}, replicated)
+		assert.Equal(t, map[string]struct{}{"team-c": {}}, r.ReplicateToURLList["default/teams"])
+	})
+
+	t.Run("refuses endpoints that are not allowed", func(t *testing.T) {
+		replicated = nil
+		forbidden := source.DeepCopy()
+		forbidden.Annotations[ReplicateToURL] = "http://169.254.169.254/latest/meta-data"
+
+		assert.Error(t, r.replicateResourceToTargets(context.Background(), forbidden))
+		assert.Empty(t, replicated)
+		assert.Contains(t, <-recorder.Events, "EndpointNotAllowed")
+		assert.False(t, r.isPushSource("default/teams"))
+	})
+}
@@ -0,0 +1,27 @@
+package common
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// InEnvironment checks if an object belongs to the environment this controller
+// is responsible for. If no environment is configured, all objects do.
+func InEnvironment(object metav1.Object) bool {
+	if Options.Environment == "" {
+		return true
+	}
+
+	return object.GetAnnotations()[EnvironmentAnnotation] == Options.Environment
+}
+
+// forgetSource removes a resource from all lists of push-based sources
+func (r *GenericReplicator) forgetSource(sourceKey string) {
+	delete(r.ReplicateToList, sourceKey)
+	delete(r.ReplicateToMatchingList, sourceKey)
+	delete(r.ReplicateToLabelKeyList, sourceKey)
+	delete(r.ReplicateToReleaseList, sourceKey)
+	delete(r.ReplicateToCreatorList, sourceKey)
+	delete(r.ReplicateToCELList, sourceKey)
+	delete(r.ReplicateToURLList, sourceKey)
+	delete(r.ReplicateToConsumersList, sourceKey)
+	propagationLags.forget(r.Kind, sourceKey)
+	r.forgetOrphans(sourceKey, nil)
+}
@@ -0,0 +1,144 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// The failure status config map is kept small, so that it doesn't grow without
+// bounds in namespaces with many failing sources
+const (
+	maxFailureStatusEntries = 100
+	maxFailureMessageBytes  = 1024
+)
+
+// FailureStatusLabel marks config maps that hold the failure status of sources
+const FailureStatusLabel = "replicator.v1.mittwald.de/failure-status"
+
+// FailureStatus is the entry of a failing source in the failure status config map
+type FailureStatus struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+// failureStatusKey returns the key of a source in the failure status config map
+func failureStatusKey(kind string, name string) string {
+	return fmt.Sprintf("%s.%s", kind, name)
+}
+
+// truncateFailureMessage shortens a message to maxFailureMessageBytes
+func truncateFailureMessage(message string) string {
+	if len(message) <= maxFailureMessageBytes {
+		return message
+	}
+
+	return message[:maxFailureMessageBytes-3] + "..."
+}
+
+// reportFailureStatus records the outcome of the replication of a source in
+// the failure status config map in the namespace of the source, if enabled by
+// Options.FailureStatusConfigMap. Failures are added or updated; successful
+// replications remove the entry of the source. This lets tenants see why their
+// sources are not replicated without access to the replicator's logs.
+func (r *GenericReplicator) reportFailureStatus(obj interface{}, failure error) {
+	if Options.FailureStatusConfigMap == "" {
+		return
+	}
+
+	objectMeta := MustGetObject(obj)
+	logger := log.WithField("kind", r.Kind).WithField("resource", MustGetKey(objectMeta))
+
+	if err := r.updateFailureStatus(objectMeta, failure); err != nil {
+		logger.WithError(err).Warnf("could not update failure status: %v", err)
+	}
+}
+
+func (r *GenericReplicator) updateFailureStatus(source metav1.Object, failure error) error {
+	configMaps := r.Client.CoreV1().ConfigMaps(source.GetNamespace())
+	key := failureStatusKey(r.Kind, source.GetName())
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := configMaps.Get(context.TODO(), Options.FailureStatusConfigMap, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if create {
+			if failure == nil {
+				return nil
+			}
+			existing = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:      Options.FailureStatusConfigMap,
+				Namespace: source.GetNamespace(),
+				Labels:    map[string]string{FailureStatusLabel: "true"},
+			}}
+		} else if err != nil {
+			return errors.Wrapf(err, "could not get config map %s/%s: %v", source.GetNamespace(), Options.FailureStatusConfigMap, err)
+		}
+
+		updated := existing.DeepCopy()
+		if !setFailureStatus(updated, key, failure, time.Now()) {
+			return nil
+		}
+
+		if create {
+			_, err = configMaps.Create(context.TODO(), updated, metav1.CreateOptions{})
+		} else {
+			_, err = configMaps.Update(context.TODO(), updated, metav1.UpdateOptions{})
+		}
+		return err
+	})
+}
+
+// setFailureStatus sets or removes the entry of a source in a failure status
+// config map. If the config map is full, the oldest entry is removed. It
+// returns false if the config map did not change, e.g. because a source
+// failed again with the same message.
+func setFailureStatus(configMap *v1.ConfigMap, key string, failure error, now time.Time) bool {
+	if failure == nil {
+		if _, ok := configMap.Data[key]; !ok {
+			return false
+		}
+		delete(configMap.Data, key)
+		return true
+	}
+
+	status := FailureStatus{Time: now.UTC().Format(time.RFC3339), Message: truncateFailureMessage(failure.Error())}
+
+	if previous, ok := configMap.Data[key]; ok {
+		var previousStatus FailureStatus
+		if json.Unmarshal([]byte(previous), &previousStatus) == nil && previousStatus.Message == status.Message {
+			return false
+		}
+	} else if len(configMap.Data) >= maxFailureStatusEntries {
+		removeOldestFailureStatus(configMap)
+	}
+
+	value, _ := json.Marshal(status)
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[key] = string(value)
+
+	return true
+}
+
+func removeOldestFailureStatus(configMap *v1.ConfigMap) {
+	oldestKey, oldestTime := "", ""
+	for key, value := range configMap.Data {
+		var status FailureStatus
+		_ = json.Unmarshal([]byte(value), &status)
+
+		if oldestKey == "" || status.Time < oldestTime {
+			oldestKey, oldestTime = key, status.Time
+		}
+	}
+
+	delete(configMap.Data, oldestKey)
+}
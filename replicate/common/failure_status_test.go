@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReportFailureStatus(t *testing.T) {
+	previous := Options.FailureStatusConfigMap
+	defer func() { Options.FailureStatusConfigMap = previous }()
+	Options.FailureStatusConfigMap = "replicator-status"
+
+	client := fake.NewSimpleClientset()
+	r := newTestReplicator(t, "Secret")
+	r.Client = client
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "tenant"}}
+
+	get := func() (*v1.ConfigMap, error) {
+		return client.CoreV1().ConfigMaps("tenant").Get(context.TODO(), "replicator-status", metav1.GetOptions{})
+	}
+
+	r.reportFailureStatus(source, nil)
+	_, err := get()
+	assert.True(t, apierrors.IsNotFound(err), "successes don't create the config map")
+
+	r.reportFailureStatus(source, errors.New("could not replicate to target"))
+	configMap, err := get()
+	require.NoError(t, err)
+	assert.Equal(t, "true", configMap.Labels[FailureStatusLabel])
+
+	var status FailureStatus
+	require.NoError(t, json.Unmarshal([]byte(configMap.Data["Secret.source"]), &status))
+	assert.Equal(t, "could not replicate to target", status.Message)
+
+	r.reportFailureStatus(source, nil)
+	configMap, err = get()
+	require.NoError(t, err)
+	assert.NotContains(t, configMap.Data, "Secret.source")
+}
+
+func TestSetFailureStatus(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	configMap := &v1.ConfigMap{}
+
+	assert.False(t, setFailureStatus(configMap, "Secret.a", nil, now), "removing a missing entry changes nothing")
+	assert.True(t, setFailureStatus(configMap, "Secret.a", errors.New("failed"), now))
+	assert.False(t, setFailureStatus(configMap, "Secret.a", errors.New("failed"), now.Add(time.Minute)),
+		"repeated failures with the same message change nothing")
+	assert.True(t, setFailureStatus(configMap, "Secret.a", errors.New("failed differently"), now))
+
+	long := strings.Repeat("x", 2*maxFailureMessageBytes)
+	assert.True(t, setFailureStatus(configMap, "Secret.b", errors.New(long), now))
+	var status FailureStatus
+	require.NoError(t, json.Unmarshal([]byte(configMap.Data["Secret.b"]), &status))
+	assert.Len(t, status.Message, maxFailureMessageBytes)
+
+	for i := len(configMap.Data); i < maxFailureStatusEntries; i++ {
+		setFailureStatus(configMap, fmt.Sprintf("Secret.c%d", i), errors.New("failed"), now.Add(time.Duration(i)*time.Second))
+	}
+	require.Len(t, configMap.Data, maxFailureStatusEntries)
+
+	setFailureStatus(configMap, "Secret.new", errors.New("failed"), now.Add(time.Hour))
+	assert.Len(t, configMap.Data, maxFailureStatusEntries)
+	assert.Contains(t, configMap.Data, "Secret.new")
+	_, hasA := configMap.Data["Secret.a"]
+	_, hasB := configMap.Data["Secret.b"]
+	assert.False(t, hasA && hasB, "one of the oldest entries is evicted")
+	assert.Contains(t, configMap.Data, "Secret.c2")
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxFanout returns the maximum number of namespaces the given object may be
+// pushed to. The MaxFanout annotation of the object overrides the global
+// limit; 0 means unlimited.
+func maxFanout(obj interface{}) int {
+	limit, ok := MustGetObject(obj).GetAnnotations()[MaxFanout]
+	if !ok {
+		return Options.MaxFanout
+	}
+
+	fanout, err := strconv.Atoi(limit)
+	if err != nil || fanout < 0 {
+		log.WithField("resource", MustGetKey(obj)).
+			Warnf("ignoring invalid %s annotation '%s'", MaxFanout, limit)
+		return Options.MaxFanout
+	}
+
+	return fanout
+}
+
+// refuseExcessiveFanout checks if obj is pushed to more namespaces than
+// allowed, e.g. because of an accidental "replicate-to: .*". The limit applies
+// to all targets of all push-based annotations and replication rules together.
+// Refused sources are reported with a warning event and the
+// RefusedFanoutsTotal metric.
+func (r *GenericReplicator) refuseExcessiveFanout(obj interface{}) bool {
+	limit := maxFanout(obj)
+	if limit == 0 {
+		return false
+	}
+
+	fanout := len(pushTargets(r.Kind, MustGetObject(obj), namespacesFromStore()))
+	if fanout <= limit {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", MustGetKey(obj)).
+		Warnf("refusing to replicate %s %s: %d target namespaces exceed the limit of %d", r.Kind, MustGetKey(obj), fanout, limit)
+	RefusedFanoutsTotal.WithLabelValues(r.Kind).Inc()
+	r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "FanoutTooLarge",
+		"Not replicated: %d target namespaces exceed the limit of %d; set the %s annotation to override it", fanout, limit, MaxFanout)
+
+	return true
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRefuseExcessiveFanout(t *testing.T) {
+	defer func(limit int) { Options.MaxFanout = limit }(Options.MaxFanout)
+	Options.MaxFanout = 3
+
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for i := 0; i < 5; i++ {
+		_ = namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("team-%d", i)}})
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+
+	secret := func(annotations map[string]string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: annotations}}
+	}
+
+	assert.False(t, r.refuseExcessiveFanout(secret(map[string]string{ReplicateTo: "team-1,team-2"})))
+	assert.Empty(t, recorder.Events)
+
+	assert.True(t, r.refuseExcessiveFanout(secret(map[string]string{ReplicateTo: ".*"})))
+	assert.Contains(t, <-recorder.Events, "FanoutTooLarge")
+
+	assert.False(t, r.refuseExcessiveFanout(secret(map[string]string{ReplicateTo: ".*", MaxFanout: "10"})))
+	assert.False(t, r.refuseExcessiveFanout(secret(map[string]string{ReplicateTo: ".*", MaxFanout: "0"})))
+	assert.True(t, r.refuseExcessiveFanout(secret(map[string]string{ReplicateTo: "team-1,team-2", MaxFanout: "1"})))
+	assert.True(t, r.refuseExcessiveFanout(secret(map[string]string{ReplicateTo: ".*", MaxFanout: "invalid"})))
+}
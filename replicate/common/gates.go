@@ -0,0 +1,113 @@
+package common
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var featureGates FeatureGateSet
+
+type FeatureGatesChangedFunc func(old map[string]bool, new map[string]bool)
+
+// FeatureGateSet holds the currently enabled feature gates
+type FeatureGateSet struct {
+	lock  sync.RWMutex
+	gates map[string]bool
+
+	ChangedFuncs []FeatureGatesChangedFunc
+}
+
+// SetFeatureGates replaces the feature gates with the data of a feature gate
+// config map and notifies all replicators about the change. A gate is enabled
+// if its value is "true"; all other values disable it.
+func SetFeatureGates(data map[string]string) {
+	gates := make(map[string]bool, len(data))
+	for name, value := range data {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.WithField("gate", name).Warnf("ignoring invalid value '%s' of feature gate %s", value, name)
+		}
+		gates[name] = enabled
+	}
+
+	featureGates.set(gates)
+}
+
+// FeatureGateEnabled checks if a feature gate is enabled. Unknown gates are disabled.
+func FeatureGateEnabled(name string) bool {
+	featureGates.lock.RLock()
+	defer featureGates.lock.RUnlock()
+
+	return featureGates.gates[name]
+}
+
+// OnFeatureGatesChanged adds a function that is called whenever the feature gates change
+func OnFeatureGatesChanged(changedFunc FeatureGatesChangedFunc) {
+	featureGates.lock.Lock()
+	defer featureGates.lock.Unlock()
+
+	featureGates.ChangedFuncs = append(featureGates.ChangedFuncs, changedFunc)
+}
+
+func (s *FeatureGateSet) set(gates map[string]bool) {
+	s.lock.Lock()
+	old := s.gates
+	s.gates = gates
+	changedFuncs := s.ChangedFuncs
+	s.lock.Unlock()
+
+	for _, changedFunc := range changedFuncs {
+		changedFunc(old, gates)
+	}
+}
+
+// gateOpen checks if the feature gate named by the GatedBy annotation of an
+// object is enabled. Objects without that annotation are not gated.
+func gateOpen(object metav1.Object) bool {
+	gate, ok := object.GetAnnotations()[GatedBy]
+	if !ok {
+		return true
+	}
+
+	return FeatureGateEnabled(gate)
+}
+
+// refuseGatedSource checks if obj is a source whose feature gate is not enabled
+func (r *GenericReplicator) refuseGatedSource(obj interface{}) bool {
+	objectMeta := MustGetObject(obj)
+	if gateOpen(objectMeta) {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", MustGetKey(obj)).
+		Debugf("%s %s is gated by feature gate %s, which is not enabled", r.Kind, MustGetKey(obj), objectMeta.GetAnnotations()[GatedBy])
+	return true
+}
+
+// FeatureGatesChanged re-evaluates all resources that are gated by a feature
+// gate whose value changed.
+func (r *GenericReplicator) FeatureGatesChanged(old map[string]bool, new map[string]bool) {
+	logger := log.WithField("kind", r.Kind)
+
+	for _, obj := range r.Store.List() {
+		gate, ok := MustGetObject(obj).GetAnnotations()[GatedBy]
+		if !ok || old[gate] == new[gate] {
+			continue
+		}
+
+		logger.WithField("resource", MustGetKey(obj)).Infof("feature gate %s changed, replicating again", gate)
+		r.ResourceAdded(obj)
+	}
+}
+
+// WatchFeatureGates watches the feature gate config map <namespace>/<name> and
+// updates the feature gates whenever it changes. It blocks until the config
+// map has been read once.
+func WatchFeatureGates(client kubernetes.Interface, namespace string, name string, resyncPeriod time.Duration) error {
+	return WatchConfigMap(client, "FeatureGates", namespace, name, resyncPeriod, SetFeatureGates)
+}
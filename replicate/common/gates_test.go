@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestFeatureGates(t *testing.T) {
+	defer SetFeatureGates(nil)
+
+	SetFeatureGates(map[string]string{"enabled": "true", "disabled": "false", "invalid": "yes please"})
+
+	assert.True(t, FeatureGateEnabled("enabled"))
+	assert.False(t, FeatureGateEnabled("disabled"))
+	assert.False(t, FeatureGateEnabled("invalid"))
+	assert.False(t, FeatureGateEnabled("missing"))
+
+	assert.True(t, gateOpen(&metav1.ObjectMeta{}))
+	assert.True(t, gateOpen(&metav1.ObjectMeta{Annotations: map[string]string{GatedBy: "enabled"}}))
+	assert.False(t, gateOpen(&metav1.ObjectMeta{Annotations: map[string]string{GatedBy: "missing"}}))
+}
+
+func TestGatedSourceIsReplicatedWhenGateIsEnabled(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	defer SetFeatureGates(nil)
+
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "target"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	var replicatedTo []string
+	r := newTestReplicator(t, "Secret")
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		replicatedTo = append(replicatedTo, target.Name)
+		return nil
+	}
+
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{
+		ReplicateTo: "target",
+		GatedBy:     "myfeature",
+	}}}))
+
+	for _, obj := range r.Store.List() {
+		r.ResourceAdded(obj)
+	}
+	assert.Empty(t, replicatedTo, "gate is missing")
+
+	old := map[string]bool{}
+	r.FeatureGatesChanged(old, map[string]bool{"unrelated": true})
+	assert.Empty(t, replicatedTo, "unrelated gate changed")
+
+	r.FeatureGatesChanged(old, map[string]bool{"myfeature": true})
+	assert.Empty(t, replicatedTo, "gate is still disabled in the feature gate set")
+
+	SetFeatureGates(map[string]string{"myfeature": "true"})
+	assert.Equal(t, []string{"target"}, replicatedTo)
+}
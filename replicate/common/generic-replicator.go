@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -16,9 +17,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 )
 
 type ReplicatorConfig struct {
@@ -29,13 +33,27 @@ type ReplicatorConfig struct {
 	ListFunc     cache.ListFunc
 	WatchFunc    cache.WatchFunc
 	ObjType      runtime.Object
+
+	// KeyedData is set for kinds that store their data by key, so that targets
+	// can pull single keys from their source
+	KeyedData bool
 }
 
+// UpdateFuncs are the kind specific operations of a replicator.
+// PatchDeleteDependent clears the replicated content of a target; it is nil
+// for kinds that are not valid without their content, whose replicas are
+// deleted instead.
 type UpdateFuncs struct {
 	ReplicateDataFrom        func(source interface{}, target interface{}) error
 	ReplicateObjectTo        func(source interface{}, target *v1.Namespace) error
 	PatchDeleteDependent     func(sourceKey string, target interface{}) (interface{}, error)
+	PatchOrphanDependent     func(target interface{}) (interface{}, error)
 	DeleteReplicatedResource func(target interface{}) error
+
+	// OnResourceAdded and OnResourceDeleted are optional and called for every
+	// added (or updated) and deleted resource of the replicator's kind
+	OnResourceAdded   func(obj interface{}) error
+	OnResourceDeleted func(obj interface{})
 }
 
 type GenericReplicator struct {
@@ -53,18 +71,99 @@ type GenericReplicator struct {
 	// ReplicateToMatchingList is a set that caches the names of all secrets
 	// that have a "replicate-to-matching" annotation.
 	ReplicateToMatchingList map[string]labels.Selector
+
+	// ReplicateToLabelKeyList caches the namespace selectors of all resources
+	// that have a "replicate-to-label-key" annotation.
+	ReplicateToLabelKeyList map[string]labels.Selector
+
+	// ReplicateToReleaseList caches the namespace selectors of all resources
+	// that have a "replicate-to-release" annotation.
+	ReplicateToReleaseList map[string]labels.Selector
+
+	// ReplicateToCreatorList caches the namespace selectors of all resources
+	// that have a "replicate-to-creator" annotation.
+	ReplicateToCreatorList map[string]labels.Selector
+
+	// ReplicateToCELList caches the compiled expressions of all resources that
+	// have a "replicate-to-cel" annotation.
+	ReplicateToCELList map[string]*NamespaceExpression
+
+	// ReplicateToURLList caches the namespaces that resources with a
+	// "replicate-to-url" annotation were last replicated to, as listed by
+	// their endpoint.
+	ReplicateToURLList map[string]map[string]struct{}
+
+	// ReplicateToConsumersList contains all secrets that have a
+	// "replicate-to-consumers" annotation.
+	ReplicateToConsumersList map[string]struct{}
+
+	Recorder record.EventRecorder
+
+	// Quarantine tracks resources that repeatedly failed to replicate
+	Quarantine *Quarantine
+
+	// Watchdog tracks running operations to detect operations that hang
+	Watchdog *Watchdog
+
+	// worker is held while an operation of the replicator runs, so that
+	// events, namespace changes and delayed items are processed one after
+	// another and don't access the target maps concurrently
+	worker sync.Mutex
+
+	deferred deferredOperations
+
+	intervals reconcileIntervals
+
+	stability sourceStability
+
+	sizes sourceSizes
+
+	// dependencyWaits holds since when replications wait for the replicas
+	// of their dependencies
+	dependencyWaits map[dependencyWait]time.Time
+
+	orphans orphanedReplicas
+
+	batch writeBatch
+
+	recentErrors recentErrors
+
+	// events holds the keys of objects whose informer events are waiting to
+	// be processed, with changes before resyncs
+	events *eventQueue
+
+	// deleted holds deleted objects until their event is processed
+	deleted     map[string]interface{}
+	deletedLock sync.Mutex
+
+	// DelayQueue holds replications into namespaces that are delayed by a
+	// "replicate-delay" annotation.
+	DelayQueue workqueue.DelayingInterface
 }
 
 // NewReplicator creates a new generic replicator
 func NewGenericReplicator(config ReplicatorConfig) *GenericReplicator {
-	repl := GenericReplicator{
-		ReplicatorConfig:        config,
-		DependencyMap:           make(map[string]map[string]interface{}),
-		ReplicateToList:         make(map[string]struct{}),
-		ReplicateToMatchingList: make(map[string]labels.Selector),
+	repl := &GenericReplicator{
+		ReplicatorConfig:         config,
+		DependencyMap:            make(map[string]map[string]interface{}),
+		ReplicateToList:          make(map[string]struct{}),
+		ReplicateToMatchingList:  make(map[string]labels.Selector),
+		ReplicateToLabelKeyList:  make(map[string]labels.Selector),
+		ReplicateToReleaseList:   make(map[string]labels.Selector),
+		ReplicateToCreatorList:   make(map[string]labels.Selector),
+		ReplicateToCELList:       make(map[string]*NamespaceExpression),
+		ReplicateToURLList:       make(map[string]map[string]struct{}),
+		ReplicateToConsumersList: make(map[string]struct{}),
+		DelayQueue:               workqueue.NewNamedDelayingQueue(config.Kind),
+		Recorder:                 newEventRecorder(config.Client),
+		Quarantine:               NewQuarantine(config.Kind, Options.QuarantineThreshold),
+		Watchdog:                 NewWatchdog(config.Kind),
+		events:                   newEventQueue(config.Kind),
+		deleted:                  make(map[string]interface{}),
 	}
 
-	store, controller := cache.NewInformer(
+	store, controller := newInformer(
+		config.Kind,
 		&cache.ListWatch{
 			ListFunc:  config.ListFunc,
 			WatchFunc: config.WatchFunc,
@@ -72,19 +171,43 @@ func NewGenericReplicator(config ReplicatorConfig) *GenericReplicator {
 		config.ObjType,
 		config.ResyncPeriod,
 		cache.ResourceEventHandlerFuncs{
-			AddFunc:    repl.ResourceAdded,
-			UpdateFunc: func(old interface{}, new interface{}) { repl.ResourceAdded(new) },
-			DeleteFunc: repl.ResourceDeleted,
+			AddFunc: func(obj interface{}) {
+				repl.enqueue(obj, PriorityChange)
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				repl.enqueue(new, eventPriority(old, new))
+			},
+			DeleteFunc: repl.enqueueDeletion,
 		},
 	)
 
-	namespaceWatcher.OnNamespaceAdded(config.Client, config.ResyncPeriod, repl.NamespaceAdded)
-	namespaceWatcher.OnNamespaceUpdated(config.Client, config.ResyncPeriod, repl.NamespaceUpdated)
+	namespaceWatcher.OnNamespaceAdded(config.Client, config.ResyncPeriod, func(ns *v1.Namespace) {
+		repl.whenWritable(nil, func() { repl.NamespaceAdded(ns) })
+	})
+	namespaceWatcher.OnNamespaceUpdated(config.Client, config.ResyncPeriod, func(nsOld *v1.Namespace, nsNew *v1.Namespace) {
+		repl.whenWritable(nil, func() { repl.NamespaceUpdated(nsOld, nsNew) })
+	})
+	namespaceWatcher.OnNamespaceDeleted(config.Client, config.ResyncPeriod, func(ns *v1.Namespace) {
+		repl.whenWritable(nil, func() { repl.NamespaceDeleted(ns) })
+	})
+	OnReplicationRulesChanged(func(old []ReplicationRule, new []ReplicationRule) {
+		repl.whenWritable(nil, func() { repl.ReplicationRulesChanged(old, new) })
+	})
+	OnFeatureGatesChanged(func(old map[string]bool, new map[string]bool) {
+		repl.whenWritable(nil, func() { repl.FeatureGatesChanged(old, new) })
+	})
+	OnNamespaceMappingChanged(func() {
+		repl.whenWritable(nil, repl.NamespaceMappingChanged)
+	})
+	OnSecretConsumersChanged(func(names map[string]struct{}) {
+		repl.whenWritable(nil, func() { repl.SecretConsumersChanged(names) })
+	})
 
 	repl.Store = store
 	repl.Controller = controller
+	registerReplicator(repl)
 
-	return &repl
+	return repl
 }
 
 // IsReplicationPermitted checks if replication is allowed in annotations of the source object
@@ -110,21 +233,41 @@ func (r *GenericReplicator) IsReplicationPermitted(object *metav1.ObjectMeta, so
 	}
 
 	// check if the target namespace is permitted
-	annotationAllowedNamespaces, ok := sourceObject.Annotations[ReplicationAllowedNamespaces]
-	if !ok {
+	annotationAllowedNamespaces, hasList := sourceObject.Annotations[ReplicationAllowedNamespaces]
+	annotationAllowedMatching, hasSelector := sourceObject.Annotations[ReplicationAllowedMatching]
+	if !hasList && !hasSelector {
 		return false, fmt.Errorf(
-			"source %s/%s does not allow replication (%s annotation missing). %s will not be replicated",
-			sourceObject.Namespace, sourceObject.Name, ReplicationAllowedNamespaces, object.Name)
+			"source %s/%s does not allow replication (%s or %s annotation missing). %s will not be replicated",
+			sourceObject.Namespace, sourceObject.Name, ReplicationAllowedNamespaces, ReplicationAllowedMatching, object.Name)
 	}
-	allowedNamespaces := strings.Split(annotationAllowedNamespaces, ",")
+
 	allowed := false
-	for _, ns := range allowedNamespaces {
-		ns := BuildStrictRegex(ns)
+	if hasList {
+		allowedNamespaces := strings.Split(annotationAllowedNamespaces, ",")
+		for _, ns := range allowedNamespaces {
+			ns := BuildStrictRegex(ns)
+
+			if matched, _ := regexp.MatchString(ns, object.Namespace); matched {
+				log.Tracef("Namespace '%s' matches '%s' -- allowing replication", object.Namespace, ns)
+				allowed = true
+				break
+			}
+		}
+	}
 
-		if matched, _ := regexp.MatchString(ns, object.Namespace); matched {
-			log.Tracef("Namespace '%s' matches '%s' -- allowing replication", object.Namespace, ns)
-			allowed = true
-			break
+	if !allowed && hasSelector {
+		namespaceSelector, err := labels.Parse(annotationAllowedMatching)
+		if err != nil {
+			return false, errors.Wrapf(err, "source %s/%s has an invalid %s annotation: %v",
+				sourceObject.Namespace, sourceObject.Name, ReplicationAllowedMatching, err)
+		}
+
+		allowed, err = namespaceMatchesSelector(object.Namespace, namespaceSelector)
+		if err != nil {
+			return false, err
+		}
+		if allowed {
+			log.Tracef("Namespace '%s' matches '%s' -- allowing replication", object.Namespace, namespaceSelector)
 		}
 	}
 
@@ -141,14 +284,38 @@ func (r *GenericReplicator) Synced() bool {
 	return r.Controller.HasSynced()
 }
 
+// Stalled checks if an operation of the replicator has been running for longer
+// than Options.StallThreshold, and returns for how long it has been running
+func (r *GenericReplicator) Stalled() (time.Duration, bool) {
+	return r.Watchdog.Stalled(time.Now(), Options.StallThreshold)
+}
+
 func (r *GenericReplicator) Run() {
 	log.WithField("kind", r.Kind).Infof("running %s controller", r.Kind)
+	go r.runEvents()
+	go r.runDelayedReplications()
+	go r.runDeferredOperations()
+	if Options.SweepDeletedSources {
+		go r.sweepDeletedSources(wait.NeverStop)
+	}
 	r.Controller.Run(wait.NeverStop)
 }
 
+// runOperation runs op once no other operation of the replicator is running.
+// Like the single worker of a controller, this processes all events,
+// namespace changes and delayed items of a kind one after another, since they
+// share the target maps of the replicator.
+func (r *GenericReplicator) runOperation(op func()) {
+	r.worker.Lock()
+	defer r.worker.Unlock()
+
+	r.Watchdog.Track(op)
+}
+
 // NamespaceAdded replicates resources with ReplicateTo and ReplicateToMatching
 // annotations into newly created namespaces.
 func (r *GenericReplicator) NamespaceAdded(ns *v1.Namespace) {
+	ctx := context.Background()
 	logger := log.WithField("kind", r.Kind).WithField("target", ns.Name)
 	for sourceKey := range r.ReplicateToList {
 		logger := logger.WithField("resource", sourceKey)
@@ -164,9 +331,9 @@ func (r *GenericReplicator) NamespaceAdded(ns *v1.Namespace) {
 
 		objectMeta := MustGetObject(obj)
 		replicatedList := make([]string, 0)
-		namespacePatterns, found := objectMeta.GetAnnotations()[ReplicateTo]
+		namespacePatterns, found := replicateToPatterns(r.Kind, objectMeta, objectMeta.GetAnnotations())
 		if found {
-			if err := r.replicateResourceToMatchingNamespaces(obj, namespacePatterns, []v1.Namespace{*ns}); err != nil {
+			if err := r.replicateResourceToMatchingNamespaces(ctx, obj, namespacePatterns, []v1.Namespace{*ns}); err != nil {
 				logger.
 					WithError(err).
 					Errorf("Failed replicating the resource to the new namespace %s: %v", ns.Name, err)
@@ -178,7 +345,30 @@ func (r *GenericReplicator) NamespaceAdded(ns *v1.Namespace) {
 	}
 
 	namespaceLabels := labels.Set(ns.Labels)
-	for sourceKey, selector := range r.ReplicateToMatchingList {
+	for _, selectors := range []map[string]labels.Selector{r.ReplicateToMatchingList, r.ReplicateToLabelKeyList, r.ReplicateToReleaseList, r.ReplicateToCreatorList} {
+		for sourceKey, selector := range selectors {
+			logger := logger.WithField("resource", sourceKey)
+
+			obj, exists, err := r.Store.GetByKey(sourceKey)
+			if err != nil {
+				log.WithError(err).Error("error fetching object from store")
+				continue
+			} else if !exists {
+				log.Warn("object not found in store")
+				continue
+			}
+
+			if !selector.Matches(namespaceLabels) {
+				continue
+			}
+
+			if _, _, err := r.replicateResourceToNamespaces(ctx, obj, []v1.Namespace{*ns}); err != nil {
+				logger.WithError(err).Error("error while replicating object to namespace")
+			}
+		}
+	}
+
+	for sourceKey, expression := range r.ReplicateToCELList {
 		logger := logger.WithField("resource", sourceKey)
 
 		obj, exists, err := r.Store.GetByKey(sourceKey)
@@ -190,11 +380,52 @@ func (r *GenericReplicator) NamespaceAdded(ns *v1.Namespace) {
 			continue
 		}
 
-		if !selector.Matches(namespaceLabels) {
+		namespaces := r.getNamespacesMatchingExpression(MustGetObject(obj).GetNamespace(), expression, []v1.Namespace{*ns})
+		if _, _, err := r.replicateResourceToNamespaces(ctx, obj, namespaces); err != nil {
+			logger.WithError(err).Error("error while replicating object to namespace")
+		}
+	}
+
+	for sourceKey, listed := range r.ReplicateToURLList {
+		if _, ok := listed[ns.Name]; !ok {
 			continue
 		}
+		logger := logger.WithField("resource", sourceKey)
 
-		if _, err := r.replicateResourceToNamespaces(obj, []v1.Namespace{*ns}); err != nil {
+		obj, exists, err := r.Store.GetByKey(sourceKey)
+		if err != nil {
+			log.WithError(err).Error("error fetching object from store")
+			continue
+		} else if !exists {
+			log.Warn("object not found in store")
+			continue
+		}
+
+		namespaces := getNamespacesListedByEndpoint(MustGetObject(obj).GetNamespace(), listed, []v1.Namespace{*ns})
+		if _, _, err := r.replicateResourceToNamespaces(ctx, obj, namespaces); err != nil {
+			logger.WithError(err).Error("error while replicating object to namespace")
+		}
+	}
+
+	// deployments may be seen before the namespace they are in
+	for sourceKey := range r.ReplicateToConsumersList {
+		logger := logger.WithField("resource", sourceKey)
+
+		obj, exists, err := r.Store.GetByKey(sourceKey)
+		if err != nil {
+			log.WithError(err).Error("error fetching object from store")
+			continue
+		} else if !exists {
+			log.Warn("object not found in store")
+			continue
+		}
+
+		consumers, ok := secretConsumerNamespaces(MustGetObject(obj).GetName())
+		if !ok {
+			continue
+		}
+		namespaces := getNamespacesListedByEndpoint(MustGetObject(obj).GetNamespace(), consumers, []v1.Namespace{*ns})
+		if _, _, err := r.replicateResourceToNamespaces(ctx, obj, namespaces); err != nil {
 			logger.WithError(err).Error("error while replicating object to namespace")
 		}
 	}
@@ -205,9 +436,9 @@ func (r *GenericReplicator) NamespaceAdded(ns *v1.Namespace) {
 // on the updated set of labels
 func (r *GenericReplicator) NamespaceUpdated(nsOld *v1.Namespace, nsNew *v1.Namespace) {
 	logger := log.WithField("kind", r.Kind).WithField("target", nsNew.Name)
-	// check if labels changed
-	if reflect.DeepEqual(nsNew.Labels, nsOld.Labels) {
-		logger.Debug("labels didn't change")
+	// check if labels or annotations changed
+	if reflect.DeepEqual(nsNew.Labels, nsOld.Labels) && reflect.DeepEqual(nsNew.Annotations, nsOld.Annotations) {
+		logger.Debug("labels and annotations didn't change")
 		return
 	} else {
 		logger.Infof("labels of namespace %s changed, attempting to delete %ss that no longer match", nsNew.Name, r.Kind)
@@ -216,9 +447,46 @@ func (r *GenericReplicator) NamespaceUpdated(nsOld *v1.Namespace, nsNew *v1.Name
 		newLabelSet = nsNew.Labels
 		var oldLabelSet labels.Set
 		oldLabelSet = nsOld.Labels
-		// check 'replicate-to-matching' resources against new labels
-		for sourceKey, selector := range r.ReplicateToMatchingList {
-			if selector.Matches(oldLabelSet) && !selector.Matches(newLabelSet) {
+		// check 'replicate-to-matching' and 'replicate-to-label-key' resources against new labels
+		for _, selectors := range []map[string]labels.Selector{r.ReplicateToMatchingList, r.ReplicateToLabelKeyList, r.ReplicateToReleaseList, r.ReplicateToCreatorList} {
+			for sourceKey, selector := range selectors {
+				if selector.Matches(oldLabelSet) && !selector.Matches(newLabelSet) {
+					obj, exists, err := r.Store.GetByKey(sourceKey)
+					if err != nil {
+						log.WithError(err).Error("error fetching object from store")
+						continue
+					} else if !exists {
+						log.Warn("object not found in store")
+						continue
+					}
+					// delete resource from the updated namespace
+					logger.Infof("removed %s %s from %s", r.Kind, sourceKey, nsNew.Name)
+					r.DeleteResourceInNamespaces(obj, &v1.NamespaceList{Items: []v1.Namespace{*nsNew}})
+				}
+			}
+		}
+
+		// check sources of replication rules with namespace selectors against new labels
+		for sourceKey := range r.ReplicateToList {
+			obj, exists, err := r.Store.GetByKey(sourceKey)
+			if err != nil || !exists {
+				continue
+			}
+			objectMeta := MustGetObject(obj)
+			if !replicationRules.SelectsNamespace(r.Kind, objectMeta, nsOld) {
+				continue
+			}
+			if _, targeted := pushTargets(r.Kind, objectMeta, []v1.Namespace{*nsNew})[nsNew.Name]; !targeted {
+				logger.Infof("removed %s %s from %s", r.Kind, sourceKey, nsNew.Name)
+				r.DeleteResourceInNamespaces(obj, &v1.NamespaceList{Items: []v1.Namespace{*nsNew}})
+			}
+		}
+
+		// check 'replicate-to-cel' resources against the new metadata
+		for sourceKey, expression := range r.ReplicateToCELList {
+			matchedOld, _ := expression.Matches(nsOld)
+			matchedNew, _ := expression.Matches(nsNew)
+			if matchedOld && !matchedNew {
 				obj, exists, err := r.Store.GetByKey(sourceKey)
 				if err != nil {
 					log.WithError(err).Error("error fetching object from store")
@@ -227,7 +495,6 @@ func (r *GenericReplicator) NamespaceUpdated(nsOld *v1.Namespace, nsNew *v1.Name
 					log.Warn("object not found in store")
 					continue
 				}
-				// delete resource from the updated namespace
 				logger.Infof("removed %s %s from %s", r.Kind, sourceKey, nsNew.Name)
 				r.DeleteResourceInNamespaces(obj, &v1.NamespaceList{Items: []v1.Namespace{*nsNew}})
 			}
@@ -239,43 +506,172 @@ func (r *GenericReplicator) NamespaceUpdated(nsOld *v1.Namespace, nsNew *v1.Name
 	}
 }
 
+// NamespaceDeleted replicates all push-based sources that targeted a deleted
+// namespace again. Replicating into a terminating namespace fails, so this
+// clears the failure status and quarantine of these sources right away instead
+// of at the next resync.
+func (r *GenericReplicator) NamespaceDeleted(ns *v1.Namespace) {
+	logger := log.WithField("kind", r.Kind).WithField("target", ns.Name)
+
+	for _, obj := range r.Store.List() {
+		objectMeta := MustGetObject(obj)
+		sourceKey := MustGetKey(objectMeta)
+		if objectMeta.GetNamespace() == ns.Name || !r.isPushSource(sourceKey) {
+			continue
+		}
+		if _, targeted := pushTargets(r.Kind, objectMeta, []v1.Namespace{*ns})[ns.Name]; !targeted {
+			continue
+		}
+
+		logger.WithField("resource", sourceKey).Infof("target namespace %s was deleted, replicating again", ns.Name)
+		r.Quarantine.Reset(sourceKey)
+		r.ResourceAdded(obj)
+	}
+}
+
 // ResourceAdded checks resources with ReplicateTo or ReplicateFromAnnotation annotation
 func (r *GenericReplicator) ResourceAdded(obj interface{}) {
 	objectMeta := MustGetObject(obj)
 	sourceKey := MustGetKey(objectMeta)
+	version := objectMeta.GetResourceVersion()
 	logger := log.WithField("kind", r.Kind).WithField("resource", sourceKey)
 
-	ctx := context.Background()
+	// targets of "replicate-from" annotations are checked against their source
+	if _, isTarget := objectMeta.GetAnnotations()[ReplicateFromAnnotation]; !isTarget && !InEnvironment(objectMeta) {
+		logger.Debugf("%s %s is not in environment %s, ignoring it", r.Kind, sourceKey, Options.Environment)
+		r.forgetSource(sourceKey)
+		return
+	}
+
+	if r.Quarantine.IsQuarantined(sourceKey, version) {
+		logger.Debugf("%s %s is quarantined until it changes", r.Kind, sourceKey)
+		return
+	}
+
+	if r.deferUnstable(objectMeta) {
+		logger.Debugf("%s %s changed less than %s ago, deferring", r.Kind, sourceKey, minStableAge(objectMeta))
+		return
+	}
+
+	if r.deferReconcile(objectMeta) {
+		logger.Debugf("%s %s was reconciled less than %s ago, deferring", r.Kind, sourceKey, minReconcileInterval(objectMeta))
+		return
+	}
+
+	ctx, span := r.startReconcileSpan(context.Background(), obj)
+	err := r.replicateResource(ctx, obj)
+	endSpan(span, err)
+	r.updatePropagationLag(obj)
+	if err == nil {
+		r.reportFailureStatus(obj, nil)
+		r.Quarantine.Reset(sourceKey)
+		return
+	}
+
+	r.recordFailure(obj, err)
+}
+
+// recordFailure records that the replication of a source failed: the failure
+// is reported in the failure status of the source and in the summary, and it
+// counts towards the quarantine of the source version.
+func (r *GenericReplicator) recordFailure(obj interface{}, err error) {
+	objectMeta := MustGetObject(obj)
+	sourceKey := MustGetKey(objectMeta)
+
+	r.reportFailureStatus(obj, err)
+	r.recentErrors.add(sourceKey, err)
+	if r.Quarantine.RecordFailure(sourceKey, objectMeta.GetResourceVersion()) {
+		log.WithField("kind", r.Kind).WithField("resource", sourceKey).
+			Warnf("%s %s failed to replicate %d times, quarantining it until it changes", r.Kind, sourceKey, r.Quarantine.Threshold)
+		r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "Quarantined",
+			"Replication failed %d times, not retrying until the resource changes", r.Quarantine.Threshold)
+	}
+}
+
+// replicateResource replicates a resource according to its annotations. It
+// returns the errors of all parts of the replication that failed.
+func (r *GenericReplicator) replicateResource(ctx context.Context, obj interface{}) error {
+	var failures *multierror.Error
+
+	objectMeta := MustGetObject(obj)
+	sourceKey := MustGetKey(objectMeta)
+	logger := log.WithField("kind", r.Kind).WithField("resource", sourceKey)
+
+	if r.UpdateFuncs.OnResourceAdded != nil {
+		if err := r.UpdateFuncs.OnResourceAdded(obj); err != nil {
+			logger.WithError(err).Error("failed to process resource")
+			failures = multierror.Append(failures, err)
+		}
+	}
+
+	// existing replicas are kept while the feature gate is disabled
+	if r.refuseGatedSource(obj) {
+		return failures.ErrorOrNil()
+	}
 
 	if replicas, ok := r.DependencyMap[sourceKey]; ok {
 		logger.Debugf("objectMeta %s has %d dependents", sourceKey, len(replicas))
-		if err := r.updateDependents(obj, replicas); err != nil {
+		if err := r.updateDependents(ctx, obj, replicas); err != nil {
 			logger.WithError(err).Error("failed to update cache")
+			failures = multierror.Append(failures, err)
 		}
 	}
 
-	annotations := objectMeta.GetAnnotations()
-
 	// Match resources with "replicate-from" annotation
-	if source, ok := annotations[ReplicateFromAnnotation]; ok {
-		if err := r.resourceAddedReplicateFrom(source, obj); err != nil {
+	if source, ok, err := r.replicateFrom(objectMeta); ok {
+		if err != nil {
+			logger.WithError(err).Warn("could not parse source")
+			r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "InvalidReplicateFrom", "Not replicated: %v", err)
+			failures = multierror.Append(failures, err)
+		} else if err := r.resourceAddedReplicateFrom(ctx, source, obj); err != nil {
 			logger.WithError(err).Error("could not copy from source")
+			failures = multierror.Append(failures, err)
 		}
 
-		return
+		return failures.ErrorOrNil()
 	}
 
-	// Match resources with "replicate-to" annotation
-	if namespacePatterns, ok := annotations[ReplicateTo]; ok {
-		r.ReplicateToList[sourceKey] = struct{}{}
+	// sources that stopped pushing still need to remove their replicas
+	prune := r.isPushSource(sourceKey) || hasPushAnnotations(r.Kind, objectMeta)
+
+	if prune && Options.DeleteBeforeCreate {
+		if err := r.pruneStaleReplicas(obj); err != nil {
+			failures = multierror.Append(failures, err)
+		}
+	}
+
+	if err := r.replicateResourceToTargets(ctx, obj); err != nil {
+		failures = multierror.Append(failures, err)
+	}
 
-		namespacesFromStore := namespaceWatcher.NamespaceStore.List()
-		namespaces := make([]v1.Namespace, len(namespacesFromStore))
-		for i, ns := range namespacesFromStore {
-			namespaces[i] = *ns.(*v1.Namespace)
+	if prune && !Options.DeleteBeforeCreate {
+		if err := r.pruneStaleReplicas(obj); err != nil {
+			failures = multierror.Append(failures, err)
 		}
-		if err := r.replicateResourceToMatchingNamespaces(obj, namespacePatterns, namespaces); err != nil {
+	}
+
+	return failures.ErrorOrNil()
+}
+
+// replicateResourceToTargets replicates a resource into all namespaces that
+// are targeted by its push-based replication annotations. It returns the
+// errors of all parts of the replication that failed.
+func (r *GenericReplicator) replicateResourceToTargets(ctx context.Context, obj interface{}) error {
+	var failures *multierror.Error
+
+	objectMeta := MustGetObject(obj)
+	sourceKey := MustGetKey(objectMeta)
+	logger := log.WithField("kind", r.Kind).WithField("resource", sourceKey)
+
+	annotations := objectMeta.GetAnnotations()
+
+	// Match resources with "replicate-to" annotation or a matching replication rule
+	if namespacePatterns, ok := replicateToPatterns(r.Kind, objectMeta, annotations); ok {
+		r.ReplicateToList[sourceKey] = struct{}{}
+
+		if err := r.replicateResourceToMatchingNamespaces(ctx, obj, namespacePatterns, nil); err != nil {
 			logger.WithError(err).Errorf("could not replicate object to other namespaces")
+			failures = multierror.Append(failures, err)
 		}
 	} else {
 		delete(r.ReplicateToList, sourceKey)
@@ -288,30 +684,242 @@ func (r *GenericReplicator) ResourceAdded(obj interface{}) {
 			delete(r.ReplicateToMatchingList, sourceKey)
 			logger.WithError(err).Error("failed to parse label selector")
 
-			return
+			return multierror.Append(failures, err)
 		}
 
 		r.ReplicateToMatchingList[sourceKey] = namespaceSelector
 
 		if err := r.replicateResourceToMatchingNamespacesByLabel(ctx, obj, namespaceSelector); err != nil {
 			logger.WithError(err).Error("error while replicating by label selector")
+			failures = multierror.Append(failures, err)
 		}
 	} else {
 		delete(r.ReplicateToMatchingList, sourceKey)
 	}
+
+	// Match resources with "replicate-to-label-key" annotation
+	if labelKey, ok := annotations[ReplicateToLabelKey]; ok {
+		namespaceSelector, err := labelKeySelector(labelKey, objectMeta.GetNamespace())
+		if err != nil {
+			delete(r.ReplicateToLabelKeyList, sourceKey)
+			logger.WithError(err).Error("failed to build label selector")
+
+			return multierror.Append(failures, err)
+		}
+
+		r.ReplicateToLabelKeyList[sourceKey] = namespaceSelector
+
+		if err := r.replicateResourceToMatchingNamespacesByLabel(ctx, obj, namespaceSelector); err != nil {
+			logger.WithError(err).Error("error while replicating by label key")
+			failures = multierror.Append(failures, err)
+		}
+	} else {
+		delete(r.ReplicateToLabelKeyList, sourceKey)
+	}
+
+	// Match resources with "replicate-to-release" annotation
+	if release, ok := annotations[ReplicateToRelease]; ok {
+		namespaceSelector, err := releaseSelector(release)
+		if err != nil {
+			delete(r.ReplicateToReleaseList, sourceKey)
+			logger.WithError(err).Error("failed to build release selector")
+
+			return multierror.Append(failures, err)
+		}
+
+		r.ReplicateToReleaseList[sourceKey] = namespaceSelector
+
+		if err := r.replicateResourceToMatchingNamespacesByLabel(ctx, obj, namespaceSelector); err != nil {
+			logger.WithError(err).Error("error while replicating by release")
+			failures = multierror.Append(failures, err)
+		}
+	} else {
+		delete(r.ReplicateToReleaseList, sourceKey)
+	}
+
+	// Match resources with "replicate-to-creator" annotation
+	if creators, ok := annotations[ReplicateToCreator]; ok {
+		namespaceSelector, err := creatorSelector(creators)
+		if err != nil {
+			delete(r.ReplicateToCreatorList, sourceKey)
+			logger.WithError(err).Error("failed to build creator selector")
+
+			return multierror.Append(failures, err)
+		}
+
+		r.ReplicateToCreatorList[sourceKey] = namespaceSelector
+
+		if err := r.replicateResourceToMatchingNamespacesByLabel(ctx, obj, namespaceSelector); err != nil {
+			logger.WithError(err).Error("error while replicating by creator")
+			failures = multierror.Append(failures, err)
+		}
+	} else {
+		delete(r.ReplicateToCreatorList, sourceKey)
+	}
+
+	// Match resources with "replicate-to-cel" annotation
+	if expressionString, ok := annotations[ReplicateToCEL]; ok {
+		expression, err := r.namespaceExpression(sourceKey, expressionString)
+		if err != nil {
+			delete(r.ReplicateToCELList, sourceKey)
+			logger.WithError(err).Error("failed to compile namespace expression")
+			r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "InvalidExpression",
+				"Invalid %s annotation: %v", ReplicateToCEL, err)
+
+			return multierror.Append(failures, err)
+		}
+
+		r.ReplicateToCELList[sourceKey] = expression
+
+		namespaces := r.getNamespacesMatchingExpression(objectMeta.GetNamespace(), expression, namespacesFromStore())
+		if replicated, pending, err := r.replicateResourceToNamespaces(ctx, obj, namespaces); err != nil {
+			logger.WithError(err).Errorf("Replicated %s to %d out of %d namespaces (%d pending)", sourceKey, len(replicated), len(namespaces), len(pending))
+			failures = multierror.Append(failures, err)
+		}
+	} else {
+		delete(r.ReplicateToCELList, sourceKey)
+	}
+
+	// Match resources with "replicate-to-url" annotation
+	if url, ok := annotations[ReplicateToURL]; ok {
+		if !namespaceEndpointAllowed(url) {
+			delete(r.ReplicateToURLList, sourceKey)
+			err := errors.Errorf("endpoint %s is not allowed by -namespace-endpoint-prefixes", url)
+			logger.WithError(err).Error("refusing to fetch namespaces")
+			r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "EndpointNotAllowed",
+				"Invalid %s annotation: %v", ReplicateToURL, err)
+
+			return multierror.Append(failures, err)
+		}
+
+		// the endpoint is only fetched from the delay queue; until its
+		// namespaces are known, they are fetched right away
+		listed, known := cachedEndpointNamespaces(url)
+		if !known {
+			delete(r.ReplicateToURLList, sourceKey)
+			logger.Infof("namespaces of endpoint %s are not known yet, replicating once they are fetched", url)
+			r.DelayQueue.Add(delayedEndpointRefresh{SourceKey: sourceKey})
+		} else {
+			r.DelayQueue.AddAfter(delayedEndpointRefresh{SourceKey: sourceKey}, namespaceEndpointRefresh())
+			r.ReplicateToURLList[sourceKey] = listed
+		}
+
+		namespaces := getNamespacesListedByEndpoint(objectMeta.GetNamespace(), listed, namespacesFromStore())
+		if replicated, pending, err := r.replicateResourceToNamespaces(ctx, obj, namespaces); err != nil {
+			logger.WithError(err).Errorf("Replicated %s to %d out of %d namespaces (%d pending)", sourceKey, len(replicated), len(namespaces), len(pending))
+			failures = multierror.Append(failures, err)
+		}
+	} else {
+		delete(r.ReplicateToURLList, sourceKey)
+	}
+
+	// Match secrets with "replicate-to-consumers" annotation
+	if replicatesToConsumers(objectMeta) {
+		consumers, ok := secretConsumerNamespaces(objectMeta.GetName())
+		if !ok || r.Kind != "Secret" {
+			delete(r.ReplicateToConsumersList, sourceKey)
+			err := errors.New("consumer discovery is not enabled by -consumer-discovery")
+			if r.Kind != "Secret" {
+				err = errors.Errorf("only secrets can be replicated to their consumers, not %ss", r.Kind)
+			}
+			logger.WithError(err).Error("refusing to replicate to consumers")
+			r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "ConsumerDiscoveryDisabled",
+				"Invalid %s annotation: %v", ReplicateToConsumers, err)
+
+			return multierror.Append(failures, err)
+		}
+
+		r.ReplicateToConsumersList[sourceKey] = struct{}{}
+
+		namespaces := getNamespacesListedByEndpoint(objectMeta.GetNamespace(), consumers, namespacesFromStore())
+		if replicated, pending, err := r.replicateResourceToNamespaces(ctx, obj, namespaces); err != nil {
+			logger.WithError(err).Errorf("Replicated %s to %d out of %d namespaces (%d pending)", sourceKey, len(replicated), len(namespaces), len(pending))
+			failures = multierror.Append(failures, err)
+		}
+	} else {
+		delete(r.ReplicateToConsumersList, sourceKey)
+	}
+
+	return failures.ErrorOrNil()
+}
+
+// namespaceExpression returns the compiled expression for the given resource. The
+// compiled program is reused as long as the expression doesn't change.
+func (r *GenericReplicator) namespaceExpression(sourceKey string, expression string) (*NamespaceExpression, error) {
+	if cached, ok := r.ReplicateToCELList[sourceKey]; ok && cached.Expression == expression {
+		return cached, nil
+	}
+
+	return ParseNamespaceExpression(expression)
+}
+
+// ReplicationRulesChanged re-evaluates all resources that are affected by
+// changed replication rules.
+func (r *GenericReplicator) ReplicationRulesChanged(old []ReplicationRule, new []ReplicationRule) {
+	logger := log.WithField("kind", r.Kind)
+
+	affected := make(map[string]struct{})
+	selecting := make([]ReplicationRule, 0)
+	for _, rules := range [][]ReplicationRule{old, new} {
+		for _, rule := range rules {
+			if rule.Kind != "" && rule.Kind != r.Kind {
+				continue
+			}
+			if rule.Owner != nil || rule.Selector != nil {
+				selecting = append(selecting, rule)
+			} else {
+				affected[rule.Source] = struct{}{}
+			}
+		}
+	}
+
+	// the resources of owner and selector rules are only known by their
+	// owner references and labels
+	if len(selecting) > 0 {
+		for _, obj := range r.Store.List() {
+			for _, rule := range selecting {
+				if rule.Matches(r.Kind, MustGetObject(obj)) {
+					affected[MustGetKey(obj)] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+
+	for sourceKey := range affected {
+		obj, exists, err := r.Store.GetByKey(sourceKey)
+		if err != nil {
+			logger.WithError(err).Error("error fetching object from store")
+			continue
+		} else if !exists {
+			logger.Debugf("%s %s of replication rule not found in store", r.Kind, sourceKey)
+			continue
+		}
+
+		logger.WithField("resource", sourceKey).Info("replication rules changed, replicating again")
+		r.ResourceAdded(obj)
+	}
+}
+
+// replicateFrom parses the ReplicateFromAnnotation of a target. Single keys can
+// only be pulled by kinds with keyed data.
+func (r *GenericReplicator) replicateFrom(target metav1.Object) (source ReplicateFromSource, ok bool, err error) {
+	source, ok, err = ReplicateFrom(target)
+	if err == nil && source.Key != "" && !r.KeyedData {
+		err = errors.Errorf("invalid %s annotation '%s': %ss have no keys that could be pulled",
+			ReplicateFromAnnotation, target.GetAnnotations()[ReplicateFromAnnotation], r.Kind)
+	}
+
+	return
 }
 
 // resourceAddedReplicateFrom replicates resources with ReplicateFromAnnotation
-func (r *GenericReplicator) resourceAddedReplicateFrom(sourceLocation string, target interface{}) error {
+func (r *GenericReplicator) resourceAddedReplicateFrom(ctx context.Context, source ReplicateFromSource, target interface{}) error {
 	cacheKey := MustGetKey(target)
+	sourceLocation := source.String()
 
 	logger := log.WithField("kind", r.Kind).WithField("source", sourceLocation).WithField("target", cacheKey)
 	logger.Debugf("%s %s is replicated from %s", r.Kind, cacheKey, sourceLocation)
-	v := strings.SplitN(sourceLocation, "/", 2)
-
-	if len(v) < 2 {
-		return errors.Errorf("Invalid source location expected '<namespace>/<name>', got '%s'", sourceLocation)
-	}
 
 	if _, ok := r.DependencyMap[sourceLocation]; !ok {
 		r.DependencyMap[sourceLocation] = make(map[string]interface{})
@@ -323,10 +931,27 @@ func (r *GenericReplicator) resourceAddedReplicateFrom(sourceLocation string, ta
 	if err != nil {
 		return errors.Wrapf(err, "Could not get source %s: %v", sourceLocation, err)
 	} else if !exists {
-		return errors.Errorf("Could not get source %s: does not exist", sourceLocation)
+		// the source might just have been created and not be in the cache yet
+		r.requeueMissingSource(cacheKey, 0)
+		return nil
+	}
+
+	if !InEnvironment(MustGetObject(sourceObject)) {
+		logger.Debugf("source %s is not in environment %s, ignoring it", sourceLocation, Options.Environment)
+		return nil
+	}
+
+	if r.refuseGatedSource(sourceObject) || r.refuseOversizedObject(sourceObject) || r.refuseReplicationLoop(sourceObject, cacheKey) {
+		return nil
 	}
 
-	if err := r.UpdateFuncs.ReplicateDataFrom(sourceObject, target); err != nil {
+	if err := r.traceWrite(ctx, cacheKey, func() error {
+		return r.UpdateFuncs.ReplicateDataFrom(sourceObject, target)
+	}); err != nil {
+		if r.requeueIfThrottled(delayedResync{Key: cacheKey}, err) {
+			return nil
+		}
+
 		return errors.Wrapf(err, "Failed to replicate %s target %s -> %s: %v",
 			r.Kind, MustGetKey(sourceObject), cacheKey, err,
 		)
@@ -335,24 +960,82 @@ func (r *GenericReplicator) resourceAddedReplicateFrom(sourceLocation string, ta
 	return nil
 }
 
-// resourceAddedReplicateFrom replicates resources with ReplicateTo annotation
-func (r *GenericReplicator) replicateResourceToMatchingNamespaces(obj interface{}, nsPatternList string, namespaceList []v1.Namespace) error {
+// replicateResourceToMatchingNamespaces replicates a resource to the namespaces
+// in namespaceList that match its patterns or are selected by replication
+// rules. A nil namespaceList stands for all namespaces, which are looked up in
+// the namespace index.
+func (r *GenericReplicator) replicateResourceToMatchingNamespaces(ctx context.Context, obj interface{}, nsPatternList string, namespaceList []v1.Namespace) error {
 	cacheKey := MustGetKey(obj)
+	objectMeta := MustGetObject(obj)
 	logger := log.WithField("kind", r.Kind).WithField("source", cacheKey)
 
 	logger.Infof("%s %s to be replicated to: [%s]", r.Kind, cacheKey, nsPatternList)
 
-	replicateTo := r.getNamespacesToReplicate(MustGetObject(obj).GetNamespace(), nsPatternList, namespaceList)
+	var replicateTo []v1.Namespace
+	indexed := false
+	if namespaceList == nil {
+		replicateTo, indexed = namespaceIndex.matching(objectMeta.GetNamespace(), nsPatternList)
+	}
+	if !indexed {
+		if namespaceList == nil {
+			namespaceList = namespacesFromStore()
+		}
+		replicateTo = r.getNamespacesToReplicate(objectMeta.GetNamespace(), nsPatternList, namespaceList)
+	}
+
+	if replicationRules.selectsNamespaces(r.Kind, objectMeta) {
+		if namespaceList == nil {
+			namespaceList = namespacesFromStore()
+		}
+		replicateTo = append(replicateTo, r.getNamespacesSelectedByRules(objectMeta, namespaceList, replicateTo)...)
+	}
 
-	if replicated, err := r.replicateResourceToNamespaces(obj, replicateTo); err != nil {
-		return errors.Wrapf(err, "Replicated %s to %d out of %d namespaces",
-			cacheKey, len(replicated), len(replicateTo),
+	if replicated, pending, err := r.replicateResourceToNamespaces(ctx, obj, replicateTo); err != nil {
+		return errors.Wrapf(err, "Replicated %s to %d out of %d namespaces (%d pending)",
+			cacheKey, len(replicated), len(replicateTo), len(pending),
 		)
 	}
 
 	return nil
 }
 
+// labelKeySelector selects all namespaces whose label labelKey references the
+// given source namespace.
+func labelKeySelector(labelKey string, sourceNamespace string) (labels.Selector, error) {
+	return labels.ValidatedSelectorFromSet(labels.Set{strings.TrimSpace(labelKey): sourceNamespace})
+}
+
+// releaseSelector selects all namespaces that belong to the given Helm release
+func releaseSelector(release string) (labels.Selector, error) {
+	return labels.ValidatedSelectorFromSet(labels.Set{HelmReleaseLabel: strings.TrimSpace(release)})
+}
+
+// creatorSelector selects all namespaces that were created by one of the
+// given comma-separated provisioners, according to their creator label
+func creatorSelector(creators string) (labels.Selector, error) {
+	var values []string
+	for _, creator := range strings.Split(creators, ",") {
+		if creator = strings.TrimSpace(creator); creator != "" {
+			values = append(values, creator)
+		}
+	}
+	if len(values) == 0 {
+		return nil, errors.Errorf("no creators given")
+	}
+
+	creatorLabel := Options.CreatorLabel
+	if creatorLabel == "" {
+		creatorLabel = DefaultCreatorLabel
+	}
+
+	requirement, err := labels.NewRequirement(creatorLabel, selection.In, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels.NewSelector().Add(*requirement), nil
+}
+
 func (r *GenericReplicator) replicateResourceToMatchingNamespacesByLabel(ctx context.Context, obj interface{}, selector labels.Selector) error {
 	cacheKey := MustGetKey(obj)
 
@@ -361,9 +1044,9 @@ func (r *GenericReplicator) replicateResourceToMatchingNamespacesByLabel(ctx con
 		return errors.Wrap(err, "error while listing namespaces by selector")
 	}
 
-	if replicated, err := r.replicateResourceToNamespaces(obj, namespaces.Items); err != nil {
-		return errors.Wrapf(err, "Replicated %s to %d out of %d namespaces",
-			cacheKey, len(replicated), len(namespaces.Items),
+	if replicated, pending, err := r.replicateResourceToNamespaces(ctx, obj, namespaces.Items); err != nil {
+		return errors.Wrapf(err, "Replicated %s to %d out of %d namespaces (%d pending)",
+			cacheKey, len(replicated), len(namespaces.Items), len(pending),
 		)
 	}
 
@@ -375,29 +1058,108 @@ func (r *GenericReplicator) replicateResourceToMatchingNamespacesByLabel(ctx con
 func (r *GenericReplicator) getNamespacesToReplicate(myNs string, patterns string, namespaces []v1.Namespace) []v1.Namespace {
 
 	replicateTo := make([]v1.Namespace, 0)
+	patternList := StringToNamespacePatterns(patterns)
 	for _, namespace := range namespaces {
-		for _, ns := range StringToPatternList(patterns) {
-			if matched := ns.MatchString(namespace.Name); matched {
-				if namespace.Name == myNs {
-					// Don't replicate upon itself
-					continue
-				}
-				replicateTo = append(replicateTo, namespace)
-				break
+		if namespace.Name == myNs {
+			// Don't replicate upon itself
+			continue
+		}
+		if matchesLogicalName(patternList, namespace.Name) {
+			replicateTo = append(replicateTo, namespace)
+		}
+	}
+	return replicateTo
+}
 
-			}
+// getNamespacesSelectedByRules returns all namespaces (except the source's own
+// namespace and the namespaces in except) that are selected by the
+// NamespaceSelector of a replication rule matching the source.
+func (r *GenericReplicator) getNamespacesSelectedByRules(object metav1.Object, namespaces []v1.Namespace, except []v1.Namespace) []v1.Namespace {
+	excluded := map[string]struct{}{object.GetNamespace(): {}}
+	for _, namespace := range except {
+		excluded[namespace.Name] = struct{}{}
+	}
+
+	selected := make([]v1.Namespace, 0)
+	for _, namespace := range namespaces {
+		if _, ok := excluded[namespace.Name]; ok {
+			continue
+		}
+		if replicationRules.SelectsNamespace(r.Kind, object, &namespace) {
+			selected = append(selected, namespace)
+		}
+	}
+	return selected
+}
+
+// getNamespacesMatchingExpression returns all namespaces (except the source's own
+// namespace) for which the given expression evaluates to true.
+func (r *GenericReplicator) getNamespacesMatchingExpression(myNs string, expression *NamespaceExpression, namespaces []v1.Namespace) []v1.Namespace {
+	replicateTo := make([]v1.Namespace, 0)
+	for i := range namespaces {
+		if namespaces[i].Name == myNs {
+			// Don't replicate upon itself
+			continue
+		}
+
+		matches, err := expression.Matches(&namespaces[i])
+		if err != nil {
+			log.WithField("kind", r.Kind).WithError(err).Warn("could not evaluate namespace expression")
+			continue
+		}
+
+		if matches {
+			replicateTo = append(replicateTo, namespaces[i])
 		}
 	}
 	return replicateTo
 }
 
 // replicateResourceToNamespaces will replicate the given object into target namespaces. It will return a list of
-// Namespaces it was successful in replicating into
-func (r *GenericReplicator) replicateResourceToNamespaces(obj interface{}, targets []v1.Namespace) (replicatedTo []v1.Namespace, err error) {
+// Namespaces it was successful in replicating into, and a list of namespaces whose write was delayed, batched or
+// requeued and is still pending
+func (r *GenericReplicator) replicateResourceToNamespaces(ctx context.Context, obj interface{}, targets []v1.Namespace) (replicatedTo []v1.Namespace, pending []v1.Namespace, err error) {
 	cacheKey := MustGetKey(obj)
+	delays := ParseReplicationDelays(MustGetObject(obj).GetAnnotations()[ReplicateDelay])
+
+	if len(targets) > 0 && (r.refuseGatedSource(obj) || r.refuseOversizedObject(obj) || r.refuseExcessiveFanout(obj)) {
+		return
+	}
 
 	for _, namespace := range targets {
-		if innerErr := r.UpdateFuncs.ReplicateObjectTo(obj, &namespace); innerErr != nil {
+		if r.refuseOptedOutNamespace(obj, &namespace) {
+			continue
+		}
+
+		if delay := delays.For(namespace.Name); delay > 0 {
+			logger := log.WithField("kind", r.Kind).WithField("source", cacheKey)
+			logger.Infof("Delaying replication of %s to %s by %s", cacheKey, namespace.Name, delay)
+			r.DelayQueue.AddAfter(delayedReplication{SourceKey: cacheKey, Namespace: namespace.Name}, delay)
+			pending = append(pending, namespace)
+			continue
+		}
+
+		if r.deferForDependency(obj, &namespace) {
+			pending = append(pending, namespace)
+			continue
+		}
+
+		if r.batchWrite(delayedReplication{SourceKey: cacheKey, Namespace: namespace.Name}) {
+			pending = append(pending, namespace)
+			continue
+		}
+
+		if r.refuseReplicationLoop(obj, fmt.Sprintf("%s/%s", namespace.Name, MustGetObject(obj).GetName())) {
+			continue
+		}
+
+		if innerErr := r.traceWrite(ctx, fmt.Sprintf("%s/%s", namespace.Name, MustGetObject(obj).GetName()), func() error {
+			return r.replicateObjectTo(obj, &namespace)
+		}); innerErr != nil {
+			if r.requeueIfThrottled(delayedReplication{SourceKey: cacheKey, Namespace: namespace.Name}, innerErr) {
+				pending = append(pending, namespace)
+				continue
+			}
 			err = multierror.Append(err, errors.Wrapf(innerErr, "Failed to replicate %s %s -> %s: %v",
 				r.Kind, cacheKey, namespace.Name, innerErr,
 			))
@@ -411,10 +1173,144 @@ func (r *GenericReplicator) replicateResourceToNamespaces(obj interface{}, targe
 	return
 }
 
-func (r *GenericReplicator) updateDependents(obj interface{}, dependents map[string]interface{}) error {
+// runDelayedReplications processes replications that were scheduled by a
+// "replicate-delay" annotation, requeued after being throttled or waiting for
+// their source to appear until the delay queue is shut down.
+func (r *GenericReplicator) runDelayedReplications() {
+	for {
+		item, shutdown := r.DelayQueue.Get()
+		if shutdown {
+			return
+		}
+
+		if refresh, ok := item.(delayedEndpointRefresh); ok {
+			r.refreshEndpointDelayed(refresh)
+			r.DelayQueue.Done(item)
+			continue
+		}
+
+		// delayed items are comparable, so items that are requeued during a
+		// maintenance window are deferred only once
+		r.whenWritable(item, func() {
+			switch item := item.(type) {
+			case delayedReplication:
+				r.replicateDelayed(item)
+			case delayedResync:
+				r.resyncDelayed(item)
+			case delayedDeletion:
+				r.deleteDelayed(item)
+			case delayedSourceLookup:
+				r.lookupSourceDelayed(item)
+			case delayedReconcile:
+				r.reconcileDelayed(item)
+			case delayedStabilityCheck:
+				r.checkStabilityDelayed(item)
+			case delayedBatchFlush:
+				r.flushBatch()
+			}
+		})
+		r.DelayQueue.Done(item)
+	}
+}
+
+// replicateDelayed replicates the latest version of the source into the target
+// namespace. The source is looked up again, so that changes made while the
+// replication was waiting in the queue are not lost.
+func (r *GenericReplicator) replicateDelayed(item delayedReplication) {
+	obj, namespace, ok := r.delayedTarget(item)
+	if !ok {
+		return
+	}
+
+	if err := r.writeDelayed(item, obj, namespace); err != nil {
+		log.WithField("kind", r.Kind).WithField("source", item.SourceKey).WithField("target", item.Namespace).WithError(err).
+			Errorf("Failed to replicate %s %s -> %s: %v", r.Kind, item.SourceKey, item.Namespace, err)
+	}
+}
+
+// delayedTarget looks up the latest version of the source of a delayed
+// replication and its target namespace, and checks that the replication still
+// applies. Since it reads the target maps, it must only run as an operation of
+// the replicator.
+func (r *GenericReplicator) delayedTarget(item delayedReplication) (obj interface{}, namespace *v1.Namespace, ok bool) {
+	logger := log.WithField("kind", r.Kind).WithField("source", item.SourceKey).WithField("target", item.Namespace)
+
+	_, isReplicateTo := r.ReplicateToList[item.SourceKey]
+	_, isReplicateToMatching := r.ReplicateToMatchingList[item.SourceKey]
+	_, isReplicateToLabelKey := r.ReplicateToLabelKeyList[item.SourceKey]
+	_, isReplicateToRelease := r.ReplicateToReleaseList[item.SourceKey]
+	_, isReplicateToCreator := r.ReplicateToCreatorList[item.SourceKey]
+	_, isReplicateToCEL := r.ReplicateToCELList[item.SourceKey]
+	_, isReplicateToURL := r.ReplicateToURLList[item.SourceKey]
+	_, isReplicateToConsumers := r.ReplicateToConsumersList[item.SourceKey]
+	if !isReplicateTo && !isReplicateToMatching && !isReplicateToLabelKey && !isReplicateToRelease && !isReplicateToCreator && !isReplicateToCEL && !isReplicateToURL && !isReplicateToConsumers {
+		logger.Debugf("%s %s is no longer replicated, dropping delayed replication", r.Kind, item.SourceKey)
+		return nil, nil, false
+	}
+
+	obj, exists, err := r.Store.GetByKey(item.SourceKey)
+	if err != nil {
+		logger.WithError(err).Error("error fetching object from store")
+		return nil, nil, false
+	} else if !exists {
+		logger.Debugf("%s %s does not exist any more, dropping delayed replication", r.Kind, item.SourceKey)
+		return nil, nil, false
+	}
+
+	nsObj, exists, err := namespaceWatcher.NamespaceStore.GetByKey(item.Namespace)
+	if err != nil {
+		logger.WithError(err).Error("error fetching namespace from store")
+		return nil, nil, false
+	} else if !exists {
+		logger.Debugf("namespace %s does not exist any more, dropping delayed replication", item.Namespace)
+		return nil, nil, false
+	}
+	namespace = nsObj.(*v1.Namespace)
+
+	// the annotations may have changed while the replication was waiting
+	if _, targeted := pushTargets(r.Kind, MustGetObject(obj), []v1.Namespace{*namespace})[item.Namespace]; !targeted {
+		logger.Debugf("%s %s does not target %s any more, dropping delayed replication", r.Kind, item.SourceKey, item.Namespace)
+		return nil, nil, false
+	}
+
+	if r.refuseGatedSource(obj) || r.refuseOversizedObject(obj) || r.refuseOptedOutNamespace(obj, namespace) ||
+		r.refuseReplicationLoop(obj, fmt.Sprintf("%s/%s", item.Namespace, MustGetObject(obj).GetName())) {
+		return nil, nil, false
+	}
+
+	if r.deferForDependency(obj, namespace) {
+		return nil, nil, false
+	}
+
+	return obj, namespace, true
+}
+
+// writeDelayed writes a delayed replication whose target was looked up by
+// delayedTarget. Throttled writes are requeued instead of failing.
+func (r *GenericReplicator) writeDelayed(item delayedReplication, obj interface{}, namespace *v1.Namespace) error {
+	if err := r.traceWrite(context.Background(), fmt.Sprintf("%s/%s", item.Namespace, MustGetObject(obj).GetName()), func() error {
+		return r.replicateObjectTo(obj, namespace)
+	}); err != nil {
+		if r.requeueIfThrottled(item, err) {
+			return nil
+		}
+		return err
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", item.SourceKey).WithField("target", item.Namespace).
+		Infof("Replicated %s to: %v", item.SourceKey, item.Namespace)
+	return nil
+}
+
+func (r *GenericReplicator) updateDependents(ctx context.Context, obj interface{}, dependents map[string]interface{}) error {
+	var failures *multierror.Error
 	cacheKey := MustGetKey(obj)
 	logger := log.WithField("kind", r.Kind).WithField("source", cacheKey)
 
+	if len(dependents) > 0 && r.refuseOversizedObject(obj) {
+		return nil
+	}
+
 	for dependentKey := range dependents {
 		logger.Infof("updating dependent %s %s -> %s", r.Kind, cacheKey, dependentKey)
 
@@ -427,12 +1323,23 @@ func (r *GenericReplicator) updateDependents(obj interface{}, dependents map[str
 			continue
 		}
 
-		if err := r.UpdateFuncs.ReplicateDataFrom(obj, targetObject); err != nil {
-			return errors.WithStack(err)
+		if r.refuseReplicationLoop(obj, dependentKey) {
+			continue
+		}
+
+		if err := r.traceWrite(ctx, dependentKey, func() error {
+			return r.UpdateFuncs.ReplicateDataFrom(obj, targetObject)
+		}); err != nil {
+			if r.requeueIfThrottled(delayedResync{Key: dependentKey}, err) {
+				continue
+			}
+			// a dependent that is not permitted must not keep the others
+			// from being updated, e.g. after the source granted them access
+			failures = multierror.Append(failures, errors.WithStack(err))
 		}
 	}
 
-	return nil
+	return failures.ErrorOrNil()
 }
 
 // ObjectFromStore gets object from store cache
@@ -453,28 +1360,42 @@ func (r *GenericReplicator) ObjectFromStore(key string) (interface{}, error) {
 func (r *GenericReplicator) ResourceDeleted(source interface{}) {
 	sourceKey := MustGetKey(source)
 	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
+
+	if !InEnvironment(MustGetObject(source)) {
+		logger.Debugf("%s %s is not in environment %s, ignoring its deletion", r.Kind, sourceKey, Options.Environment)
+		return
+	}
+
 	logger.Debugf("Deleting %s %s", r.Kind, sourceKey)
 
 	r.ResourceDeletedReplicateTo(source)
 	r.ResourceDeletedReplicateFrom(source)
 
-	delete(r.ReplicateToList, sourceKey)
+	if r.UpdateFuncs.OnResourceDeleted != nil {
+		r.UpdateFuncs.OnResourceDeleted(source)
+	}
 
+	r.forgetSource(sourceKey)
+	r.forgetReconcileInterval(sourceKey)
+	r.forgetStability(sourceKey)
+	r.forgetSize(sourceKey)
+	r.forgetDependencyWaits(sourceKey)
+	r.Quarantine.Reset(sourceKey)
 }
 
 func (r *GenericReplicator) ResourceDeletedReplicateTo(source interface{}) {
 	sourceKey := MustGetKey(source)
 	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
 	objMeta := MustGetObject(source)
-	namespaceList, replicateTo := objMeta.GetAnnotations()[ReplicateTo]
+	namespaceList, replicateTo := replicateToPatterns(r.Kind, objMeta, objMeta.GetAnnotations())
 	if replicateTo {
-		filters := strings.Split(namespaceList, ",")
+		patterns := StringToNamespacePatterns(namespaceList)
 		list, err := r.Client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
 		if err != nil {
 			err = errors.Wrapf(err, "Failed to list namespaces: %v", err)
 			logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
 		} else {
-			r.DeleteResources(source, list, filters)
+			r.DeleteResources(source, list, patterns)
 		}
 	}
 
@@ -496,15 +1417,91 @@ func (r *GenericReplicator) ResourceDeletedReplicateTo(source interface{}) {
 			}
 		}
 	}
+
+	// delete replicated resources in namespaces that reference the source's namespace
+	labelKey, replicateToLabelKey := objMeta.GetAnnotations()[ReplicateToLabelKey]
+	if replicateToLabelKey {
+		namespaceSelector, err := labelKeySelector(labelKey, objMeta.GetNamespace())
+		if err != nil {
+			logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+		} else {
+			namespaces, err := r.Client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{LabelSelector: namespaceSelector.String()})
+			if err != nil {
+				err = errors.Wrapf(err, "Failed to list namespaces: %v", err)
+				logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+			} else {
+				r.DeleteResourceInNamespaces(source, namespaces)
+			}
+		}
+	}
+
+	// delete replicated resources in namespaces that belong to the release
+	release, replicateToRelease := objMeta.GetAnnotations()[ReplicateToRelease]
+	if replicateToRelease {
+		namespaceSelector, err := releaseSelector(release)
+		if err != nil {
+			logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+		} else {
+			namespaces, err := r.Client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{LabelSelector: namespaceSelector.String()})
+			if err != nil {
+				err = errors.Wrapf(err, "Failed to list namespaces: %v", err)
+				logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+			} else {
+				r.DeleteResourceInNamespaces(source, namespaces)
+			}
+		}
+	}
+
+	// delete replicated resources in namespaces that were created by the creators
+	creators, replicateToCreator := objMeta.GetAnnotations()[ReplicateToCreator]
+	if replicateToCreator {
+		namespaceSelector, err := creatorSelector(creators)
+		if err != nil {
+			logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+		} else {
+			namespaces, err := r.Client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{LabelSelector: namespaceSelector.String()})
+			if err != nil {
+				err = errors.Wrapf(err, "Failed to list namespaces: %v", err)
+				logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+			} else {
+				r.DeleteResourceInNamespaces(source, namespaces)
+			}
+		}
+	}
+
+	// delete replicated resources in namespaces that match the expression
+	expressionString, replicateToCEL := objMeta.GetAnnotations()[ReplicateToCEL]
+	if replicateToCEL {
+		expression, err := ParseNamespaceExpression(expressionString)
+		if err != nil {
+			logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
+		} else {
+			namespaces := r.getNamespacesMatchingExpression(objMeta.GetNamespace(), expression, namespacesFromStore())
+			r.DeleteResourceInNamespaces(source, &v1.NamespaceList{Items: namespaces})
+		}
+	}
+
+	// delete replicated resources in namespaces that were listed by the endpoint
+	if url, replicateToURL := objMeta.GetAnnotations()[ReplicateToURL]; replicateToURL {
+		if listed, ok := cachedEndpointNamespaces(url); ok {
+			namespaces := getNamespacesListedByEndpoint(objMeta.GetNamespace(), listed, namespacesFromStore())
+			r.DeleteResourceInNamespaces(source, &v1.NamespaceList{Items: namespaces})
+		}
+	}
+
+	// delete replicated resources in namespaces that consume the secret
+	if replicatesToConsumers(objMeta) && r.Kind == "Secret" {
+		if consumers, ok := secretConsumerNamespaces(objMeta.GetName()); ok {
+			namespaces := getNamespacesListedByEndpoint(objMeta.GetNamespace(), consumers, namespacesFromStore())
+			r.DeleteResourceInNamespaces(source, &v1.NamespaceList{Items: namespaces})
+		}
+	}
 }
 
-func (r *GenericReplicator) DeleteResources(source interface{}, list *v1.NamespaceList, filters []string) {
+func (r *GenericReplicator) DeleteResources(source interface{}, list *v1.NamespaceList, patterns NamespacePatterns) {
 	for _, namespace := range list.Items {
-		for _, ns := range filters {
-			ns = strings.TrimSpace(ns)
-			if matched, _ := regexp.MatchString(ns, namespace.Name); matched {
-				r.DeleteResource(namespace, source)
-			}
+		if matchesLogicalName(patterns, namespace.Name) || replicationRules.SelectsNamespace(r.Kind, MustGetObject(source), &namespace) {
+			r.DeleteResource(namespace, source)
 		}
 	}
 }
@@ -517,26 +1514,38 @@ func (r *GenericReplicator) DeleteResourceInNamespaces(source interface{}, list
 }
 
 func (r *GenericReplicator) DeleteResource(namespace v1.Namespace, source interface{}) {
-	sourceKey := MustGetKey(source)
-
-	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
 	objMeta := MustGetObject(source)
 
 	if namespace.Name == objMeta.GetNamespace() {
 		// Don't work upon itself
 		return
 	}
-	targetLocation := fmt.Sprintf("%s/%s", namespace.Name, objMeta.GetName())
+	for _, targetLocation := range replicaKeys(objMeta, namespace.Name) {
+		r.deleteReplica(objMeta, targetLocation)
+	}
+}
+
+// deleteReplica releases the replica of a source with the given key. Objects
+// that were not written by the replicator are left alone, since they only
+// have the name of the source in common with it.
+func (r *GenericReplicator) deleteReplica(objMeta metav1.Object, targetLocation string) {
+	sourceKey := MustGetKey(objMeta)
+	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
+
 	targetResource, exists, err := r.Store.GetByKey(targetLocation)
 	if err != nil {
 		logger.WithError(err).Errorf("Could not get objectMeta %s: %+v", targetLocation, err)
 		return
 	}
-	if !exists {
+	if !exists || isForeignObject(MustGetObject(targetResource)) {
 		return
 	}
-	if err := r.UpdateFuncs.DeleteReplicatedResource(targetResource); err != nil {
-		logger.WithError(err).Errorf("Could not delete resource %s: %+v", targetLocation, err)
+	mode := onSourceDelete(objMeta, OnSourceDeleteCascade)
+	if err := r.releaseReplica(sourceKey, mode, targetResource); err != nil {
+		if mode == OnSourceDeleteCascade && r.requeueIfThrottled(newDelayedDeletion(sourceKey, targetResource), err) {
+			return
+		}
+		logger.WithError(err).Errorf("Could not %s resource %s: %+v", mode, targetLocation, err)
 	}
 }
 
@@ -550,19 +1559,15 @@ func (r *GenericReplicator) ResourceDeletedReplicateFrom(source interface{}) {
 		return
 	}
 
+	mode := onSourceDelete(MustGetObject(source), OnSourceDeleteClear)
 	for dependentKey := range replicas {
 		target, err := r.ObjectFromStore(dependentKey)
 		if err != nil {
 			logger.WithError(err).Warnf("could not load dependent %s %s: %v", r.Kind, dependentKey, err)
 			continue
 		}
-		s, err := r.UpdateFuncs.PatchDeleteDependent(sourceKey, target)
-		if err != nil {
-			logger.WithError(err).Warnf("could not patch dependent %s %s: %v", r.Kind, dependentKey, err)
-			continue
-		}
-		if err := r.Store.Update(s); err != nil {
-			logger.WithError(err).Errorf("Error updating store for %s %s: %v", r.Kind, MustGetKey(s), err)
+		if err := r.releaseReplica(sourceKey, mode, target); err != nil {
+			logger.WithError(err).Warnf("could not %s dependent %s %s: %v", mode, r.Kind, dependentKey, err)
 		}
 	}
 }
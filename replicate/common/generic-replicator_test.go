@@ -0,0 +1,137 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// newTestReplicator creates a replicator of the given kind with
+// NewGenericReplicator, using a fake client and the namespaces of
+// namespaceWatcher.NamespaceStore instead of a namespace watcher. Its events
+// are recorded by a fake recorder. The callbacks it registers and its
+// registration as replicator of the kind are dropped when the test ends.
+func newTestReplicator(t testing.TB, kind string) *GenericReplicator {
+	t.Helper()
+
+	// keep the namespace store set by the test instead of watching namespaces
+	namespaceWatcher.doOnce.Do(func() {})
+
+	addFuncs, updateFuncs, deleteFuncs := namespaceWatcher.AddFuncs, namespaceWatcher.UpdateFuncs, namespaceWatcher.DeleteFuncs
+	rulesChangedFuncs := replicationRules.ChangedFuncs
+	gatesChangedFuncs := featureGates.ChangedFuncs
+	mappingChangedFuncs := namespaceMapping.ChangedFuncs
+	consumersChangedFuncs := secretConsumers.ChangedFuncs
+	previous, registered := replicatorForKind(kind)
+
+	r := NewGenericReplicator(ReplicatorConfig{Kind: kind, Client: fake.NewSimpleClientset()})
+	r.Recorder = record.NewFakeRecorder(100)
+	t.Cleanup(r.DelayQueue.ShutDown)
+
+	t.Cleanup(func() {
+		namespaceWatcher.AddFuncs, namespaceWatcher.UpdateFuncs, namespaceWatcher.DeleteFuncs = addFuncs, updateFuncs, deleteFuncs
+		replicationRules.ChangedFuncs = rulesChangedFuncs
+		featureGates.ChangedFuncs = gatesChangedFuncs
+		namespaceMapping.ChangedFuncs = mappingChangedFuncs
+		secretConsumers.ChangedFuncs = consumersChangedFuncs
+
+		replicatorsByKind.lock.Lock()
+		defer replicatorsByKind.lock.Unlock()
+		if registered {
+			replicatorsByKind.replicators[kind] = previous
+		} else {
+			delete(replicatorsByKind.replicators, kind)
+		}
+	})
+
+	return r
+}
+
+func TestIsReplicationPermitted(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "a"}}}))
+	require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}))
+
+	r := newTestReplicator(t, "Secret")
+
+	source := func(annotations map[string]string) *metav1.ObjectMeta {
+		annotations[ReplicationAllowed] = "true"
+		return &metav1.ObjectMeta{Name: "source", Namespace: "default", Annotations: annotations}
+	}
+	target := func(namespace string) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Name: "target", Namespace: namespace}
+	}
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		namespace   string
+		allowed     bool
+	}{
+		{"exact list allows listed namespace", map[string]string{ReplicationAllowedNamespaces: "other,team-a"}, "team-a", true},
+		{"exact list refuses other namespace", map[string]string{ReplicationAllowedNamespaces: "other"}, "team-a", false},
+		{"regex allows matching namespace", map[string]string{ReplicationAllowedNamespaces: "team-.*"}, "team-a", true},
+		{"regex refuses namespace matching partially", map[string]string{ReplicationAllowedNamespaces: "team"}, "team-a", false},
+		{"selector allows matching namespace", map[string]string{ReplicationAllowedMatching: "team=a"}, "team-a", true},
+		{"selector refuses other namespace", map[string]string{ReplicationAllowedMatching: "team=a"}, "other", false},
+		{"selector refuses unknown namespace", map[string]string{ReplicationAllowedMatching: "team=a"}, "unknown", false},
+		{"list and selector are combined", map[string]string{ReplicationAllowedNamespaces: "other", ReplicationAllowedMatching: "team"}, "team-a", true},
+		{"missing annotations refuse", map[string]string{}, "team-a", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			allowed, err := r.IsReplicationPermitted(target(c.namespace), source(c.annotations))
+			assert.Equal(t, c.allowed, allowed)
+			if c.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	t.Run("invalid selector is an error", func(t *testing.T) {
+		allowed, err := r.IsReplicationPermitted(target("team-a"), source(map[string]string{ReplicationAllowedMatching: "not a selector!"}))
+		assert.False(t, allowed)
+		assert.Error(t, err)
+	})
+}
+
+func TestNamespaceDeletedReplicatesTargetingSources(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "team-b"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	r := newTestReplicator(t, "Secret")
+	r.ReplicateToList = map[string]struct{}{"default/teams": {}, "default/other": {}}
+	r.Quarantine.Threshold = 1
+
+	var replicated []string
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		replicated = append(replicated, MustGetKey(source)+" -> "+target.Name)
+		return nil
+	}
+
+	teams := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "teams", ResourceVersion: "1", Annotations: map[string]string{ReplicateTo: "team-.*"}}}
+	other := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other", ResourceVersion: "1", Annotations: map[string]string{ReplicateTo: "other"}}}
+	require.NoError(t, r.Store.Add(teams))
+	require.NoError(t, r.Store.Add(other))
+
+	// replicating into the terminating namespace failed before
+	assert.True(t, r.Quarantine.RecordFailure("default/teams", "1"))
+
+	r.NamespaceDeleted(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+
+	assert.Equal(t, []string{"default/teams -> team-b"}, replicated)
+	assert.False(t, r.Quarantine.IsQuarantined("default/teams", "1"))
+}
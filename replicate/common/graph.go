@@ -0,0 +1,204 @@
+package common
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Replication is a single edge of the replication graph
+type Replication struct {
+	Kind         string `json:"kind"`
+	Source       string `json:"source"`
+	Target       string `json:"target"`
+	ReplicatedAt string `json:"replicatedAt,omitempty"`
+}
+
+// Replications returns all replications between the given objects of a kind.
+// Targets of "replicate-from" annotations are found by their annotation; pushed
+// copies are matched to the source with the same name (or suffixed name) that
+// targets their namespace.
+func Replications(kind string, objects []metav1.Object, namespaces []v1.Namespace) []Replication {
+	replications := make([]Replication, 0)
+	sourcesByName := make(map[string][]metav1.Object)
+
+	for _, object := range objects {
+		if pushTargets(kind, object, namespaces) != nil {
+			sourcesByName[object.GetName()] = append(sourcesByName[object.GetName()], object)
+			suffixed := SuffixedReplicaName(object)
+			sourcesByName[suffixed] = append(sourcesByName[suffixed], object)
+		}
+	}
+
+	// replicas targeted by several sources are attributed to the same source,
+	// regardless of the order of the objects
+	for _, sources := range sourcesByName {
+		sort.Slice(sources, func(i, j int) bool {
+			return MustGetKey(sources[i]) < MustGetKey(sources[j])
+		})
+	}
+
+	for _, object := range objects {
+		annotations := object.GetAnnotations()
+		replicatedAt, replicated := annotations[ReplicatedAtAnnotation]
+
+		if source, ok, err := ReplicateFrom(object); ok {
+			if err != nil {
+				continue
+			}
+			replications = append(replications, Replication{
+				Kind:         kind,
+				Source:       source.String(),
+				Target:       MustGetKey(object),
+				ReplicatedAt: replicatedAt,
+			})
+			continue
+		}
+
+		if !replicated {
+			continue
+		}
+
+		for _, source := range sourcesByName[object.GetName()] {
+			if source.GetNamespace() == object.GetNamespace() {
+				continue
+			}
+			if _, ok := pushTargets(kind, source, namespaces)[object.GetNamespace()]; ok {
+				replications = append(replications, Replication{
+					Kind:         kind,
+					Source:       MustGetKey(source),
+					Target:       MustGetKey(object),
+					ReplicatedAt: replicatedAt,
+				})
+				break
+			}
+		}
+	}
+
+	sort.Slice(replications, func(i, j int) bool {
+		if replications[i].Source != replications[j].Source {
+			return replications[i].Source < replications[j].Source
+		}
+		return replications[i].Target < replications[j].Target
+	})
+
+	return replications
+}
+
+// pushTargets returns the names of all namespaces the object is pushed to, or
+// nil if the object is not a source of push-based replication.
+func pushTargets(kind string, object metav1.Object, namespaces []v1.Namespace) map[string]struct{} {
+	annotations := object.GetAnnotations()
+	var targets map[string]struct{}
+
+	add := func(namespace string) {
+		if targets == nil {
+			targets = make(map[string]struct{})
+		}
+		if namespace != object.GetNamespace() {
+			targets[namespace] = struct{}{}
+		}
+	}
+
+	if patterns, ok := replicateToPatterns(kind, object, annotations); ok {
+		add(object.GetNamespace())
+		patternList := StringToNamespacePatterns(patterns)
+		for _, ns := range namespaces {
+			if matchesLogicalName(patternList, ns.Name) || replicationRules.SelectsNamespace(kind, object, &ns) {
+				add(ns.Name)
+			}
+		}
+	}
+
+	if selectorString, ok := annotations[ReplicateToMatching]; ok {
+		add(object.GetNamespace())
+		if selector, err := labels.Parse(selectorString); err == nil {
+			for _, ns := range namespaces {
+				if selector.Matches(labels.Set(ns.Labels)) {
+					add(ns.Name)
+				}
+			}
+		}
+	}
+
+	if labelKey, ok := annotations[ReplicateToLabelKey]; ok {
+		add(object.GetNamespace())
+		if selector, err := labelKeySelector(labelKey, object.GetNamespace()); err == nil {
+			for _, ns := range namespaces {
+				if selector.Matches(labels.Set(ns.Labels)) {
+					add(ns.Name)
+				}
+			}
+		}
+	}
+
+	if release, ok := annotations[ReplicateToRelease]; ok {
+		add(object.GetNamespace())
+		if selector, err := releaseSelector(release); err == nil {
+			for _, ns := range namespaces {
+				if selector.Matches(labels.Set(ns.Labels)) {
+					add(ns.Name)
+				}
+			}
+		}
+	}
+
+	if creators, ok := annotations[ReplicateToCreator]; ok {
+		add(object.GetNamespace())
+		if selector, err := creatorSelector(creators); err == nil {
+			for _, ns := range namespaces {
+				if selector.Matches(labels.Set(ns.Labels)) {
+					add(ns.Name)
+				}
+			}
+		}
+	}
+
+	if expressionString, ok := annotations[ReplicateToCEL]; ok {
+		add(object.GetNamespace())
+		if expression, err := ParseNamespaceExpression(expressionString); err == nil {
+			for i := range namespaces {
+				if matches, err := expression.Matches(&namespaces[i]); err == nil && matches {
+					add(namespaces[i].Name)
+				}
+			}
+		}
+	}
+
+	if url, ok := annotations[ReplicateToURL]; ok {
+		add(object.GetNamespace())
+		if listed, ok := cachedEndpointNamespaces(url); ok {
+			for _, ns := range namespaces {
+				if _, ok := listed[ns.Name]; ok {
+					add(ns.Name)
+				}
+			}
+		}
+	}
+
+	if replicatesToConsumers(object) {
+		add(object.GetNamespace())
+		if consumers, ok := secretConsumerNamespaces(object.GetName()); ok && kind == "Secret" {
+			for _, ns := range namespaces {
+				if _, ok := consumers[ns.Name]; ok {
+					add(ns.Name)
+				}
+			}
+		}
+	}
+
+	return targets
+}
+
+// Replications returns all replications of this replicator's kind, based on
+// the objects in its cache.
+func (r *GenericReplicator) Replications() []Replication {
+	objects := make([]metav1.Object, 0)
+	for _, obj := range r.Store.List() {
+		objects = append(objects, MustGetObject(obj))
+	}
+
+	return Replications(r.Kind, objects, namespacesFromStore())
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestReplications(t *testing.T) {
+	namespaces := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"distribute-to": "default"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "shop-prod", Labels: map[string]string{HelmReleaseLabel: "shop"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "provisioned", Labels: map[string]string{DefaultCreatorLabel: "platform"}}},
+	}
+
+	object := func(namespace string, name string, annotations map[string]string) metav1.Object {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations}}
+	}
+
+	objects := []metav1.Object{
+		object("default", "pushed", map[string]string{ReplicateTo: "team-.*"}),
+		object("team-a", "pushed", map[string]string{ReplicatedAtAnnotation: "2026-10-16T12:00:00Z"}),
+		object("other", "pushed", map[string]string{ReplicatedAtAnnotation: "2026-10-16T12:00:00Z"}),
+		object("default", "labelled", map[string]string{ReplicateToMatching: "team=true"}),
+		object("team-a", "labelled", map[string]string{ReplicatedAtAnnotation: "2026-10-16T13:00:00Z"}),
+		object("default", "pulled", nil),
+		object("other", "pulled", map[string]string{ReplicateFromAnnotation: "default/pulled", ReplicatedAtAnnotation: "2026-10-16T14:00:00Z"}),
+		object("team-a", "unrelated", nil),
+		object("default", "subscribed", map[string]string{ReplicateToLabelKey: "distribute-to"}),
+		object("other", "subscribed", map[string]string{ReplicatedAtAnnotation: "2026-10-16T15:00:00Z"}),
+		object("team-a", "subscribed", map[string]string{ReplicatedAtAnnotation: "2026-10-16T15:00:00Z"}),
+		object("default", "released", map[string]string{ReplicateToRelease: "shop"}),
+		object("shop-prod", "released", map[string]string{ReplicatedAtAnnotation: "2026-10-16T16:00:00Z"}),
+		object("default", "provisioned", map[string]string{ReplicateToCreator: "platform"}),
+		object("provisioned", "provisioned", map[string]string{ReplicatedAtAnnotation: "2026-10-16T17:00:00Z"}),
+	}
+
+	assert.Equal(t, []Replication{
+		{Kind: "Secret", Source: "default/labelled", Target: "team-a/labelled", ReplicatedAt: "2026-10-16T13:00:00Z"},
+		{Kind: "Secret", Source: "default/provisioned", Target: "provisioned/provisioned", ReplicatedAt: "2026-10-16T17:00:00Z"},
+		{Kind: "Secret", Source: "default/pulled", Target: "other/pulled", ReplicatedAt: "2026-10-16T14:00:00Z"},
+		{Kind: "Secret", Source: "default/pushed", Target: "team-a/pushed", ReplicatedAt: "2026-10-16T12:00:00Z"},
+		{Kind: "Secret", Source: "default/released", Target: "shop-prod/released", ReplicatedAt: "2026-10-16T16:00:00Z"},
+		{Kind: "Secret", Source: "default/subscribed", Target: "other/subscribed", ReplicatedAt: "2026-10-16T15:00:00Z"},
+	}, Replications("Secret", objects, namespaces))
+}
+
+func TestReplicationsDoNotDependOnObjectOrder(t *testing.T) {
+	namespaces := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "infra"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+	}
+
+	objects := []metav1.Object{
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shared", Annotations: map[string]string{ReplicateTo: "team-a"}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "shared", Annotations: map[string]string{ReplicateTo: "team-.*"}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shared", Annotations: map[string]string{ReplicatedAtAnnotation: "2026-10-16T12:00:00Z"}}},
+	}
+	expected := Replications("Secret", objects, namespaces)
+	assert.Equal(t, "default/shared", expected[0].Source)
+
+	reversed := []metav1.Object{objects[2], objects[1], objects[0]}
+	assert.Equal(t, expected, Replications("Secret", reversed, namespaces))
+}
+
+func TestLabelKeySelector(t *testing.T) {
+	selector, err := labelKeySelector("distribute-to", "shared")
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{"distribute-to": "shared"}))
+	assert.False(t, selector.Matches(labels.Set{"distribute-to": "other"}))
+	assert.False(t, selector.Matches(labels.Set{}))
+
+	_, err = labelKeySelector("not a valid key", "shared")
+	assert.Error(t, err)
+}
+
+func TestReleaseSelector(t *testing.T) {
+	selector, err := releaseSelector("shop")
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{HelmReleaseLabel: "shop", "team": "a"}))
+	assert.False(t, selector.Matches(labels.Set{HelmReleaseLabel: "blog"}))
+	assert.False(t, selector.Matches(labels.Set{}))
+
+	_, err = releaseSelector("not a valid release")
+	assert.Error(t, err)
+}
+
+func TestCreatorSelector(t *testing.T) {
+	defer func(label string) { Options.CreatorLabel = label }(Options.CreatorLabel)
+
+	selector, err := creatorSelector("platform, onboarding")
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{DefaultCreatorLabel: "platform"}))
+	assert.True(t, selector.Matches(labels.Set{DefaultCreatorLabel: "onboarding"}))
+	assert.False(t, selector.Matches(labels.Set{DefaultCreatorLabel: "someone-else"}))
+	assert.False(t, selector.Matches(labels.Set{}))
+
+	Options.CreatorLabel = "provisioner"
+	selector, err = creatorSelector("platform")
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{"provisioner": "platform"}))
+	assert.False(t, selector.Matches(labels.Set{DefaultCreatorLabel: "platform"}))
+
+	_, err = creatorSelector(" , ")
+	assert.Error(t, err)
+	_, err = creatorSelector("not a valid creator")
+	assert.Error(t, err)
+}
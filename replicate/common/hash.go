@@ -0,0 +1,62 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SourceHash calculates a SHA256 checksum of the given content. The content is
+// serialized as JSON, which sorts map keys, so the checksum does not depend on
+// the order of map iteration.
+func SourceHash(content ...interface{}) (string, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SetSourceHash sets the SourceHashAnnotation of a replica to the checksum of
+// the content it received from its source, if enabled by Options.SourceHash.
+func SetSourceHash(annotations map[string]string, content ...interface{}) {
+	if !Options.SourceHash {
+		delete(annotations, SourceHashAnnotation)
+		return
+	}
+
+	hash, err := SourceHash(content...)
+	if err != nil {
+		log.WithError(err).Warn("could not calculate source hash")
+		delete(annotations, SourceHashAnnotation)
+		return
+	}
+
+	annotations[SourceHashAnnotation] = hash
+}
+
+// BinaryMapSubset returns the entries of data with the given keys
+func BinaryMapSubset(data map[string][]byte, keys []string) map[string][]byte {
+	subset := make(map[string][]byte)
+	for _, key := range keys {
+		if value, ok := data[key]; ok {
+			subset[key] = value
+		}
+	}
+	return subset
+}
+
+// StringMapSubset returns the entries of data with the given keys
+func StringMapSubset(data map[string]string, keys []string) map[string]string {
+	subset := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := data[key]; ok {
+			subset[key] = value
+		}
+	}
+	return subset
+}
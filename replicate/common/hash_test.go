@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceHashIsStable(t *testing.T) {
+	data := make(map[string][]byte)
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		data[key] = []byte("value-" + key)
+	}
+
+	expected, err := SourceHash("Opaque", data)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		copied := make(map[string][]byte)
+		for key, value := range data {
+			copied[key] = value
+		}
+
+		hash, err := SourceHash("Opaque", copied)
+		require.NoError(t, err)
+		assert.Equal(t, expected, hash)
+	}
+
+	other, err := SourceHash("kubernetes.io/tls", data)
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, other, "the type is part of the hash")
+}
+
+func TestSetSourceHash(t *testing.T) {
+	defer func(enabled bool) { Options.SourceHash = enabled }(Options.SourceHash)
+
+	annotations := map[string]string{}
+
+	Options.SourceHash = true
+	SetSourceHash(annotations, "content")
+	assert.Len(t, annotations[SourceHashAnnotation], 64)
+
+	Options.SourceHash = false
+	SetSourceHash(annotations, "content")
+	assert.NotContains(t, annotations, SourceHashAnnotation)
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newInformer creates an informer for the given kind that reports broken watch
+// connections and relists as metrics. With a ListPageSize, objects are listed
+// in pages of that size.
+func newInformer(kind string, lw *cache.ListWatch, objType runtime.Object, resyncPeriod time.Duration, handler cache.ResourceEventHandler) (cache.Store, cache.Controller) {
+	logger := log.WithField("kind", kind)
+
+	listed := false
+	listFunc := lw.ListFunc
+	lw.ListFunc = func(lo metav1.ListOptions) (runtime.Object, error) {
+		// follow-up pages of a paginated list carry a continue token
+		if lo.Continue == "" {
+			if listed {
+				logger.Debugf("relisting %ss", kind)
+				RelistTotal.WithLabelValues(kind).Inc()
+			}
+			listed = true
+		}
+
+		// shared informers don't expose the WatchListPageSize of their
+		// reflector, but its pager follows the continue tokens of any page
+		if Options.ListPageSize > 0 {
+			lo.Limit = Options.ListPageSize
+			// the watch cache of the API server ignores the limit of lists at
+			// resource version 0 and returns all objects at once
+			if lo.ResourceVersion == "0" {
+				lo.ResourceVersion = ""
+			}
+		}
+
+		return listFunc(lo)
+	}
+
+	informer := cache.NewSharedIndexInformer(lw, objType, resyncPeriod, cache.Indexers{})
+	informer.AddEventHandler(handler)
+
+	err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		if errors.Is(err, io.EOF) {
+			// watch closed normally
+			logger.Debugf("watch of %ss closed", kind)
+			return
+		}
+
+		WatchErrorsTotal.WithLabelValues(kind).Inc()
+		logger.WithError(err).Warnf("watch of %ss failed: %v", kind, err)
+	})
+	if err != nil {
+		// only happens if the informer has already been started
+		logger.WithError(err).Error("could not set watch error handler")
+	}
+
+	return informer.GetIndexer(), informer
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInformerListsInPages(t *testing.T) {
+	defer func(size int64) { Options.ListPageSize = size }(Options.ListPageSize)
+	Options.ListPageSize = 2
+
+	secrets := make([]v1.Secret, 5)
+	for i := range secrets {
+		secrets[i] = v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("secret-%d", i)}}
+	}
+
+	var lock sync.Mutex
+	var requests []metav1.ListOptions
+	lw := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			lock.Lock()
+			requests = append(requests, lo)
+			lock.Unlock()
+
+			start := 0
+			if lo.Continue != "" {
+				_, _ = fmt.Sscanf(lo.Continue, "%d", &start)
+			}
+			end := start + int(lo.Limit)
+			list := &v1.SecretList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}
+			if lo.Limit == 0 || end >= len(secrets) {
+				end = len(secrets)
+			} else {
+				list.Continue = fmt.Sprintf("%d", end)
+			}
+			list.Items = secrets[start:end]
+			return list, nil
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+
+	store, controller := newInformer("PagedSecret", lw, &v1.Secret{}, time.Hour, cache.ResourceEventHandlerFuncs{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+	require.True(t, cache.WaitForCacheSync(stop, controller.HasSynced))
+
+	assert.Len(t, store.List(), len(secrets))
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, requests, 3)
+	for _, request := range requests {
+		assert.Equal(t, int64(2), request.Limit)
+		assert.NotEqual(t, "0", request.ResourceVersion, "lists are not served by the watch cache")
+	}
+	assert.Equal(t, "", requests[0].Continue)
+	assert.Equal(t, "2", requests[1].Continue)
+	assert.Equal(t, "4", requests[2].Continue)
+}
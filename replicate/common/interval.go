@@ -0,0 +1,103 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// delayedReconcile is put into the delay queue to reconcile a resource whose
+// minimum interval between reconciles has not passed yet
+type delayedReconcile struct {
+	Key string
+}
+
+// reconcileIntervals tracks when resources were last reconciled, and which
+// resources have a deferred reconcile in the delay queue
+type reconcileIntervals struct {
+	lock       sync.Mutex
+	reconciled map[string]time.Time
+	pending    map[string]struct{}
+}
+
+// minReconcileInterval returns the minimum interval between two reconciles of
+// a resource. The MinReconcileInterval annotation of the resource overrides
+// Options.MinReconcileInterval.
+func minReconcileInterval(object metav1.Object) time.Duration {
+	if value, ok := object.GetAnnotations()[MinReconcileInterval]; ok {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval >= 0 {
+			return interval
+		}
+		log.WithField("resource", MustGetKey(object)).Warnf("invalid %s annotation '%s', using the default", MinReconcileInterval, value)
+	}
+
+	return Options.MinReconcileInterval
+}
+
+// deferReconcile checks if a resource was reconciled less than its minimum
+// interval ago. If so, a reconcile is scheduled for when the interval has
+// passed, unless one is scheduled already, and true is returned. Since the
+// deferred reconcile processes the latest version of the resource, all
+// changes made in the meantime are coalesced into it.
+func (r *GenericReplicator) deferReconcile(object metav1.Object) bool {
+	key := MustGetKey(object)
+	interval := minReconcileInterval(object)
+	now := time.Now()
+
+	r.intervals.lock.Lock()
+	defer r.intervals.lock.Unlock()
+
+	if interval <= 0 {
+		delete(r.intervals.reconciled, key)
+		return false
+	}
+
+	if r.intervals.reconciled == nil {
+		r.intervals.reconciled = make(map[string]time.Time)
+		r.intervals.pending = make(map[string]struct{})
+	}
+
+	if _, pending := r.intervals.pending[key]; pending {
+		DeferredReconcilesTotal.WithLabelValues(r.Kind).Inc()
+		return true
+	}
+
+	if last, ok := r.intervals.reconciled[key]; ok && now.Sub(last) < interval {
+		r.intervals.pending[key] = struct{}{}
+		r.DelayQueue.AddAfter(delayedReconcile{Key: key}, interval-now.Sub(last))
+		DeferredReconcilesTotal.WithLabelValues(r.Kind).Inc()
+		return true
+	}
+
+	r.intervals.reconciled[key] = now
+	return false
+}
+
+// forgetReconcileInterval drops the reconcile times of a deleted resource
+func (r *GenericReplicator) forgetReconcileInterval(key string) {
+	r.intervals.lock.Lock()
+	defer r.intervals.lock.Unlock()
+
+	delete(r.intervals.reconciled, key)
+}
+
+// reconcileDelayed reconciles the latest version of a resource after its
+// reconcile was deferred by deferReconcile
+func (r *GenericReplicator) reconcileDelayed(item delayedReconcile) {
+	r.intervals.lock.Lock()
+	delete(r.intervals.pending, item.Key)
+	r.intervals.lock.Unlock()
+
+	obj, exists, err := r.Store.GetByKey(item.Key)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Error("error fetching object from store")
+		return
+	} else if !exists {
+		return
+	}
+
+	r.ResourceAdded(obj)
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMinReconcileInterval(t *testing.T) {
+	defer func(interval time.Duration) { Options.MinReconcileInterval = interval }(Options.MinReconcileInterval)
+	Options.MinReconcileInterval = 100 * time.Millisecond
+
+	r := newTestReplicator(t, "Secret")
+
+	var reconciled []string
+	r.UpdateFuncs.OnResourceAdded = func(obj interface{}) error {
+		reconciled = append(reconciled, MustGetObject(obj).GetResourceVersion())
+		return nil
+	}
+
+	secret := func(version string, annotations map[string]string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "noisy",
+			ResourceVersion: version,
+			Annotations:     annotations,
+		}}
+	}
+
+	for _, version := range []string{"1", "2", "3"} {
+		require.NoError(t, r.Store.Update(secret(version, nil)))
+		r.ResourceAdded(secret(version, nil))
+	}
+	assert.Equal(t, []string{"1"}, reconciled)
+	assert.Len(t, r.intervals.pending, 1, "a single reconcile is deferred")
+
+	item, _ := r.DelayQueue.Get()
+	require.Equal(t, delayedReconcile{Key: "default/noisy"}, item)
+	r.reconcileDelayed(item.(delayedReconcile))
+	r.DelayQueue.Done(item)
+	assert.Equal(t, []string{"1", "3"}, reconciled, "the deferred reconcile processes the latest version")
+
+	t.Run("the annotation overrides the global interval", func(t *testing.T) {
+		reconciled = nil
+		unlimited := map[string]string{MinReconcileInterval: "0s"}
+		for _, version := range []string{"4", "5"} {
+			r.ResourceAdded(secret(version, unlimited))
+		}
+		assert.Equal(t, []string{"4", "5"}, reconciled)
+	})
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeyNamespaces restricts the namespaces individual keys of a source are
+// replicated to. Keys that are not contained are replicated to all targets.
+type KeyNamespaces map[string][]*regexp.Regexp
+
+// ParseKeyNamespaces parses the value of a KeyNamespacesAnnotation, which is a
+// JSON object mapping keys to a comma separated list of namespace patterns.
+func ParseKeyNamespaces(annotation string) (KeyNamespaces, error) {
+	var patterns map[string]string
+	if err := json.Unmarshal([]byte(annotation), &patterns); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s annotation", KeyNamespacesAnnotation)
+	}
+
+	keyNamespaces := make(KeyNamespaces, len(patterns))
+	for key, list := range patterns {
+		keyNamespaces[key] = StringToPatternList(list)
+	}
+
+	return keyNamespaces, nil
+}
+
+// KeyNamespacesFor returns the KeyNamespaces of an object. Objects without a
+// KeyNamespacesAnnotation replicate all keys to all targets.
+func KeyNamespacesFor(annotations map[string]string) (KeyNamespaces, error) {
+	annotation, ok := annotations[KeyNamespacesAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	return ParseKeyNamespaces(annotation)
+}
+
+// Allows checks if key may be replicated into the given namespace
+func (k KeyNamespaces) Allows(key string, namespace string) bool {
+	patterns, ok := k[key]
+	if !ok {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if pattern.MatchString(namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GeneratedKeys maps keys whose value is generated for each target instead of
+// being copied from the source to the length of the generated value in bytes.
+type GeneratedKeys map[string]int
+
+// ParseGeneratedKeys parses the value of a GenerateKeys annotation, which is a
+// comma separated list of <key>=<length> pairs.
+func ParseGeneratedKeys(annotation string) (GeneratedKeys, error) {
+	generatedKeys := make(GeneratedKeys)
+
+	for _, pair := range strings.Split(annotation, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid %s annotation: expected <key>=<length>, got '%s'", GenerateKeys, pair)
+		}
+
+		length, err := strconv.Atoi(parts[1])
+		if err != nil || length <= 0 {
+			return nil, errors.Errorf("invalid %s annotation: length of key %s must be a positive number, got '%s'", GenerateKeys, parts[0], parts[1])
+		}
+
+		generatedKeys[parts[0]] = length
+	}
+
+	return generatedKeys, nil
+}
+
+// GeneratedKeysFor returns the GeneratedKeys of an object. Objects without a
+// GenerateKeys annotation have no generated keys.
+func GeneratedKeysFor(annotations map[string]string) (GeneratedKeys, error) {
+	annotation, ok := annotations[GenerateKeys]
+	if !ok {
+		return nil, nil
+	}
+
+	return ParseGeneratedKeys(annotation)
+}
+
+// GenerateValue returns a random value for a generated key
+func (g GeneratedKeys) GenerateValue(key string) ([]byte, error) {
+	value := make([]byte, g[key])
+	if _, err := rand.Read(value); err != nil {
+		return nil, errors.Wrapf(err, "could not generate value for key %s", key)
+	}
+	return value, nil
+}
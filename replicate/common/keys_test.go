@@ -0,0 +1,49 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyNamespaces(t *testing.T) {
+	keyNamespaces, err := KeyNamespacesFor(map[string]string{
+		KeyNamespacesAnnotation: `{"prod-password": "prod-.*", "staging-password": "staging,qa"}`,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, keyNamespaces.Allows("prod-password", "prod-eu"))
+	assert.False(t, keyNamespaces.Allows("prod-password", "staging"))
+	assert.True(t, keyNamespaces.Allows("staging-password", "qa"))
+	assert.False(t, keyNamespaces.Allows("staging-password", "qa-2"))
+	assert.True(t, keyNamespaces.Allows("common-config", "anywhere"))
+
+	none, err := KeyNamespacesFor(nil)
+	require.NoError(t, err)
+	assert.True(t, none.Allows("prod-password", "staging"))
+
+	_, err = KeyNamespacesFor(map[string]string{KeyNamespacesAnnotation: "prod-password: prod"})
+	assert.Error(t, err)
+}
+
+func TestGeneratedKeys(t *testing.T) {
+	generatedKeys, err := GeneratedKeysFor(map[string]string{
+		GenerateKeys: "hmac-key=32, session-key=16",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, GeneratedKeys{"hmac-key": 32, "session-key": 16}, generatedKeys)
+
+	value, err := generatedKeys.GenerateValue("session-key")
+	require.NoError(t, err)
+	assert.Len(t, value, 16)
+
+	none, err := GeneratedKeysFor(nil)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	for _, invalid := range []string{"hmac-key", "=32", "hmac-key=0", "hmac-key=abc"} {
+		_, err = ParseGeneratedKeys(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
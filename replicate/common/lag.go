@@ -0,0 +1,144 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// propagationLagTracker keeps track of the propagation lag of all sources and
+// sets the PropagationLag gauge of each kind to the largest one
+type propagationLagTracker struct {
+	lock sync.Mutex
+	lags map[string]map[string]time.Duration
+}
+
+var propagationLags = propagationLagTracker{lags: make(map[string]map[string]time.Duration)}
+
+func (t *propagationLagTracker) record(kind string, key string, lag time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.lags[kind] == nil {
+		t.lags[kind] = make(map[string]time.Duration)
+	}
+	t.lags[kind][key] = lag
+	t.update(kind)
+}
+
+func (t *propagationLagTracker) forget(kind string, key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.lags[kind][key]; !ok {
+		return
+	}
+	delete(t.lags[kind], key)
+	t.update(kind)
+}
+
+func (t *propagationLagTracker) update(kind string) {
+	var max time.Duration
+	for _, lag := range t.lags[kind] {
+		if lag > max {
+			max = lag
+		}
+	}
+	PropagationLag.WithLabelValues(kind).Set(max.Seconds())
+}
+
+// modifiedAt returns the time an object was last modified, which is the
+// latest time of its managed fields. Objects without managed fields were last
+// modified when they were created.
+func modifiedAt(object metav1.Object) time.Time {
+	modified := object.GetCreationTimestamp().Time
+	for _, entry := range object.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(modified) {
+			modified = entry.Time.Time
+		}
+	}
+	return modified
+}
+
+// SourcePropagationLag returns the delay between the last modification of a
+// source and the latest write of its targets. As long as a target was not
+// written from the current version of the source (see ReplicaUpToDate), the
+// lag grows until now. It returns false if the source has no targets or its
+// modification time is unknown.
+func SourcePropagationLag(source metav1.Object, targets []metav1.Object, now time.Time) (time.Duration, bool) {
+	modified := modifiedAt(source)
+	if len(targets) == 0 || modified.IsZero() {
+		return 0, false
+	}
+
+	var latest time.Time
+	for _, target := range targets {
+		replicatedAt, err := time.Parse(time.RFC3339, target.GetAnnotations()[ReplicatedAtAnnotation])
+		if err != nil || !ReplicaUpToDate(target, source) {
+			latest = now
+			break
+		}
+		if replicatedAt.After(latest) {
+			latest = replicatedAt
+		}
+	}
+
+	if lag := latest.Sub(modified); lag > 0 {
+		return lag, true
+	}
+	return 0, true
+}
+
+// targetsOf returns the targets of a source in the cache: the targets of
+// "replicate-from" annotations that depend on it and its pushed replicas.
+func (r *GenericReplicator) targetsOf(source metav1.Object) []metav1.Object {
+	sourceKey := MustGetKey(source)
+	targets := make([]metav1.Object, 0)
+
+	for dependentKey := range r.DependencyMap[sourceKey] {
+		if obj, exists, err := r.Store.GetByKey(dependentKey); err == nil && exists {
+			targets = append(targets, MustGetObject(obj))
+		}
+	}
+
+	if !r.isPushSource(sourceKey) {
+		return targets
+	}
+
+	for namespace := range pushTargets(r.Kind, source, namespacesFromStore()) {
+		for _, name := range []string{source.GetName(), SuffixedReplicaName(source)} {
+			obj, exists, err := r.Store.GetByKey(namespace + "/" + name)
+			if err != nil || !exists {
+				continue
+			}
+			if target := MustGetObject(obj); ReplicaSource(target) == sourceKey {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	return targets
+}
+
+// updatePropagationLag records the propagation lag of a source after it was
+// reconciled. Sources without targets are not taken into account. In
+// read-only mode, replicas are never written, so no lag is recorded.
+func (r *GenericReplicator) updatePropagationLag(obj interface{}) {
+	if Options.ReadOnly {
+		return
+	}
+
+	source := MustGetObject(obj)
+	sourceKey := MustGetKey(source)
+
+	lag, ok := SourcePropagationLag(source, r.targetsOf(source), time.Now())
+	if !ok {
+		propagationLags.forget(r.Kind, sourceKey)
+		return
+	}
+
+	log.WithField("kind", r.Kind).WithField("resource", sourceKey).Debugf("propagation lag of %s %s is %s", r.Kind, sourceKey, lag)
+	propagationLags.record(r.Kind, sourceKey, lag)
+}
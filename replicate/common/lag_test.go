@@ -0,0 +1,61 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSourcePropagationLag(t *testing.T) {
+	created := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	modified := metav1.NewTime(created.Add(time.Hour))
+	now := created.Add(2 * time.Hour)
+
+	source := &metav1.ObjectMeta{
+		Namespace:         "source",
+		Name:              "foo",
+		ResourceVersion:   "2",
+		CreationTimestamp: metav1.NewTime(created),
+		ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "kubectl", Time: &modified}},
+	}
+	target := func(version string, replicatedAt time.Time) metav1.Object {
+		return &metav1.ObjectMeta{Namespace: "target", Name: "foo", Annotations: map[string]string{
+			ReplicatedFromVersionAnnotation: version,
+			ReplicatedAtAnnotation:          replicatedAt.Format(time.RFC3339),
+		}}
+	}
+
+	_, ok := SourcePropagationLag(source, nil, now)
+	assert.False(t, ok, "sources without targets have no lag")
+
+	lag, ok := SourcePropagationLag(source, []metav1.Object{
+		target("2", modified.Add(5*time.Second)),
+		target("2", modified.Add(30*time.Second)),
+	}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, lag, "the latest write of a target counts")
+
+	lag, _ = SourcePropagationLag(source, []metav1.Object{
+		target("2", modified.Add(5*time.Second)),
+		target("1", created),
+	}, now)
+	assert.Equal(t, time.Hour, lag, "targets behind their source lag until now")
+
+	lag, _ = SourcePropagationLag(source, []metav1.Object{target("2", created)}, now)
+	assert.Equal(t, time.Duration(0), lag, "lag is never negative")
+}
+
+func TestPropagationLagGaugeReportsLargestLag(t *testing.T) {
+	propagationLags.record("LaggingSecret", "default/fast", time.Second)
+	propagationLags.record("LaggingSecret", "default/slow", time.Minute)
+	assert.Equal(t, float64(60), testutil.ToFloat64(PropagationLag.WithLabelValues("LaggingSecret")))
+
+	propagationLags.forget("LaggingSecret", "default/slow")
+	assert.Equal(t, float64(1), testutil.ToFloat64(PropagationLag.WithLabelValues("LaggingSecret")))
+
+	propagationLags.forget("LaggingSecret", "default/fast")
+	assert.Equal(t, float64(0), testutil.ToFloat64(PropagationLag.WithLabelValues("LaggingSecret")))
+}
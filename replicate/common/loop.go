@@ -0,0 +1,81 @@
+package common
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ReplicationChain returns the keys of all sources an object was (directly or
+// indirectly) replicated from, starting with the original source.
+func ReplicationChain(object metav1.Object) []string {
+	chain, ok := object.GetAnnotations()[ReplicationChainAnnotation]
+	if !ok || chain == "" {
+		return nil
+	}
+
+	return strings.Split(chain, ",")
+}
+
+// SetReplicationChain sets the ReplicationChainAnnotation of a replica to the
+// chain of its source, followed by the source itself.
+func SetReplicationChain(annotations map[string]string, source metav1.Object) {
+	chain := append(ReplicationChain(source), MustGetKey(source))
+	annotations[ReplicationChainAnnotation] = strings.Join(chain, ",")
+}
+
+// replicationLoop checks if replicating source into the target with the given
+// key would close a replication loop. This is the case if the target is part
+// of the source's replication chain, or if the target itself replicates back
+// into the source.
+func (r *GenericReplicator) replicationLoop(source metav1.Object, targetKey string) bool {
+	if MustGetKey(source) == targetKey {
+		return true
+	}
+
+	for _, key := range ReplicationChain(source) {
+		if key == targetKey {
+			return true
+		}
+	}
+
+	if pulled, ok, err := ReplicateFrom(source); ok && err == nil && pulled.String() == targetKey {
+		return true
+	}
+
+	obj, exists, err := r.Store.GetByKey(targetKey)
+	if err != nil || !exists {
+		return false
+	}
+
+	target := MustGetObject(obj)
+	if target.GetName() != source.GetName() {
+		return false
+	}
+
+	_, pushesBack := pushTargets(r.Kind, target, namespacesFromStore())[source.GetNamespace()]
+	return pushesBack
+}
+
+// refuseReplicationLoop checks if replicating obj into the target with the
+// given key would close a replication loop. Loops are reported with a warning
+// event on the source.
+func (r *GenericReplicator) refuseReplicationLoop(obj interface{}, targetKey string) bool {
+	source := MustGetObject(obj)
+	if !r.replicationLoop(source, targetKey) {
+		return false
+	}
+
+	sourceKey := MustGetKey(obj)
+	chain := strings.Join(append(ReplicationChain(source), sourceKey, targetKey), " -> ")
+
+	log.WithField("kind", r.Kind).WithField("source", sourceKey).WithField("target", targetKey).
+		Warnf("refusing to replicate %s %s to %s: replication loop %s", r.Kind, sourceKey, targetKey, chain)
+	r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "ReplicationLoop",
+		"Not replicated to %s: replication loop %s", targetKey, chain)
+
+	return true
+}
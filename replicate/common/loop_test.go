@@ -0,0 +1,73 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReplicationChain(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "b", Annotations: map[string]string{
+		ReplicationChainAnnotation: "a/foo",
+	}}}
+
+	annotations := make(map[string]string)
+	SetReplicationChain(annotations, source)
+	assert.Equal(t, "a/foo,b/foo", annotations[ReplicationChainAnnotation])
+
+	assert.Nil(t, ReplicationChain(&v1.Secret{}))
+}
+
+func TestRefuseReplicationLoop(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "a", Annotations: map[string]string{
+		ReplicateTo: "b",
+	}}}
+
+	t.Run("plain replication is allowed", func(t *testing.T) {
+		assert.False(t, r.refuseReplicationLoop(source, "b/foo"))
+		assert.Empty(t, recorder.Events)
+	})
+
+	t.Run("targets in the replication chain are refused", func(t *testing.T) {
+		replica := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "c", Annotations: map[string]string{
+			ReplicationChainAnnotation: "a/foo,b/foo",
+		}}}
+
+		assert.True(t, r.refuseReplicationLoop(replica, "a/foo"))
+		assert.Contains(t, <-recorder.Events, "ReplicationLoop")
+		assert.False(t, r.refuseReplicationLoop(replica, "d/foo"))
+	})
+
+	t.Run("targets that replicate back are refused", func(t *testing.T) {
+		require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "b", Annotations: map[string]string{
+			ReplicateTo: "a",
+		}}}))
+
+		assert.True(t, r.refuseReplicationLoop(source, "b/foo"))
+		assert.Contains(t, <-recorder.Events, "ReplicationLoop")
+	})
+
+	t.Run("sources that pull from their target are refused", func(t *testing.T) {
+		pulling := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "a", Annotations: map[string]string{
+			ReplicateFromAnnotation: "c/bar",
+		}}}
+
+		assert.True(t, r.refuseReplicationLoop(pulling, "c/bar"))
+		assert.Contains(t, <-recorder.Events, "ReplicationLoop")
+	})
+}
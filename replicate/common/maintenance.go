@@ -0,0 +1,186 @@
+package common
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// MaintenanceWindow is a period of time during which no replication writes are
+// made. It is either a one-off window between Start and End, or a recurring
+// window between From and Until (offsets since midnight UTC) on the given Days.
+type MaintenanceWindow struct {
+	Start time.Time
+	End   time.Time
+
+	Days  map[time.Weekday]struct{}
+	From  time.Duration
+	Until time.Duration
+}
+
+// MaintenanceWindows is a list of maintenance windows
+type MaintenanceWindows []MaintenanceWindow
+
+// ParseMaintenanceWindows parses a semicolon separated list of maintenance
+// windows. Each window is either "<RFC3339 start>/<RFC3339 end>" or a recurring
+// window "[<days>] HH:MM-HH:MM" in UTC, where days is a comma separated list of
+// weekdays or weekday ranges (e.g. "Mon-Fri,Sun").
+func ParseMaintenanceWindows(spec string) (MaintenanceWindows, error) {
+	windows := make(MaintenanceWindows, 0)
+
+	for _, windowSpec := range strings.Split(spec, ";") {
+		windowSpec = strings.TrimSpace(windowSpec)
+		if windowSpec == "" {
+			continue
+		}
+
+		window, err := parseMaintenanceWindow(windowSpec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid maintenance window '%s'", windowSpec)
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+func parseMaintenanceWindow(spec string) (MaintenanceWindow, error) {
+	var window MaintenanceWindow
+
+	if parts := strings.SplitN(spec, "/", 2); len(parts) == 2 {
+		start, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			return window, err
+		}
+		end, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return window, err
+		}
+		if !end.After(start) {
+			return window, errors.New("end must be after start")
+		}
+
+		window.Start, window.End = start, end
+		return window, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 2 {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return window, err
+		}
+		window.Days = days
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return window, errors.New("expected '[<days>] HH:MM-HH:MM' or '<start>/<end>'")
+	}
+
+	times := strings.SplitN(fields[0], "-", 2)
+	if len(times) != 2 {
+		return window, errors.New("expected 'HH:MM-HH:MM'")
+	}
+
+	from, err := parseTimeOfDay(times[0])
+	if err != nil {
+		return window, err
+	}
+	until, err := parseTimeOfDay(times[1])
+	if err != nil {
+		return window, err
+	}
+	if from == until {
+		return window, errors.New("start and end must differ")
+	}
+
+	window.From, window.Until = from, until
+	return window, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.Errorf("invalid time of day '%s'", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseWeekdays(list string) (map[time.Weekday]struct{}, error) {
+	days := make(map[time.Weekday]struct{})
+
+	for _, item := range strings.Split(list, ",") {
+		bounds := strings.SplitN(item, "-", 2)
+
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return nil, errors.Errorf("invalid weekday '%s'", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+				return nil, errors.Errorf("invalid weekday '%s'", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = struct{}{}
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// Active checks if the maintenance window contains the given time
+func (w MaintenanceWindow) Active(t time.Time) bool {
+	if !w.Start.IsZero() {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	// recurring windows may have started on the previous day and wrap around midnight
+	return w.activeOn(midnight, t) || w.activeOn(midnight.AddDate(0, 0, -1), t)
+}
+
+func (w MaintenanceWindow) activeOn(day time.Time, t time.Time) bool {
+	if len(w.Days) > 0 {
+		if _, ok := w.Days[day.Weekday()]; !ok {
+			return false
+		}
+	}
+
+	until := w.Until
+	if until <= w.From {
+		until += 24 * time.Hour
+	}
+
+	return !t.Before(day.Add(w.From)) && t.Before(day.Add(until))
+}
+
+// Active checks if any of the maintenance windows contains the given time
+func (ws MaintenanceWindows) Active(t time.Time) bool {
+	for _, w := range ws {
+		if w.Active(t) {
+			return true
+		}
+	}
+	return false
+}
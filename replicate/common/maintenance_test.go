@@ -0,0 +1,95 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	windows, err := ParseMaintenanceWindows("Sat-Sun 22:00-04:00; 12:00-13:00 ;2026-11-03T08:00:00Z/2026-11-03T12:00:00Z")
+	require.NoError(t, err)
+	require.Len(t, windows, 3)
+
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	// 2026-10-17 is a Saturday
+	assert.True(t, windows.Active(at("2026-10-17T23:00:00Z")))
+	assert.True(t, windows.Active(at("2026-10-19T03:59:00Z")), "window of Sunday wraps into Monday")
+	assert.False(t, windows.Active(at("2026-10-19T23:00:00Z")), "Monday is no maintenance day")
+	assert.False(t, windows.Active(at("2026-10-17T21:59:00Z")))
+	assert.True(t, windows.Active(at("2026-10-20T12:30:00Z")), "daily window")
+	assert.False(t, windows.Active(at("2026-10-20T13:00:00Z")))
+	assert.True(t, windows.Active(at("2026-11-03T10:00:00+01:00")))
+	assert.False(t, windows.Active(at("2026-11-04T10:00:00Z")))
+}
+
+func TestParseMaintenanceWindowsRejectsInvalidWindows(t *testing.T) {
+	for _, spec := range []string{
+		"22:00",
+		"25:00-04:00",
+		"Caturday 22:00-04:00",
+		"10:00-10:00",
+		"2026-11-03T12:00:00Z/2026-11-03T08:00:00Z",
+		"Sat 22:00-04:00 extra",
+	} {
+		_, err := ParseMaintenanceWindows(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestOperationsAreDeferredDuringMaintenance(t *testing.T) {
+	defer func(windows MaintenanceWindows) { Options.MaintenanceWindows = windows }(Options.MaintenanceWindows)
+
+	now := time.Now()
+	Options.MaintenanceWindows = MaintenanceWindows{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}}
+
+	r := newTestReplicator(t, "Test")
+	order := make([]int, 0)
+
+	r.whenWritable(nil, func() { order = append(order, 1) })
+	r.whenWritable(nil, func() { order = append(order, 2) })
+	r.drainDeferredOperations()
+	assert.Empty(t, order)
+
+	Options.MaintenanceWindows = nil
+	r.whenWritable(nil, func() { order = append(order, 3) })
+	assert.Empty(t, order, "operations are deferred while older operations are pending")
+
+	r.drainDeferredOperations()
+	assert.Equal(t, []int{1, 2, 3}, order)
+
+	r.whenWritable(nil, func() { order = append(order, 4) })
+	assert.Equal(t, []int{1, 2, 3, 4}, order)
+}
+
+func TestDeferredOperationsAreCoalesced(t *testing.T) {
+	defer func(windows MaintenanceWindows) { Options.MaintenanceWindows = windows }(Options.MaintenanceWindows)
+
+	now := time.Now()
+	Options.MaintenanceWindows = MaintenanceWindows{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}}
+
+	r := newTestReplicator(t, "Test")
+	processed := make([]string, 0)
+
+	for _, version := range []string{"1", "2", "3"} {
+		version := version
+		r.whenWritable(objectEvent("default/foo"), func() { processed = append(processed, "foo@"+version) })
+		r.whenWritable(nil, func() { processed = append(processed, "namespace") })
+	}
+	r.whenWritable(objectEvent("default/bar"), func() { processed = append(processed, "bar") })
+	r.whenWritable(delayedReplication{SourceKey: "default/foo", Namespace: "team-a"}, func() { processed = append(processed, "delayed") })
+	r.whenWritable(delayedReplication{SourceKey: "default/foo", Namespace: "team-a"}, func() { processed = append(processed, "delayed") })
+	assert.Len(t, r.deferred.operations, 6, "events of the same object are deferred once")
+
+	Options.MaintenanceWindows = nil
+	r.drainDeferredOperations()
+	assert.Equal(t, []string{"foo@3", "namespace", "namespace", "namespace", "bar", "delayed"}, processed, "the latest operation of a key runs at its first position")
+	assert.Empty(t, r.deferred.keys)
+}
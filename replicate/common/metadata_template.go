@@ -0,0 +1,170 @@
+package common
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// ReplicaMetadataConfig is the format of the replica metadata template file.
+// It maps label and annotation keys to Go templates, which are applied to the
+// metadata of the target namespace of each replica.
+type ReplicaMetadataConfig struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ReplicaMetadataTemplate contains the parsed templates of the labels and
+// annotations that are set on every replica
+type ReplicaMetadataTemplate struct {
+	Labels      map[string]*template.Template
+	Annotations map[string]*template.Template
+}
+
+// replicaMetadataTemplateData is the data that replica metadata templates are applied to
+type replicaMetadataTemplateData struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// LoadReplicaMetadataTemplate reads the templates of replica labels and
+// annotations from a YAML or JSON file
+func LoadReplicaMetadataTemplate(path string) (*ReplicaMetadataTemplate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read replica metadata template from %s", path)
+	}
+
+	var config ReplicaMetadataConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, errors.Wrapf(err, "could not parse replica metadata template from %s", path)
+	}
+
+	labels, err := parseMetadataTemplates("label", config.Labels)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := parseMetadataTemplates("annotation", config.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplicaMetadataTemplate{Labels: labels, Annotations: annotations}, nil
+}
+
+func parseMetadataTemplates(kind string, templates map[string]string) (map[string]*template.Template, error) {
+	parsed := make(map[string]*template.Template, len(templates))
+
+	for key, text := range templates {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, errors.Errorf("invalid replica %s key '%s': %s", kind, key, strings.Join(errs, "; "))
+		}
+		if strings.HasPrefix(key, "replicator.v1.mittwald.de/") {
+			return nil, errors.Errorf("replica %s key '%s' is reserved for the replicator", kind, key)
+		}
+
+		tmpl, err := template.New(key).Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid template of replica %s '%s': %v", kind, key, err)
+		}
+		parsed[key] = tmpl
+	}
+
+	return parsed, nil
+}
+
+// render applies the templates to a target namespace. Empty values mean that
+// the label or annotation is removed from the replica.
+func (t *ReplicaMetadataTemplate) render(namespace *v1.Namespace) (labels map[string]string, annotations map[string]string) {
+	data := replicaMetadataTemplateData{
+		Name:        namespace.Name,
+		Labels:      namespace.Labels,
+		Annotations: namespace.Annotations,
+	}
+
+	execute := func(kind string, templates map[string]*template.Template, valid func(string) []string) map[string]string {
+		values := make(map[string]string, len(templates))
+		for key, tmpl := range templates {
+			var value bytes.Buffer
+			if err := tmpl.Execute(&value, data); err != nil {
+				log.WithField("target", namespace.Name).WithError(err).Warnf("could not render replica %s %s: %v", kind, key, err)
+				continue
+			}
+
+			if errs := valid(value.String()); len(errs) > 0 {
+				log.WithField("target", namespace.Name).Warnf("ignoring invalid value '%s' of replica %s %s: %s",
+					value.String(), kind, key, strings.Join(errs, "; "))
+				continue
+			}
+			values[key] = value.String()
+		}
+		return values
+	}
+
+	labels = execute("label", t.Labels, validation.IsValidLabelValue)
+	annotations = execute("annotation", t.Annotations, func(string) []string { return nil })
+	return
+}
+
+// ApplyReplicaMetadata sets the labels and annotations of the replica metadata
+// template from the Options on a replica in the given namespace
+func ApplyReplicaMetadata(object metav1.Object, namespace *v1.Namespace) {
+	if Options.ReplicaMetadataTemplate == nil {
+		return
+	}
+
+	labels, annotations := Options.ReplicaMetadataTemplate.render(namespace)
+	object.SetLabels(withMetadataValues(object.GetLabels(), labels))
+	object.SetAnnotations(withMetadataValues(object.GetAnnotations(), annotations))
+}
+
+// ReplicaMetadataUpToDate checks if a replica in the given namespace carries
+// the extra annotations and the current labels and annotations of the replica
+// metadata template, e.g. after the labels of the namespace changed or the
+// extra annotations were removed from the replica
+func ReplicaMetadataUpToDate(object metav1.Object, namespace *v1.Namespace) bool {
+	if !hasMetadataValues(object.GetAnnotations(), Options.ReplicaExtraAnnotations) {
+		return false
+	}
+	if Options.ReplicaMetadataTemplate == nil {
+		return true
+	}
+
+	labels, annotations := Options.ReplicaMetadataTemplate.render(namespace)
+	return hasMetadataValues(object.GetLabels(), labels) && hasMetadataValues(object.GetAnnotations(), annotations)
+}
+
+func withMetadataValues(existing map[string]string, values map[string]string) map[string]string {
+	if existing == nil {
+		existing = make(map[string]string)
+	}
+
+	for key, value := range values {
+		if value == "" {
+			delete(existing, key)
+		} else {
+			existing[key] = value
+		}
+	}
+
+	return existing
+}
+
+func hasMetadataValues(existing map[string]string, values map[string]string) bool {
+	for key, value := range values {
+		if existing[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadReplicaMetadataTemplate(t *testing.T) {
+	tmpl, err := LoadReplicaMetadataTemplate(writeRulesFile(t, `
+labels:
+  cost-center: '{{ index .Labels "cost-center" }}'
+annotations:
+  example.com/owner: 'team {{ .Name }}'
+`))
+	require.NoError(t, err)
+	assert.Len(t, tmpl.Labels, 1)
+	assert.Len(t, tmpl.Annotations, 1)
+
+	for name, content := range map[string]string{
+		"invalid key":      "labels:\n  'not a key': foo\n",
+		"reserved key":     "annotations:\n  replicator.v1.mittwald.de/replicate-to: foo\n",
+		"invalid template": "labels:\n  team: '{{ .Name'\n",
+		"unknown field":    "label:\n  team: foo\n",
+	} {
+		_, err := LoadReplicaMetadataTemplate(writeRulesFile(t, content))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestApplyReplicaMetadata(t *testing.T) {
+	defer func(tmpl *ReplicaMetadataTemplate) { Options.ReplicaMetadataTemplate = tmpl }(Options.ReplicaMetadataTemplate)
+
+	var err error
+	Options.ReplicaMetadataTemplate, err = LoadReplicaMetadataTemplate(writeRulesFile(t, `
+labels:
+  cost-center: '{{ index .Labels "cost-center" }}'
+  owner: '{{ index .Annotations "owner" }} team'
+annotations:
+  example.com/namespace: '{{ .Name }}'
+`))
+	require.NoError(t, err)
+
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "team-a",
+		Labels: map[string]string{"cost-center": "1234"},
+	}}
+	replica := &metav1.ObjectMeta{Labels: map[string]string{"app": "foo"}}
+
+	assert.False(t, ReplicaMetadataUpToDate(replica, namespace))
+	ApplyReplicaMetadata(replica, namespace)
+	assert.Equal(t, map[string]string{"app": "foo", "cost-center": "1234"}, replica.Labels, "invalid label values are ignored")
+	assert.Equal(t, map[string]string{"example.com/namespace": "team-a"}, replica.Annotations)
+	assert.True(t, ReplicaMetadataUpToDate(replica, namespace))
+
+	t.Run("changed namespace labels are applied", func(t *testing.T) {
+		namespace.Labels = nil
+		assert.False(t, ReplicaMetadataUpToDate(replica, namespace))
+
+		ApplyReplicaMetadata(replica, namespace)
+		assert.Equal(t, map[string]string{"app": "foo"}, replica.Labels, "empty values remove labels")
+		assert.True(t, ReplicaMetadataUpToDate(replica, namespace))
+	})
+}
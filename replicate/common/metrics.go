@@ -0,0 +1,199 @@
+package common
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// DefaultMetricsNamespace is the default prefix of the names of all metrics
+// that are exported by the replicator
+const DefaultMetricsNamespace = "replicator"
+
+var metricsPrefixPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Metrics that are exported by the replicator on the "/metrics" endpoint. Their
+// names are prefixed when they are registered, see RegisterMetrics.
+var (
+	WatchErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "watch_errors_total",
+		Help: "Number of times the watch connection of an informer broke with an error",
+	}, []string{"kind"})
+
+	RelistTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "relist_total",
+		Help: "Number of times an informer had to relist all objects after its initial list",
+	}, []string{"kind"})
+
+	QuarantinedSources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "quarantined_sources",
+		Help: "Number of resources that are not replicated any more because they failed too often",
+	}, []string{"kind"})
+
+	ReconcileSkippedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reconcile_skipped_total",
+		Help: "Number of reconciles of a target that ended without writing it, by reason",
+	}, []string{"kind", "reason"})
+
+	OversizedObjectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "oversized_objects_total",
+		Help: "Number of times the replication of an object was refused because it exceeded the size limit",
+	}, []string{"kind"})
+
+	ThrottledRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "throttled_requests_total",
+		Help: "Number of requests that were throttled by the API server and retried later",
+	}, []string{"kind"})
+
+	ShadowDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "shadow_drift",
+		Help: "Number of objects that shadow mode found to differ from their intended state",
+	}, []string{"kind"})
+
+	DriftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "drift_total",
+		Help: "Number of writes in shadow mode that found a target to differ from its intended state, by type of drift",
+	}, []string{"kind", "type"})
+
+	DeferredOperations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "deferred_operations",
+		Help: "Number of operations that are deferred until the current maintenance window ends",
+	}, []string{"kind"})
+
+	WriteVerificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "write_verification_failures_total",
+		Help: "Number of replicas whose content differed from what was written when they were read back",
+	}, []string{"kind"})
+
+	WriteVerificationsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "write_verifications_skipped_total",
+		Help: "Number of writes that were not verified because of the rate limit of verification reads",
+	}, []string{"kind"})
+
+	LastProgress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "last_progress_timestamp_seconds",
+		Help: "Time at which the replicator last completed processing an event",
+	}, []string{"kind"})
+
+	ExternalSinkWritesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_sink_writes_total",
+		Help: "Number of replicas that were written to the external sink",
+	}, []string{"kind"})
+
+	ExternalSinkWriteFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_sink_write_failures_total",
+		Help: "Number of failed writes of replicas to the external sink; failed writes are retried",
+	}, []string{"kind"})
+
+	RefusedFanoutsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "refused_fanouts_total",
+		Help: "Number of times the replication of a source was refused because it targeted more namespaces than allowed",
+	}, []string{"kind"})
+
+	DeferredReconcilesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "deferred_reconciles_total",
+		Help: "Number of events that were deferred and coalesced because their resource was reconciled less than its minimum interval ago",
+	}, []string{"kind"})
+
+	BatchedWritesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "batched_writes_total",
+		Help: "Number of writes that were collected in a batch window",
+	}, []string{"kind"})
+
+	CoalescedWritesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "coalesced_writes_total",
+		Help: "Number of writes that were dropped because the same source was already batched for the same namespace",
+	}, []string{"kind"})
+
+	PropagationLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "propagation_lag_seconds",
+		Help: "Largest delay between the last modification of a source and the last write of its targets",
+	}, []string{"kind"})
+
+	QueuedEvents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queued_events",
+		Help: "Number of objects whose events are waiting to be processed, by priority",
+	}, []string{"kind", "priority"})
+
+	TransformErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transform_errors_total",
+		Help: "Number of replicas that were not written because the transformation plugin failed",
+	}, []string{"kind"})
+
+	PreDeleteDeferralsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pre_delete_deferrals_total",
+		Help: "Number of replica deletions that were deferred because the pre-delete hook did not approve them",
+	}, []string{"kind"})
+
+	UnstableSourceDeferralsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "unstable_source_deferrals_total",
+		Help: "Number of events that were deferred because their source changed less than its minimum stable age ago",
+	}, []string{"kind"})
+)
+
+// ValidateMetricsPrefix checks that a metrics namespace and subsystem only
+// contain characters that are allowed in metric names. Both may be empty.
+func ValidateMetricsPrefix(namespace, subsystem string) error {
+	for _, part := range []string{namespace, subsystem} {
+		if part != "" && !metricsPrefixPattern.MatchString(part) {
+			return errors.Errorf("invalid metrics namespace or subsystem '%s'", part)
+		}
+	}
+
+	return nil
+}
+
+// MetricsPrefix returns the prefix of the names of all metrics for the given
+// namespace and subsystem, e.g. "platform_replicator_". Both may be empty.
+func MetricsPrefix(namespace, subsystem string) string {
+	var prefix string
+	for _, part := range []string{namespace, subsystem} {
+		if part != "" {
+			prefix += part + "_"
+		}
+	}
+	return prefix
+}
+
+// RegisterMetrics registers all metrics of the replicator, the metrics of its
+// work queues and the Go runtime and process metrics with registerer. The
+// names of all of them are prefixed with MetricsPrefix(namespace, subsystem).
+// The returned registerer adds the same prefix to metrics that are registered
+// with it later, e.g. those of the metrics handler.
+func RegisterMetrics(registerer prometheus.Registerer, namespace, subsystem string) prometheus.Registerer {
+	prefixed := prometheus.WrapRegistererWithPrefix(MetricsPrefix(namespace, subsystem), registerer)
+	prefixed.MustRegister(
+		WatchErrorsTotal,
+		RelistTotal,
+		QuarantinedSources,
+		ReconcileSkippedTotal,
+		OversizedObjectsTotal,
+		ThrottledRequestsTotal,
+		ShadowDrift,
+		DriftTotal,
+		DeferredOperations,
+		WriteVerificationFailures,
+		WriteVerificationsSkipped,
+		LastProgress,
+		ExternalSinkWritesTotal,
+		ExternalSinkWriteFailures,
+		RefusedFanoutsTotal,
+		DeferredReconcilesTotal,
+		BatchedWritesTotal,
+		CoalescedWritesTotal,
+		PropagationLag,
+		QueuedEvents,
+		TransformErrorsTotal,
+		PreDeleteDeferralsTotal,
+		UnstableSourceDeferralsTotal,
+	)
+	prefixed.MustRegister(workqueueMetrics...)
+	prefixed.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return prefixed
+}
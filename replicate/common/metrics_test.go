@@ -0,0 +1,47 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	// vectors are only gathered once they have a child
+	WatchErrorsTotal.WithLabelValues("Secret")
+	workqueueDepth.WithLabelValues("Secret")
+
+	names := func(namespace, subsystem string) []string {
+		registry := prometheus.NewRegistry()
+		RegisterMetrics(registry, namespace, subsystem)
+
+		families, err := registry.Gather()
+		require.NoError(t, err)
+
+		var names []string
+		for _, family := range families {
+			names = append(names, family.GetName())
+		}
+		return names
+	}
+
+	defaultNames := names(DefaultMetricsNamespace, "")
+	assert.Contains(t, defaultNames, "replicator_watch_errors_total")
+	assert.Contains(t, defaultNames, "replicator_workqueue_depth")
+
+	renamed := names("platform", "secrets")
+	assert.Contains(t, renamed, "platform_secrets_watch_errors_total")
+	assert.Contains(t, renamed, "platform_secrets_workqueue_depth")
+	assert.Contains(t, renamed, "platform_secrets_go_goroutines")
+	for _, name := range renamed {
+		assert.True(t, strings.HasPrefix(name, "platform_secrets_"), "%s is not prefixed", name)
+	}
+
+	assert.Contains(t, names("", ""), "watch_errors_total")
+
+	assert.NoError(t, ValidateMetricsPrefix("platform", ""))
+	assert.Error(t, ValidateMetricsPrefix("platform-team", ""))
+}
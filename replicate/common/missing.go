@@ -0,0 +1,65 @@
+package common
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Sources that are not found in the cache are looked up again after
+// sourceLookupDelay, doubling the delay for every attempt, until
+// maxSourceLookups attempts were made.
+const (
+	sourceLookupDelay = time.Second
+	maxSourceLookups  = 5
+)
+
+// delayedSourceLookup is put into the delay queue to process the target of a
+// "replicate-from" annotation again once its source might be in the cache
+type delayedSourceLookup struct {
+	TargetKey string
+	Attempt   int
+}
+
+// requeueMissingSource schedules another attempt to replicate into the target
+// with the given key. It returns false if all attempts were used up.
+func (r *GenericReplicator) requeueMissingSource(targetKey string, attempt int) bool {
+	if attempt >= maxSourceLookups {
+		return false
+	}
+
+	delay := sourceLookupDelay << attempt
+	log.WithField("kind", r.Kind).WithField("target", targetKey).Debugf("source of %s is not in the cache yet, retrying in %s", targetKey, delay)
+	r.DelayQueue.AddAfter(delayedSourceLookup{TargetKey: targetKey, Attempt: attempt}, delay)
+
+	return true
+}
+
+// lookupSourceDelayed processes the target of a "replicate-from" annotation
+// again if its source appeared in the cache in the meantime
+func (r *GenericReplicator) lookupSourceDelayed(item delayedSourceLookup) {
+	logger := log.WithField("kind", r.Kind).WithField("target", item.TargetKey)
+
+	obj, exists, err := r.Store.GetByKey(item.TargetKey)
+	if err != nil {
+		logger.WithError(err).Error("error fetching object from store")
+		return
+	} else if !exists {
+		return
+	}
+
+	source, ok, err := ReplicateFrom(MustGetObject(obj))
+	if !ok || err != nil {
+		return
+	}
+	sourceKey := source.String()
+
+	if _, exists, err := r.Store.GetByKey(sourceKey); err == nil && !exists {
+		if !r.requeueMissingSource(item.TargetKey, item.Attempt+1) {
+			logger.Warnf("source %s of %s does not exist, waiting for it to be created", sourceKey, item.TargetKey)
+		}
+		return
+	}
+
+	r.ResourceAdded(obj)
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestTargetIsFilledOnceSourceAppears(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	clock := testingclock.NewFakeClock(time.Now())
+	queue := workqueue.NewDelayingQueueWithCustomClock(clock, "Test")
+	defer queue.ShutDown()
+
+	r := newTestReplicator(t, "Test")
+	r.DelayQueue = queue
+
+	filled := make(map[string]string)
+	r.UpdateFuncs.ReplicateDataFrom = func(source interface{}, target interface{}) error {
+		filled[MustGetKey(target)] = MustGetKey(source)
+		return nil
+	}
+
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "foo", Annotations: map[string]string{
+		ReplicateFromAnnotation: "source/foo",
+	}}}
+	require.NoError(t, r.Store.Add(target))
+
+	next := func(delay time.Duration) delayedSourceLookup {
+		clock.Step(delay)
+		require.Eventually(t, func() bool { return queue.Len() == 1 }, time.Second, 10*time.Millisecond)
+		item, _ := queue.Get()
+		queue.Done(item)
+		return item.(delayedSourceLookup)
+	}
+
+	// the target is created before its source
+	r.ResourceAdded(target)
+	assert.Empty(t, filled)
+
+	item := next(sourceLookupDelay)
+	assert.Equal(t, delayedSourceLookup{TargetKey: "target/foo", Attempt: 0}, item)
+
+	// the source is still missing, so the lookup is retried with a longer delay
+	r.lookupSourceDelayed(item)
+	item = next(2 * sourceLookupDelay)
+	assert.Equal(t, 1, item.Attempt)
+	assert.Empty(t, filled)
+
+	// the source shows up in the cache
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "source", Name: "foo"}}))
+	r.lookupSourceDelayed(item)
+
+	assert.Equal(t, map[string]string{"target/foo": "source/foo"}, filled)
+	assert.Equal(t, 0, queue.Len())
+}
+
+func TestSourceLookupsAreLimited(t *testing.T) {
+	queue := workqueue.NewDelayingQueue()
+	defer queue.ShutDown()
+
+	r := newTestReplicator(t, "Test")
+	r.DelayQueue = queue
+
+	assert.True(t, r.requeueMissingSource("target/foo", maxSourceLookups-1))
+	assert.False(t, r.requeueMissingSource("target/foo", maxSourceLookups))
+}
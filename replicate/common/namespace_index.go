@@ -0,0 +1,131 @@
+package common
+
+import (
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/cache"
+)
+
+// maxNamespaceIndexEntries limits the number of pattern lists in the
+// namespace index. Once it is exceeded, e.g. because annotations were changed
+// often, the index is cleared and filled again by the next reconciles.
+const maxNamespaceIndexEntries = 1000
+
+// NamespaceIndex caches which namespaces match the patterns of "replicate-to"
+// annotations and replication rules, so that the targets of a source are not
+// resolved by matching all its patterns against every namespace on each
+// reconcile. The namespaces of a pattern list are looked up once; afterwards,
+// the index is updated incrementally by the events of the namespace
+// informer.
+type NamespaceIndex struct {
+	lock sync.Mutex
+
+	// store is the namespace store whose events update the index. The index
+	// is not used with other stores, e.g. static namespaces.
+	store   cache.Store
+	entries map[string]*namespaceIndexEntry
+}
+
+type namespaceIndexEntry struct {
+	patterns   NamespacePatterns
+	namespaces map[string]struct{}
+}
+
+var namespaceIndex NamespaceIndex
+
+// maintain starts maintaining the index for the given namespace store
+func (i *NamespaceIndex) maintain(store cache.Store) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.store = store
+	i.entries = nil
+}
+
+// reset clears the index, e.g. after the namespace mapping changed
+func (i *NamespaceIndex) reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.entries = nil
+}
+
+// namespaceChanged matches an added or updated namespace against all pattern
+// lists in the index
+func (i *NamespaceIndex) namespaceChanged(old *v1.Namespace, namespace *v1.Namespace) {
+	// the names of namespaces are matched only, but the labels of updated
+	// namespaces are checked again anyway
+	if old != nil && equality.Semantic.DeepEqual(old.Labels, namespace.Labels) {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, entry := range i.entries {
+		if matchesLogicalName(entry.patterns, namespace.Name) {
+			entry.namespaces[namespace.Name] = struct{}{}
+		} else {
+			delete(entry.namespaces, namespace.Name)
+		}
+	}
+}
+
+// namespaceDeleted removes a namespace from the index
+func (i *NamespaceIndex) namespaceDeleted(namespace *v1.Namespace) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, entry := range i.entries {
+		delete(entry.namespaces, namespace.Name)
+	}
+}
+
+// matching returns all namespaces except sourceNamespace that match the given
+// comma separated pattern list, sorted by name. It returns false if the index
+// is not maintained for the current namespace store.
+func (i *NamespaceIndex) matching(sourceNamespace string, patterns string) ([]v1.Namespace, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	store := namespaceWatcher.NamespaceStore
+	if i.store == nil || i.store != store {
+		return nil, false
+	}
+
+	entry, ok := i.entries[patterns]
+	if !ok {
+		// the store is read while the index is locked, so that the events of
+		// namespaces that are added in the meantime are applied to this entry
+		entry = &namespaceIndexEntry{patterns: StringToNamespacePatterns(patterns), namespaces: make(map[string]struct{})}
+		for _, name := range store.ListKeys() {
+			if matchesLogicalName(entry.patterns, name) {
+				entry.namespaces[name] = struct{}{}
+			}
+		}
+
+		if i.entries == nil || len(i.entries) >= maxNamespaceIndexEntries {
+			i.entries = make(map[string]*namespaceIndexEntry)
+		}
+		i.entries[patterns] = entry
+	}
+
+	names := make([]string, 0, len(entry.namespaces))
+	for name := range entry.namespaces {
+		if name != sourceNamespace {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	namespaces := make([]v1.Namespace, 0, len(names))
+	for _, name := range names {
+		if obj, exists, err := store.GetByKey(name); err == nil && exists {
+			namespaces = append(namespaces, *obj.(*v1.Namespace))
+		}
+	}
+	return namespaces, true
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNamespaceIndex(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	index := &NamespaceIndex{}
+	names := func(namespaces []v1.Namespace, ok bool) []string {
+		require.True(t, ok)
+		result := make([]string, 0, len(namespaces))
+		for _, ns := range namespaces {
+			result = append(result, ns.Name)
+		}
+		return result
+	}
+	add := func(name string) *v1.Namespace {
+		ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(ns))
+		index.namespaceChanged(nil, ns)
+		return ns
+	}
+
+	for _, name := range []string{"default", "team-a", "team-sandbox", "other"} {
+		add(name)
+	}
+
+	t.Run("the index is only used for the maintained store", func(t *testing.T) {
+		_, ok := index.matching("default", "team-.*")
+		assert.False(t, ok)
+	})
+
+	index.maintain(namespaceWatcher.NamespaceStore)
+
+	t.Run("matching namespaces are looked up once", func(t *testing.T) {
+		assert.Equal(t, []string{"team-a", "team-sandbox"}, names(index.matching("default", "team-.*")))
+		assert.Equal(t, []string{"team-a"}, names(index.matching("default", "team-.*,!team-sandbox")))
+		assert.Equal(t, []string{"team-sandbox"}, names(index.matching("team-a", "team-.*")))
+		assert.Len(t, index.entries, 2)
+	})
+
+	t.Run("added namespaces are indexed", func(t *testing.T) {
+		add("team-b")
+		assert.Equal(t, []string{"team-a", "team-b", "team-sandbox"}, names(index.matching("default", "team-.*")))
+	})
+
+	t.Run("deleted namespaces are removed", func(t *testing.T) {
+		ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+		require.NoError(t, namespaceWatcher.NamespaceStore.Delete(ns))
+		index.namespaceDeleted(ns)
+		assert.Equal(t, []string{"team-b", "team-sandbox"}, names(index.matching("default", "team-.*")))
+	})
+
+	t.Run("the index is not used for other stores", func(t *testing.T) {
+		namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+		_, ok := index.matching("default", "team-.*")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespaceMapping NamespaceMapping
+
+type NamespaceMappingChangedFunc func()
+
+// NamespaceMapping maps the logical namespace names used in replicate-to
+// annotations to the names of the namespaces in the host cluster, e.g. for
+// namespaces of virtual clusters that are synced into the host cluster
+// under a different name.
+type NamespaceMapping struct {
+	lock    sync.RWMutex
+	logical map[string]string
+	host    map[string]string
+
+	ChangedFuncs []NamespaceMappingChangedFunc
+}
+
+// SetNamespaceMapping replaces the namespace mapping with the data of a
+// mapping config map, whose keys are logical namespace names and whose
+// values are the names of the respective host namespaces, and notifies all
+// replicators about the change.
+func SetNamespaceMapping(data map[string]string) {
+	logical := make(map[string]string, len(data))
+	host := make(map[string]string, len(data))
+	for logicalName, hostName := range data {
+		if other, ok := logical[hostName]; ok {
+			log.WithField("kind", "NamespaceMapping").Warnf("namespaces %s and %s are both mapped to %s, ignoring %s", other, logicalName, hostName, logicalName)
+			continue
+		}
+		logical[hostName] = logicalName
+		host[logicalName] = hostName
+	}
+
+	namespaceMapping.set(logical, host)
+}
+
+// LogicalNamespaceName returns the logical name of the host namespace with
+// the given name. Namespaces that are not mapped keep their name, unless
+// their name is mapped to another host namespace; those can't be referred to
+// by any logical name.
+func LogicalNamespaceName(hostName string) (string, bool) {
+	namespaceMapping.lock.RLock()
+	defer namespaceMapping.lock.RUnlock()
+
+	if logicalName, ok := namespaceMapping.logical[hostName]; ok {
+		return logicalName, true
+	}
+
+	_, shadowed := namespaceMapping.host[hostName]
+	return hostName, !shadowed
+}
+
+// matchesLogicalName checks if the logical name of the host namespace with
+// the given name matches any of the patterns
+func matchesLogicalName(patterns NamespacePatterns, hostName string) bool {
+	logicalName, ok := LogicalNamespaceName(hostName)
+	return ok && patterns.MatchString(logicalName)
+}
+
+// OnNamespaceMappingChanged adds a function that is called whenever the namespace mapping changes
+func OnNamespaceMappingChanged(changedFunc NamespaceMappingChangedFunc) {
+	namespaceMapping.lock.Lock()
+	defer namespaceMapping.lock.Unlock()
+
+	namespaceMapping.ChangedFuncs = append(namespaceMapping.ChangedFuncs, changedFunc)
+}
+
+func (m *NamespaceMapping) set(logical map[string]string, host map[string]string) {
+	m.lock.Lock()
+	m.logical = logical
+	m.host = host
+	changedFuncs := m.ChangedFuncs
+	m.lock.Unlock()
+
+	// patterns are matched against the logical names of namespaces
+	namespaceIndex.reset()
+
+	for _, changedFunc := range changedFuncs {
+		changedFunc()
+	}
+}
+
+// NamespaceMappingChanged replicates all sources with replicate-to
+// annotations again, since their targets may resolve to other host
+// namespaces now.
+func (r *GenericReplicator) NamespaceMappingChanged() {
+	logger := log.WithField("kind", r.Kind)
+
+	for _, obj := range r.Store.List() {
+		objectMeta := MustGetObject(obj)
+		if _, ok := replicateToPatterns(r.Kind, objectMeta, objectMeta.GetAnnotations()); !ok {
+			continue
+		}
+
+		logger.WithField("resource", MustGetKey(obj)).Info("namespace mapping changed, replicating again")
+		r.ResourceAdded(obj)
+	}
+}
+
+// WatchNamespaceMapping watches the namespace mapping config map
+// <namespace>/<name> and updates the namespace mapping whenever it changes.
+// It blocks until the config map has been read once.
+func WatchNamespaceMapping(client kubernetes.Interface, namespace string, name string, resyncPeriod time.Duration) error {
+	return WatchConfigMap(client, "NamespaceMapping", namespace, name, resyncPeriod, SetNamespaceMapping)
+}
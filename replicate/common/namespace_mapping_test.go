@@ -0,0 +1,57 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceMapping(t *testing.T) {
+	defer SetNamespaceMapping(nil)
+
+	name, ok := LogicalNamespaceName("team-a")
+	assert.True(t, ok)
+	assert.Equal(t, "team-a", name)
+
+	SetNamespaceMapping(map[string]string{"team-a": "team-a-x-default-x-vcluster"})
+
+	name, ok = LogicalNamespaceName("team-a-x-default-x-vcluster")
+	assert.True(t, ok)
+	assert.Equal(t, "team-a", name)
+
+	name, ok = LogicalNamespaceName("other")
+	assert.True(t, ok)
+	assert.Equal(t, "other", name)
+
+	_, ok = LogicalNamespaceName("team-a")
+	assert.False(t, ok, "host namespaces named like a mapped logical namespace are shadowed")
+}
+
+func TestReplicateToResolvesMappedNamespaces(t *testing.T) {
+	defer SetNamespaceMapping(nil)
+
+	namespaces := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a-x-default-x-vcluster"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	}
+	names := func(namespaces []v1.Namespace) []string {
+		var names []string
+		for _, namespace := range namespaces {
+			names = append(names, namespace.Name)
+		}
+		return names
+	}
+
+	r := newTestReplicator(t, "Secret")
+
+	assert.Equal(t, []string{"team-a"}, names(r.getNamespacesToReplicate("default", "team-a", namespaces)))
+
+	SetNamespaceMapping(map[string]string{"team-a": "team-a-x-default-x-vcluster", "vcluster-b": "team-b"})
+
+	assert.Equal(t, []string{"team-a-x-default-x-vcluster"}, names(r.getNamespacesToReplicate("default", "team-a", namespaces)))
+	assert.Equal(t, []string{"team-b"}, names(r.getNamespacesToReplicate("default", "vcluster-.*", namespaces)))
+}
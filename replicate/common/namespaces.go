@@ -5,9 +5,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
@@ -21,6 +23,8 @@ type AddFunc func(obj *v1.Namespace)
 
 type UpdateFunc func(old *v1.Namespace, new *v1.Namespace)
 
+type DeleteFunc func(obj *v1.Namespace)
+
 type NamespaceWatcher struct {
 	doOnce sync.Once
 
@@ -29,6 +33,7 @@ type NamespaceWatcher struct {
 
 	AddFuncs    []AddFunc
 	UpdateFuncs []UpdateFunc
+	DeleteFuncs []DeleteFunc
 }
 
 // create will create a new namespace if one does not already exist. If it does, it will do nothing.
@@ -36,6 +41,7 @@ func (nw *NamespaceWatcher) create(client kubernetes.Interface, resyncPeriod tim
 	nw.doOnce.Do(func() {
 		namespaceAdded := func(obj interface{}) {
 			namespace := obj.(*v1.Namespace)
+			namespaceIndex.namespaceChanged(nil, namespace)
 			for _, addFunc := range nw.AddFuncs {
 				go addFunc(namespace)
 			}
@@ -44,12 +50,28 @@ func (nw *NamespaceWatcher) create(client kubernetes.Interface, resyncPeriod tim
 		namespaceUpdated := func(old interface{}, new interface{}) {
 			nsOld := old.(*v1.Namespace)
 			nsNew := new.(*v1.Namespace)
+			namespaceIndex.namespaceChanged(nsOld, nsNew)
 			for _, updateFunc := range nw.UpdateFuncs {
 				go updateFunc(nsOld, nsNew)
 			}
 		}
 
-		nw.NamespaceStore, nw.NamespaceController = cache.NewInformer(
+		namespaceDeleted := func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			namespace, ok := obj.(*v1.Namespace)
+			if !ok {
+				return
+			}
+			namespaceIndex.namespaceDeleted(namespace)
+			for _, deleteFunc := range nw.DeleteFuncs {
+				go deleteFunc(namespace)
+			}
+		}
+
+		nw.NamespaceStore, nw.NamespaceController = newInformer(
+			"Namespace",
 			&cache.ListWatch{
 				ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
 					return client.CoreV1().Namespaces().List(context.TODO(), lo)
@@ -63,15 +85,32 @@ func (nw *NamespaceWatcher) create(client kubernetes.Interface, resyncPeriod tim
 			cache.ResourceEventHandlerFuncs{
 				AddFunc:    namespaceAdded,
 				UpdateFunc: namespaceUpdated,
+				DeleteFunc: namespaceDeleted,
 			},
 		)
 
+		namespaceIndex.maintain(nw.NamespaceStore)
+
 		log.WithField("kind", "Namespace").Infof("running Namespace controller")
 		go nw.NamespaceController.Run(wait.NeverStop)
 
 	})
 }
 
+// UseStaticNamespaces replaces the namespace watcher by a fixed set of
+// namespaces, e.g. to simulate replications without a cluster. It must be
+// called before any replicator is created; calling it again replaces the
+// namespaces of the previous call.
+func UseStaticNamespaces(namespaces []v1.Namespace) {
+	namespaceWatcher.doOnce.Do(func() {})
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for i := range namespaces {
+		_ = store.Add(&namespaces[i])
+	}
+	namespaceWatcher.NamespaceStore = store
+}
+
 // OnNamespaceAdded will add another method to a list of functions to be called when a new namespace is created
 func (nw *NamespaceWatcher) OnNamespaceAdded(client kubernetes.Interface, resyncPeriod time.Duration, addFunc AddFunc) {
 	nw.create(client, resyncPeriod)
@@ -83,3 +122,33 @@ func (nw *NamespaceWatcher) OnNamespaceUpdated(client kubernetes.Interface, resy
 	nw.create(client, resyncPeriod)
 	nw.UpdateFuncs = append(nw.UpdateFuncs, updateFunc)
 }
+
+// OnNamespaceDeleted will add another method to a list of functions to be called when a namespace is deleted
+func (nw *NamespaceWatcher) OnNamespaceDeleted(client kubernetes.Interface, resyncPeriod time.Duration, deleteFunc DeleteFunc) {
+	nw.create(client, resyncPeriod)
+	nw.DeleteFuncs = append(nw.DeleteFuncs, deleteFunc)
+}
+
+// namespacesFromStore returns all namespaces currently known to the namespace watcher
+func namespacesFromStore() []v1.Namespace {
+	objects := namespaceWatcher.NamespaceStore.List()
+	namespaces := make([]v1.Namespace, len(objects))
+	for i, ns := range objects {
+		namespaces[i] = *ns.(*v1.Namespace)
+	}
+	return namespaces
+}
+
+// namespaceMatchesSelector checks if the labels of a namespace in the namespace
+// store match a label selector. Unknown namespaces never match.
+func namespaceMatchesSelector(name string, selector labels.Selector) (bool, error) {
+	obj, exists, err := namespaceWatcher.NamespaceStore.GetByKey(name)
+	if err != nil {
+		return false, errors.Wrapf(err, "Could not get namespace %s from cache: %v", name, err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	return selector.Matches(labels.Set(obj.(*v1.Namespace).Labels)), nil
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RecordedWrite is a write to an object of a replicated kind
+type RecordedWrite struct {
+	Verb string
+	Kind string
+	Key  string
+
+	// Err is set if the write failed
+	Err error
+}
+
+func (w RecordedWrite) String() string {
+	if w.Err != nil {
+		return fmt.Sprintf("%-6s %s %s: %v", w.Verb, w.Kind, w.Key, w.Err)
+	}
+	return fmt.Sprintf("%-6s %s %s", w.Verb, w.Kind, w.Key)
+}
+
+// WriteRecorder records the writes to replicated kinds that are sent through
+// its transport, e.g. to report which targets a reconcile touched
+type WriteRecorder struct {
+	lock   sync.Mutex
+	writes []RecordedWrite
+}
+
+type recordingRoundTripper struct {
+	recorder *WriteRecorder
+	next     http.RoundTripper
+}
+
+// Transport wraps a transport so that its writes are recorded
+func (w *WriteRecorder) Transport(rt http.RoundTripper) http.RoundTripper {
+	return &recordingRoundTripper{recorder: w, next: rt}
+}
+
+// Writes returns all recorded writes in the order they were sent
+func (w *WriteRecorder) Writes() []RecordedWrite {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return append([]RecordedWrite(nil), w.writes...)
+}
+
+func (t *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, ok := shadowVerbs[req.Method]
+	namespace, resource, name := parseResourcePath(req.URL.Path)
+	kind, replicated := shadowKinds[resource]
+	if !ok || !replicated {
+		return t.next.RoundTrip(req)
+	}
+
+	if name == "" && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		name = objectName(body)
+	}
+
+	write := RecordedWrite{Verb: verb, Kind: kind, Key: name}
+	if namespace != "" {
+		write.Key = namespace + "/" + name
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		write.Err = err
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		write.Err = errors.New(resp.Status)
+	}
+
+	t.recorder.lock.Lock()
+	t.recorder.writes = append(t.recorder.writes, write)
+	t.recorder.lock.Unlock()
+
+	return resp, err
+}
+
+// ReconcileOnce lists all resources of the replicator's kind and reconciles
+// the resource with the given key once and synchronously, without processing
+// any other events. Pull-based targets of the resource are updated as well.
+// Replications that are delayed by an annotation are not run.
+func (r *GenericReplicator) ReconcileOnce(ctx context.Context, key string) error {
+	go r.Controller.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), r.Controller.HasSynced) {
+		return errors.Errorf("could not list %ss", r.Kind)
+	}
+
+	obj, exists, err := r.Store.GetByKey(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.Errorf("%s %s not found", r.Kind, key)
+	}
+
+	// the dependents of pull-based sources are only known after their
+	// targets were processed
+	for _, target := range r.Store.List() {
+		if source, ok, err := r.replicateFrom(MustGetObject(target)); ok && err == nil && source.String() == key {
+			if r.DependencyMap[key] == nil {
+				r.DependencyMap[key] = make(map[string]interface{})
+			}
+			r.DependencyMap[key][MustGetKey(target)] = nil
+		}
+	}
+
+	return r.replicateResource(ctx, obj)
+}
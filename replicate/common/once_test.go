@@ -0,0 +1,54 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestWriteRecorderRecordsWrites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var secret corev1.Secret
+		if req.Method == http.MethodPost {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&secret))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(&secret))
+	}))
+	defer server.Close()
+
+	recorder := &WriteRecorder{}
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(recorder.Transport)
+	client := kubernetes.NewForConfigOrDie(config)
+
+	ctx := context.Background()
+	_, err := client.CoreV1().Secrets("default").Get(ctx, "foo", metav1.GetOptions{})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Secrets("default").Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "created"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	err = client.CoreV1().Secrets("other").Delete(ctx, "deleted", metav1.DeleteOptions{})
+	require.Error(t, err)
+	_, err = client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ignored"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	writes := recorder.Writes()
+	require.Len(t, writes, 2)
+	require.Equal(t, "create Secret default/created", writes[0].String())
+	require.Equal(t, "delete", writes[1].Verb)
+	require.Equal(t, "other/deleted", writes[1].Key)
+	require.Error(t, writes[1].Err)
+}
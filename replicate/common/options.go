@@ -0,0 +1,152 @@
+package common
+
+import (
+	"text/template"
+	"time"
+)
+
+// ControllerOptions contains settings that apply to all replicators. They are
+// set from command line flags before the replicators are created.
+type ControllerOptions struct {
+	// QuarantineThreshold is the number of failed replications of the same
+	// resource version after which a resource is quarantined. 0 disables the
+	// quarantine.
+	QuarantineThreshold int
+
+	// MaxReplicatedObjectBytes is the size limit for objects that are
+	// replicated. 0 disables the limit.
+	MaxReplicatedObjectBytes int
+
+	// MaxFanout is the maximum number of namespaces a source may be pushed
+	// to. 0 disables the limit.
+	MaxFanout int
+
+	// NamespaceEndpointPrefixes are the URL prefixes that "replicate-to-url"
+	// annotations may point to. Empty disables these annotations.
+	NamespaceEndpointPrefixes []string
+
+	// NamespaceEndpointRefresh is the interval after which the namespaces of
+	// an endpoint are fetched again. 0 means DefaultNamespaceEndpointRefresh.
+	NamespaceEndpointRefresh time.Duration
+
+	// MaintenanceWindows are the periods of time during which all writes are
+	// deferred.
+	MaintenanceWindows MaintenanceWindows
+
+	// SourceHash enables the SourceHashAnnotation on replicas
+	SourceHash bool
+
+	// Environment restricts the controller to sources whose
+	// EnvironmentAnnotation has this value. Empty means all sources.
+	Environment string
+
+	// ReplicaExtraAnnotations are added to all replicas that are created or
+	// updated by push-based replication
+	ReplicaExtraAnnotations map[string]string
+
+	// ReplicaMetadataDenylist contains the prefixes of label and annotation
+	// keys that are never copied from sources to replicas
+	ReplicaMetadataDenylist []string
+
+	// VerifyWrites enables reading replicas back after they were written by
+	// push-based replication, to detect changes made by admission webhooks
+	VerifyWrites bool
+
+	// ReadyAnnotation enables the ReadyAnnotation on replicas written by
+	// push-based replication. With VerifyWrites, replicas are only marked as
+	// ready once their write has been verified.
+	ReadyAnnotation bool
+
+	// WriteStrategy is how existing targets are written: WriteStrategyUpdate,
+	// WriteStrategyMergePatch or WriteStrategyApply. Empty means update.
+	WriteStrategy string
+
+	// NameCollisionStrategy is how push-based replication handles objects
+	// that have the name of a replica, but were not written by the
+	// replicator: NameCollisionOverwrite, NameCollisionSkip,
+	// NameCollisionSuffix or NameCollisionError. Empty means overwrite.
+	NameCollisionStrategy string
+
+	// RulePrecedence decides how the "replicate-to" annotation of a source is
+	// combined with matching replication rules: RulePrecedenceMerge,
+	// RulePrecedenceRules or RulePrecedenceAnnotations. Empty means merge.
+	RulePrecedence string
+
+	// ConfigDebounce is the time that changes of a watched configuration
+	// config map (feature gates, namespace mapping) are collected before
+	// they are applied together. 0 applies every change right away.
+	ConfigDebounce time.Duration
+
+	// MinReconcileInterval is the minimum interval between two reconciles of
+	// the same resource; events within the interval are coalesced into one
+	// deferred reconcile. 0 disables the limit.
+	MinReconcileInterval time.Duration
+
+	// CreatorLabel is the namespace label that "replicate-to-creator"
+	// annotations select namespaces by. Empty means DefaultCreatorLabel.
+	CreatorLabel string
+
+	// BatchWindow is the time during which writes of push-based replication
+	// are collected and coalesced before they are issued together. 0
+	// disables batching.
+	BatchWindow time.Duration
+
+	// BatchConcurrency is the number of target namespaces whose batched
+	// writes are issued in parallel. 0 means DefaultBatchConcurrency.
+	BatchConcurrency int
+
+	// DeleteBeforeCreate removes replicas from namespaces that a source does
+	// not target any more before its replicas are created or updated
+	DeleteBeforeCreate bool
+
+	// OrphanDeleteGrace is the time that a replica must stay untargeted by
+	// its source before it is removed. 0 removes it right away.
+	OrphanDeleteGrace time.Duration
+
+	// SweepDeletedSources removes the replicas of sources that were deleted
+	// while the replicator was not running once the cache is synced
+	SweepDeletedSources bool
+
+	// ExternalSink receives a copy of every secret replica. nil disables the
+	// export.
+	ExternalSink ExternalSink
+
+	// ExternalSinkPath is the template of the path that replicas are written
+	// to in the ExternalSink
+	ExternalSinkPath *template.Template
+
+	// TransformPlugin changes replicas of push-based replication before they
+	// are written. nil leaves them unchanged.
+	TransformPlugin ReplicaTransformer
+
+	// PreDeleteHookURL is called before every replica is deleted; only
+	// deletions that it approves with 200 OK are carried out. Empty disables
+	// the hook.
+	PreDeleteHookURL string
+
+	// StallThreshold is the time after which a running operation is
+	// considered to hang, which makes the liveness probe fail. 0 disables the
+	// check.
+	StallThreshold time.Duration
+
+	// ReplicaMetadataTemplate sets labels and annotations of replicas that are
+	// derived from the metadata of their target namespace. nil disables it.
+	ReplicaMetadataTemplate *ReplicaMetadataTemplate
+
+	// FailureStatusConfigMap is the name of the config map in the namespace of
+	// each source that failures to replicate the source are written to. Empty
+	// disables it.
+	FailureStatusConfigMap string
+
+	// ListPageSize is the number of objects that the informers request per
+	// page when they list all objects of a kind. 0 leaves the page size to
+	// client-go.
+	ListPageSize int64
+
+	// ReadOnly is set if writes are not sent to the API server, so that
+	// metrics that are derived from written replicas are not recorded
+	ReadOnly bool
+}
+
+// Options are the ControllerOptions used by all replicators
+var Options ControllerOptions
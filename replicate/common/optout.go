@@ -0,0 +1,31 @@
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ReplicationOptOutValue is the value of the ReplicationOptOut label or
+// annotation with which a namespace opts out of push-based replication
+const ReplicationOptOutValue = "disabled"
+
+// namespaceOptedOut checks if the owners of a namespace opted out of
+// push-based replication by labelling or annotating it with ReplicationOptOut
+func namespaceOptedOut(namespace *v1.Namespace) bool {
+	return namespace.Labels[ReplicationOptOut] == ReplicationOptOutValue ||
+		namespace.Annotations[ReplicationOptOut] == ReplicationOptOutValue
+}
+
+// refuseOptedOutNamespace checks if a target namespace opted out of
+// replication. Existing replicas in the namespace are left alone; replication
+// resumes once the label or annotation is removed.
+func (r *GenericReplicator) refuseOptedOutNamespace(obj interface{}, namespace *v1.Namespace) bool {
+	if !namespaceOptedOut(namespace) {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", MustGetKey(obj)).WithField("target", namespace.Name).
+		Debugf("not replicating %s %s to %s: namespace opted out of replication", r.Kind, MustGetKey(obj), namespace.Name)
+	r.SkipReconcile(SkipReasonOptedOut)
+	return true
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOptedOutNamespacesAreSkipped(t *testing.T) {
+	var replicated []string
+	r := newTestReplicator(t, "OptOutSecret")
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		replicated = append(replicated, target.Name)
+		return nil
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	targets := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "labelled", Labels: map[string]string{ReplicationOptOut: ReplicationOptOutValue}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "annotated", Annotations: map[string]string{ReplicationOptOut: ReplicationOptOutValue}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other-value", Labels: map[string]string{ReplicationOptOut: "enabled"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "plain"}},
+	}
+
+	replicatedTo, _, err := r.replicateResourceToNamespaces(context.Background(), source, targets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other-value", "plain"}, replicated)
+	assert.Len(t, replicatedTo, 2)
+	assert.Equal(t, float64(2), testutil.ToFloat64(ReconcileSkippedTotal.WithLabelValues("OptOutSecret", SkipReasonOptedOut)))
+
+	// removing the opt-out resumes replication on the next reconcile
+	delete(targets[0].Labels, ReplicationOptOut)
+	replicated = nil
+
+	_, _, err = r.replicateResourceToNamespaces(context.Background(), source, targets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"labelled", "other-value", "plain"}, replicated)
+}
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// dependencyRetryDelay is the time after which a replication that waits for
+// the replica of a dependency is tried again
+var dependencyRetryDelay = 5 * time.Second
+
+// dependencyWaitTimeout is the time after which a replication stops waiting
+// for the replica of a dependency
+var dependencyWaitTimeout = 2 * time.Minute
+
+// replicationDependency is an object that must be replicated into a target
+// namespace before the object that declares it with an After annotation
+type replicationDependency struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (d replicationDependency) key() string {
+	return d.Namespace + "/" + d.Name
+}
+
+// replicatorsByKind holds all replicators, so that the replicas of
+// dependencies of other kinds can be looked up
+var replicatorsByKind = struct {
+	lock        sync.RWMutex
+	replicators map[string]*GenericReplicator
+}{replicators: make(map[string]*GenericReplicator)}
+
+func registerReplicator(r *GenericReplicator) {
+	replicatorsByKind.lock.Lock()
+	defer replicatorsByKind.lock.Unlock()
+
+	replicatorsByKind.replicators[r.Kind] = r
+}
+
+func replicatorForKind(kind string) (*GenericReplicator, bool) {
+	replicatorsByKind.lock.RLock()
+	defer replicatorsByKind.lock.RUnlock()
+
+	r, ok := replicatorsByKind.replicators[kind]
+	return r, ok
+}
+
+// replicationDependencies parses the After annotation of an object, a comma
+// separated list of <kind>/<namespace>/<name> or <kind>/<name> (in the
+// namespace of the object). Invalid entries are logged and skipped.
+func replicationDependencies(object metav1.Object) []replicationDependency {
+	value, ok := object.GetAnnotations()[After]
+	if !ok {
+		return nil
+	}
+
+	var dependencies []replicationDependency
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "/")
+		switch {
+		case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+			dependencies = append(dependencies, replicationDependency{Kind: parts[0], Namespace: object.GetNamespace(), Name: parts[1]})
+		case len(parts) == 3 && parts[0] != "" && parts[1] != "" && parts[2] != "":
+			dependencies = append(dependencies, replicationDependency{Kind: parts[0], Namespace: parts[1], Name: parts[2]})
+		default:
+			log.WithField("resource", MustGetKey(object)).Warnf("ignoring invalid entry '%s' in %s annotation", entry, After)
+		}
+	}
+
+	return dependencies
+}
+
+// dependencyWait identifies a replication that waits for the replica of a
+// dependency
+type dependencyWait struct {
+	SourceKey string
+	Namespace string
+}
+
+// hasReplicaIn checks if the replicator holds a replica of source in
+// namespace, under any of the names its replicas may have
+func (r *GenericReplicator) hasReplicaIn(source metav1.Object, namespace string) bool {
+	for _, key := range replicaKeys(source, namespace) {
+		replica, exists, err := r.Store.GetByKey(key)
+		if err == nil && exists && ReplicaSource(MustGetObject(replica)) == MustGetKey(source) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// missingDependency returns the first dependency of a source that is pushed
+// into namespace, but whose replica does not exist there yet. Dependencies
+// that don't exist, that are of a kind that isn't replicated or that don't
+// target the namespace are not waited for.
+func missingDependency(source metav1.Object, namespace *v1.Namespace) (replicationDependency, bool) {
+	for _, dependency := range replicationDependencies(source) {
+		if dependency.Namespace == namespace.Name {
+			continue
+		}
+
+		repl, ok := replicatorForKind(dependency.Kind)
+		if !ok {
+			log.WithField("source", MustGetKey(source)).Debugf("%ss are not replicated, not waiting for dependency %s", dependency.Kind, dependency.key())
+			continue
+		}
+
+		depObj, exists, err := repl.Store.GetByKey(dependency.key())
+		if err != nil || !exists {
+			log.WithField("source", MustGetKey(source)).Debugf("dependency %s %s does not exist, not waiting for it", dependency.Kind, dependency.key())
+			continue
+		}
+
+		if _, targeted := pushTargets(dependency.Kind, MustGetObject(depObj), []v1.Namespace{*namespace})[namespace.Name]; !targeted {
+			continue
+		}
+
+		if !repl.hasReplicaIn(MustGetObject(depObj), namespace.Name) {
+			return dependency, true
+		}
+	}
+
+	return replicationDependency{}, false
+}
+
+// deferForDependency checks if the source has not been written into the
+// target namespace yet and a dependency that is pushed there is missing. If
+// so, the replication into the namespace is tried again after
+// dependencyRetryDelay and true is returned. A replication waits for at most
+// dependencyWaitTimeout, since the dependency may never be written (e.g. if
+// it is refused in the namespace); after that, it is written anyway and a
+// warning event is recorded on the source.
+func (r *GenericReplicator) deferForDependency(obj interface{}, namespace *v1.Namespace) bool {
+	objectMeta := MustGetObject(obj)
+	sourceKey := MustGetKey(objectMeta)
+	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey).WithField("target", namespace.Name)
+	wait := dependencyWait{SourceKey: sourceKey, Namespace: namespace.Name}
+
+	dependency, missing := missingDependency(objectMeta, namespace)
+	if !missing || r.hasReplicaIn(objectMeta, namespace.Name) {
+		delete(r.dependencyWaits, wait)
+		return false
+	}
+
+	since, waiting := r.dependencyWaits[wait]
+	switch {
+	case !waiting:
+		if r.dependencyWaits == nil {
+			r.dependencyWaits = make(map[dependencyWait]time.Time)
+		}
+		r.dependencyWaits[wait] = time.Now()
+		logger.Infof("waiting for dependency %s %s to be replicated to %s before replicating %s", dependency.Kind, dependency.key(), namespace.Name, sourceKey)
+	case time.Since(since) >= dependencyWaitTimeout:
+		delete(r.dependencyWaits, wait)
+		logger.Warnf("dependency %s %s was not replicated to %s within %s, replicating %s anyway", dependency.Kind, dependency.key(), namespace.Name, dependencyWaitTimeout, sourceKey)
+		r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "DependencyNotReplicated",
+			"%s %s was not replicated to %s within %s; replicating without it", dependency.Kind, dependency.key(), namespace.Name, dependencyWaitTimeout)
+		return false
+	default:
+		logger.Debugf("still waiting for dependency %s %s to be replicated to %s", dependency.Kind, dependency.key(), namespace.Name)
+	}
+
+	r.DelayQueue.AddAfter(delayedReplication{SourceKey: sourceKey, Namespace: namespace.Name}, dependencyRetryDelay)
+	return true
+}
+
+// forgetDependencyWaits drops the waits of a deleted source
+func (r *GenericReplicator) forgetDependencyWaits(sourceKey string) {
+	for wait := range r.dependencyWaits {
+		if wait.SourceKey == sourceKey {
+			delete(r.dependencyWaits, wait)
+		}
+	}
+}
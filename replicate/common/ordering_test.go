@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReplicationDependencies(t *testing.T) {
+	object := &metav1.ObjectMeta{Namespace: "apps", Name: "settings", Annotations: map[string]string{
+		After: "Secret/kube-system/credentials, Secret/local,invalid",
+	}}
+
+	assert.Equal(t, []replicationDependency{
+		{Kind: "Secret", Namespace: "kube-system", Name: "credentials"},
+		{Kind: "Secret", Namespace: "apps", Name: "local"},
+	}, replicationDependencies(object))
+}
+
+func TestDeferForDependency(t *testing.T) {
+	defer func(delay time.Duration) { dependencyRetryDelay = delay }(dependencyRetryDelay)
+	dependencyRetryDelay = 0
+
+	secrets := newTestReplicator(t, "Secret")
+
+	recorder := record.NewFakeRecorder(10)
+	configMaps := newTestReplicator(t, "ConfigMap")
+	configMaps.Recorder = recorder
+
+	configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "settings", Annotations: map[string]string{
+		ReplicateTo: "team-a,team-b,team-c",
+		After:       "Secret/kube-system/credentials",
+	}}}
+	teamA := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	teamB := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}
+	teamC := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}}
+
+	assert.False(t, configMaps.deferForDependency(configMap, teamA), "missing dependencies are not waited for")
+
+	dependency := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "credentials", Annotations: map[string]string{
+		ReplicateTo: "team-a,team-c",
+	}}}
+	require.NoError(t, secrets.Store.Add(dependency))
+	assert.True(t, configMaps.deferForDependency(configMap, teamA), "the dependency is not replicated yet")
+	assert.False(t, configMaps.deferForDependency(configMap, teamB), "the dependency doesn't target team-b")
+
+	item, _ := configMaps.DelayQueue.Get()
+	assert.Equal(t, delayedReplication{SourceKey: "kube-system/settings", Namespace: "team-a"}, item)
+	configMaps.DelayQueue.Done(item)
+
+	require.NoError(t, secrets.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "credentials"}}))
+	assert.True(t, configMaps.deferForDependency(configMap, teamA), "an object not written by the replicator is not the replica")
+
+	require.NoError(t, secrets.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "team-a",
+		Name:      SuffixedReplicaName(dependency),
+		Labels:    map[string]string{SourceNamespaceLabel: "kube-system", SourceNameLabel: "credentials"},
+	}}))
+	assert.False(t, configMaps.deferForDependency(configMap, teamA), "the dependency was replicated under a suffixed name")
+	assert.Empty(t, configMaps.dependencyWaits)
+
+	t.Run("waits are bounded", func(t *testing.T) {
+		defer func(timeout time.Duration) { dependencyWaitTimeout = timeout }(dependencyWaitTimeout)
+		dependencyWaitTimeout = 0
+
+		assert.True(t, configMaps.deferForDependency(configMap, teamC))
+		assert.False(t, configMaps.deferForDependency(configMap, teamC), "the source is written once the wait timed out")
+		assert.Contains(t, <-recorder.Events, "DependencyNotReplicated")
+	})
+
+	t.Run("sources that were written are not held up", func(t *testing.T) {
+		require.NoError(t, configMaps.Store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-c",
+			Name:      "settings",
+			Labels:    map[string]string{SourceNamespaceLabel: "kube-system", SourceNameLabel: "settings"},
+		}}))
+		assert.False(t, configMaps.deferForDependency(configMap, teamC))
+	})
+}
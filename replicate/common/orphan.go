@@ -0,0 +1,96 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Values of the OnSourceDelete annotation
+const (
+	OnSourceDeleteCascade = "cascade"
+	OnSourceDeleteOrphan  = "orphan"
+	OnSourceDeleteClear   = "clear"
+)
+
+// replicaAnnotations are the annotations that tie a replica to its source
+var replicaAnnotations = []string{
+	ReplicateFromAnnotation,
+	ReplicatedAtAnnotation,
+	ReplicatedFromVersionAnnotation,
+	ReplicatedFromUIDAnnotation,
+	ReplicatedKeysAnnotation,
+	AugmentedKeysAnnotation,
+	SourceHashAnnotation,
+	ReplicationChainAnnotation,
+	ReadyAnnotation,
+}
+
+// onSourceDelete returns what should happen to the replicas of a deleted
+// source. Sources without a valid OnSourceDelete annotation use the given
+// default.
+func onSourceDelete(source metav1.Object, defaultMode string) string {
+	mode, ok := source.GetAnnotations()[OnSourceDelete]
+	if !ok {
+		return defaultMode
+	}
+
+	switch mode {
+	case OnSourceDeleteCascade, OnSourceDeleteOrphan, OnSourceDeleteClear:
+		return mode
+	}
+
+	log.WithField("source", MustGetKey(source)).Warnf("invalid %s annotation '%s', using '%s'", OnSourceDelete, mode, defaultMode)
+	return defaultMode
+}
+
+// OrphanPatch builds a patch that removes all annotations and labels that tie
+// the target to its source, so that it is not touched by the replicator any
+// more.
+func OrphanPatch(target metav1.Object) []JSONPatchOperation {
+	patch := make([]JSONPatchOperation, 0)
+	annotations := target.GetAnnotations()
+	labels := target.GetLabels()
+
+	for _, annotation := range replicaAnnotations {
+		if _, ok := annotations[annotation]; ok {
+			patch = append(patch, JSONPatchOperation{Operation: "remove", Path: fmt.Sprintf("/metadata/annotations/%s", JSONPatchPathEscape(annotation))})
+		}
+	}
+	for _, label := range replicaLabels {
+		if _, ok := labels[label]; ok {
+			patch = append(patch, JSONPatchOperation{Operation: "remove", Path: fmt.Sprintf("/metadata/labels/%s", JSONPatchPathEscape(label))})
+		}
+	}
+
+	return patch
+}
+
+// releaseReplica deletes, orphans or clears a replica of a deleted source.
+// Replicas of kinds that can't be cleared are deleted instead.
+func (r *GenericReplicator) releaseReplica(sourceKey string, mode string, target interface{}) error {
+	var s interface{}
+	var err error
+
+	switch {
+	case mode == OnSourceDeleteClear && r.UpdateFuncs.PatchDeleteDependent == nil:
+		log.WithField("kind", r.Kind).WithField("target", MustGetKey(target)).Debugf("%ss can't be cleared, deleting %s", r.Kind, MustGetKey(target))
+		fallthrough
+	case mode == OnSourceDeleteCascade:
+		return r.deleteReplicatedResource(sourceKey, target)
+	case mode == OnSourceDeleteOrphan:
+		s, err = r.UpdateFuncs.PatchOrphanDependent(target)
+	default:
+		s, err = r.UpdateFuncs.PatchDeleteDependent(sourceKey, target)
+	}
+
+	if err != nil {
+		return err
+	}
+	if err := r.Store.Update(s); err != nil {
+		return errors.Wrapf(err, "Error updating store for %s %s: %v", r.Kind, MustGetKey(s), err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReplicasOfUnclearableKindsAreDeleted(t *testing.T) {
+	r := newTestReplicator(t, "Ingress")
+
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	replica := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "common"}}
+	require.NoError(t, r.releaseReplica("platform/common", OnSourceDeleteClear, replica))
+	assert.Equal(t, []string{"tenant-a/common"}, deleted)
+}
@@ -1,5 +1,16 @@
 package common
 
+import (
+	"encoding/json"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
 // JSONPatchOperation is a struct that defines PATCH operations on
 // a JSON structure.
 type JSONPatchOperation struct {
@@ -7,3 +18,144 @@ type JSONPatchOperation struct {
 	Path      string      `json:"path"`
 	Value     interface{} `json:"value,omitempty"`
 }
+
+// Values of the WriteStrategy annotation and of Options.WriteStrategy. The
+// annotation only accepts WriteStrategyUpdate and WriteStrategyMergePatch.
+const (
+	WriteStrategyUpdate     = "update"
+	WriteStrategyMergePatch = "merge-patch"
+	WriteStrategyApply      = "apply"
+)
+
+// FieldManager is the field manager of the replicator's server-side applies
+const FieldManager = "kubernetes-replicator"
+
+// ParseWriteStrategy validates the global write strategy
+func ParseWriteStrategy(strategy string) (string, error) {
+	switch strategy {
+	case WriteStrategyUpdate, WriteStrategyMergePatch, WriteStrategyApply:
+		return strategy, nil
+	}
+
+	return "", errors.Errorf("invalid write strategy '%s': expected %s, %s or %s",
+		strategy, WriteStrategyUpdate, WriteStrategyMergePatch, WriteStrategyApply)
+}
+
+// TargetWriteStrategy returns how a target is written. The WriteStrategy
+// annotation of the target overrides Options.WriteStrategy.
+func TargetWriteStrategy(object *metav1.ObjectMeta) string {
+	switch strategy := object.Annotations[WriteStrategy]; strategy {
+	case WriteStrategyUpdate, WriteStrategyMergePatch:
+		return strategy
+	}
+
+	if Options.WriteStrategy == "" {
+		return WriteStrategyUpdate
+	}
+	return Options.WriteStrategy
+}
+
+// UsesMergePatch checks if a target is written with a JSON merge patch of the
+// replicator's changes instead of being replaced by an update
+func UsesMergePatch(object *metav1.ObjectMeta) bool {
+	return TargetWriteStrategy(object) == WriteStrategyMergePatch
+}
+
+// UsesApply checks if a target is written with a server-side apply
+func UsesApply(object *metav1.ObjectMeta) bool {
+	return TargetWriteStrategy(object) == WriteStrategyApply
+}
+
+// ApplyPatch serializes the fields of an object that the replicator manages
+// as the body of a server-side apply: the name and namespace, the
+// replicator's own labels and annotations and the given top-level content
+// fields (e.g. "data"). All other fields are left out, so the replicator
+// never claims ownership of fields that others manage.
+func ApplyPatch(object runtime.Object, gvk schema.GroupVersionKind, fields ...string) ([]byte, error) {
+	objectJSON, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	content := make(map[string]interface{})
+	if err := json.Unmarshal(objectJSON, &content); err != nil {
+		return nil, err
+	}
+
+	meta := MustGetObject(object)
+	metadata := map[string]interface{}{
+		"name":      meta.GetName(),
+		"namespace": meta.GetNamespace(),
+	}
+	if labels := replicatorKeys(meta.GetLabels()); len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if annotations := replicatorKeys(meta.GetAnnotations()); len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	applied := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   metadata,
+	}
+	for _, field := range fields {
+		if value, ok := content[field]; ok {
+			applied[field] = value
+		}
+	}
+
+	return json.Marshal(applied)
+}
+
+// replicatorKeys returns the labels or annotations that belong to the
+// replicator, i.e. that are prefixed with replicator.v1.mittwald.de/
+func replicatorKeys(values map[string]string) map[string]string {
+	owned := make(map[string]string)
+	for key, value := range values {
+		if strings.HasPrefix(key, "replicator.v1.mittwald.de/") {
+			owned[key] = value
+		}
+	}
+	return owned
+}
+
+// MergePatch creates a JSON merge patch that only contains the fields that
+// differ between original and modified. Fields that others changed after
+// original was read are not part of the patch, so they are kept.
+func MergePatch(original interface{}, modified interface{}) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonpatch.CreateMergePatch(originalJSON, modifiedJSON)
+}
+
+// RebaseUpdate applies the changes between original and modified to latest, a
+// more recent version of the same object, and decodes the result into
+// rebased. Everything that the replicator did not change, e.g. finalizers,
+// owner references or annotations added by others after original was read,
+// is taken from latest.
+func RebaseUpdate(original interface{}, modified interface{}, latest interface{}, rebased interface{}) error {
+	patch, err := MergePatch(original, modified)
+	if err != nil {
+		return err
+	}
+	latestJSON, err := json.Marshal(latest)
+	if err != nil {
+		return err
+	}
+
+	rebasedJSON, err := jsonpatch.MergePatch(latestJSON, patch)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(rebasedJSON, rebased)
+}
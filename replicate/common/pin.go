@@ -0,0 +1,23 @@
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RefusePinnedReplica checks if a pull-based target is pinned to a version of
+// its source with the PinVersion annotation that differs from the source's
+// current version. Pinned targets keep their data until the pin is changed to
+// the current version or removed.
+func (r *GenericReplicator) RefusePinnedReplica(target metav1.Object, source metav1.Object) bool {
+	pin, ok := target.GetAnnotations()[PinVersion]
+	if !ok || pin == source.GetResourceVersion() {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", MustGetKey(source)).WithField("target", MustGetKey(target)).
+		Debugf("not updating target %s: pinned to version %s of source %s, which is at version %s",
+			MustGetKey(target), pin, MustGetKey(source), source.GetResourceVersion())
+	r.SkipReconcile(SkipReasonPinned)
+	return true
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// preDeleteHookRetryDelay is the time after which a deletion that was not
+// approved by the pre-delete hook is tried again
+var preDeleteHookRetryDelay = 30 * time.Second
+
+var preDeleteHookClient = &http.Client{Timeout: 10 * time.Second}
+
+// PreDeleteHookRequest is sent to the pre-delete hook before a replica is
+// deleted
+type PreDeleteHookRequest struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+
+	// Source is the key of the source of the replica, if it is known
+	Source string `json:"source,omitempty"`
+}
+
+// callPreDeleteHook asks the hook at Options.PreDeleteHookURL whether the
+// replica may be deleted. The deletion is approved only if the hook responds
+// with 200 OK; without a hook, all deletions are approved.
+func callPreDeleteHook(kind string, target metav1.Object) error {
+	if Options.PreDeleteHookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(PreDeleteHookRequest{
+		Kind:      kind,
+		Namespace: target.GetNamespace(),
+		Name:      target.GetName(),
+		UID:       string(target.GetUID()),
+		Source:    ReplicaSource(target),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := preDeleteHookClient.Post(Options.PreDeleteHookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "could not call pre-delete hook")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("pre-delete hook responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// deleteReplicatedResource deletes a replica of the source with the given key
+// once the pre-delete hook approved it. Deletions that were not approved are
+// deferred and tried again later, without returning an error.
+func (r *GenericReplicator) deleteReplicatedResource(sourceKey string, target interface{}) error {
+	if err := callPreDeleteHook(r.Kind, MustGetObject(target)); err != nil {
+		targetKey := MustGetKey(target)
+		log.WithField("kind", r.Kind).WithField("target", targetKey).WithError(err).
+			Warnf("deletion of %s %s was not approved, retrying in %s", r.Kind, targetKey, preDeleteHookRetryDelay)
+		PreDeleteDeferralsTotal.WithLabelValues(r.Kind).Inc()
+		r.DelayQueue.AddAfter(newDelayedDeletion(sourceKey, target), preDeleteHookRetryDelay)
+
+		return nil
+	}
+
+	return r.UpdateFuncs.DeleteReplicatedResource(target)
+}
+
+// ReplicaDeleteOptions returns the options for deleting a replica, with
+// preconditions that make the deletion fail if the replica was recreated or
+// changed since it was cached, since the decision to delete it was based on
+// the cached version
+func ReplicaDeleteOptions(target metav1.Object) metav1.DeleteOptions {
+	options := metav1.DeleteOptions{}
+	if target.GetUID() != "" {
+		uid, version := target.GetUID(), target.GetResourceVersion()
+		options.Preconditions = &metav1.Preconditions{UID: &uid, ResourceVersion: &version}
+	}
+
+	return options
+}
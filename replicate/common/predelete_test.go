@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPreDeleteHookDefersDeletions(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	var requests []PreDeleteHookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var request PreDeleteHookRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+		requests = append(requests, request)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	defer func(url string) { Options.PreDeleteHookURL = url }(Options.PreDeleteHookURL)
+	Options.PreDeleteHookURL = server.URL
+	defer func(delay time.Duration) { preDeleteHookRetryDelay = delay }(preDeleteHookRetryDelay)
+	preDeleteHookRetryDelay = 0
+
+	r := newTestReplicator(t, "Secret")
+
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	replica := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "registry", UID: "1", Labels: map[string]string{
+		SourceNamespaceLabel: "kube-system",
+		SourceNameLabel:      "registry",
+	}}}
+	require.NoError(t, r.Store.Add(replica))
+
+	require.NoError(t, r.deleteReplicatedResource("kube-system/registry", replica))
+	assert.Empty(t, deleted, "deletions are deferred until the hook approves them")
+	assert.Equal(t, []PreDeleteHookRequest{{Kind: "Secret", Namespace: "team-a", Name: "registry", UID: "1", Source: "kube-system/registry"}}, requests)
+
+	item, _ := r.DelayQueue.Get()
+	assert.Equal(t, delayedDeletion{TargetKey: "team-a/registry", TargetUID: "1", SourceKey: "kube-system/registry"}, item)
+	r.DelayQueue.Done(item)
+
+	status = http.StatusOK
+	r.deleteDelayed(item.(delayedDeletion))
+	assert.Equal(t, []string{"team-a/registry"}, deleted)
+	assert.Len(t, requests, 2)
+}
+
+func TestDeferredDeletionsAreCheckedAgain(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}))
+
+	r := newTestReplicator(t, "Secret")
+
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	replica := func(uid string, labels map[string]string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "registry", UID: types.UID(uid), Labels: labels}}
+	}
+	replicaLabels := map[string]string{SourceNamespaceLabel: "kube-system", SourceNameLabel: "registry"}
+	item := delayedDeletion{TargetKey: "team-a/registry", TargetUID: "1", SourceKey: "kube-system/registry"}
+
+	require.NoError(t, r.Store.Add(replica("1", replicaLabels)))
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "registry", Annotations: map[string]string{ReplicateTo: "team-a"}}}
+	require.NoError(t, r.Store.Add(source))
+	r.deleteDelayed(item)
+	assert.Empty(t, deleted, "the source was recreated and targets the namespace again")
+
+	require.NoError(t, r.Store.Delete(source))
+	require.NoError(t, r.Store.Update(replica("1", nil)))
+	r.deleteDelayed(item)
+	assert.Empty(t, deleted, "the replica is not written by the replicator any more")
+
+	require.NoError(t, r.Store.Update(replica("2", replicaLabels)))
+	r.deleteDelayed(item)
+	assert.Empty(t, deleted, "the replica was recreated")
+
+	require.NoError(t, r.Store.Update(replica("1", replicaLabels)))
+	r.deleteDelayed(item)
+	assert.Equal(t, []string{"team-a/registry"}, deleted, "the source is still deleted")
+}
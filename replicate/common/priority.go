@@ -0,0 +1,198 @@
+package common
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Priorities of the events in the event queue of a replicator
+const (
+	// PriorityResync is used for resyncs, which deliver objects that did not
+	// change since they were last processed
+	PriorityResync = "resync"
+
+	// PriorityChange is used for objects that were created, changed or
+	// deleted
+	PriorityChange = "change"
+)
+
+// eventQueue holds the keys of the objects whose events still have to be
+// processed, in two FIFO queues. Keys with changes are always processed before
+// resyncs, so that new objects are replicated quickly even while a large
+// resync is processed. Every key is queued only once; a change of a key that
+// is waiting for its resync moves it to the front queue.
+type eventQueue struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	kind     string
+	changes  []string
+	resyncs  []string
+	queued   map[string]string
+	shutdown bool
+}
+
+func newEventQueue(kind string) *eventQueue {
+	q := &eventQueue{
+		kind:   kind,
+		queued: make(map[string]string),
+	}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+// Add queues a key with the given priority
+func (q *eventQueue) Add(key string, priority string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.shutdown {
+		return
+	}
+
+	switch q.queued[key] {
+	case PriorityChange:
+		return
+	case PriorityResync:
+		if priority == PriorityResync {
+			return
+		}
+		q.resyncs = removeKey(q.resyncs, key)
+	}
+
+	q.queued[key] = priority
+	if priority == PriorityChange {
+		q.changes = append(q.changes, key)
+	} else {
+		q.resyncs = append(q.resyncs, key)
+	}
+	q.updateMetrics()
+	q.cond.Signal()
+}
+
+// Get blocks until a key is queued and returns it, preferring changes over
+// resyncs. ok is false once the queue was shut down.
+func (q *eventQueue) Get() (key string, ok bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for len(q.changes) == 0 && len(q.resyncs) == 0 && !q.shutdown {
+		q.cond.Wait()
+	}
+	if q.shutdown {
+		return "", false
+	}
+
+	if len(q.changes) > 0 {
+		key, q.changes = q.changes[0], q.changes[1:]
+	} else {
+		key, q.resyncs = q.resyncs[0], q.resyncs[1:]
+	}
+	delete(q.queued, key)
+	q.updateMetrics()
+
+	return key, true
+}
+
+// Len returns the number of queued keys
+func (q *eventQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.queued)
+}
+
+// ShutDown makes Get return, so that the worker of the queue ends
+func (q *eventQueue) ShutDown() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.shutdown = true
+	q.cond.Broadcast()
+}
+
+func (q *eventQueue) updateMetrics() {
+	QueuedEvents.WithLabelValues(q.kind, PriorityChange).Set(float64(len(q.changes)))
+	QueuedEvents.WithLabelValues(q.kind, PriorityResync).Set(float64(len(q.resyncs)))
+}
+
+func removeKey(keys []string, key string) []string {
+	for i := range keys {
+		if keys[i] == key {
+			return append(keys[:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
+
+// eventPriority returns the priority of an update event. Resyncs deliver the
+// same version of an object again.
+func eventPriority(old interface{}, new interface{}) string {
+	if MustGetObject(old).GetResourceVersion() == MustGetObject(new).GetResourceVersion() {
+		return PriorityResync
+	}
+	return PriorityChange
+}
+
+// enqueue queues an object of an informer event
+func (r *GenericReplicator) enqueue(obj interface{}, priority string) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Error("could not get key of object")
+		return
+	}
+
+	r.events.Add(key, priority)
+}
+
+// enqueueDeletion queues a deleted object. The object is kept until the event
+// is processed, since it is not in the store any more.
+func (r *GenericReplicator) enqueueDeletion(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	r.deletedLock.Lock()
+	r.deleted[MustGetKey(obj)] = obj
+	r.deletedLock.Unlock()
+
+	r.enqueue(obj, PriorityChange)
+}
+
+// runEvents processes the queued events until the queue is shut down. The
+// latest version of each object is taken from the store, so that events that
+// arrived while a key was queued are coalesced.
+func (r *GenericReplicator) runEvents() {
+	for {
+		key, ok := r.events.Get()
+		if !ok {
+			return
+		}
+		r.whenWritable(objectEvent(key), func() { r.processEvent(key) })
+	}
+}
+
+// processEvent processes the latest state of an object: objects that are in
+// the store were added or updated, all others were deleted. It must only run
+// as an operation of the replicator.
+func (r *GenericReplicator) processEvent(key string) {
+	r.deletedLock.Lock()
+	deleted, wasDeleted := r.deleted[key]
+	delete(r.deleted, key)
+	r.deletedLock.Unlock()
+
+	obj, exists, err := r.Store.GetByKey(key)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Error("error fetching object from store")
+		return
+	}
+
+	// an object that was deleted and created again is first removed
+	if wasDeleted && (!exists || MustGetObject(obj).GetUID() != MustGetObject(deleted).GetUID()) {
+		r.ResourceDeleted(deleted)
+	}
+	if exists {
+		r.ResourceAdded(obj)
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func drainEventQueue(t *testing.T, q *eventQueue) []string {
+	var keys []string
+	for q.Len() > 0 {
+		key, ok := q.Get()
+		require.True(t, ok)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestEventQueuePrefersChanges(t *testing.T) {
+	q := newEventQueue("Secret")
+
+	q.Add("default/resync-1", PriorityResync)
+	q.Add("default/resync-2", PriorityResync)
+	q.Add("default/created", PriorityChange)
+	q.Add("default/resync-3", PriorityResync)
+
+	assert.Equal(t, []string{"default/created", "default/resync-1", "default/resync-2", "default/resync-3"}, drainEventQueue(t, q))
+
+	t.Run("keys are queued only once", func(t *testing.T) {
+		q.Add("default/foo", PriorityChange)
+		q.Add("default/foo", PriorityChange)
+		q.Add("default/foo", PriorityResync)
+
+		assert.Equal(t, []string{"default/foo"}, drainEventQueue(t, q))
+	})
+
+	t.Run("changes move resynced keys to the front", func(t *testing.T) {
+		q.Add("default/resync", PriorityResync)
+		q.Add("default/changed", PriorityResync)
+		q.Add("default/changed", PriorityChange)
+
+		assert.Equal(t, []string{"default/changed", "default/resync"}, drainEventQueue(t, q))
+	})
+
+	t.Run("get returns after shutdown", func(t *testing.T) {
+		q.ShutDown()
+		_, ok := q.Get()
+		assert.False(t, ok)
+	})
+}
+
+func TestEventPriority(t *testing.T) {
+	old := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", ResourceVersion: "1"}}
+	changed := old.DeepCopy()
+	changed.ResourceVersion = "2"
+
+	assert.Equal(t, PriorityResync, eventPriority(old, old.DeepCopy()))
+	assert.Equal(t, PriorityChange, eventPriority(old, changed))
+}
+
+func TestProcessEvent(t *testing.T) {
+	r := newTestReplicator(t, "Secret")
+
+	var processed []string
+	r.UpdateFuncs.OnResourceAdded = func(obj interface{}) error {
+		processed = append(processed, "added "+MustGetKey(obj))
+		return nil
+	}
+	r.UpdateFuncs.OnResourceDeleted = func(obj interface{}) {
+		processed = append(processed, "deleted "+MustGetKey(obj))
+	}
+
+	deleted := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deleted", UID: types.UID("1")}}
+	r.enqueueDeletion(cache.DeletedFinalStateUnknown{Key: "default/deleted", Obj: deleted})
+
+	recreated := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "recreated", UID: types.UID("2")}}
+	r.enqueueDeletion(recreated)
+	recreated = recreated.DeepCopy()
+	recreated.UID = types.UID("3")
+	require.NoError(t, r.Store.Add(recreated))
+	r.enqueue(recreated, PriorityChange)
+
+	for _, key := range drainEventQueue(t, r.events) {
+		r.processEvent(key)
+	}
+
+	assert.Equal(t, []string{"deleted default/deleted", "deleted default/recreated", "added default/recreated"}, processed)
+	assert.Empty(t, r.deleted)
+}
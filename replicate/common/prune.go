@@ -0,0 +1,203 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// pruneStaleReplicas removes the replicas of a source from all namespaces that
+// the source does not target any more. Only replicas that were written by the
+// source itself are removed; what happens to them depends on the
+// OnSourceDelete annotation of the source. With Options.OrphanDeleteGrace,
+// replicas are only removed once they stayed untargeted for that long. It
+// returns an error if the targets of the source could not be determined.
+func (r *GenericReplicator) pruneStaleReplicas(obj interface{}) error {
+	objectMeta := MustGetObject(obj)
+	sourceKey := MustGetKey(objectMeta)
+	logger := log.WithField("kind", r.Kind).WithField("resource", sourceKey)
+
+	if err := validatePushAnnotations(objectMeta); err != nil {
+		logger.WithError(err).Debug("not pruning stale replicas, since the targets can't be determined")
+		return err
+	}
+
+	namespaces := namespacesFromStore()
+	targets := pushTargets(r.Kind, objectMeta, namespaces)
+	orphaned := make(map[string]struct{})
+
+	for _, namespace := range namespaces {
+		if _, targeted := targets[namespace.Name]; targeted || namespace.Name == objectMeta.GetNamespace() {
+			continue
+		}
+
+		for _, targetKey := range replicaKeys(objectMeta, namespace.Name) {
+			target, exists, err := r.Store.GetByKey(targetKey)
+			if err != nil || !exists {
+				continue
+			}
+
+			targetMeta := MustGetObject(target)
+			if _, isPullTarget := targetMeta.GetAnnotations()[ReplicateFromAnnotation]; isPullTarget || ReplicaSource(targetMeta) != sourceKey {
+				continue
+			}
+
+			orphaned[targetKey] = struct{}{}
+			if !r.orphanGraceElapsed(sourceKey, targetKey) {
+				logger.Debugf("%s %s is not targeted by %s any more, waiting for the grace period before removing it", r.Kind, targetKey, sourceKey)
+				continue
+			}
+
+			logger.Infof("%s %s is not targeted by %s any more, removing it", r.Kind, targetKey, sourceKey)
+			r.deleteReplica(objectMeta, targetKey)
+		}
+	}
+
+	r.forgetOrphans(sourceKey, orphaned)
+
+	return nil
+}
+
+// isPushSource checks if a resource is contained in any list of push-based
+// sources
+func (r *GenericReplicator) isPushSource(sourceKey string) bool {
+	_, replicateTo := r.ReplicateToList[sourceKey]
+	_, replicateToMatching := r.ReplicateToMatchingList[sourceKey]
+	_, replicateToLabelKey := r.ReplicateToLabelKeyList[sourceKey]
+	_, replicateToRelease := r.ReplicateToReleaseList[sourceKey]
+	_, replicateToCreator := r.ReplicateToCreatorList[sourceKey]
+	_, replicateToCEL := r.ReplicateToCELList[sourceKey]
+	_, replicateToURL := r.ReplicateToURLList[sourceKey]
+	_, replicateToConsumers := r.ReplicateToConsumersList[sourceKey]
+
+	return replicateTo || replicateToMatching || replicateToLabelKey || replicateToRelease || replicateToCreator || replicateToCEL || replicateToURL || replicateToConsumers
+}
+
+// hasPushAnnotations checks if a resource is a source of push-based
+// replication, either by one of its annotations or by a replication rule
+func hasPushAnnotations(kind string, object metav1.Object) bool {
+	annotations := object.GetAnnotations()
+	if _, ok := replicateToPatterns(kind, object, annotations); ok {
+		return true
+	}
+
+	for _, annotation := range []string{ReplicateToMatching, ReplicateToLabelKey, ReplicateToRelease, ReplicateToCreator, ReplicateToCEL, ReplicateToURL} {
+		if _, ok := annotations[annotation]; ok {
+			return true
+		}
+	}
+
+	return replicatesToConsumers(object)
+}
+
+// validatePushAnnotations checks that all annotations that select the targets
+// of push-based replication can be parsed, so that no replica is pruned
+// because of a typo.
+func validatePushAnnotations(object metav1.Object) error {
+	annotations := object.GetAnnotations()
+
+	if selector, ok := annotations[ReplicateToMatching]; ok {
+		if _, err := labels.Parse(selector); err != nil {
+			return err
+		}
+	}
+	if labelKey, ok := annotations[ReplicateToLabelKey]; ok {
+		if _, err := labelKeySelector(labelKey, object.GetNamespace()); err != nil {
+			return err
+		}
+	}
+	if release, ok := annotations[ReplicateToRelease]; ok {
+		if _, err := releaseSelector(release); err != nil {
+			return err
+		}
+	}
+	if creators, ok := annotations[ReplicateToCreator]; ok {
+		if _, err := creatorSelector(creators); err != nil {
+			return err
+		}
+	}
+	if expression, ok := annotations[ReplicateToCEL]; ok {
+		if _, err := ParseNamespaceExpression(expression); err != nil {
+			return err
+		}
+	}
+	if url, ok := annotations[ReplicateToURL]; ok {
+		if _, ok := cachedEndpointNamespaces(url); !ok {
+			return errors.Errorf("namespaces of endpoint %s are not known yet", url)
+		}
+	}
+	if replicatesToConsumers(object) {
+		if _, ok := secretConsumerNamespaces(object.GetName()); !ok {
+			return errors.New("consumers of secrets are not known without -consumer-discovery")
+		}
+	}
+
+	return nil
+}
+
+// orphanedReplica is a replica that is not targeted by its source any more
+type orphanedReplica struct {
+	SourceKey string
+	Since     time.Time
+}
+
+// orphanedReplicas tracks since when replicas have been orphaned, by the key
+// of the replica. It is kept in memory only, so the grace period starts again
+// after a restart.
+type orphanedReplicas struct {
+	lock     sync.Mutex
+	replicas map[string]orphanedReplica
+}
+
+// orphanGraceElapsed checks if a replica has been orphaned for at least
+// Options.OrphanDeleteGrace. A replica that is seen orphaned for the first time
+// is recorded, and the source is processed again once the grace period is
+// over, so that the replica is removed even if nothing changes in the
+// meantime.
+func (r *GenericReplicator) orphanGraceElapsed(sourceKey string, targetKey string) bool {
+	grace := Options.OrphanDeleteGrace
+	if grace <= 0 {
+		return true
+	}
+
+	now := time.Now()
+
+	r.orphans.lock.Lock()
+	defer r.orphans.lock.Unlock()
+
+	if r.orphans.replicas == nil {
+		r.orphans.replicas = make(map[string]orphanedReplica)
+	}
+
+	orphan, ok := r.orphans.replicas[targetKey]
+	if !ok || orphan.SourceKey != sourceKey {
+		orphan = orphanedReplica{SourceKey: sourceKey, Since: now}
+		r.orphans.replicas[targetKey] = orphan
+	}
+
+	if elapsed := now.Sub(orphan.Since); elapsed < grace {
+		r.DelayQueue.AddAfter(delayedResync{Key: sourceKey}, grace-elapsed)
+		return false
+	}
+
+	delete(r.orphans.replicas, targetKey)
+	return true
+}
+
+// forgetOrphans drops the orphaned replicas of a source that were not seen
+// orphaned again, e.g. because the source targets their namespace again. A
+// nil set drops all of them.
+func (r *GenericReplicator) forgetOrphans(sourceKey string, orphaned map[string]struct{}) {
+	r.orphans.lock.Lock()
+	defer r.orphans.lock.Unlock()
+
+	for targetKey, orphan := range r.orphans.replicas {
+		if _, ok := orphaned[targetKey]; !ok && orphan.SourceKey == sourceKey {
+			delete(r.orphans.replicas, targetKey)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStaleReplicasArePruned(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	defer func(enabled bool) { Options.DeleteBeforeCreate = enabled }(Options.DeleteBeforeCreate)
+
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "old", "new", "foreign"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{
+		ReplicateTo: "new",
+	}}}
+
+	newReplicator := func(operations *[]string) *GenericReplicator {
+		r := newTestReplicator(t, "Secret")
+		r.ReplicateToList = map[string]struct{}{"default/foo": {}}
+		r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+			*operations = append(*operations, "replicate "+target.Name)
+			return nil
+		}
+		r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+			*operations = append(*operations, "delete "+MustGetKey(target))
+			return nil
+		}
+
+		require.NoError(t, r.Store.Add(source))
+		require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "old", Name: "foo", Labels: map[string]string{
+			SourceNamespaceLabel: "default",
+			SourceNameLabel:      "foo",
+		}}}))
+		require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "foreign", Name: "foo"}}))
+		return r
+	}
+
+	t.Run("replicas are pruned after creating new ones by default", func(t *testing.T) {
+		Options.DeleteBeforeCreate = false
+		var operations []string
+
+		assert.NoError(t, newReplicator(&operations).replicateResource(context.Background(), source))
+		assert.Equal(t, []string{"replicate new", "delete old/foo"}, operations)
+	})
+
+	t.Run("replicas are pruned before creating new ones if configured", func(t *testing.T) {
+		Options.DeleteBeforeCreate = true
+		var operations []string
+
+		assert.NoError(t, newReplicator(&operations).replicateResource(context.Background(), source))
+		assert.Equal(t, []string{"delete old/foo", "replicate new"}, operations)
+	})
+
+	t.Run("replicas are not pruned if the targets are unknown", func(t *testing.T) {
+		Options.DeleteBeforeCreate = true
+		var operations []string
+
+		invalid := source.DeepCopy()
+		invalid.Annotations[ReplicateToMatching] = "not a selector!"
+
+		assert.Error(t, newReplicator(&operations).replicateResource(context.Background(), invalid))
+		assert.Equal(t, []string{"replicate new"}, operations)
+	})
+}
+
+func TestStaleReplicasArePrunedAfterGracePeriod(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	defer func(grace time.Duration) { Options.OrphanDeleteGrace = grace }(Options.OrphanDeleteGrace)
+	Options.OrphanDeleteGrace = time.Hour
+
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "old", "new"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{
+		ReplicateTo: "new",
+	}}}
+
+	r := newTestReplicator(t, "Secret")
+
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	require.NoError(t, r.Store.Add(source))
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "old", Name: "foo", Labels: map[string]string{
+		SourceNamespaceLabel: "default",
+		SourceNameLabel:      "foo",
+	}}}))
+
+	require.NoError(t, r.pruneStaleReplicas(source))
+	assert.Empty(t, deleted, "replica is kept during the grace period")
+	assert.Contains(t, r.orphans.replicas, "old/foo")
+
+	t.Run("replicas that are targeted again are not orphaned any more", func(t *testing.T) {
+		retargeted := source.DeepCopy()
+		retargeted.Annotations[ReplicateTo] = "new,old"
+
+		require.NoError(t, r.pruneStaleReplicas(retargeted))
+		assert.Empty(t, deleted)
+		assert.NotContains(t, r.orphans.replicas, "old/foo")
+	})
+
+	t.Run("replicas are removed once the grace period is over", func(t *testing.T) {
+		require.NoError(t, r.pruneStaleReplicas(source))
+		assert.Empty(t, deleted)
+
+		r.orphans.replicas["old/foo"] = orphanedReplica{SourceKey: "default/foo", Since: time.Now().Add(-time.Hour)}
+		require.NoError(t, r.pruneStaleReplicas(source))
+		assert.Equal(t, []string{"old/foo"}, deleted)
+		assert.Empty(t, r.orphans.replicas)
+	})
+}
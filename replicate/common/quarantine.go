@@ -0,0 +1,109 @@
+package common
+
+import (
+	"sync"
+)
+
+// Quarantine keeps track of resources that repeatedly failed to replicate. Once a
+// resource version failed too often, it is quarantined and not retried until the
+// resource changes.
+type Quarantine struct {
+	Kind      string
+	Threshold int
+
+	lock     sync.Mutex
+	failures map[string]*quarantineRecord
+}
+
+type quarantineRecord struct {
+	version     string
+	count       int
+	quarantined bool
+}
+
+// NewQuarantine creates a quarantine for resources of the given kind. A threshold
+// of 0 disables the quarantine.
+func NewQuarantine(kind string, threshold int) *Quarantine {
+	return &Quarantine{
+		Kind:      kind,
+		Threshold: threshold,
+		failures:  make(map[string]*quarantineRecord),
+	}
+}
+
+// IsQuarantined checks if the given version of a resource is quarantined. A
+// quarantined resource is released as soon as its version changes.
+func (q *Quarantine) IsQuarantined(key string, version string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	record, ok := q.failures[key]
+	if !ok {
+		return false
+	}
+
+	if record.version != version {
+		q.release(key)
+		return false
+	}
+
+	return record.quarantined
+}
+
+// RecordFailure counts a failed replication of the given resource version. It
+// returns true if the resource has been quarantined by this failure.
+func (q *Quarantine) RecordFailure(key string, version string) bool {
+	if q.Threshold <= 0 {
+		return false
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	record, ok := q.failures[key]
+	if !ok || record.version != version {
+		q.release(key)
+		record = &quarantineRecord{version: version}
+		q.failures[key] = record
+	}
+
+	record.count++
+	if record.quarantined || record.count < q.Threshold {
+		return false
+	}
+
+	record.quarantined = true
+	QuarantinedSources.WithLabelValues(q.Kind).Inc()
+
+	return true
+}
+
+// Reset forgets all failures of a resource, e.g. after it was replicated
+// successfully or deleted.
+func (q *Quarantine) Reset(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.release(key)
+}
+
+// Count returns the number of quarantined resources
+func (q *Quarantine) Count() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	count := 0
+	for _, record := range q.failures {
+		if record.quarantined {
+			count++
+		}
+	}
+	return count
+}
+
+func (q *Quarantine) release(key string) {
+	if record, ok := q.failures[key]; ok && record.quarantined {
+		QuarantinedSources.WithLabelValues(q.Kind).Dec()
+	}
+	delete(q.failures, key)
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantine(t *testing.T) {
+	q := NewQuarantine("Test", 2)
+
+	assert.False(t, q.RecordFailure("default/foo", "1"))
+	assert.False(t, q.IsQuarantined("default/foo", "1"))
+	assert.True(t, q.RecordFailure("default/foo", "1"))
+	assert.True(t, q.IsQuarantined("default/foo", "1"))
+
+	// further failures don't quarantine the resource again
+	assert.False(t, q.RecordFailure("default/foo", "1"))
+
+	// a new version is released from the quarantine
+	assert.False(t, q.IsQuarantined("default/foo", "2"))
+	assert.False(t, q.RecordFailure("default/foo", "2"))
+
+	q.Reset("default/foo")
+	assert.False(t, q.RecordFailure("default/foo", "2"))
+}
+
+func TestQuarantineDisabled(t *testing.T) {
+	q := NewQuarantine("Test", 0)
+
+	for i := 0; i < 10; i++ {
+		assert.False(t, q.RecordFailure("default/foo", "1"))
+	}
+	assert.False(t, q.IsQuarantined("default/foo", "1"))
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// readOnlyRoundTripper answers every write itself instead of sending it to
+// the API server
+type readOnlyRoundTripper struct {
+	next http.RoundTripper
+}
+
+// ReadOnlyTransport wraps a transport so that only reads reach the API
+// server. Unlike ShadowTransport, writes are not even sent as dry runs, so
+// that no admission webhook is called. Creates, updates and server-side
+// applies are answered with the object that was sent, other patches with the
+// current object and deletes with success. Every write to a replicated kind
+// is logged; unlike in shadow mode, it is not counted as drift, so that the
+// writes that are not sent don't have side effects on the metrics either.
+func ReadOnlyTransport(rt http.RoundTripper) http.RoundTripper {
+	return &readOnlyRoundTripper{next: rt}
+}
+
+func (t *readOnlyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, ok := shadowVerbs[req.Method]
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	namespace, resource, name := parseResourcePath(req.URL.Path)
+	if kind, ok := shadowKinds[resource]; ok {
+		if name == "" {
+			name = objectName(body)
+		}
+
+		key := name
+		if namespace != "" {
+			key = namespace + "/" + name
+		}
+
+		log.WithField("kind", kind).WithField("target", key).Infof("read-only mode: would %s %s %s", verb, kind, key)
+	}
+
+	switch {
+	case req.Method == http.MethodDelete:
+		return readOnlyResponse(req, http.StatusOK, []byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`)), nil
+	case req.Method == http.MethodPatch && req.Header.Get("Content-Type") != string(types.ApplyPatchType):
+		// the patched object can't be known without the API server
+		get := req.Clone(req.Context())
+		get.Method = http.MethodGet
+		get.Body = nil
+		get.ContentLength = 0
+		get.URL.RawQuery = ""
+		get.Header.Del("Content-Type")
+		return t.next.RoundTrip(get)
+	case req.Method == http.MethodPost:
+		return readOnlyResponse(req, http.StatusCreated, body), nil
+	}
+
+	return readOnlyResponse(req, http.StatusOK, body), nil
+}
+
+// readOnlyResponse builds a JSON response to a request that was not sent
+func readOnlyResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestReadOnlyTransportOnlySendsReads(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		methods = append(methods, req.Method)
+
+		secret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "read-only", Namespace: "default", ResourceVersion: "1"},
+			Data:       map[string][]byte{"current": []byte("value")},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(&secret))
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(ReadOnlyTransport)
+	client := kubernetes.NewForConfigOrDie(config)
+
+	ctx := context.Background()
+	before := testutil.ToFloat64(ShadowDrift.WithLabelValues("Secret"))
+	secrets := client.CoreV1().Secrets("default")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-only", Namespace: "default"},
+		Data:       map[string][]byte{"intended": []byte("value")},
+	}
+	created, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, secret.Data, created.Data, "creates are answered with the sent object")
+
+	updated, err := secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, secret.Data, updated.Data)
+
+	patched, err := secrets.Patch(ctx, "read-only", types.MergePatchType, []byte(`{"data":{"intended":"dmFsdWU="}}`), metav1.PatchOptions{})
+	require.NoError(t, err)
+	require.Contains(t, patched.Data, "current", "patches are answered with the current object")
+
+	require.NoError(t, secrets.Delete(ctx, "read-only", metav1.DeleteOptions{}))
+
+	_, err = secrets.Get(ctx, "read-only", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{http.MethodGet, http.MethodGet}, methods, "only the patched object and the get are read")
+	require.Equal(t, before, testutil.ToFloat64(ShadowDrift.WithLabelValues("Secret")), "writes are not counted as drift")
+}
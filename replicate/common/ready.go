@@ -0,0 +1,57 @@
+package common
+
+import (
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SetReadyAnnotation sets the ReadyAnnotation of a replica that is about to be
+// written by push-based replication, if enabled by Options.ReadyAnnotation.
+// If writes are verified, the replica is only marked as ready by MarkReady
+// once its write has been verified.
+func SetReadyAnnotation(annotations map[string]string) {
+	if !Options.ReadyAnnotation {
+		delete(annotations, ReadyAnnotation)
+		return
+	}
+
+	if Options.VerifyWrites {
+		annotations[ReadyAnnotation] = "false"
+	} else {
+		annotations[ReadyAnnotation] = "true"
+	}
+}
+
+// MarkReady sets the ReadyAnnotation of a replica whose write was verified to
+// "true", if enabled by Options.ReadyAnnotation. The annotation is written
+// with the given JSON merge patch function, and the patched replica replaces
+// the written one in the cache.
+func (r *GenericReplicator) MarkReady(written interface{}, patch func(data []byte) (interface{}, error)) {
+	if !Options.ReadyAnnotation || !Options.VerifyWrites {
+		return
+	}
+
+	key := MustGetKey(written)
+	logger := log.WithField("kind", r.Kind).WithField("target", key)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{ReadyAnnotation: "true"},
+		},
+	})
+	if err != nil {
+		logger.WithError(err).Warn("could not build patch to mark replica as ready")
+		return
+	}
+
+	patched, err := patch(data)
+	if err != nil {
+		logger.WithError(err).Warnf("could not mark %s %s as ready", r.Kind, key)
+		return
+	}
+
+	if err := r.Store.Update(patched); err != nil {
+		logger.WithError(err).Warnf("could not update cache for %s %s", r.Kind, key)
+	}
+}
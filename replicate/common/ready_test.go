@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetReadyAnnotation(t *testing.T) {
+	defer func(options ControllerOptions) { Options = options }(Options)
+
+	annotations := map[string]string{ReadyAnnotation: "true"}
+	Options.ReadyAnnotation = false
+	SetReadyAnnotation(annotations)
+	assert.NotContains(t, annotations, ReadyAnnotation)
+
+	Options.ReadyAnnotation = true
+	SetReadyAnnotation(annotations)
+	assert.Equal(t, "true", annotations[ReadyAnnotation])
+
+	Options.VerifyWrites = true
+	SetReadyAnnotation(annotations)
+	assert.Equal(t, "false", annotations[ReadyAnnotation], "verified replicas are not ready before their verification")
+}
+
+func TestMarkReady(t *testing.T) {
+	defer func(options ControllerOptions) { Options = options }(Options)
+
+	r := newTestReplicator(t, "Secret")
+	written := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "foo", Annotations: map[string]string{ReadyAnnotation: "false"}}}
+
+	var patches []string
+	patch := func(data []byte) (interface{}, error) {
+		patches = append(patches, string(data))
+		patched := written.DeepCopy()
+		patched.Annotations[ReadyAnnotation] = "true"
+		return patched, nil
+	}
+
+	Options.ReadyAnnotation = true
+	r.MarkReady(written, patch)
+	assert.Empty(t, patches, "replicas are written as ready if writes are not verified")
+
+	Options.VerifyWrites = true
+	r.MarkReady(written, patch)
+	require.Equal(t, []string{`{"metadata":{"annotations":{"replicator.v1.mittwald.de/ready":"true"}}}`}, patches)
+
+	cached, exists, err := r.Store.GetByKey("target/foo")
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, "true", cached.(*v1.Secret).Annotations[ReadyAnnotation])
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ReplicateFromSource is the parsed value of a ReplicateFromAnnotation
+type ReplicateFromSource struct {
+	Namespace string
+	Name      string
+
+	// Key is the only key that is pulled from the source. Empty means all keys.
+	Key string
+}
+
+// String returns the key of the source in the format <namespace>/<name>
+func (s ReplicateFromSource) String() string {
+	return s.Namespace + "/" + s.Name
+}
+
+// ParseReplicateFrom parses the value of a ReplicateFromAnnotation in the format
+// [<namespace>/]<name>[:<key>]. Sources without namespace are looked up in the
+// namespace of the target.
+func ParseReplicateFrom(value string, targetNamespace string) (ReplicateFromSource, error) {
+	var source ReplicateFromSource
+
+	location := strings.TrimSpace(value)
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		location, source.Key = location[:i], location[i+1:]
+		if source.Key == "" {
+			return source, errors.Errorf("invalid %s annotation '%s': key after ':' must not be empty", ReplicateFromAnnotation, value)
+		}
+		if msgs := validation.IsConfigMapKey(source.Key); len(msgs) > 0 {
+			return source, errors.Errorf("invalid %s annotation '%s': invalid key '%s': %s",
+				ReplicateFromAnnotation, value, source.Key, strings.Join(msgs, "; "))
+		}
+	}
+
+	parts := strings.Split(location, "/")
+	switch len(parts) {
+	case 1:
+		source.Namespace, source.Name = targetNamespace, parts[0]
+	case 2:
+		source.Namespace, source.Name = parts[0], parts[1]
+	default:
+		return source, errors.Errorf("invalid %s annotation '%s': expected '<namespace>/<name>' or '<namespace>/<name>:<key>'",
+			ReplicateFromAnnotation, value)
+	}
+
+	if msgs := validation.IsDNS1123Label(source.Namespace); len(msgs) > 0 {
+		return source, errors.Errorf("invalid %s annotation '%s': invalid namespace '%s': %s",
+			ReplicateFromAnnotation, value, source.Namespace, strings.Join(msgs, "; "))
+	}
+	if msgs := validation.IsDNS1123Subdomain(source.Name); len(msgs) > 0 {
+		return source, errors.Errorf("invalid %s annotation '%s': invalid name '%s': %s",
+			ReplicateFromAnnotation, value, source.Name, strings.Join(msgs, "; "))
+	}
+
+	return source, nil
+}
+
+// ReplicateFrom returns the parsed ReplicateFromAnnotation of a target. ok is
+// false if the target has no such annotation.
+func ReplicateFrom(target metav1.Object) (source ReplicateFromSource, ok bool, err error) {
+	value, ok := target.GetAnnotations()[ReplicateFromAnnotation]
+	if !ok {
+		return source, false, nil
+	}
+
+	source, err = ParseReplicateFrom(value, target.GetNamespace())
+	return source, true, err
+}
+
+// PulledKey returns the single key that a target pulls from its source, or an
+// empty string if it pulls all keys
+func PulledKey(target metav1.Object) string {
+	source, _, err := ReplicateFrom(target)
+	if err != nil {
+		return ""
+	}
+
+	return source.Key
+}
+
+// ReportMissingPulledKey reports that the single key pulled by target does not
+// exist in its source. The target keeps its current data; this is not treated
+// as a failure, since the key might be added to the source later.
+func (r *GenericReplicator) ReportMissingPulledKey(source interface{}, target interface{}, key string) {
+	log.WithField("kind", r.Kind).WithField("source", MustGetKey(source)).WithField("target", MustGetKey(target)).
+		Warnf("key %s does not exist in source %s, not updating %s", key, MustGetKey(source), MustGetKey(target))
+	r.SkipReconcile(SkipReasonMissingKey)
+	r.Recorder.Eventf(target.(runtime.Object), v1.EventTypeWarning, "SourceKeyMissing",
+		"Not replicated: key %s does not exist in source %s", key, MustGetKey(source))
+}
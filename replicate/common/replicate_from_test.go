@@ -0,0 +1,65 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseReplicateFrom(t *testing.T) {
+	valid := map[string]ReplicateFromSource{
+		"default/foo":           {Namespace: "default", Name: "foo"},
+		" default/foo ":         {Namespace: "default", Name: "foo"},
+		"foo":                   {Namespace: "target", Name: "foo"},
+		"default/foo:password":  {Namespace: "default", Name: "foo", Key: "password"},
+		"foo:tls.crt":           {Namespace: "target", Name: "foo", Key: "tls.crt"},
+		"default/foo.bar:a_b-c": {Namespace: "default", Name: "foo.bar", Key: "a_b-c"},
+	}
+	for value, expected := range valid {
+		source, err := ParseReplicateFrom(value, "target")
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, source, value)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"/foo",
+		"default/",
+		"default/foo/password",
+		"default/foo:",
+		"default/foo:pass/word",
+		"Default/foo",
+		"default/Foo",
+		"default/foo bar",
+	}
+	for _, value := range invalid {
+		_, err := ParseReplicateFrom(value, "target")
+		assert.Error(t, err, value)
+	}
+}
+
+func TestReplicateFromRejectsKeysOfKindsWithoutKeys(t *testing.T) {
+	target := &metav1.ObjectMeta{Namespace: "target", Name: "foo", Annotations: map[string]string{
+		ReplicateFromAnnotation: "default/foo:key",
+	}}
+
+	secrets := newTestReplicator(t, "Secret")
+	secrets.KeyedData = true
+	roles := newTestReplicator(t, "Role")
+
+	source, ok, err := secrets.replicateFrom(target)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "key", source.Key)
+
+	_, ok, err = roles.replicateFrom(target)
+	assert.True(t, ok)
+	assert.Error(t, err)
+
+	_, ok, err = roles.replicateFrom(&metav1.ObjectMeta{})
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,187 @@
+package common
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// ReplicationRuleResources is the resource of the cluster-scoped
+// ReplicationRule custom resource definition
+var ReplicationRuleResources = schema.GroupVersionResource{
+	Group:    "replicator.mittwald.de",
+	Version:  "v1alpha1",
+	Resource: "replicationrules",
+}
+
+// ReplicationRuleResource is a ReplicationRule custom resource. Each resource
+// is translated into a ReplicationRule, which replicates the selected sources
+// as if they had a "replicate-to" annotation.
+type ReplicationRuleResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReplicationRuleSpec `json:"spec"`
+}
+
+// ReplicationRuleSpec selects sources and the namespaces they are replicated to
+type ReplicationRuleSpec struct {
+	// Kind restricts the rule to a kind of resource (e.g. "Secret"). Rules
+	// without kind apply to all kinds.
+	Kind string `json:"kind,omitempty"`
+
+	Source ReplicationRuleSource `json:"source"`
+	Target ReplicationRuleTarget `json:"target"`
+}
+
+// ReplicationRuleSource selects either a single source by its name or all
+// sources in a namespace by their labels
+type ReplicationRuleSource struct {
+	Namespace string                `json:"namespace"`
+	Name      string                `json:"name,omitempty"`
+	Selector  *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ReplicationRuleTarget selects the namespaces that sources are replicated
+// to, by name patterns (like the "replicate-to" annotation), by labels or both
+type ReplicationRuleTarget struct {
+	Namespaces        []string              `json:"namespaces,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// ReplicationRule translates the resource into a ReplicationRule
+func (r *ReplicationRuleResource) ReplicationRule() (ReplicationRule, error) {
+	rule := ReplicationRule{
+		Kind:        r.Spec.Kind,
+		ReplicateTo: strings.Join(r.Spec.Target.Namespaces, ","),
+	}
+
+	source := r.Spec.Source
+	switch {
+	case source.Namespace == "":
+		return rule, errors.Errorf("source requires a namespace")
+	case source.Name != "" && source.Selector != nil:
+		return rule, errors.Errorf("either name or selector of the source must be set, not both")
+	case source.Name != "":
+		rule.Source = source.Namespace + "/" + source.Name
+	case source.Selector != nil:
+		selector, err := metav1.LabelSelectorAsSelector(source.Selector)
+		if err != nil {
+			return rule, errors.Wrapf(err, "invalid source selector")
+		}
+		rule.Selector = &SourceSelector{Namespace: source.Namespace, Labels: selector.String()}
+	default:
+		return rule, errors.Errorf("source requires a name or a selector")
+	}
+
+	if r.Spec.Target.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(r.Spec.Target.NamespaceSelector)
+		if err != nil {
+			return rule, errors.Wrapf(err, "invalid target namespaceSelector")
+		}
+		if selector.Empty() {
+			return rule, errors.Errorf("target namespaceSelector must not be empty")
+		}
+		rule.NamespaceSelector = selector.String()
+	}
+
+	return rule, rule.Validate()
+}
+
+// ruleResourceKey is the key of the rules of a ReplicationRule resource in
+// the ReplicationRuleSet
+func ruleResourceKey(name string) string {
+	return "ReplicationRule/" + name
+}
+
+// SetReplicationRuleResource replaces the rules of a ReplicationRule
+// resource. Invalid resources are reported with a warning event and don't
+// replicate anything.
+func SetReplicationRuleResource(obj *unstructured.Unstructured, recorder record.EventRecorder) {
+	logger := log.WithField("kind", "ReplicationRule").WithField("resource", obj.GetName())
+
+	var resource ReplicationRuleResource
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &resource)
+	if err != nil {
+		err = errors.Wrapf(err, "could not parse ReplicationRule %s", obj.GetName())
+	}
+
+	var rule ReplicationRule
+	if err == nil {
+		rule, err = resource.ReplicationRule()
+	}
+
+	if err != nil {
+		logger.WithError(err).Errorf("ignoring invalid ReplicationRule %s: %v", obj.GetName(), err)
+		if recorder != nil {
+			recorder.Eventf(obj, v1.EventTypeWarning, "InvalidReplicationRule", "Rule is ignored: %v", err)
+		}
+		SetBundleRules(ruleResourceKey(obj.GetName()), nil)
+		return
+	}
+
+	logger.Infof("replicating %s to [%s] (namespaces matching [%s]) by ReplicationRule %s", rule.describeSources(), rule.ReplicateTo, rule.NamespaceSelector, obj.GetName())
+	SetBundleRules(ruleResourceKey(obj.GetName()), []ReplicationRule{rule})
+}
+
+// WatchReplicationRuleResources watches all ReplicationRule resources and
+// updates the replication rules whenever they change. It blocks until the
+// resources have been listed once, which requires the custom resource
+// definition to be installed.
+func WatchReplicationRuleResources(client dynamic.Interface, eventClient kubernetes.Interface, resyncPeriod time.Duration) error {
+	resources := client.Resource(ReplicationRuleResources)
+	recorder := newEventRecorder(eventClient)
+
+	_, controller := newInformer(
+		"ReplicationRule",
+		&cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return resources.List(context.TODO(), lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return resources.Watch(context.TODO(), lo)
+			},
+		},
+		&unstructured.Unstructured{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				SetReplicationRuleResource(obj.(*unstructured.Unstructured), recorder)
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				SetReplicationRuleResource(new.(*unstructured.Unstructured), recorder)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				if resource, ok := obj.(*unstructured.Unstructured); ok {
+					SetBundleRules(ruleResourceKey(resource.GetName()), nil)
+				}
+			},
+		},
+	)
+
+	log.WithField("kind", "ReplicationRule").Infof("watching %s", ReplicationRuleResources.String())
+	go controller.Run(wait.NeverStop)
+
+	if !cache.WaitForCacheSync(wait.NeverStop, controller.HasSynced) {
+		return errors.Errorf("could not list %s", ReplicationRuleResources.String())
+	}
+
+	return nil
+}
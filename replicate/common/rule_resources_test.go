@@ -0,0 +1,139 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+func ruleResource(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "replicator.mittwald.de/v1alpha1",
+		"kind":       "ReplicationRule",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+}
+
+func TestReplicationRuleResourceTranslatesSourceAndTarget(t *testing.T) {
+	resource := ReplicationRuleResource{Spec: ReplicationRuleSpec{
+		Kind: "ConfigMap",
+		Source: ReplicationRuleSource{
+			Namespace: "shop",
+			Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"replicate": "true"}},
+		},
+		Target: ReplicationRuleTarget{
+			Namespaces:        []string{"team-.*", "infra"},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "production"}},
+		},
+	}}
+
+	rule, err := resource.ReplicationRule()
+	require.NoError(t, err)
+	assert.Equal(t, ReplicationRule{
+		Kind:              "ConfigMap",
+		Selector:          &SourceSelector{Namespace: "shop", Labels: "replicate=true"},
+		ReplicateTo:       "team-.*,infra",
+		NamespaceSelector: "tier=production",
+	}, rule)
+
+	resource.Spec.Source = ReplicationRuleSource{Namespace: "shop", Name: "config"}
+	resource.Spec.Target.NamespaceSelector = nil
+	rule, err = resource.ReplicationRule()
+	require.NoError(t, err)
+	assert.Equal(t, "shop/config", rule.Source)
+	assert.Nil(t, rule.Selector)
+}
+
+func TestReplicationRuleResourceRejectsInvalidSpecs(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "shop"}}
+
+	for name, spec := range map[string]ReplicationRuleSpec{
+		"no source namespace":     {Source: ReplicationRuleSource{Name: "config"}, Target: ReplicationRuleTarget{Namespaces: []string{"infra"}}},
+		"name and selector":       {Source: ReplicationRuleSource{Namespace: "shop", Name: "config", Selector: selector}, Target: ReplicationRuleTarget{Namespaces: []string{"infra"}}},
+		"no name or selector":     {Source: ReplicationRuleSource{Namespace: "shop"}, Target: ReplicationRuleTarget{Namespaces: []string{"infra"}}},
+		"no target":               {Source: ReplicationRuleSource{Namespace: "shop", Name: "config"}},
+		"empty namespaceSelector": {Source: ReplicationRuleSource{Namespace: "shop", Name: "config"}, Target: ReplicationRuleTarget{NamespaceSelector: &metav1.LabelSelector{}}},
+	} {
+		resource := ReplicationRuleResource{Spec: spec}
+		_, err := resource.ReplicationRule()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSetReplicationRuleResource(t *testing.T) {
+	defer SetBundleRules(ruleResourceKey("shop"), nil)
+	recorder := record.NewFakeRecorder(10)
+
+	SetReplicationRuleResource(ruleResource("shop", map[string]interface{}{
+		"source": map[string]interface{}{
+			"namespace": "shop",
+			"selector":  map[string]interface{}{"matchLabels": map[string]interface{}{"replicate": "true"}},
+		},
+		"target": map[string]interface{}{
+			"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "production"}},
+		},
+	}), recorder)
+
+	selected := &metav1.ObjectMeta{Namespace: "shop", Name: "config", Labels: map[string]string{"replicate": "true"}}
+	production := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"tier": "production"}}}
+	staging := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging", Labels: map[string]string{"tier": "staging"}}}
+
+	patterns, ok := replicateToPatterns("Secret", selected, nil)
+	assert.True(t, ok, "rules with a namespace selector only match as well")
+	assert.Equal(t, "", patterns)
+	assert.True(t, replicationRules.SelectsNamespace("Secret", selected, production))
+	assert.False(t, replicationRules.SelectsNamespace("Secret", selected, staging))
+
+	_, ok = replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "shop", Name: "other"}, nil)
+	assert.False(t, ok, "sources without matching labels don't match")
+
+	_, ok = replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "other", Name: "config", Labels: selected.Labels}, nil)
+	assert.False(t, ok, "sources in other namespaces don't match")
+
+	SetReplicationRuleResource(ruleResource("shop", map[string]interface{}{
+		"source": map[string]interface{}{"namespace": "shop"},
+		"target": map[string]interface{}{"namespaces": []interface{}{"infra"}},
+	}), recorder)
+
+	_, ok = replicateToPatterns("Secret", selected, nil)
+	assert.False(t, ok, "invalid resources remove their previous rule")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "InvalidReplicationRule")
+}
+
+func TestSelectorRulesInReplicationRulesFile(t *testing.T) {
+	path := writeRulesFile(t, `
+rules:
+  - selector:
+      namespace: shop
+      labels: "app=shop,tier in (web,api)"
+    namespaceSelector: "env=prod"
+`)
+
+	rules, err := LoadReplicationRules(path)
+	require.NoError(t, err)
+	SetReplicationRules(rules)
+	defer SetReplicationRules(nil)
+
+	_, ok := replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "shop", Name: "api", Labels: map[string]string{"app": "shop", "tier": "api"}}, nil)
+	assert.True(t, ok)
+
+	_, ok = replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "shop", Name: "db", Labels: map[string]string{"app": "shop", "tier": "db"}}, nil)
+	assert.False(t, ok)
+
+	for name, content := range map[string]string{
+		"source and selector":   "rules:\n  - source: shop/foo\n    selector: {namespace: shop, labels: app=shop}\n    replicateTo: foo\n",
+		"selector no namespace": "rules:\n  - selector: {labels: app=shop}\n    replicateTo: foo\n",
+		"invalid labels":        "rules:\n  - selector: {namespace: shop, labels: \"app in\"}\n    replicateTo: foo\n",
+		"invalid nsSelector":    "rules:\n  - source: shop/foo\n    namespaceSelector: \"env in\"\n",
+	} {
+		_, err := LoadReplicationRules(writeRulesFile(t, content))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,404 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+var replicationRules ReplicationRuleSet
+
+// Values of Options.RulePrecedence
+const (
+	// RulePrecedenceMerge replicates sources into the namespaces of both
+	// their "replicate-to" annotation and all matching rules
+	RulePrecedenceMerge = "merge"
+
+	// RulePrecedenceRules ignores the "replicate-to" annotation of sources
+	// that are matched by a rule
+	RulePrecedenceRules = "rules"
+
+	// RulePrecedenceAnnotations ignores all rules for sources with a
+	// "replicate-to" annotation
+	RulePrecedenceAnnotations = "annotations"
+)
+
+// ParseRulePrecedence validates the global rule precedence
+func ParseRulePrecedence(precedence string) (string, error) {
+	switch precedence {
+	case RulePrecedenceMerge, RulePrecedenceRules, RulePrecedenceAnnotations:
+		return precedence, nil
+	}
+
+	return "", errors.Errorf("invalid rule precedence '%s': expected %s, %s or %s",
+		precedence, RulePrecedenceMerge, RulePrecedenceRules, RulePrecedenceAnnotations)
+}
+
+// ReplicationRule replicates a single source as if it had a "replicate-to"
+// annotation. Rules are configured centrally, so tenants can't remove them.
+type ReplicationRule struct {
+	// Kind restricts the rule to a kind of resource (e.g. "Secret"). Rules
+	// without kind apply to all kinds.
+	Kind string `json:"kind,omitempty"`
+
+	// Source is the source resource in the format <namespace>/<name>
+	Source string `json:"source,omitempty"`
+
+	// Owner selects all sources with a matching owner reference instead of a
+	// single Source, e.g. the secrets generated for a certificate
+	Owner *OwnerSelector `json:"owner,omitempty"`
+
+	// Selector selects all sources with matching labels instead of a single
+	// Source
+	Selector *SourceSelector `json:"selector,omitempty"`
+
+	// ReplicateTo is a comma separated list of namespaces or regular expressions
+	ReplicateTo string `json:"replicateTo,omitempty"`
+
+	// NamespaceSelector is a label selector for namespaces that sources are
+	// replicated to in addition to ReplicateTo
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+}
+
+// SourceSelector selects the resources in a namespace by their labels
+type SourceSelector struct {
+	// Namespace of the selected resources
+	Namespace string `json:"namespace"`
+
+	// Labels is a label selector, e.g. "app=shop,tier in (web,api)"
+	Labels string `json:"labels"`
+}
+
+// Matches checks if a resource is selected
+func (s SourceSelector) Matches(object metav1.Object) bool {
+	if object.GetNamespace() != s.Namespace {
+		return false
+	}
+
+	selector, err := labels.Parse(s.Labels)
+	if err != nil {
+		return false
+	}
+
+	return selector.Matches(labels.Set(object.GetLabels()))
+}
+
+func (s SourceSelector) String() string {
+	return fmt.Sprintf("%s/[%s]", s.Namespace, s.Labels)
+}
+
+// OwnerSelector selects the resources that are owned by a single object
+type OwnerSelector struct {
+	// Namespace is the namespace of the owner and the owned resources
+	Namespace string `json:"namespace"`
+
+	// APIVersion of the owner (e.g. "cert-manager.io/v1"). Owners of all
+	// versions match if empty.
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the owner (e.g. "Certificate")
+	Kind string `json:"kind"`
+
+	// Name of the owner
+	Name string `json:"name"`
+}
+
+// Matches checks if a resource is owned by the selected object
+func (s OwnerSelector) Matches(object metav1.Object) bool {
+	if object.GetNamespace() != s.Namespace {
+		return false
+	}
+
+	for _, owner := range object.GetOwnerReferences() {
+		if owner.Kind == s.Kind && owner.Name == s.Name && (s.APIVersion == "" || owner.APIVersion == s.APIVersion) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s OwnerSelector) String() string {
+	return fmt.Sprintf("%s/%s %s", s.Namespace, s.Kind, s.Name)
+}
+
+// ReplicationRulesConfig is the format of the replication rules file
+type ReplicationRulesConfig struct {
+	Rules []ReplicationRule `json:"rules"`
+}
+
+type RulesChangedFunc func(old []ReplicationRule, new []ReplicationRule)
+
+// ReplicationRuleSet holds the currently active replication rules
+type ReplicationRuleSet struct {
+	lock  sync.RWMutex
+	rules []ReplicationRule
+
+	// bundles contains the rules generated from bundles and ReplicationRule
+	// resources, by bundle or resource key
+	bundles map[string][]ReplicationRule
+
+	ChangedFuncs []RulesChangedFunc
+}
+
+// LoadReplicationRules reads replication rules from a YAML or JSON file
+func LoadReplicationRules(path string) ([]ReplicationRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read replication rules from %s", path)
+	}
+
+	var config ReplicationRulesConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, errors.Wrapf(err, "could not parse replication rules from %s", path)
+	}
+
+	for i, rule := range config.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "rule %d", i)
+		}
+	}
+
+	return config.Rules, nil
+}
+
+// Validate checks that a rule selects its sources in exactly one way and
+// has at least one target
+func (r ReplicationRule) Validate() error {
+	selectors := 0
+	for _, set := range []bool{r.Source != "", r.Owner != nil, r.Selector != nil} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return errors.Errorf("only one of source, owner and selector may be set")
+	}
+
+	switch {
+	case r.Owner != nil:
+		if r.Owner.Namespace == "" || r.Owner.Kind == "" || r.Owner.Name == "" {
+			return errors.Errorf("owner requires namespace, kind and name, got '%s'", r.Owner)
+		}
+	case r.Selector != nil:
+		if r.Selector.Namespace == "" {
+			return errors.Errorf("selector requires a namespace")
+		}
+		if _, err := labels.Parse(r.Selector.Labels); err != nil {
+			return errors.Wrapf(err, "invalid selector labels '%s'", r.Selector.Labels)
+		}
+	default:
+		if len(strings.SplitN(r.Source, "/", 2)) < 2 {
+			return errors.Errorf("invalid source expected '<namespace>/<name>', got '%s'", r.Source)
+		}
+	}
+
+	if r.NamespaceSelector != "" {
+		if _, err := labels.Parse(r.NamespaceSelector); err != nil {
+			return errors.Wrapf(err, "invalid namespaceSelector '%s'", r.NamespaceSelector)
+		}
+	} else if strings.TrimSpace(r.ReplicateTo) == "" {
+		return errors.Errorf("replicateTo of %s must not be empty", r.describeSources())
+	}
+
+	return nil
+}
+
+// SetReplicationRules replaces the active replication rules and notifies all
+// replicators about the change.
+func SetReplicationRules(rules []ReplicationRule) {
+	replicationRules.set(rules)
+}
+
+// SetBundleRules replaces the replication rules generated from a bundle or a
+// ReplicationRule resource. Passing no rules removes them.
+func SetBundleRules(bundleKey string, rules []ReplicationRule) {
+	replicationRules.setBundle(bundleKey, rules)
+}
+
+// OnReplicationRulesChanged adds a function that is called whenever the replication rules are replaced
+func OnReplicationRulesChanged(changedFunc RulesChangedFunc) {
+	replicationRules.lock.Lock()
+	defer replicationRules.lock.Unlock()
+
+	replicationRules.ChangedFuncs = append(replicationRules.ChangedFuncs, changedFunc)
+}
+
+func (s *ReplicationRuleSet) set(rules []ReplicationRule) {
+	s.lock.Lock()
+	old := s.rules
+	s.rules = rules
+	changedFuncs := s.ChangedFuncs
+	s.lock.Unlock()
+
+	for _, changedFunc := range changedFuncs {
+		changedFunc(old, rules)
+	}
+}
+
+func (s *ReplicationRuleSet) setBundle(bundleKey string, rules []ReplicationRule) {
+	s.lock.Lock()
+	old := s.bundles[bundleKey]
+	if reflect.DeepEqual(old, rules) || len(old) == 0 && len(rules) == 0 {
+		s.lock.Unlock()
+		return
+	}
+
+	if s.bundles == nil {
+		s.bundles = make(map[string][]ReplicationRule)
+	}
+	if len(rules) == 0 {
+		delete(s.bundles, bundleKey)
+	} else {
+		s.bundles[bundleKey] = rules
+	}
+	changedFuncs := s.ChangedFuncs
+	s.lock.Unlock()
+
+	for _, changedFunc := range changedFuncs {
+		changedFunc(old, rules)
+	}
+}
+
+// matching returns all rules matching the given resource. With
+// RulePrecedenceAnnotations, no rules match resources with a "replicate-to"
+// annotation.
+func (s *ReplicationRuleSet) matching(kind string, object metav1.Object) []ReplicationRule {
+	if Options.RulePrecedence == RulePrecedenceAnnotations {
+		if _, ok := object.GetAnnotations()[ReplicateTo]; ok {
+			return nil
+		}
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	matching := make([]ReplicationRule, 0)
+	for _, rule := range s.rules {
+		if rule.Matches(kind, object) {
+			matching = append(matching, rule)
+		}
+	}
+
+	bundleKeys := make([]string, 0, len(s.bundles))
+	for bundleKey := range s.bundles {
+		bundleKeys = append(bundleKeys, bundleKey)
+	}
+	sort.Strings(bundleKeys)
+
+	for _, bundleKey := range bundleKeys {
+		for _, rule := range s.bundles[bundleKey] {
+			if rule.Matches(kind, object) {
+				matching = append(matching, rule)
+			}
+		}
+	}
+
+	return matching
+}
+
+// ReplicateTo returns the namespace patterns of all rules matching the given
+// resource, joined by comma. It also returns true if only rules with a
+// NamespaceSelector match.
+func (s *ReplicationRuleSet) ReplicateTo(kind string, object metav1.Object) (string, bool) {
+	matching := s.matching(kind, object)
+
+	patterns := make([]string, 0, len(matching))
+	for _, rule := range matching {
+		if strings.TrimSpace(rule.ReplicateTo) != "" {
+			patterns = append(patterns, rule.ReplicateTo)
+		}
+	}
+
+	return strings.Join(patterns, ","), len(matching) > 0
+}
+
+// selectsNamespaces checks if any rule matching the given resource has a
+// NamespaceSelector
+func (s *ReplicationRuleSet) selectsNamespaces(kind string, object metav1.Object) bool {
+	for _, rule := range s.matching(kind, object) {
+		if rule.NamespaceSelector != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SelectsNamespace checks if the NamespaceSelector of any rule matching the
+// given resource selects the namespace
+func (s *ReplicationRuleSet) SelectsNamespace(kind string, object metav1.Object, namespace *v1.Namespace) bool {
+	for _, rule := range s.matching(kind, object) {
+		if rule.NamespaceSelector == "" {
+			continue
+		}
+
+		selector, err := labels.Parse(rule.NamespaceSelector)
+		if err == nil && selector.Matches(labels.Set(namespace.Labels)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches checks if the rule applies to the given resource
+func (r ReplicationRule) Matches(kind string, object metav1.Object) bool {
+	if r.Kind != "" && r.Kind != kind {
+		return false
+	}
+	if r.Owner != nil {
+		return r.Owner.Matches(object)
+	}
+	if r.Selector != nil {
+		return r.Selector.Matches(object)
+	}
+
+	return r.Source == MustGetKey(object)
+}
+
+func (r ReplicationRule) describeSources() string {
+	if r.Owner != nil {
+		return "resources owned by " + r.Owner.String()
+	}
+	if r.Selector != nil {
+		return "resources selected by " + r.Selector.String()
+	}
+
+	return r.Source
+}
+
+// replicateToPatterns returns the namespace patterns a resource should be
+// replicated to. Patterns from the "replicate-to" annotation are merged with
+// the patterns of matching replication rules, unless Options.RulePrecedence
+// gives one of them precedence.
+func replicateToPatterns(kind string, object metav1.Object, annotations map[string]string) (string, bool) {
+	patterns := make([]string, 0)
+
+	annotationPatterns, hasAnnotation := annotations[ReplicateTo]
+	rulePatterns, hasRules := replicationRules.ReplicateTo(kind, object)
+	switch Options.RulePrecedence {
+	case RulePrecedenceRules:
+		hasAnnotation = hasAnnotation && !hasRules
+	case RulePrecedenceAnnotations:
+		hasRules = hasRules && !hasAnnotation
+	}
+
+	if hasAnnotation {
+		patterns = append(patterns, annotationPatterns)
+	}
+	if hasRules {
+		patterns = append(patterns, rulePatterns)
+	}
+
+	return strings.Join(patterns, ","), len(patterns) > 0
+}
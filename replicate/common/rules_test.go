@@ -0,0 +1,178 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func writeRulesFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadReplicationRules(t *testing.T) {
+	path := writeRulesFile(t, `
+rules:
+  - source: kube-system/registry-credentials
+    replicateTo: "team-.*"
+  - kind: ConfigMap
+    source: kube-system/ca-bundle
+    replicateTo: "team-.*,infra"
+`)
+
+	rules, err := LoadReplicationRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, ReplicationRule{Source: "kube-system/registry-credentials", ReplicateTo: "team-.*"}, rules[0])
+	assert.Equal(t, "ConfigMap", rules[1].Kind)
+}
+
+func TestLoadReplicationRulesRejectsInvalidRules(t *testing.T) {
+	for name, content := range map[string]string{
+		"invalid source":    "rules:\n  - source: no-namespace\n    replicateTo: foo\n",
+		"empty replicateTo": "rules:\n  - source: default/foo\n    replicateTo: \"\"\n",
+		"unknown field":     "rules:\n  - source: default/foo\n    replicate-to: foo\n",
+		"source and owner":  "rules:\n  - source: default/foo\n    owner: {namespace: default, kind: Certificate, name: foo}\n    replicateTo: foo\n",
+		"incomplete owner":  "rules:\n  - owner: {namespace: default, kind: Certificate}\n    replicateTo: foo\n",
+	} {
+		_, err := LoadReplicationRules(writeRulesFile(t, content))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestReplicateToPatternsMergesAnnotationsAndRules(t *testing.T) {
+	SetReplicationRules([]ReplicationRule{
+		{Source: "default/any-kind", ReplicateTo: "team-.*"},
+		{Kind: "Secret", Source: "default/secret-only", ReplicateTo: "infra"},
+	})
+	defer SetReplicationRules(nil)
+
+	patterns, ok := replicateToPatterns("ConfigMap", &metav1.ObjectMeta{Namespace: "default", Name: "any-kind"}, map[string]string{ReplicateTo: "my-ns"})
+	assert.True(t, ok)
+	assert.Equal(t, "my-ns,team-.*", patterns)
+
+	patterns, ok = replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "default", Name: "secret-only"}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "infra", patterns)
+
+	_, ok = replicateToPatterns("ConfigMap", &metav1.ObjectMeta{Namespace: "default", Name: "secret-only"}, nil)
+	assert.False(t, ok)
+
+	patterns, ok = replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "default", Name: "other"}, map[string]string{ReplicateTo: "my-ns"})
+	assert.True(t, ok)
+	assert.Equal(t, "my-ns", patterns)
+}
+
+func TestRulePrecedence(t *testing.T) {
+	defer func(precedence string) { Options.RulePrecedence = precedence }(Options.RulePrecedence)
+
+	SetReplicationRules([]ReplicationRule{
+		{Source: "default/both", ReplicateTo: "team-.*", NamespaceSelector: "tier=production"},
+		{Source: "default/rule-only", ReplicateTo: "infra"},
+	})
+	defer SetReplicationRules(nil)
+
+	both := &metav1.ObjectMeta{Namespace: "default", Name: "both", Annotations: map[string]string{ReplicateTo: "my-ns,!team-sandbox"}}
+	ruleOnly := &metav1.ObjectMeta{Namespace: "default", Name: "rule-only"}
+	annotationOnly := &metav1.ObjectMeta{Namespace: "default", Name: "annotation-only", Annotations: map[string]string{ReplicateTo: "my-ns"}}
+
+	for _, test := range []struct {
+		precedence string
+		both       string
+		selects    bool
+	}{
+		{RulePrecedenceMerge, "my-ns,!team-sandbox,team-.*", true},
+		{RulePrecedenceRules, "team-.*", true},
+		{RulePrecedenceAnnotations, "my-ns,!team-sandbox", false},
+	} {
+		t.Run(test.precedence, func(t *testing.T) {
+			Options.RulePrecedence = test.precedence
+
+			patterns, ok := replicateToPatterns("Secret", both, both.Annotations)
+			assert.True(t, ok)
+			assert.Equal(t, test.both, patterns)
+			assert.Equal(t, test.selects, replicationRules.selectsNamespaces("Secret", both))
+
+			patterns, ok = replicateToPatterns("Secret", ruleOnly, nil)
+			assert.True(t, ok)
+			assert.Equal(t, "infra", patterns, "rules apply to sources without annotation")
+
+			patterns, ok = replicateToPatterns("Secret", annotationOnly, annotationOnly.Annotations)
+			assert.True(t, ok)
+			assert.Equal(t, "my-ns", patterns, "annotations apply to sources without rules")
+		})
+	}
+
+	t.Run("invalid precedences are rejected", func(t *testing.T) {
+		_, err := ParseRulePrecedence("crd")
+		assert.Error(t, err)
+	})
+}
+
+func TestBundleRulesAreMergedWithReplicationRules(t *testing.T) {
+	changes := 0
+	replicationRules.ChangedFuncs = append(replicationRules.ChangedFuncs, func(old []ReplicationRule, new []ReplicationRule) { changes++ })
+	defer func(funcs []RulesChangedFunc) { replicationRules.ChangedFuncs = funcs }(replicationRules.ChangedFuncs[:len(replicationRules.ChangedFuncs)-1])
+
+	SetReplicationRules([]ReplicationRule{{Source: "default/foo", ReplicateTo: "infra"}})
+	defer SetReplicationRules(nil)
+
+	bundle := []ReplicationRule{{Kind: "Secret", Source: "default/foo", ReplicateTo: "team-.*"}}
+	SetBundleRules("default/bundle", bundle)
+	SetBundleRules("default/bundle", bundle)
+	assert.Equal(t, 2, changes, "unchanged bundles do not notify")
+
+	patterns, ok := replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "default", Name: "foo"}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "infra,team-.*", patterns)
+
+	SetBundleRules("default/bundle", nil)
+	patterns, _ = replicateToPatterns("Secret", &metav1.ObjectMeta{Namespace: "default", Name: "foo"}, nil)
+	assert.Equal(t, "infra", patterns)
+}
+
+func TestOwnerRulesMatchOwnedResources(t *testing.T) {
+	path := writeRulesFile(t, `
+rules:
+  - kind: Secret
+    owner:
+      namespace: certs
+      apiVersion: cert-manager.io/v1
+      kind: Certificate
+      name: wildcard
+    replicateTo: "team-.*"
+`)
+
+	rules, err := LoadReplicationRules(path)
+	require.NoError(t, err)
+	SetReplicationRules(rules)
+	defer SetReplicationRules(nil)
+
+	owned := func(namespace string, apiVersion string, name string) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Namespace: namespace, Name: "tls", OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: apiVersion, Kind: "Certificate", Name: name},
+		}}
+	}
+
+	patterns, ok := replicateToPatterns("Secret", owned("certs", "cert-manager.io/v1", "wildcard"), nil)
+	assert.True(t, ok)
+	assert.Equal(t, "team-.*", patterns)
+
+	_, ok = replicateToPatterns("Secret", owned("certs", "cert-manager.io/v1", "other"), nil)
+	assert.False(t, ok, "other owners don't match")
+
+	_, ok = replicateToPatterns("Secret", owned("certs", "cert-manager.io/v1alpha2", "wildcard"), nil)
+	assert.False(t, ok, "other owner versions don't match")
+
+	_, ok = replicateToPatterns("Secret", owned("other", "cert-manager.io/v1", "wildcard"), nil)
+	assert.False(t, ok, "owners in other namespaces don't match")
+
+	_, ok = replicateToPatterns("ConfigMap", owned("certs", "cert-manager.io/v1", "wildcard"), nil)
+	assert.False(t, ok, "other kinds don't match")
+}
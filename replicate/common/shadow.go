@@ -0,0 +1,315 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// shadowKinds maps the API resources that are replicated to their kinds
+var shadowKinds = map[string]string{
+	"secrets":      "Secret",
+	"configmaps":   "ConfigMap",
+	"roles":        "Role",
+	"rolebindings": "RoleBinding",
+	"ingresses":    "Ingress",
+}
+
+var shadowVerbs = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "patch",
+	http.MethodDelete: "delete",
+}
+
+// Types of drift between the actual and the intended state of a target, used
+// as the type label of the DriftTotal metric
+const (
+	DriftTypeMissing  = "missing"
+	DriftTypeData     = "data"
+	DriftTypeExtra    = "extra"
+	DriftTypeMetadata = "metadata"
+)
+
+// shadowKeyedFields are the content fields whose keys are compared one by one,
+// so that keys that only exist in the actual target are told apart from keys
+// whose values differ
+var shadowKeyedFields = map[string]bool{
+	"data":       true,
+	"binaryData": true,
+}
+
+// shadowDrift keeps track of the objects that differ from their intended state
+type shadowDrift struct {
+	lock    sync.Mutex
+	objects map[string]map[string]struct{}
+}
+
+var drift = shadowDrift{objects: make(map[string]map[string]struct{})}
+
+// record remembers that the object with the given key does not match its
+// intended state and updates the ShadowDrift gauge.
+func (d *shadowDrift) record(kind string, key string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.objects[kind] == nil {
+		d.objects[kind] = make(map[string]struct{})
+	}
+	d.objects[kind][key] = struct{}{}
+	ShadowDrift.WithLabelValues(kind).Set(float64(len(d.objects[kind])))
+}
+
+// shadowRoundTripper turns every write into a server-side dry run
+type shadowRoundTripper struct {
+	next http.RoundTripper
+}
+
+// ShadowTransport wraps a transport so that no request it sends changes the
+// cluster. Writes are sent as dry runs, so that the API server still validates
+// them and returns the object as it would have been stored, and every write to
+// a replicated kind is reported as drift between the intended and the actual
+// state of the cluster.
+func ShadowTransport(rt http.RoundTripper) http.RoundTripper {
+	return &shadowRoundTripper{next: rt}
+}
+
+func (s *shadowRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, ok := shadowVerbs[req.Method]
+	if !ok {
+		return s.next.RoundTrip(req)
+	}
+
+	dryRun := req.Clone(req.Context())
+	query := dryRun.URL.Query()
+	query.Set("dryRun", "All")
+	dryRun.URL.RawQuery = query.Encode()
+
+	namespace, resource, name := parseResourcePath(req.URL.Path)
+	if kind, ok := shadowKinds[resource]; ok {
+		if name == "" && req.Body != nil {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			req.Body.Close()
+			dryRun.Body = io.NopCloser(bytes.NewReader(body))
+			name = objectName(body)
+		}
+
+		key := name
+		if namespace != "" {
+			key = namespace + "/" + name
+		}
+
+		log.WithField("kind", kind).WithField("target", key).Infof("shadow mode: would %s %s %s", verb, kind, key)
+		drift.record(kind, key)
+
+		switch req.Method {
+		case http.MethodPost:
+			DriftTotal.WithLabelValues(kind, DriftTypeMissing).Inc()
+		case http.MethodDelete:
+			DriftTotal.WithLabelValues(kind, DriftTypeExtra).Inc()
+		default:
+			return s.compare(req, dryRun, kind)
+		}
+	}
+
+	return s.next.RoundTrip(dryRun)
+}
+
+// compare sends the dry run of an update or patch and compares the object
+// that the API server would store with the current target. Every type of
+// drift that is found is counted in DriftTotal.
+func (s *shadowRoundTripper) compare(req *http.Request, dryRun *http.Request, kind string) (*http.Response, error) {
+	get := req.Clone(req.Context())
+	get.Method = http.MethodGet
+	get.Body = nil
+	get.ContentLength = 0
+	get.URL.RawQuery = ""
+	get.Header.Del("Content-Type")
+
+	actual, status, err := s.readObject(get, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var intended map[string]interface{}
+	resp, err := s.next.RoundTrip(dryRun)
+	if err == nil && resp.StatusCode < http.StatusMultipleChoices {
+		intended, _, err = s.readObject(nil, resp)
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	switch {
+	case status == http.StatusNotFound:
+		DriftTotal.WithLabelValues(kind, DriftTypeMissing).Inc()
+	case actual != nil && intended != nil:
+		for _, driftType := range driftTypes(actual, intended) {
+			DriftTotal.WithLabelValues(kind, driftType).Inc()
+		}
+	}
+
+	return resp, nil
+}
+
+// readObject decodes the object in the response to req, which is sent first
+// if resp is nil. The body of resp is replaced, so that it can still be read
+// by the caller. Responses that are not successful are not decoded.
+func (s *shadowRoundTripper) readObject(req *http.Request, resp *http.Response) (map[string]interface{}, int, error) {
+	if resp == nil {
+		var err error
+		if resp, err = s.next.RoundTrip(req); err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, resp.StatusCode, nil
+	}
+
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, resp.StatusCode, nil
+	}
+	return obj, resp.StatusCode, nil
+}
+
+// driftTypes compares the actual state of a target with its intended state.
+// Keys of keyed content fields (e.g. "data") that only exist in the actual
+// target are extra, all other differences in content are data drift. Labels
+// and annotations are compared without the ReplicatedAtAnnotation.
+func driftTypes(actual map[string]interface{}, intended map[string]interface{}) []string {
+	var data, extra bool
+	for field := range mergeKeys(actual, intended) {
+		switch field {
+		case "apiVersion", "kind", "metadata", "status":
+			continue
+		}
+
+		if !shadowKeyedFields[field] {
+			data = data || !reflect.DeepEqual(actual[field], intended[field])
+			continue
+		}
+
+		actualKeys, _ := actual[field].(map[string]interface{})
+		intendedKeys, _ := intended[field].(map[string]interface{})
+		for key, value := range actualKeys {
+			intendedValue, ok := intendedKeys[key]
+			extra = extra || !ok
+			data = data || (ok && !reflect.DeepEqual(value, intendedValue))
+		}
+		for key := range intendedKeys {
+			_, ok := actualKeys[key]
+			data = data || !ok
+		}
+	}
+
+	actualMeta, _ := actual["metadata"].(map[string]interface{})
+	intendedMeta, _ := intended["metadata"].(map[string]interface{})
+	metadata := false
+	for _, field := range []string{"labels", "annotations"} {
+		actualValues, _ := actualMeta[field].(map[string]interface{})
+		intendedValues, _ := intendedMeta[field].(map[string]interface{})
+		metadata = metadata || !reflect.DeepEqual(withoutTimestamp(actualValues), withoutTimestamp(intendedValues))
+	}
+
+	var types []string
+	for driftType, found := range map[string]bool{DriftTypeData: data, DriftTypeExtra: extra, DriftTypeMetadata: metadata} {
+		if found {
+			types = append(types, driftType)
+		}
+	}
+	return types
+}
+
+// mergeKeys returns the set of the keys of both maps
+func mergeKeys(a map[string]interface{}, b map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// withoutTimestamp returns labels or annotations without the
+// ReplicatedAtAnnotation, which changes with every write. Empty values are
+// returned as nil, so that missing and empty maps are equal.
+func withoutTimestamp(values map[string]interface{}) map[string]interface{} {
+	var result map[string]interface{}
+	for key, value := range values {
+		if key == ReplicatedAtAnnotation {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]interface{})
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// parseResourcePath extracts namespace, resource and name from a request path
+// like /api/v1/namespaces/<namespace>/<resource>/<name> or
+// /apis/<group>/<version>/namespaces/<namespace>/<resource>/<name>.
+func parseResourcePath(path string) (namespace string, resource string, name string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return "", "", ""
+	}
+
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		if len(parts) == 2 {
+			return "", "namespaces", parts[1]
+		}
+		namespace = parts[1]
+		parts = parts[2:]
+	}
+
+	if len(parts) >= 1 {
+		resource = parts[0]
+	}
+	if len(parts) >= 2 {
+		name = parts[1]
+	}
+
+	return namespace, resource, name
+}
+
+// objectName returns the name from the metadata of a serialized object
+func objectName(body []byte) string {
+	var obj struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return ""
+	}
+	return obj.Metadata.Name
+}
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestParseResourcePath(t *testing.T) {
+	tests := []struct {
+		path      string
+		namespace string
+		resource  string
+		name      string
+	}{
+		{"/api/v1/namespaces/default/secrets/foo", "default", "secrets", "foo"},
+		{"/api/v1/namespaces/default/configmaps", "default", "configmaps", ""},
+		{"/apis/rbac.authorization.k8s.io/v1/namespaces/test/rolebindings/bar", "test", "rolebindings", "bar"},
+		{"/api/v1/namespaces/default", "", "namespaces", "default"},
+		{"/version", "", "", ""},
+	}
+
+	for _, test := range tests {
+		namespace, resource, name := parseResourcePath(test.path)
+		require.Equal(t, test.namespace, namespace, test.path)
+		require.Equal(t, test.resource, resource, test.path)
+		require.Equal(t, test.name, name, test.path)
+	}
+}
+
+func TestShadowTransportSendsDryRuns(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req)
+
+		var secret corev1.Secret
+		if req.Method == http.MethodPost {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&secret))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(&secret))
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(ShadowTransport)
+	client := kubernetes.NewForConfigOrDie(config)
+
+	ctx := context.Background()
+	before := testutil.ToFloat64(ShadowDrift.WithLabelValues("Secret"))
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "shadow", Namespace: "default"}}
+	_, err := client.CoreV1().Secrets("default").Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Secrets("default").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Secrets("default").Get(ctx, "shadow", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// the update is compared with the current object, which is read first
+	require.Len(t, requests, 4)
+	require.Equal(t, "All", requests[0].URL.Query().Get("dryRun"))
+	require.Equal(t, http.MethodGet, requests[1].Method)
+	require.Empty(t, requests[1].URL.Query().Get("dryRun"))
+	require.Equal(t, "All", requests[2].URL.Query().Get("dryRun"))
+	require.Empty(t, requests[3].URL.Query().Get("dryRun"))
+
+	// create and update concern the same object, so it is only counted once
+	require.Equal(t, before+1, testutil.ToFloat64(ShadowDrift.WithLabelValues("Secret")))
+}
+
+func TestShadowTransportCountsDriftByType(t *testing.T) {
+	current := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "drift",
+			Namespace:   "default",
+			Labels:      map[string]string{"team": "a"},
+			Annotations: map[string]string{ReplicatedAtAnnotation: "2021-01-01T00:00:00Z"},
+		},
+		Data: map[string]string{"kept": "1", "changed": "old", "extra": "1"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var configMap corev1.ConfigMap
+		switch req.Method {
+		case http.MethodGet:
+			if req.URL.Path != "/api/v1/namespaces/default/configmaps/drift" {
+				w.WriteHeader(http.StatusNotFound)
+				require.NoError(t, json.NewEncoder(w).Encode(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusNotFound, Reason: metav1.StatusReasonNotFound}))
+				return
+			}
+			configMap = current
+		case http.MethodPost, http.MethodPut:
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&configMap))
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(&configMap))
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(ShadowTransport)
+	client := kubernetes.NewForConfigOrDie(config).CoreV1().ConfigMaps("default")
+	ctx := context.Background()
+
+	driftTypes := []string{DriftTypeMissing, DriftTypeData, DriftTypeExtra, DriftTypeMetadata}
+	counts := func() map[string]float64 {
+		counts := make(map[string]float64)
+		for _, driftType := range driftTypes {
+			counts[driftType] = testutil.ToFloat64(DriftTotal.WithLabelValues("ConfigMap", driftType))
+		}
+		return counts
+	}
+	requireDrift := func(write func() error, expected ...string) {
+		before := counts()
+		require.NoError(t, write())
+		after := counts()
+		for _, driftType := range driftTypes {
+			increase := 0.0
+			for _, e := range expected {
+				if e == driftType {
+					increase = 1
+				}
+			}
+			require.Equal(t, before[driftType]+increase, after[driftType], driftType)
+		}
+	}
+
+	requireDrift(func() error {
+		_, err := client.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "new"}}, metav1.CreateOptions{})
+		return err
+	}, DriftTypeMissing)
+
+	requireDrift(func() error {
+		_, err := client.Patch(ctx, "absent", types.ApplyPatchType, []byte(`{"apiVersion":"v1","kind":"ConfigMap"}`), metav1.PatchOptions{FieldManager: FieldManager})
+		return err
+	}, DriftTypeMissing)
+
+	requireDrift(func() error {
+		updated := current.DeepCopy()
+		updated.Annotations[ReplicatedAtAnnotation] = "2022-01-01T00:00:00Z"
+		_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+
+	requireDrift(func() error {
+		updated := current.DeepCopy()
+		updated.Data = map[string]string{"kept": "1", "changed": "new"}
+		_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	}, DriftTypeData, DriftTypeExtra)
+
+	requireDrift(func() error {
+		updated := current.DeepCopy()
+		updated.Labels["team"] = "b"
+		_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	}, DriftTypeMetadata)
+
+	requireDrift(func() error {
+		return client.Delete(ctx, "drift", metav1.DeleteOptions{})
+	}, DriftTypeExtra)
+}
@@ -0,0 +1,18 @@
+package common
+
+import "context"
+
+// Simulate replicates a source once and synchronously, as if it had just been
+// added to the cluster. The existing resources of the replicator's kind (e.g.
+// previous replicas) are added to the store first. Delayed replications are
+// not run. Together with UseStaticNamespaces, this allows reasoning about the
+// targets of a source without a cluster.
+func (r *GenericReplicator) Simulate(source interface{}, existing []interface{}) error {
+	for _, obj := range append(existing, source) {
+		if err := r.Store.Add(obj); err != nil {
+			return err
+		}
+	}
+
+	return r.replicateResource(context.Background(), source)
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// DefaultExternalSinkPath is the path template used if none is configured
+const DefaultExternalSinkPath = "{{ .Namespace }}/{{ .Name }}"
+
+// ExternalSink is a store outside of the cluster (e.g. a file system or a
+// secret manager) that receives a copy of every secret replica
+type ExternalSink interface {
+	// Write stores the data of a replica at the given path, replacing data that
+	// was stored there before
+	Write(path string, data map[string][]byte) error
+}
+
+// ParseExternalSink creates the external sink for a "<type>:<location>"
+// specification. Supported types are:
+//
+//	file:<directory>  writes each replica into a directory with one file per key
+func ParseExternalSink(spec string) (ExternalSink, error) {
+	v := strings.SplitN(spec, ":", 2)
+	if len(v) < 2 || v[1] == "" {
+		return nil, errors.Errorf("invalid external sink expected '<type>:<location>', got '%s'", spec)
+	}
+
+	switch v[0] {
+	case "file":
+		return &FileSink{Directory: v[1]}, nil
+	default:
+		return nil, errors.Errorf("unknown external sink type '%s'", v[0])
+	}
+}
+
+// ParseExternalSinkPath parses the template of the path that replicas are
+// written to in the external sink. The template is applied to the namespace
+// and name of the replica, e.g. "{{ .Namespace }}/{{ .Name }}".
+func ParseExternalSinkPath(path string) (*template.Template, error) {
+	tmpl, err := template.New("external-sink-path").Option("missingkey=error").Parse(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid external sink path '%s': %v", path, err)
+	}
+
+	return tmpl, nil
+}
+
+// FileSink writes replicas into a directory below Directory, with one file per
+// key, like secrets mounted into a pod
+type FileSink struct {
+	Directory string
+}
+
+// Write replaces the content of the directory at path with the given data
+func (s *FileSink) Write(path string, data map[string][]byte) error {
+	// cleaning an absolute path removes all ".." elements, so that paths can't
+	// leave the sink's directory
+	dir := filepath.Join(s.Directory, filepath.Clean("/"+path))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, "could not create directory %s: %v", dir, err)
+	}
+
+	for key, value := range data {
+		if err := writeFileAtomically(filepath.Join(dir, key), value); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "could not list directory %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		if _, ok := data[entry.Name()]; ok || !entry.Type().IsRegular() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return errors.Wrapf(err, "could not remove stale key %s: %v", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomically writes a file by renaming a temporary file, so that
+// readers never see partially written content
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "could not write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "could not write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "could not write %s: %v", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "could not write %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// externalExport is the latest data of a replica that is waiting to be written
+// to the external sink
+type externalExport struct {
+	kind string
+	data map[string][]byte
+}
+
+// externalExporter writes replicas to the external sink in the background.
+// Failed writes are retried with an exponential backoff, independently of
+// the replication within the cluster. Only the latest data of each path is
+// kept, so retries never overwrite newer data.
+type externalExporter struct {
+	startOnce sync.Once
+	queue     workqueue.RateLimitingInterface
+
+	lock    sync.Mutex
+	pending map[string]externalExport
+}
+
+var exporter = &externalExporter{}
+
+// ExportReplica writes the data of a replica to the external sink, if one is
+// configured with Options.ExternalSink. The write happens in the background.
+func (r *GenericReplicator) ExportReplica(namespace string, name string, data map[string][]byte) {
+	if Options.ExternalSink == nil {
+		return
+	}
+
+	logger := log.WithField("kind", r.Kind).WithField("target", namespace+"/"+name)
+
+	var path bytes.Buffer
+	if err := Options.ExternalSinkPath.Execute(&path, struct{ Namespace, Name string }{namespace, name}); err != nil {
+		logger.WithError(err).Errorf("could not determine external sink path of %s/%s: %v", namespace, name, err)
+		ExternalSinkWriteFailures.WithLabelValues(r.Kind).Inc()
+		return
+	}
+
+	exporter.export(path.String(), externalExport{kind: r.Kind, data: data})
+}
+
+func (e *externalExporter) export(path string, item externalExport) {
+	e.startOnce.Do(func() {
+		e.pending = make(map[string]externalExport)
+		e.queue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ExternalSink")
+		go e.run(Options.ExternalSink)
+	})
+
+	e.lock.Lock()
+	e.pending[path] = item
+	e.lock.Unlock()
+
+	e.queue.Forget(path)
+	e.queue.Add(path)
+}
+
+func (e *externalExporter) run(sink ExternalSink) {
+	for {
+		key, shutdown := e.queue.Get()
+		if shutdown {
+			return
+		}
+
+		path := key.(string)
+		e.lock.Lock()
+		item, ok := e.pending[path]
+		e.lock.Unlock()
+
+		if ok {
+			e.write(sink, path, item)
+		}
+		e.queue.Done(key)
+	}
+}
+
+func (e *externalExporter) write(sink ExternalSink, path string, item externalExport) {
+	logger := log.WithField("kind", item.kind).WithField("path", path)
+
+	if err := sink.Write(path, item.data); err != nil {
+		ExternalSinkWriteFailures.WithLabelValues(item.kind).Inc()
+		logger.WithError(err).Warnf("could not write replica to external sink, retrying: %v", err)
+		e.queue.AddRateLimited(path)
+		return
+	}
+
+	e.lock.Lock()
+	// data that arrived while writing is written by the next run
+	if current, ok := e.pending[path]; ok && equalData(current.data, item.data) {
+		delete(e.pending, path)
+	}
+	e.lock.Unlock()
+
+	e.queue.Forget(path)
+	ExternalSinkWritesTotal.WithLabelValues(item.kind).Inc()
+	logger.Debugf("wrote replica to external sink")
+}
+
+func equalData(a map[string][]byte, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExternalSink(t *testing.T) {
+	sink, err := ParseExternalSink("file:/var/lib/replicator")
+	require.NoError(t, err)
+	assert.Equal(t, &FileSink{Directory: "/var/lib/replicator"}, sink)
+
+	for _, spec := range []string{"file", "file:", "vault:secret/replicas"} {
+		_, err := ParseExternalSink(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := &FileSink{Directory: dir}
+
+	require.NoError(t, sink.Write("team-a/foo", map[string][]byte{"user": []byte("admin"), "password": []byte("secret")}))
+	require.NoError(t, sink.Write("team-a/foo", map[string][]byte{"password": []byte("rotated")}))
+
+	content, err := os.ReadFile(filepath.Join(dir, "team-a", "foo", "password"))
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "team-a", "foo", "user"), "removed keys are removed from the sink")
+
+	require.NoError(t, sink.Write("../../outside", map[string][]byte{"key": []byte("value")}))
+	assert.FileExists(t, filepath.Join(dir, "outside", "key"), "paths can't leave the sink's directory")
+}
+
+type failingSink struct {
+	lock     sync.Mutex
+	failures int
+	written  map[string]map[string][]byte
+}
+
+func (s *failingSink) Write(path string, data map[string][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+
+	s.written[path] = data
+	return nil
+}
+
+func (s *failingSink) get(path string) map[string][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.written[path]
+}
+
+func TestExportReplicaIsRetried(t *testing.T) {
+	defer func(options ControllerOptions) { Options = options }(Options)
+
+	sink := &failingSink{failures: 2, written: map[string]map[string][]byte{}}
+	Options.ExternalSink = sink
+	Options.ExternalSinkPath, _ = ParseExternalSinkPath("replicas/{{ .Namespace }}/{{ .Name }}")
+
+	defer func(e *externalExporter) { exporter = e }(exporter)
+	exporter = &externalExporter{}
+
+	r := newTestReplicator(t, "Secret")
+	r.ExportReplica("team-a", "foo", map[string][]byte{"password": []byte("secret")})
+
+	assert.Eventually(t, func() bool {
+		return string(sink.get("replicas/team-a/foo")["password"]) == "secret"
+	}, 5*time.Second, 10*time.Millisecond)
+	exporter.queue.ShutDown()
+}
+
+func TestExportReplicaWithoutSink(t *testing.T) {
+	defer func(options ControllerOptions) { Options = options }(Options)
+	Options.ExternalSink = nil
+
+	r := newTestReplicator(t, "Secret")
+	r.ExportReplica("team-a", "foo", map[string][]byte{"password": []byte("secret")})
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxReplicatedObjectBytes returns the size limit for replicating the given
+// object. The MaxReplicatedObjectBytes annotation of the object overrides the
+// global limit; 0 means unlimited.
+func maxReplicatedObjectBytes(obj interface{}) int {
+	limit, ok := MustGetObject(obj).GetAnnotations()[MaxReplicatedObjectBytes]
+	if !ok {
+		return Options.MaxReplicatedObjectBytes
+	}
+
+	bytes, err := strconv.Atoi(limit)
+	if err != nil || bytes < 0 {
+		log.WithField("resource", MustGetKey(obj)).
+			Warnf("ignoring invalid %s annotation '%s'", MaxReplicatedObjectBytes, limit)
+		return Options.MaxReplicatedObjectBytes
+	}
+
+	return bytes
+}
+
+// objectSize returns the size of the serialized object
+func objectSize(obj interface{}) (int, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// sizeVerdict is whether a version of a source was refused as oversized
+type sizeVerdict struct {
+	version string
+	refused bool
+}
+
+// sourceSizes holds the verdict on the latest version of each source, so that
+// a source is measured and reported once per version, no matter how many
+// targets it is replicated to
+type sourceSizes struct {
+	lock     sync.Mutex
+	verdicts map[string]sizeVerdict
+}
+
+// refuseOversizedObject checks if obj is too large to be replicated. Oversized
+// objects are reported with a warning event and the OversizedObjectsTotal
+// metric once per version; the verdict is remembered for later checks of the
+// same version.
+func (r *GenericReplicator) refuseOversizedObject(obj interface{}) bool {
+	key := MustGetKey(obj)
+	version := MustGetObject(obj).GetResourceVersion()
+
+	r.sizes.lock.Lock()
+	defer r.sizes.lock.Unlock()
+
+	if verdict, ok := r.sizes.verdicts[key]; ok && version != "" && verdict.version == version {
+		return verdict.refused
+	}
+
+	refused := r.measureObject(obj)
+	if version != "" {
+		if r.sizes.verdicts == nil {
+			r.sizes.verdicts = make(map[string]sizeVerdict)
+		}
+		r.sizes.verdicts[key] = sizeVerdict{version: version, refused: refused}
+	}
+
+	return refused
+}
+
+// measureObject checks if obj exceeds its size limit and reports it if so
+func (r *GenericReplicator) measureObject(obj interface{}) bool {
+	limit := maxReplicatedObjectBytes(obj)
+	if limit == 0 {
+		return false
+	}
+
+	logger := log.WithField("kind", r.Kind).WithField("source", MustGetKey(obj))
+
+	size, err := objectSize(obj)
+	if err != nil {
+		logger.WithError(err).Warn("could not determine object size")
+		return false
+	}
+
+	if size <= limit {
+		return false
+	}
+
+	logger.Warnf("refusing to replicate %s %s: size of %d bytes exceeds the limit of %d bytes", r.Kind, MustGetKey(obj), size, limit)
+	OversizedObjectsTotal.WithLabelValues(r.Kind).Inc()
+	r.Recorder.Eventf(obj.(runtime.Object), v1.EventTypeWarning, "ObjectTooLarge",
+		"Not replicated: size of %d bytes exceeds the limit of %d bytes", size, limit)
+
+	return true
+}
+
+// forgetSize drops the verdict on a deleted source
+func (r *GenericReplicator) forgetSize(key string) {
+	r.sizes.lock.Lock()
+	defer r.sizes.lock.Unlock()
+
+	delete(r.sizes.verdicts, key)
+}
+
+// replicaDataSize returns the total size of the values of a secret or config
+// map, as counted by the API server when validating the object
+func replicaDataSize(obj interface{}) (int, bool) {
+	size := 0
+	switch o := obj.(type) {
+	case *v1.Secret:
+		for _, value := range o.Data {
+			size += len(value)
+		}
+		for _, value := range o.StringData {
+			size += len(value)
+		}
+	case *v1.ConfigMap:
+		for _, value := range o.Data {
+			size += len(value)
+		}
+		for _, value := range o.BinaryData {
+			size += len(value)
+		}
+	default:
+		return 0, false
+	}
+
+	return size, true
+}
+
+// RefuseOversizedReplica checks if the data of a replica that is about to be
+// written exceeds v1.MaxSecretSize. The API server rejects such objects, since
+// etcd limits the size of a single object; a replica may get that large even
+// if its source is not, e.g. by augmenting a target. Oversized replicas are
+// reported with a warning event on the source and the OversizedObjectsTotal
+// metric, and an error is returned instead of writing them.
+func (r *GenericReplicator) RefuseOversizedReplica(source interface{}, targetKey string, replica interface{}) error {
+	size, ok := replicaDataSize(replica)
+	if !ok || size <= v1.MaxSecretSize {
+		return nil
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", MustGetKey(source)).WithField("target", targetKey).
+		Warnf("refusing to write %s %s: data of %d bytes exceeds the limit of %d bytes", r.Kind, targetKey, size, v1.MaxSecretSize)
+	OversizedObjectsTotal.WithLabelValues(r.Kind).Inc()
+	r.SkipReconcile(SkipReasonTooLarge)
+	r.Recorder.Eventf(source.(runtime.Object), v1.EventTypeWarning, "ReplicaTooLarge",
+		"Not replicated to %s: data of %d bytes exceeds the limit of %d bytes that the API server accepts for a %s, since etcd limits the size of objects; compress the values or split the %s",
+		targetKey, size, v1.MaxSecretSize, strings.ToLower(r.Kind), strings.ToLower(r.Kind))
+
+	return errors.Errorf("data of %s %s (%d bytes) exceeds the limit of %d bytes", r.Kind, targetKey, size, v1.MaxSecretSize)
+}
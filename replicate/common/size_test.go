@@ -0,0 +1,79 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRefuseOversizedObject(t *testing.T) {
+	defer func(limit int) { Options.MaxReplicatedObjectBytes = limit }(Options.MaxReplicatedObjectBytes)
+	Options.MaxReplicatedObjectBytes = 1024
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "Secret")
+	r.Recorder = recorder
+
+	secret := func(size int, annotations map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: annotations},
+			Data:       map[string][]byte{"data": make([]byte, size)},
+		}
+	}
+
+	assert.False(t, r.refuseOversizedObject(secret(16, nil)))
+	assert.Empty(t, recorder.Events)
+
+	assert.True(t, r.refuseOversizedObject(secret(2048, nil)))
+	assert.Contains(t, <-recorder.Events, "ObjectTooLarge")
+
+	assert.False(t, r.refuseOversizedObject(secret(2048, map[string]string{MaxReplicatedObjectBytes: "8192"})))
+	assert.False(t, r.refuseOversizedObject(secret(2048, map[string]string{MaxReplicatedObjectBytes: "0"})))
+	assert.True(t, r.refuseOversizedObject(secret(16, map[string]string{MaxReplicatedObjectBytes: "8"})))
+	assert.True(t, r.refuseOversizedObject(secret(2048, map[string]string{MaxReplicatedObjectBytes: "invalid"})))
+
+	t.Run("versions are reported once", func(t *testing.T) {
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+		}
+
+		versioned := secret(2048, nil)
+		versioned.ResourceVersion = "1"
+		for i := 0; i < 3; i++ {
+			assert.True(t, r.refuseOversizedObject(versioned))
+		}
+		assert.Len(t, recorder.Events, 1)
+
+		versioned = secret(16, nil)
+		versioned.ResourceVersion = "2"
+		assert.False(t, r.refuseOversizedObject(versioned))
+
+		r.forgetSize("default/foo")
+		assert.Empty(t, r.sizes.verdicts)
+	})
+}
+
+func TestRefuseOversizedReplica(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "ConfigMap")
+	r.Recorder = recorder
+
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+	replica := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "target"},
+		Data:       map[string]string{"data": string(make([]byte, v1.MaxSecretSize/2))},
+		BinaryData: map[string][]byte{"binary": make([]byte, v1.MaxSecretSize/2)},
+	}
+
+	assert.NoError(t, r.RefuseOversizedReplica(source, "target/foo", replica))
+	assert.Empty(t, recorder.Events)
+
+	replica.BinaryData["more"] = []byte{0}
+	assert.Error(t, r.RefuseOversizedReplica(source, "target/foo", replica))
+	assert.Contains(t, <-recorder.Events, "ReplicaTooLarge")
+
+	assert.NoError(t, r.RefuseOversizedReplica(source, "target/foo", &v1.Namespace{}))
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Reasons for which a reconcile of a single target ends without writing it,
+// used as the reason label of the ReconcileSkippedTotal metric
+const (
+	SkipReasonUpToDate        = "up_to_date"
+	SkipReasonNotPermitted    = "not_permitted"
+	SkipReasonMissingKey      = "missing_key"
+	SkipReasonUpdateOnly      = "update_only"
+	SkipReasonPlaceholderOnly = "placeholder_only"
+	SkipReasonSourceConflict  = "source_conflict"
+	SkipReasonNameCollision   = "name_collision"
+	SkipReasonTooLarge        = "too_large"
+	SkipReasonOptedOut        = "opted_out"
+	SkipReasonServiceAccount  = "service_account_token"
+	SkipReasonPinned          = "pinned"
+	SkipReasonInvalidData     = "invalid_data"
+)
+
+// SkipReconcile counts a reconcile of a target that is skipped for one of
+// the SkipReason* reasons
+func (r *GenericReplicator) SkipReconcile(reason string) {
+	ReconcileSkippedTotal.WithLabelValues(r.Kind, reason).Inc()
+}
+
+// ReplicaChanged checks if the updated copy of an existing replica differs
+// from it in anything but its ReplicatedAtAnnotation. Writes that would only
+// change the timestamp are skipped, so that reconciles without changes don't
+// create new resource versions and watch events.
+func ReplicaChanged(current runtime.Object, updated runtime.Object) bool {
+	currentCopy, updatedCopy := current.DeepCopyObject(), updated.DeepCopyObject()
+	for _, obj := range []runtime.Object{currentCopy, updatedCopy} {
+		delete(MustGetObject(obj).GetAnnotations(), ReplicatedAtAnnotation)
+	}
+
+	return !equality.Semantic.DeepEqual(currentCopy, updatedCopy)
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSkippedReconcilesAreCountedByReason(t *testing.T) {
+	defer func(strategy string) { Options.NameCollisionStrategy = strategy }(Options.NameCollisionStrategy)
+	Options.NameCollisionStrategy = NameCollisionSkip
+
+	r := newTestReplicator(t, "SkippedSecret")
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "foreign", Name: "foo"}}))
+
+	_, skip, err := r.ReplicaName(source, "foreign")
+	require.NoError(t, err)
+	assert.True(t, skip)
+
+	r.ReportMissingPulledKey(source, &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "foo"}}, "missing")
+	r.SkipReconcile(SkipReasonUpToDate)
+	r.SkipReconcile(SkipReasonUpToDate)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(ReconcileSkippedTotal.WithLabelValues("SkippedSecret", SkipReasonNameCollision)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(ReconcileSkippedTotal.WithLabelValues("SkippedSecret", SkipReasonMissingKey)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(ReconcileSkippedTotal.WithLabelValues("SkippedSecret", SkipReasonUpToDate)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(ReconcileSkippedTotal.WithLabelValues("SkippedSecret", SkipReasonSourceConflict)))
+}
+
+func TestReplicaChanged(t *testing.T) {
+	replica := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "foo", Annotations: map[string]string{
+			ReplicatedAtAnnotation:          "2021-01-01T00:00:00Z",
+			ReplicatedFromVersionAnnotation: "5",
+		}},
+		Data: map[string][]byte{"foo": []byte("bar")},
+	}
+
+	restamped := replica.DeepCopy()
+	restamped.Annotations[ReplicatedAtAnnotation] = "2022-01-01T00:00:00Z"
+	assert.False(t, ReplicaChanged(replica, restamped), "a new timestamp alone is no change")
+	assert.Equal(t, "2021-01-01T00:00:00Z", replica.Annotations[ReplicatedAtAnnotation], "the replica itself is not modified")
+
+	updated := restamped.DeepCopy()
+	updated.Annotations[ReplicatedFromVersionAnnotation] = "6"
+	assert.True(t, ReplicaChanged(replica, updated))
+
+	updated = restamped.DeepCopy()
+	updated.Data["foo"] = []byte("baz")
+	assert.True(t, ReplicaChanged(replica, updated))
+}
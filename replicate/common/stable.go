@@ -0,0 +1,116 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// delayedStabilityCheck is put into the delay queue to check again whether a
+// source has been unchanged for its minimum stable age
+type delayedStabilityCheck struct {
+	Key string
+}
+
+// stableVersion is the version of a source and the time it was first seen
+type stableVersion struct {
+	version string
+	since   time.Time
+}
+
+// sourceStability tracks since when sources have been unchanged, and which
+// sources have a stability check in the delay queue
+type sourceStability struct {
+	lock     sync.Mutex
+	versions map[string]stableVersion
+	pending  map[string]struct{}
+}
+
+// minStableAge returns the time a resource must be unchanged before it is
+// replicated, as set by its MinStableAge annotation
+func minStableAge(object metav1.Object) time.Duration {
+	value, ok := object.GetAnnotations()[MinStableAge]
+	if !ok {
+		return 0
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		log.WithField("resource", MustGetKey(object)).Warnf("invalid %s annotation '%s', ignoring it", MinStableAge, value)
+		return 0
+	}
+
+	return age
+}
+
+// deferUnstable checks if a resource changed less than its minimum stable age
+// ago. If so, a stability check is scheduled for when the age is reached,
+// unless one is scheduled already, and true is returned. A resource is
+// considered changed when its resource version differs from the one seen
+// before, so every change restarts the wait.
+func (r *GenericReplicator) deferUnstable(object metav1.Object) bool {
+	key := MustGetKey(object)
+	age := minStableAge(object)
+	version := object.GetResourceVersion()
+	now := time.Now()
+
+	r.stability.lock.Lock()
+	defer r.stability.lock.Unlock()
+
+	if age <= 0 {
+		delete(r.stability.versions, key)
+		return false
+	}
+
+	if r.stability.versions == nil {
+		r.stability.versions = make(map[string]stableVersion)
+		r.stability.pending = make(map[string]struct{})
+	}
+
+	seen, ok := r.stability.versions[key]
+	if !ok || seen.version != version {
+		seen = stableVersion{version: version, since: now}
+		r.stability.versions[key] = seen
+	}
+
+	if now.Sub(seen.since) >= age {
+		return false
+	}
+
+	UnstableSourceDeferralsTotal.WithLabelValues(r.Kind).Inc()
+	if _, pending := r.stability.pending[key]; !pending {
+		r.stability.pending[key] = struct{}{}
+		r.DelayQueue.AddAfter(delayedStabilityCheck{Key: key}, age-now.Sub(seen.since))
+	}
+
+	return true
+}
+
+// forgetStability drops the tracked version of a deleted resource
+func (r *GenericReplicator) forgetStability(key string) {
+	r.stability.lock.Lock()
+	defer r.stability.lock.Unlock()
+
+	delete(r.stability.versions, key)
+}
+
+// checkStabilityDelayed processes the latest version of a resource after it
+// was deferred by deferUnstable. If the resource changed in the meantime, it
+// is deferred again.
+func (r *GenericReplicator) checkStabilityDelayed(item delayedStabilityCheck) {
+	r.stability.lock.Lock()
+	delete(r.stability.pending, item.Key)
+	r.stability.lock.Unlock()
+
+	obj, exists, err := r.Store.GetByKey(item.Key)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Error("error fetching object from store")
+		return
+	} else if !exists {
+		return
+	}
+
+	r.ResourceAdded(obj)
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMinStableAge(t *testing.T) {
+	r := newTestReplicator(t, "Secret")
+
+	var replicated []string
+	r.UpdateFuncs.OnResourceAdded = func(obj interface{}) error {
+		replicated = append(replicated, MustGetObject(obj).GetResourceVersion())
+		return nil
+	}
+
+	secret := func(version string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "half-written",
+			ResourceVersion: version,
+			Annotations:     map[string]string{MinStableAge: "50ms"},
+		}}
+	}
+
+	for _, version := range []string{"1", "2"} {
+		require.NoError(t, r.Store.Update(secret(version)))
+		r.ResourceAdded(secret(version))
+	}
+	assert.Empty(t, replicated, "sources are not replicated before they are stable")
+	assert.Len(t, r.stability.pending, 1, "a single stability check is scheduled")
+
+	item, _ := r.DelayQueue.Get()
+	require.Equal(t, delayedStabilityCheck{Key: "default/half-written"}, item)
+	r.DelayQueue.Done(item)
+
+	require.NoError(t, r.Store.Update(secret("3")))
+	r.checkStabilityDelayed(item.(delayedStabilityCheck))
+	assert.Empty(t, replicated, "a change restarts the wait")
+
+	item, _ = r.DelayQueue.Get()
+	r.DelayQueue.Done(item)
+	r.checkStabilityDelayed(item.(delayedStabilityCheck))
+	assert.Equal(t, []string{"3"}, replicated, "the stable version is replicated")
+
+	t.Run("invalid ages are ignored", func(t *testing.T) {
+		replicated = nil
+		invalid := secret("4")
+		invalid.Annotations[MinStableAge] = "soon"
+		r.ResourceAdded(invalid)
+		assert.Equal(t, []string{"4"}, replicated)
+	})
+
+	t.Run("deleted sources are forgotten", func(t *testing.T) {
+		r.deferUnstable(secret("5"))
+		r.forgetStability("default/half-written")
+		assert.Empty(t, r.stability.versions)
+	})
+}
@@ -0,0 +1,112 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseExtraAnnotations parses a comma separated list of <key>=<value> pairs
+// that are added to all replicas, e.g. to exclude them from admission
+// webhooks or GitOps tools. Commas in values are escaped with a backslash,
+// e.g. "argocd.argoproj.io/sync-options=Prune=false\,Delete=false". Keys in
+// the replicator's own domain are refused, since they would interfere with
+// the replication.
+func ParseExtraAnnotations(list string) (map[string]string, error) {
+	annotations := make(map[string]string)
+
+	for _, pair := range splitEscaped(list, ',') {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid replica annotation: expected <key>=<value>, got '%s'", pair)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, errors.Errorf("invalid replica annotation key '%s': %s", key, strings.Join(errs, "; "))
+		}
+		if strings.HasPrefix(key, "replicator.v1.mittwald.de/") {
+			return nil, errors.Errorf("replica annotation key '%s' is reserved for the replicator", key)
+		}
+
+		annotations[key] = strings.TrimSpace(parts[1])
+	}
+
+	return annotations, nil
+}
+
+// splitEscaped splits a string at all separators that are not escaped with a
+// backslash, and removes the escaping backslashes
+func splitEscaped(s string, separator rune) []string {
+	parts := make([]string, 0)
+	var current strings.Builder
+	escaped := false
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			if c != separator && c != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == separator:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+
+	return append(parts, current.String())
+}
+
+// DefaultReplicaMetadataDenylist are the prefixes of the labels and annotations
+// of cert-manager, which would make cert-manager in the target namespaces
+// manage the replicas
+const DefaultReplicaMetadataDenylist = "cert-manager.io/,acme.cert-manager.io/,controller.cert-manager.io/"
+
+// ParseMetadataDenylist parses a comma separated list of label and annotation
+// key prefixes
+func ParseMetadataDenylist(list string) []string {
+	prefixes := make([]string, 0)
+	for _, prefix := range strings.Split(list, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+// IsDeniedMetadataKey checks if a label or annotation must never be copied
+// from a source to its replicas, because its key starts with a prefix of the
+// ReplicaMetadataDenylist from the Options
+func IsDeniedMetadataKey(key string) bool {
+	for _, prefix := range Options.ReplicaMetadataDenylist {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetExtraAnnotations adds the ReplicaExtraAnnotations from the Options to the
+// annotations of a replica
+func SetExtraAnnotations(annotations map[string]string) {
+	for key, value := range Options.ReplicaExtraAnnotations {
+		annotations[key] = value
+	}
+}
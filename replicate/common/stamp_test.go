@@ -0,0 +1,76 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseExtraAnnotations(t *testing.T) {
+	annotations, err := ParseExtraAnnotations("sidecar.istio.io/inject=false, example.com/skip = true,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sidecar.istio.io/inject": "false", "example.com/skip": "true"}, annotations)
+
+	annotations, err = ParseExtraAnnotations("")
+	assert.NoError(t, err)
+	assert.Empty(t, annotations)
+
+	_, err = ParseExtraAnnotations("missing-value")
+	assert.Error(t, err)
+
+	_, err = ParseExtraAnnotations("not a key=true")
+	assert.Error(t, err)
+
+	_, err = ParseExtraAnnotations(ReplicatedAtAnnotation + "=never")
+	assert.Error(t, err)
+
+	annotations, err = ParseExtraAnnotations(`argocd.argoproj.io/compare-options=IgnoreExtraneous,argocd.argoproj.io/sync-options=Prune=false\,Delete=false`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"argocd.argoproj.io/compare-options": "IgnoreExtraneous",
+		"argocd.argoproj.io/sync-options":    "Prune=false,Delete=false",
+	}, annotations)
+}
+
+func TestSplitEscaped(t *testing.T) {
+	assert.Equal(t, []string{"a", "b,c", `d\e`, `f\`}, splitEscaped(`a,b\,c,d\e,f\`, ','))
+	assert.Equal(t, []string{`a\`, "b"}, splitEscaped(`a\\,b`, ','))
+}
+
+func TestReplicaMetadataUpToDateChecksExtraAnnotations(t *testing.T) {
+	defer func(extra map[string]string) { Options.ReplicaExtraAnnotations = extra }(Options.ReplicaExtraAnnotations)
+	Options.ReplicaExtraAnnotations = map[string]string{"argocd.argoproj.io/compare-options": "IgnoreExtraneous"}
+
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	replica := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "foo"}}
+	assert.False(t, ReplicaMetadataUpToDate(replica, namespace))
+
+	replica.Annotations = map[string]string{}
+	SetExtraAnnotations(replica.Annotations)
+	assert.True(t, ReplicaMetadataUpToDate(replica, namespace))
+}
+
+func TestSetExtraAnnotations(t *testing.T) {
+	defer func(extra map[string]string) { Options.ReplicaExtraAnnotations = extra }(Options.ReplicaExtraAnnotations)
+	Options.ReplicaExtraAnnotations = map[string]string{"sidecar.istio.io/inject": "false"}
+
+	annotations := map[string]string{ReplicatedAtAnnotation: "now"}
+	SetExtraAnnotations(annotations)
+	assert.Equal(t, map[string]string{ReplicatedAtAnnotation: "now", "sidecar.istio.io/inject": "false"}, annotations)
+}
+
+func TestIsDeniedMetadataKey(t *testing.T) {
+	defer func(denylist []string) { Options.ReplicaMetadataDenylist = denylist }(Options.ReplicaMetadataDenylist)
+	Options.ReplicaMetadataDenylist = ParseMetadataDenylist(DefaultReplicaMetadataDenylist + ", example.com/,")
+
+	assert.Equal(t, []string{"cert-manager.io/", "acme.cert-manager.io/", "controller.cert-manager.io/", "example.com/"}, Options.ReplicaMetadataDenylist)
+	assert.True(t, IsDeniedMetadataKey("cert-manager.io/certificate-name"))
+	assert.True(t, IsDeniedMetadataKey("controller.cert-manager.io/fao"))
+	assert.True(t, IsDeniedMetadataKey("example.com/owner"))
+	assert.False(t, IsDeniedMetadataKey("app.kubernetes.io/name"))
+
+	Options.ReplicaMetadataDenylist = ParseMetadataDenylist("")
+	assert.False(t, IsDeniedMetadataKey("cert-manager.io/certificate-name"), "an empty denylist denies nothing")
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SourceStatus describes the replication status of a single source
+type SourceStatus struct {
+	Kind    string         `json:"kind"`
+	Source  string         `json:"source"`
+	Version string         `json:"version"`
+	Targets []TargetStatus `json:"targets"`
+}
+
+// TargetStatus describes the replication status of a single target
+type TargetStatus struct {
+	Target            string `json:"target"`
+	ReplicatedVersion string `json:"replicatedVersion,omitempty"`
+	ReplicatedAt      string `json:"replicatedAt,omitempty"`
+	InSync            bool   `json:"inSync"`
+}
+
+// NewSourceStatus builds the status of a source from its targets. A target is
+// in sync if it was last replicated from the current version of the source
+// (see ReplicaUpToDate).
+func NewSourceStatus(kind string, source metav1.Object, targets []metav1.Object) *SourceStatus {
+	status := SourceStatus{
+		Kind:    kind,
+		Source:  MustGetKey(source),
+		Version: source.GetResourceVersion(),
+		Targets: make([]TargetStatus, 0, len(targets)),
+	}
+
+	for _, target := range targets {
+		annotations := target.GetAnnotations()
+		status.Targets = append(status.Targets, TargetStatus{
+			Target:            MustGetKey(target),
+			ReplicatedVersion: annotations[ReplicatedFromVersionAnnotation],
+			ReplicatedAt:      annotations[ReplicatedAtAnnotation],
+			InSync:            ReplicaUpToDate(target, source),
+		})
+	}
+
+	sort.Slice(status.Targets, func(i, j int) bool {
+		return status.Targets[i].Target < status.Targets[j].Target
+	})
+
+	return &status
+}
+
+// Status returns the replication status of the source with the given
+// namespace and name, based on the objects in the cache. It returns false if
+// the source is not known.
+func (r *GenericReplicator) Status(namespace string, name string) (*SourceStatus, bool) {
+	key := namespace + "/" + name
+	obj, exists, err := r.Store.GetByKey(key)
+	if err != nil || !exists {
+		return nil, false
+	}
+
+	targets := make([]metav1.Object, 0)
+	for _, replication := range r.Replications() {
+		if replication.Source != key {
+			continue
+		}
+		if target, exists, err := r.Store.GetByKey(replication.Target); err == nil && exists {
+			targets = append(targets, MustGetObject(target))
+		}
+	}
+
+	return NewSourceStatus(r.Kind, MustGetObject(obj), targets), true
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSourceStatusIsSerializedDeterministically(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", ResourceVersion: "2"}}
+	target := func(namespace string, version string) metav1.Object {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "foo", Annotations: map[string]string{
+			ReplicatedFromVersionAnnotation: version,
+			ReplicatedAtAnnotation:          "2026-10-16T12:00:00Z",
+		}}}
+	}
+	targets := []metav1.Object{target("team-a", "2"), target("team-b", "1"), target("team-c", "2")}
+
+	expected, err := json.Marshal(NewSourceStatus("Secret", source, targets))
+	require.NoError(t, err)
+
+	reversed := []metav1.Object{targets[2], targets[1], targets[0]}
+	actual, err := json.Marshal(NewSourceStatus("Secret", source, reversed))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
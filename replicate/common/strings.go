@@ -70,3 +70,50 @@ func StringToPatternList(list string) (result []*regexp.Regexp) {
 
 	return
 }
+
+// NamespacePatterns is a list of namespace patterns, in which patterns that
+// are prefixed with "!" exclude namespaces
+type NamespacePatterns struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+}
+
+// StringToNamespacePatterns parses a comma separated list of namespace
+// patterns. Patterns prefixed with "!" are exclusions, e.g. "team-.*,!team-sandbox".
+func StringToNamespacePatterns(list string) (patterns NamespacePatterns) {
+	var include, exclude []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); strings.HasPrefix(s, "!") {
+			exclude = append(exclude, strings.TrimPrefix(s, "!"))
+		} else {
+			include = append(include, s)
+		}
+	}
+
+	if len(include) > 0 {
+		patterns.Include = StringToPatternList(strings.Join(include, ","))
+	}
+	if len(exclude) > 0 {
+		patterns.Exclude = StringToPatternList(strings.Join(exclude, ","))
+	}
+
+	return
+}
+
+// MatchString checks if a namespace matches any of the included patterns and
+// none of the excluded ones. Exclusions always win.
+func (p NamespacePatterns) MatchString(namespace string) bool {
+	for _, pattern := range p.Exclude {
+		if pattern.MatchString(namespace) {
+			return false
+		}
+	}
+
+	for _, pattern := range p.Include {
+		if pattern.MatchString(namespace) {
+			return true
+		}
+	}
+
+	return false
+}
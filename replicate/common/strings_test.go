@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespacePatterns(t *testing.T) {
+	patterns := StringToNamespacePatterns("team-.*, !team-sandbox,infra,!.*-tmp")
+
+	assert.True(t, patterns.MatchString("team-a"))
+	assert.True(t, patterns.MatchString("infra"))
+	assert.False(t, patterns.MatchString("team-sandbox"), "exclusions win over inclusions")
+	assert.False(t, patterns.MatchString("team-a-tmp"), "exclusions win over inclusions")
+	assert.False(t, patterns.MatchString("other"))
+
+	assert.False(t, StringToNamespacePatterns("!team-sandbox").MatchString("team-a"), "exclusions alone don't include anything")
+}
+
+func TestGetNamespacesToReplicateWithExclusions(t *testing.T) {
+	var namespaces []v1.Namespace
+	for _, name := range []string{"default", "team-a", "team-b", "team-sandbox", "infra"} {
+		namespaces = append(namespaces, v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+
+	r := newTestReplicator(t, "Secret")
+	var names []string
+	for _, ns := range r.getNamespacesToReplicate("team-a", "team-.*,!team-sandbox,default", namespaces) {
+		names = append(names, ns.Name)
+	}
+
+	assert.Equal(t, []string{"default", "team-b"}, names)
+}
+
+func TestSourceDeletionKeepsExcludedNamespaces(t *testing.T) {
+	r := newTestReplicator(t, "Secret")
+
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	for _, name := range []string{"default", "team-a", "team-sandbox", "other"} {
+		_, err := r.Client.CoreV1().Namespaces().Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		replica := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: name, Name: "foo", Annotations: map[string]string{
+			ReplicatedAtAnnotation: "2020-01-01T00:00:00Z",
+		}}}
+		require.NoError(t, r.Store.Add(replica))
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: map[string]string{
+		ReplicateTo: "team-.*,!team-sandbox",
+	}}}
+	r.ResourceDeletedReplicateTo(source)
+
+	assert.Equal(t, []string{"team-a/foo"}, deleted)
+}
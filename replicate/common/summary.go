@@ -0,0 +1,90 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of failed reconciles that are kept for the
+// summary of a replicator
+const maxRecentErrors = 10
+
+// RecentError is a failed reconcile of a resource
+type RecentError struct {
+	Resource string    `json:"resource"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error"`
+}
+
+// recentErrors keeps the latest failed reconciles of a replicator, oldest
+// first
+type recentErrors struct {
+	lock   sync.Mutex
+	errors []RecentError
+}
+
+func (e *recentErrors) add(key string, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.errors = append(e.errors, RecentError{Resource: key, Time: time.Now(), Error: err.Error()})
+	if len(e.errors) > maxRecentErrors {
+		e.errors = e.errors[len(e.errors)-maxRecentErrors:]
+	}
+}
+
+func (e *recentErrors) list() []RecentError {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return append([]RecentError(nil), e.errors...)
+}
+
+// Summary gives an overview of the state of a replicator for diagnostics
+type Summary struct {
+	Kind string `json:"kind"`
+
+	// CachedObjects is the number of objects in the replicator's cache
+	CachedObjects int `json:"cachedObjects"`
+
+	// Sources and Targets are the numbers of sources and targets of all
+	// replications, as reported by the status endpoint
+	Sources int `json:"sources"`
+	Targets int `json:"targets"`
+
+	// QueuedEvents and DelayedReplications are the depths of the event queue
+	// and of the queue of delayed replications
+	QueuedEvents        int `json:"queuedEvents"`
+	DelayedReplications int `json:"delayedReplications"`
+
+	Quarantined  int           `json:"quarantined"`
+	RecentErrors []RecentError `json:"recentErrors"`
+}
+
+// Summary returns an overview of the replicator, based on the objects in its
+// cache
+func (r *GenericReplicator) Summary() *Summary {
+	sources := make(map[string]struct{})
+	targets := make(map[string]struct{})
+	for _, replication := range r.Replications() {
+		sources[replication.Source] = struct{}{}
+		targets[replication.Target] = struct{}{}
+	}
+
+	summary := Summary{
+		Kind:          r.Kind,
+		CachedObjects: len(r.Store.ListKeys()),
+		Sources:       len(sources),
+		Targets:       len(targets),
+		Quarantined:   r.Quarantine.Count(),
+		RecentErrors:  r.recentErrors.list(),
+	}
+	if r.events != nil {
+		summary.QueuedEvents = r.events.Len()
+	}
+	if r.DelayQueue != nil {
+		summary.DelayedReplications = r.DelayQueue.Len()
+	}
+
+	return &summary
+}
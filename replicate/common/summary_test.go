@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSummary(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "team-a", "team-b"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	r := newTestReplicator(t, "Secret")
+	r.Quarantine.Threshold = 1
+
+	objects := []*v1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pushed", Annotations: map[string]string{ReplicateTo: "team-a,team-b"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "pushed", Annotations: map[string]string{ReplicatedAtAnnotation: "2026-10-16T12:00:00Z"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "pushed", Annotations: map[string]string{ReplicatedAtAnnotation: "2026-10-16T12:00:00Z"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unrelated"}},
+	}
+	for _, obj := range objects {
+		require.NoError(t, r.Store.Add(obj))
+	}
+
+	r.events.Add("default/unrelated", PriorityResync)
+	r.Quarantine.RecordFailure("default/pushed", "1")
+	for i := 0; i < maxRecentErrors+2; i++ {
+		r.recentErrors.add("default/pushed", fmt.Errorf("error %d", i))
+	}
+
+	summary := r.Summary()
+	assert.Equal(t, "Secret", summary.Kind)
+	assert.Equal(t, 4, summary.CachedObjects)
+	assert.Equal(t, 1, summary.Sources)
+	assert.Equal(t, 2, summary.Targets)
+	assert.Equal(t, 1, summary.QueuedEvents)
+	assert.Equal(t, 1, summary.Quarantined)
+
+	require.Len(t, summary.RecentErrors, maxRecentErrors)
+	assert.Equal(t, "error 2", summary.RecentErrors[0].Error)
+	assert.Equal(t, fmt.Sprintf("error %d", maxRecentErrors+1), summary.RecentErrors[maxRecentErrors-1].Error)
+}
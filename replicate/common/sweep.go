@@ -0,0 +1,56 @@
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// sweepDeletedSources waits for the cache to be synced and then removes the
+// replicas of sources that were deleted while the replicator was not running,
+// since the delete events of these sources were missed.
+func (r *GenericReplicator) sweepDeletedSources(stop <-chan struct{}) {
+	if !cache.WaitForCacheSync(stop, r.Controller.HasSynced) {
+		return
+	}
+
+	r.whenWritable(nil, r.removeReplicasOfDeletedSources)
+}
+
+// removeReplicasOfDeletedSources deletes all pushed replicas in the store
+// whose source does not exist any more. The OnSourceDelete annotation of the
+// deleted sources is not known, so the replicas are always deleted. Targets of
+// pull-based replication are left alone.
+func (r *GenericReplicator) removeReplicasOfDeletedSources() {
+	logger := log.WithField("kind", r.Kind)
+	removed := 0
+
+	for _, obj := range r.Store.List() {
+		objMeta := MustGetObject(obj)
+		sourceKey := ReplicaSource(objMeta)
+		if sourceKey == "" {
+			continue
+		}
+		if _, isPullTarget := objMeta.GetAnnotations()[ReplicateFromAnnotation]; isPullTarget {
+			continue
+		}
+
+		if _, exists, err := r.Store.GetByKey(sourceKey); err != nil || exists {
+			continue
+		}
+
+		targetKey := MustGetKey(objMeta)
+		logger := logger.WithField("source", sourceKey)
+		logger.Infof("source of %s %s was deleted while the replicator was not running, removing it", r.Kind, targetKey)
+
+		if err := r.deleteReplicatedResource(sourceKey, obj); err != nil {
+			if r.requeueIfThrottled(newDelayedDeletion(sourceKey, obj), err) {
+				continue
+			}
+			logger.WithError(err).Errorf("Could not delete resource %s: %+v", targetKey, err)
+			continue
+		}
+		removed++
+	}
+
+	logger.Infof("removed %d replicas of deleted sources", removed)
+}
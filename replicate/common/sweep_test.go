@@ -0,0 +1,42 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReplicasOfSourcesDeletedWhileDownAreRemoved(t *testing.T) {
+	r := newTestReplicator(t, "Secret")
+
+	var deleted []string
+	r.UpdateFuncs.DeleteReplicatedResource = func(target interface{}) error {
+		deleted = append(deleted, MustGetKey(target))
+		return nil
+	}
+
+	replicaOf := func(namespace string, source string, annotations map[string]string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: source, Annotations: annotations, Labels: map[string]string{
+			SourceNamespaceLabel: "default",
+			SourceNameLabel:      source,
+		}}}
+	}
+
+	// the source default/missed was deleted while the replicator was down, so
+	// only its replicas are in the store
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "existing", Annotations: map[string]string{
+		ReplicateTo: "a",
+	}}}))
+	require.NoError(t, r.Store.Add(replicaOf("a", "existing", nil)))
+	require.NoError(t, r.Store.Add(replicaOf("a", "missed", nil)))
+	require.NoError(t, r.Store.Add(replicaOf("b", "missed", nil)))
+	require.NoError(t, r.Store.Add(replicaOf("c", "missed", map[string]string{ReplicateFromAnnotation: "default/missed"})))
+	require.NoError(t, r.Store.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "d", Name: "missed"}}))
+
+	r.removeReplicasOfDeletedSources()
+
+	assert.ElementsMatch(t, []string{"a/missed", "b/missed"}, deleted)
+}
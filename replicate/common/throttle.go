@@ -0,0 +1,142 @@
+package common
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultThrottleDelay is used if the API server throttled a request without
+// suggesting a delay
+const defaultThrottleDelay = time.Second
+
+// delayedResync is put into the delay queue to process a resource again
+type delayedResync struct {
+	Key string
+}
+
+// delayedDeletion is put into the delay queue to delete a replicated resource
+// again. The deletion is only retried if it still applies to the same object.
+type delayedDeletion struct {
+	TargetKey string
+	TargetUID types.UID
+	SourceKey string
+}
+
+// newDelayedDeletion creates the work item that retries the deletion of a
+// replica of the source with the given key
+func newDelayedDeletion(sourceKey string, target interface{}) delayedDeletion {
+	return delayedDeletion{
+		TargetKey: MustGetKey(target),
+		TargetUID: MustGetObject(target).GetUID(),
+		SourceKey: sourceKey,
+	}
+}
+
+// throttleDelay checks if err was caused by the API server throttling our
+// requests ("429 Too Many Requests"). If so, it returns the delay suggested by
+// the server.
+func (r *GenericReplicator) throttleDelay(err error) (time.Duration, bool) {
+	if !apierrors.IsTooManyRequests(err) {
+		return 0, false
+	}
+
+	ThrottledRequestsTotal.WithLabelValues(r.Kind).Inc()
+
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	return defaultThrottleDelay, true
+}
+
+// requeueIfThrottled puts item back into the delay queue if err was caused by
+// throttling. It returns false for all other errors.
+func (r *GenericReplicator) requeueIfThrottled(item interface{}, err error) bool {
+	delay, throttled := r.throttleDelay(err)
+	if !throttled {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithError(err).Warnf("request was throttled by the API server, retrying in %s", delay)
+	r.DelayQueue.AddAfter(item, delay)
+
+	return true
+}
+
+// resyncDelayed processes a resource again after its processing was throttled
+func (r *GenericReplicator) resyncDelayed(item delayedResync) {
+	obj, exists, err := r.Store.GetByKey(item.Key)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Error("error fetching object from store")
+		return
+	} else if !exists {
+		return
+	}
+
+	r.ResourceAdded(obj)
+}
+
+// deleteDelayed deletes a replicated resource again after its deletion was
+// throttled or not approved by the pre-delete hook. Since the source or the
+// replica may have changed in the meantime, the replica is only deleted if the
+// deletion still applies.
+func (r *GenericReplicator) deleteDelayed(item delayedDeletion) {
+	logger := log.WithField("kind", r.Kind).WithField("target", item.TargetKey)
+
+	obj, exists, err := r.Store.GetByKey(item.TargetKey)
+	if err != nil {
+		logger.WithError(err).Error("error fetching object from store")
+		return
+	} else if !exists {
+		return
+	}
+
+	if !r.deletionApplies(item, MustGetObject(obj)) {
+		logger.Infof("deletion of %s %s does not apply any more, keeping it", r.Kind, item.TargetKey)
+		return
+	}
+
+	if err := r.deleteReplicatedResource(item.SourceKey, obj); err != nil && !r.requeueIfThrottled(newDelayedDeletion(item.SourceKey, obj), err) {
+		logger.WithError(err).Errorf("Could not delete resource %s: %+v", item.TargetKey, err)
+	}
+}
+
+// deletionApplies checks if a deferred deletion of a replica still applies:
+// the replica must be the same object and still be written by the
+// replicator, and its source must be deleted, must not target the namespace
+// of the replica any more, or must be empty with the DeleteOnEmpty annotation.
+func (r *GenericReplicator) deletionApplies(item delayedDeletion, target metav1.Object) bool {
+	if target.GetUID() != item.TargetUID || isForeignObject(target) {
+		return false
+	}
+
+	obj, exists, err := r.Store.GetByKey(item.SourceKey)
+	if err != nil {
+		return false
+	} else if !exists {
+		return true
+	}
+
+	source := MustGetObject(obj)
+	if deletesOnEmpty(source) && hasNoData(obj) {
+		return true
+	}
+
+	// targets of pull-based replication are only deleted with their source
+	if _, isPullTarget := target.GetAnnotations()[ReplicateFromAnnotation]; isPullTarget || ReplicaSource(target) != item.SourceKey {
+		return false
+	}
+
+	nsObj, exists, err := namespaceWatcher.NamespaceStore.GetByKey(target.GetNamespace())
+	if err != nil || !exists {
+		return false
+	}
+
+	_, targeted := pushTargets(r.Kind, source, []v1.Namespace{*nsObj.(*v1.Namespace)})[target.GetNamespace()]
+	return !targeted
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	r := newTestReplicator(t, "Test")
+
+	delay, throttled := r.throttleDelay(errors.Wrapf(apierrors.NewTooManyRequests("slow down", 7), "Failed to update secret"))
+	assert.True(t, throttled)
+	assert.Equal(t, 7*time.Second, delay)
+
+	delay, throttled = r.throttleDelay(apierrors.NewTooManyRequests("slow down", 0))
+	assert.True(t, throttled)
+	assert.Equal(t, defaultThrottleDelay, delay)
+
+	_, throttled = r.throttleDelay(apierrors.NewConflict(v1.Resource("secrets"), "foo", errors.New("conflict")))
+	assert.False(t, throttled)
+}
+
+func TestThrottledReplicationIsRequeued(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Now())
+	queue := workqueue.NewDelayingQueueWithCustomClock(clock, "Test")
+	defer queue.ShutDown()
+
+	r := newTestReplicator(t, "Test")
+	r.DelayQueue = queue
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		return apierrors.NewTooManyRequests("slow down", 5)
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	replicated, pending, err := r.replicateResourceToNamespaces(context.Background(), source, []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "target"}}})
+	require.NoError(t, err, "throttled requests are not reported as failures")
+	assert.Empty(t, replicated)
+	assert.Len(t, pending, 1, "throttled requests are pending")
+
+	clock.Step(4 * time.Second)
+	assert.Never(t, func() bool { return queue.Len() > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	clock.Step(time.Second)
+	assert.Eventually(t, func() bool { return queue.Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	item, _ := queue.Get()
+	assert.Equal(t, delayedReplication{SourceKey: "default/foo", Namespace: "target"}, item)
+}
+
+func TestNewSourceVersionIsRetriedImmediately(t *testing.T) {
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "target"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	clock := testingclock.NewFakeClock(time.Now())
+	queue := workqueue.NewDelayingQueueWithCustomClock(clock, "Test")
+	defer queue.ShutDown()
+
+	var writeErr error
+	var written []string
+	r := newTestReplicator(t, "Test")
+	r.DelayQueue = queue
+	r.Quarantine.Threshold = 2
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		written = append(written, MustGetObject(source).GetResourceVersion())
+		return writeErr
+	}
+
+	source := func(version string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", ResourceVersion: version, Annotations: map[string]string{
+			ReplicateTo: "target",
+		}}}
+	}
+
+	// the target is throttled first and then fails until the version is quarantined
+	writeErr = apierrors.NewTooManyRequests("slow down", 60)
+	r.ResourceAdded(source("1"))
+	writeErr = errors.New("admission webhook denied the request")
+	r.ResourceAdded(source("1"))
+	r.ResourceAdded(source("1"))
+	r.ResourceAdded(source("1"))
+	assert.Equal(t, []string{"1", "1", "1"}, written, "quarantined versions are not retried")
+
+	// a new version is written without waiting for the throttled retry
+	writeErr = nil
+	r.ResourceAdded(source("2"))
+	assert.Equal(t, []string{"1", "1", "1", "2"}, written)
+	assert.False(t, r.Quarantine.IsQuarantined("default/foo", "2"))
+}
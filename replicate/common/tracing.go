@@ -0,0 +1,71 @@
+package common
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer that creates the spans of reconciles
+const TracerName = "github.com/mittwald/kubernetes-replicator"
+
+// Attributes of reconcile and write spans
+const (
+	TraceKindKey            = attribute.Key("replicator.kind")
+	TraceNamespaceKey       = attribute.Key("replicator.namespace")
+	TraceNameKey            = attribute.Key("replicator.name")
+	TraceTargetNamespaceKey = attribute.Key("replicator.target.namespace")
+	TraceTargetNameKey      = attribute.Key("replicator.target.name")
+	TraceResultKey          = attribute.Key("replicator.result")
+)
+
+// tracer returns the tracer of the registered tracer provider, which is a
+// no-op unless otel.SetTracerProvider was called
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// startReconcileSpan starts the span of a reconcile of a source
+func (r *GenericReplicator) startReconcileSpan(ctx context.Context, obj interface{}) (context.Context, trace.Span) {
+	objectMeta := MustGetObject(obj)
+	return tracer().Start(ctx, "reconcile "+r.Kind, trace.WithAttributes(
+		TraceKindKey.String(r.Kind),
+		TraceNamespaceKey.String(objectMeta.GetNamespace()),
+		TraceNameKey.String(objectMeta.GetName()),
+	))
+}
+
+// traceWrite runs a write of the target with the given key in a child span of
+// the reconcile in ctx
+func (r *GenericReplicator) traceWrite(ctx context.Context, targetKey string, write func() error) error {
+	namespace, name := targetKey, ""
+	if i := strings.Index(targetKey, "/"); i >= 0 {
+		namespace, name = targetKey[:i], targetKey[i+1:]
+	}
+
+	_, span := tracer().Start(ctx, "write "+r.Kind, trace.WithAttributes(
+		TraceKindKey.String(r.Kind),
+		TraceTargetNamespaceKey.String(namespace),
+		TraceTargetNameKey.String(name),
+	))
+	err := write()
+	endSpan(span, err)
+
+	return err
+}
+
+// endSpan records the result of an operation and ends its span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(TraceResultKey.String("error"))
+	} else {
+		span.SetAttributes(TraceResultKey.String("success"))
+	}
+	span.End()
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attributes := make(map[attribute.Key]string)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value.Emit()
+	}
+	return attributes
+}
+
+func TestReconcileIsTraced(t *testing.T) {
+	defer func(provider trace.TracerProvider) { otel.SetTracerProvider(provider) }(otel.GetTracerProvider())
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	defer func(store cache.Store) { namespaceWatcher.NamespaceStore = store }(namespaceWatcher.NamespaceStore)
+	namespaceWatcher.NamespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"default", "ok", "failing"} {
+		require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}))
+	}
+
+	r := newTestReplicator(t, "Secret")
+	r.UpdateFuncs.ReplicateObjectTo = func(source interface{}, target *v1.Namespace) error {
+		if target.Name == "failing" {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "foo",
+		Annotations: map[string]string{ReplicateTo: "ok,failing"},
+	}}
+	require.NoError(t, r.Store.Add(source))
+
+	r.ResourceAdded(source)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	reconcile := spans[2]
+	assert.Equal(t, "reconcile Secret", reconcile.Name())
+	assert.Equal(t, codes.Error, reconcile.Status().Code)
+	assert.Equal(t, map[attribute.Key]string{
+		TraceKindKey:      "Secret",
+		TraceNamespaceKey: "default",
+		TraceNameKey:      "foo",
+		TraceResultKey:    "error",
+	}, spanAttributes(reconcile))
+
+	results := make(map[string]string)
+	for _, write := range spans[:2] {
+		assert.Equal(t, "write Secret", write.Name())
+		assert.Equal(t, reconcile.SpanContext().SpanID(), write.Parent().SpanID())
+
+		attributes := spanAttributes(write)
+		assert.Equal(t, "foo", attributes[TraceTargetNameKey])
+		results[attributes[TraceTargetNamespaceKey]] = attributes[TraceResultKey]
+	}
+	assert.Equal(t, map[string]string{"ok": "success", "failing": "error"}, results)
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/transform"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// transformTimeout limits the time that a transformation plugin may take for
+// a single replica
+const transformTimeout = 10 * time.Second
+
+// ReplicaTransformer changes replicas before they are written, e.g. an
+// external transformation plugin (see transform.Client)
+type ReplicaTransformer interface {
+	Transform(ctx context.Context, req *transform.TransformRequest) (*transform.TransformResponse, error)
+}
+
+// TransformReplica passes a replica that push-based replication is about to
+// write into targetNamespace to the transformation plugin and replaces it
+// with the plugin's result. Without a plugin, the replica is not changed. If
+// the plugin fails, the error is counted in TransformErrorsTotal and returned,
+// so that only this target is not written.
+func (r *GenericReplicator) TransformReplica(source interface{}, targetNamespace string, replica interface{}) error {
+	if Options.TransformPlugin == nil {
+		return nil
+	}
+
+	if err := transformReplica(r.Kind, source, targetNamespace, replica); err != nil {
+		TransformErrorsTotal.WithLabelValues(r.Kind).Inc()
+		log.WithField("kind", r.Kind).WithField("source", MustGetKey(source)).WithField("target", targetNamespace).
+			WithError(err).Warn("transformation plugin failed")
+		return errors.Wrapf(err, "could not transform %s %s for namespace %s", r.Kind, MustGetKey(source), targetNamespace)
+	}
+
+	return nil
+}
+
+func transformReplica(kind string, source interface{}, targetNamespace string, replica interface{}) error {
+	sourceJSON, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	replicaJSON, err := json.Marshal(replica)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transformTimeout)
+	defer cancel()
+
+	resp, err := Options.TransformPlugin.Transform(ctx, &transform.TransformRequest{
+		Kind:            kind,
+		TargetNamespace: targetNamespace,
+		Source:          sourceJSON,
+		Replica:         replicaJSON,
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Replica) == 0 {
+		return nil
+	}
+
+	transformed := reflect.New(reflect.TypeOf(replica).Elem()).Interface()
+	if err := json.Unmarshal(resp.Replica, transformed); err != nil {
+		return errors.Wrap(err, "invalid replica returned by transformation plugin")
+	}
+
+	before, after := MustGetObject(replica), MustGetObject(transformed)
+	if after.GetName() != before.GetName() || after.GetNamespace() != before.GetNamespace() {
+		return errors.Errorf("transformation plugin must not change the name or namespace of replica %s", MustGetKey(before))
+	}
+
+	reflect.ValueOf(replica).Elem().Set(reflect.ValueOf(transformed).Elem())
+	return nil
+}
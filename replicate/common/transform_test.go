@@ -0,0 +1,86 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/transform"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type transformerFunc func(req *transform.TransformRequest) (*transform.TransformResponse, error)
+
+func (f transformerFunc) Transform(_ context.Context, req *transform.TransformRequest) (*transform.TransformResponse, error) {
+	return f(req)
+}
+
+func TestTransformReplica(t *testing.T) {
+	defer func(plugin ReplicaTransformer) { Options.TransformPlugin = plugin }(Options.TransformPlugin)
+
+	r := newTestReplicator(t, "Secret")
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}, Data: map[string][]byte{"url": []byte("https://default.example")}}
+	replica := func() *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Data: map[string][]byte{"url": []byte("https://default.example")}}
+	}
+
+	t.Run("replicas are unchanged without a plugin", func(t *testing.T) {
+		Options.TransformPlugin = nil
+		secret := replica()
+		require.NoError(t, r.TransformReplica(source, "team-a", secret))
+		assert.Equal(t, replica(), secret)
+	})
+
+	t.Run("replicas are replaced by the plugin's result", func(t *testing.T) {
+		Options.TransformPlugin = transformerFunc(func(req *transform.TransformRequest) (*transform.TransformResponse, error) {
+			assert.Equal(t, "Secret", req.Kind)
+			var secret v1.Secret
+			require.NoError(t, json.Unmarshal(req.Replica, &secret))
+			secret.Data["url"] = []byte("https://" + req.TargetNamespace + ".example")
+			transformed, err := json.Marshal(&secret)
+			return &transform.TransformResponse{Replica: transformed}, err
+		})
+
+		secret := replica()
+		require.NoError(t, r.TransformReplica(source, "team-a", secret))
+		assert.Equal(t, []byte("https://team-a.example"), secret.Data["url"])
+		assert.Equal(t, []byte("https://default.example"), source.Data["url"])
+	})
+
+	t.Run("empty responses leave replicas unchanged", func(t *testing.T) {
+		Options.TransformPlugin = transformerFunc(func(req *transform.TransformRequest) (*transform.TransformResponse, error) {
+			return &transform.TransformResponse{}, nil
+		})
+
+		secret := replica()
+		require.NoError(t, r.TransformReplica(source, "team-a", secret))
+		assert.Equal(t, replica(), secret)
+	})
+
+	t.Run("failures of the plugin fail the target", func(t *testing.T) {
+		before := testutil.ToFloat64(TransformErrorsTotal.WithLabelValues("Secret"))
+		Options.TransformPlugin = transformerFunc(func(req *transform.TransformRequest) (*transform.TransformResponse, error) {
+			return nil, errors.New("unavailable")
+		})
+
+		secret := replica()
+		require.Error(t, r.TransformReplica(source, "team-a", secret))
+		assert.Equal(t, replica(), secret)
+		assert.Equal(t, before+1, testutil.ToFloat64(TransformErrorsTotal.WithLabelValues("Secret")))
+	})
+
+	t.Run("renamed replicas are refused", func(t *testing.T) {
+		Options.TransformPlugin = transformerFunc(func(req *transform.TransformRequest) (*transform.TransformResponse, error) {
+			return &transform.TransformResponse{Replica: []byte(`{"metadata":{"name":"bar"}}`)}, nil
+		})
+
+		secret := replica()
+		require.Error(t, r.TransformReplica(source, "team-a", secret))
+		assert.Equal(t, replica(), secret)
+	})
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetSourceUID records the UID of the source object a replica was written
+// from in its ReplicatedFromUIDAnnotation
+func SetSourceUID(annotations map[string]string, source metav1.Object) {
+	if uid := source.GetUID(); uid != "" {
+		annotations[ReplicatedFromUIDAnnotation] = string(uid)
+	}
+}
+
+// ReplicaUpToDate checks if a replica was last written from the current
+// version of its source. Resource versions are only compared for the same
+// source object: a source that was deleted and recreated with the same name
+// has a different UID, so its replicas are always written again, even if the
+// recreated source happens to have the version they were written from.
+// Replicas written before their source UID was recorded are compared by
+// version only.
+func ReplicaUpToDate(replica metav1.Object, source metav1.Object) bool {
+	annotations := replica.GetAnnotations()
+
+	version, ok := annotations[ReplicatedFromVersionAnnotation]
+	if !ok || version != source.GetResourceVersion() {
+		return false
+	}
+
+	uid, ok := annotations[ReplicatedFromUIDAnnotation]
+	return !ok || uid == string(source.GetUID())
+}
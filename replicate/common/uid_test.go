@@ -0,0 +1,30 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReplicaUpToDate(t *testing.T) {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", UID: "first", ResourceVersion: "5"}}
+
+	replica := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ReplicatedFromVersionAnnotation: "5"}}}
+	assert.True(t, ReplicaUpToDate(replica, source), "replicas without UID are compared by version")
+
+	SetSourceUID(replica.Annotations, source)
+	assert.Equal(t, "first", replica.Annotations[ReplicatedFromUIDAnnotation])
+	assert.True(t, ReplicaUpToDate(replica, source))
+
+	recreated := source.DeepCopy()
+	recreated.UID = "second"
+	assert.False(t, ReplicaUpToDate(replica, recreated))
+
+	updated := source.DeepCopy()
+	updated.ResourceVersion = "6"
+	assert.False(t, ReplicaUpToDate(replica, updated))
+
+	assert.False(t, ReplicaUpToDate(&v1.Secret{}, source))
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Verification reads are limited to this rate across all replicators, so that
+// they don't double the load on the API server during mass replications.
+// Writes above the limit are not verified.
+const (
+	verifyWritesQPS   = 2
+	verifyWritesBurst = 10
+)
+
+var verifyWritesLimiter = flowcontrol.NewTokenBucketRateLimiter(verifyWritesQPS, verifyWritesBurst)
+
+// VerifyWrite reads a replica that was just written from the API server and
+// compares the checksum of its content with the one of the replica that was
+// sent, if enabled by Options.VerifyWrites. A difference means that the write
+// was changed on its way, e.g. by a mutating admission webhook; it is logged,
+// counted and reported as a warning event on the source. It returns true if
+// the write was verified.
+func (r *GenericReplicator) VerifyWrite(source interface{}, written interface{}, read func() (interface{}, error), content func(interface{}) []interface{}) bool {
+	if !Options.VerifyWrites {
+		return false
+	}
+
+	key := MustGetKey(written)
+	logger := log.WithField("kind", r.Kind).WithField("target", key)
+
+	if !verifyWritesLimiter.TryAccept() {
+		logger.Debug("skipping write verification because of the rate limit")
+		WriteVerificationsSkipped.WithLabelValues(r.Kind).Inc()
+		return false
+	}
+
+	current, err := read()
+	if err != nil {
+		logger.WithError(err).Warn("could not read replica to verify the write")
+		return false
+	}
+
+	expected, err := SourceHash(content(written)...)
+	if err != nil {
+		logger.WithError(err).Warn("could not calculate checksum of the written replica")
+		return false
+	}
+	actual, err := SourceHash(content(current)...)
+	if err != nil {
+		logger.WithError(err).Warn("could not calculate checksum of the read replica")
+		return false
+	}
+
+	if expected == actual {
+		logger.Trace("write verified")
+		return true
+	}
+
+	logger.Warnf("content of %s %s differs from what was written", r.Kind, key)
+	WriteVerificationFailures.WithLabelValues(r.Kind).Inc()
+	r.Recorder.Eventf(source.(runtime.Object), v1.EventTypeWarning, "WriteVerificationFailed",
+		"Content of %s %s differs from what was written; it may have been changed by an admission webhook", r.Kind, key)
+	return false
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestVerifyWrite(t *testing.T) {
+	defer func(enabled bool) { Options.VerifyWrites = enabled }(Options.VerifyWrites)
+
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReplicator(t, "VerifiedSecret")
+	r.Recorder = recorder
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "source"}}
+	written := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "target"}, Data: map[string][]byte{"foo": []byte("bar")}}
+	content := func(object interface{}) []interface{} {
+		return []interface{}{object.(*v1.Secret).Data}
+	}
+
+	var current *v1.Secret
+	reads := 0
+	read := func() (interface{}, error) {
+		reads++
+		return current, nil
+	}
+
+	t.Run("disabled verification does not read", func(t *testing.T) {
+		Options.VerifyWrites = false
+		assert.False(t, r.VerifyWrite(source, written, read, content))
+		assert.Equal(t, 0, reads)
+	})
+
+	Options.VerifyWrites = true
+
+	t.Run("unchanged writes are accepted", func(t *testing.T) {
+		current = written.DeepCopy()
+		assert.True(t, r.VerifyWrite(source, written, read, content))
+		assert.Equal(t, 1, reads)
+		assert.Empty(t, recorder.Events)
+		assert.Equal(t, float64(0), testutil.ToFloat64(WriteVerificationFailures.WithLabelValues("VerifiedSecret")))
+	})
+
+	t.Run("changed writes are reported", func(t *testing.T) {
+		current = written.DeepCopy()
+		current.Data["injected"] = []byte("by a webhook")
+		assert.False(t, r.VerifyWrite(source, written, read, content))
+		assert.Contains(t, <-recorder.Events, "WriteVerificationFailed")
+		assert.Equal(t, float64(1), testutil.ToFloat64(WriteVerificationFailures.WithLabelValues("VerifiedSecret")))
+	})
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// Watchdog keeps track of the operations of a replicator that are currently
+// running, so that operations that hang (e.g. on an API call that never
+// returns) can be detected.
+type Watchdog struct {
+	Kind string
+
+	lock    sync.Mutex
+	nextID  uint64
+	running map[uint64]time.Time
+}
+
+// NewWatchdog creates a watchdog for the operations of a replicator of the given kind
+func NewWatchdog(kind string) *Watchdog {
+	return &Watchdog{
+		Kind:    kind,
+		running: make(map[uint64]time.Time),
+	}
+}
+
+// Track runs op and records it as running until it returns. Completed
+// operations are exported as progress by the LastProgress metric. A nil
+// watchdog only runs op.
+func (w *Watchdog) Track(op func()) {
+	if w == nil {
+		op()
+		return
+	}
+
+	w.lock.Lock()
+	id := w.nextID
+	w.nextID++
+	w.running[id] = time.Now()
+	w.lock.Unlock()
+
+	defer func() {
+		w.lock.Lock()
+		delete(w.running, id)
+		w.lock.Unlock()
+
+		LastProgress.WithLabelValues(w.Kind).SetToCurrentTime()
+	}()
+
+	op()
+}
+
+// Stalled returns for how long the oldest running operation has been running
+// at the given time, if that is longer than threshold. A threshold of 0
+// disables the check.
+func (w *Watchdog) Stalled(now time.Time, threshold time.Duration) (time.Duration, bool) {
+	if w == nil || threshold <= 0 {
+		return 0, false
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var longest time.Duration
+	for _, started := range w.running {
+		if running := now.Sub(started); running > longest {
+			longest = running
+		}
+	}
+
+	return longest, longest > threshold
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	w := NewWatchdog("Test")
+	now := time.Now()
+
+	_, stalled := w.Stalled(now, time.Minute)
+	assert.False(t, stalled, "idle replicators never stall")
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Track(func() { <-release })
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		_, stalled := w.Stalled(time.Now().Add(2*time.Minute), time.Minute)
+		return stalled
+	}, time.Second, 10*time.Millisecond)
+
+	running, stalled := w.Stalled(time.Now().Add(2*time.Minute), 0)
+	assert.False(t, stalled, "a threshold of 0 disables the check")
+	assert.Zero(t, running)
+
+	close(release)
+	<-done
+
+	_, stalled = w.Stalled(time.Now().Add(2*time.Minute), time.Minute)
+	assert.False(t, stalled, "completed operations don't stall")
+}
+
+func TestNilWatchdogRunsOperations(t *testing.T) {
+	var w *Watchdog
+	ran := false
+
+	w.Track(func() { ran = true })
+	assert.True(t, ran)
+}
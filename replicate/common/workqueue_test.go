@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestWorkqueueMetricsAreExportedPerQueue(t *testing.T) {
+	secrets := workqueue.NewNamedDelayingQueue("MetricsSecret")
+	defer secrets.ShutDown()
+	configMaps := workqueue.NewNamedDelayingQueue("MetricsConfigMap")
+	defer configMaps.ShutDown()
+
+	secrets.Add("foo/bar")
+	secrets.Add("foo/baz")
+	configMaps.Add("foo/bar")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(workqueueDepth.WithLabelValues("MetricsSecret")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(workqueueAdds.WithLabelValues("MetricsSecret")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(workqueueDepth.WithLabelValues("MetricsConfigMap")))
+
+	item, _ := secrets.Get()
+	secrets.Done(item)
+	assert.Equal(t, float64(1), testutil.ToFloat64(workqueueDepth.WithLabelValues("MetricsSecret")))
+}
@@ -0,0 +1,66 @@
+package configmap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// updateBundle registers replication rules for all secrets that are listed in a
+// config map with the Bundle annotation. The secrets are replicated to the
+// same namespaces as the config map.
+func (r *Replicator) updateBundle(obj interface{}) error {
+	configMap := obj.(*v1.ConfigMap)
+	common.SetBundleRules(common.MustGetKey(configMap), bundleRules(configMap))
+	return nil
+}
+
+// removeBundle removes the replication rules of a deleted config map
+func (r *Replicator) removeBundle(obj interface{}) {
+	common.SetBundleRules(common.MustGetKey(obj), nil)
+}
+
+// bundleRules returns a replication rule for every secret listed in the data of
+// a bundle config map. Secret names are separated by newlines, commas or
+// whitespace and refer to secrets in the namespace of the config map.
+func bundleRules(configMap *v1.ConfigMap) []common.ReplicationRule {
+	if configMap.Annotations[common.Bundle] != "true" {
+		return nil
+	}
+
+	replicateTo, ok := configMap.Annotations[common.ReplicateTo]
+	if !ok {
+		return nil
+	}
+
+	logger := log.WithField("kind", "ConfigMap").WithField("resource", common.MustGetKey(configMap))
+
+	names := make(map[string]struct{})
+	for _, value := range configMap.Data {
+		for _, name := range strings.FieldsFunc(value, func(c rune) bool { return c == ',' || unicode.IsSpace(c) }) {
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+				logger.Warnf("ignoring invalid secret name '%s' in bundle: %s", name, strings.Join(errs, ", "))
+				continue
+			}
+			names[name] = struct{}{}
+		}
+	}
+
+	rules := make([]common.ReplicationRule, 0, len(names))
+	for name := range names {
+		rules = append(rules, common.ReplicationRule{
+			Kind:        "Secret",
+			Source:      fmt.Sprintf("%s/%s", configMap.Namespace, name),
+			ReplicateTo: replicateTo,
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Source < rules[j].Source })
+
+	return rules
+}
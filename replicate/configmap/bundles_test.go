@@ -0,0 +1,41 @@
+package configmap
+
+import (
+	"testing"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBundleRules(t *testing.T) {
+	bundle := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bundle",
+			Namespace: "platform",
+			Annotations: map[string]string{
+				common.Bundle:      "true",
+				common.ReplicateTo: "team-.*",
+			},
+		},
+		Data: map[string]string{
+			"secrets": "registry-credentials\nca-bundle, ca-bundle\n\nInvalid_Name",
+			"more":    "tls-wildcard",
+		},
+	}
+
+	assert.Equal(t, []common.ReplicationRule{
+		{Kind: "Secret", Source: "platform/ca-bundle", ReplicateTo: "team-.*"},
+		{Kind: "Secret", Source: "platform/registry-credentials", ReplicateTo: "team-.*"},
+		{Kind: "Secret", Source: "platform/tls-wildcard", ReplicateTo: "team-.*"},
+	}, bundleRules(bundle))
+
+	notBundle := bundle.DeepCopy()
+	delete(notBundle.Annotations, common.Bundle)
+	assert.Empty(t, bundleRules(notBundle))
+
+	noTargets := bundle.DeepCopy()
+	delete(noTargets.Annotations, common.ReplicateTo)
+	assert.Empty(t, bundleRules(noTargets))
+}
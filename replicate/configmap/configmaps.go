@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -13,6 +14,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -30,6 +32,7 @@ func NewReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allo
 		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
 			Kind:         "ConfigMap",
 			ObjType:      &v1.ConfigMap{},
+			KeyedData:    true,
 			AllowAll:     allowAll,
 			ResyncPeriod: resyncPeriod,
 			Client:       client,
@@ -45,7 +48,10 @@ func NewReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allo
 		ReplicateDataFrom:        repl.ReplicateDataFrom,
 		ReplicateObjectTo:        repl.ReplicateObjectTo,
 		PatchDeleteDependent:     repl.PatchDeleteDependent,
+		PatchOrphanDependent:     repl.PatchOrphanDependent,
 		DeleteReplicatedResource: repl.DeleteReplicatedResource,
+		OnResourceAdded:          repl.updateBundle,
+		OnResourceDeleted:        repl.removeBundle,
 	}
 
 	return &repl
@@ -62,14 +68,25 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 		WithField("source", common.MustGetKey(source)).
 		WithField("target", common.MustGetKey(target))
 
-	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	if r.RefusePinnedReplica(target, source) {
+		return nil
+	}
 
-	if ok && targetVersion == sourceVersion {
+	if common.ReplicaUpToDate(target, source) {
 		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
 		return nil
 	}
 
+	pulledKey := common.PulledKey(target)
+	if pulledKey != "" {
+		_, exists := source.Data[pulledKey]
+		if _, binary := source.BinaryData[pulledKey]; !exists && !binary {
+			r.ReportMissingPulledKey(source, target, pulledKey)
+			return nil
+		}
+	}
+
 	targetCopy := target.DeepCopy()
 	if targetCopy.Data == nil {
 		targetCopy.Data = make(map[string]string)
@@ -79,6 +96,9 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	replicatedKeys := make([]string, 0)
 
 	for key, value := range source.Data {
+		if pulledKey != "" && key != pulledKey {
+			continue
+		}
 		targetCopy.Data[key] = value
 
 		replicatedKeys = append(replicatedKeys, key)
@@ -88,6 +108,9 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	if source.BinaryData != nil {
 		targetCopy.BinaryData = make(map[string][]byte)
 		for key, value := range source.BinaryData {
+			if pulledKey != "" && key != pulledKey {
+				continue
+			}
 			targetCopy.BinaryData[key] = value
 
 			replicatedKeys = append(replicatedKeys, key)
@@ -103,15 +126,43 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 		}
 	}
 
+	if err := compressKeys(source, targetCopy, replicatedKeys); err != nil {
+		return err
+	}
+
 	sort.Strings(replicatedKeys)
 
+	ignoredKeys := common.IgnoredKeysFor(source.Annotations)
+	ignoredKeys.KeepStringValues(target.Data, targetCopy.Data)
+	ignoredKeys.KeepBinaryValues(target.BinaryData, targetCopy.BinaryData)
+	if len(ignoredKeys) > 0 && ignoredKeys.StringMapsEqual(target.Data, targetCopy.Data) && ignoredKeys.BinaryMapsEqual(target.BinaryData, targetCopy.BinaryData) {
+		logger.Debugf("target %s only differs from its source in ignored keys", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
 	logger.Infof("updating config map %s/%s", target.Namespace, target.Name)
+	common.TraceKeyDiff(logger, dataDiff(target, targetCopy))
 
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
 	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(targetCopy.Annotations, source)
 	targetCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	common.SetSourceHash(targetCopy.Annotations,
+		common.StringMapSubset(source.Data, replicatedKeys), common.BinaryMapSubset(source.BinaryData, replicatedKeys))
+	common.SetReplicationChain(targetCopy.Annotations, source)
+
+	if !common.ReplicaChanged(target, targetCopy) {
+		logger.Debugf("target %s would only get a new timestamp", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
 
-	s, err := r.Client.CoreV1().ConfigMaps(target.Namespace).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
+	if err := r.RefuseOversizedReplica(source, common.MustGetKey(target), targetCopy); err != nil {
+		return err
+	}
+
+	s, err := r.updateTarget(target, targetCopy)
 	if err != nil {
 		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
 	} else if err = r.Store.Update(s); err != nil {
@@ -124,7 +175,11 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*v1.ConfigMap)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, skip, err := r.ReplicaName(source, target.Name)
+	if err != nil || skip {
+		return err
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
@@ -137,14 +192,23 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	}
 	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
 
+	if !exists && common.IsUpdateOnly(&source.ObjectMeta) {
+		logger.Debugf("%s does not exist and source is update-only, skipping", targetLocation)
+		r.SkipReconcile(common.SkipReasonUpdateOnly)
+		return nil
+	}
+
+	if exists && r.RefuseSourceConflict(source, targetResource) {
+		return nil
+	}
+
 	var resourceCopy *v1.ConfigMap
+	var targetObject *v1.ConfigMap
 	if exists {
-		targetObject := targetResource.(*v1.ConfigMap)
-		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
-
-		if ok && targetVersion == sourceVersion {
+		targetObject = targetResource.(*v1.ConfigMap)
+		if common.ReplicaUpToDate(targetObject, source) && common.ReplicaMetadataUpToDate(targetObject, target) {
 			logger.Debugf("Secret %s is already up-to-date", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
 			return nil
 		}
 
@@ -153,6 +217,14 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		resourceCopy = new(v1.ConfigMap)
 	}
 
+	// immutable replicas are recreated on every change, so they only stay
+	// immutable as long as their source requests it
+	resourceCopy.Immutable = nil
+	if common.IsImmutable(&source.ObjectMeta) || (source.Immutable != nil && *source.Immutable) {
+		immutable := true
+		resourceCopy.Immutable = &immutable
+	}
+
 	keepOwnerReferences, ok := source.Annotations[common.KeepOwnerReferences]
 	if ok && keepOwnerReferences == "true" {
 		resourceCopy.OwnerReferences = source.OwnerReferences
@@ -168,16 +240,27 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		resourceCopy.Annotations = make(map[string]string)
 	}
 
+	keyNamespaces, err := common.KeyNamespacesFor(source.Annotations)
+	if err != nil {
+		return err
+	}
+
 	prevKeys, hasPrevKeys := common.PreviouslyPresentKeys(&resourceCopy.ObjectMeta)
 	replicatedKeys := make([]string, 0)
 
 	for key, value := range source.Data {
+		if !keyNamespaces.Allows(key, target.Name) {
+			continue
+		}
 		resourceCopy.Data[key] = value
 
 		replicatedKeys = append(replicatedKeys, key)
 		delete(prevKeys, key)
 	}
 	for key, value := range source.BinaryData {
+		if !keyNamespaces.Allows(key, target.Name) {
+			continue
+		}
 		newValue := make([]byte, len(value))
 		copy(newValue, value)
 		resourceCopy.BinaryData[key] = newValue
@@ -190,34 +273,93 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		for k := range prevKeys {
 			logger.Debugf("removing previously present key %s: not present in source secret any more", k)
 			delete(resourceCopy.Data, k)
+			delete(resourceCopy.BinaryData, k)
 		}
 	}
 
+	if err := compressKeys(source, resourceCopy, replicatedKeys); err != nil {
+		return err
+	}
+
 	labelsCopy := make(map[string]string)
+	if targetObject != nil && common.UsesMergePatch(&targetObject.ObjectMeta) {
+		// labels that were added by others are kept when merging
+		for key, value := range targetObject.Labels {
+			labelsCopy[key] = value
+		}
+	}
 
 	stripLabels, ok := source.Annotations[common.StripLabels]
 	if !ok && stripLabels != "true" {
 		if source.Labels != nil {
 			for key, value := range source.Labels {
-				labelsCopy[key] = value
+				if !common.IsDeniedMetadataKey(key) {
+					labelsCopy[key] = value
+				}
 			}
 		}
 	}
 
 	sort.Strings(replicatedKeys)
-	resourceCopy.Name = source.Name
+	resourceCopy.Name = targetName
+	common.SetSourceLabels(labelsCopy, source)
 	resourceCopy.Labels = labelsCopy
 	resourceCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
 	resourceCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(resourceCopy.Annotations, source)
 	resourceCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	common.SetSourceHash(resourceCopy.Annotations,
+		common.StringMapSubset(source.Data, replicatedKeys), common.BinaryMapSubset(source.BinaryData, replicatedKeys))
+	common.SetReplicationChain(resourceCopy.Annotations, source)
+	common.SetExtraAnnotations(resourceCopy.Annotations)
+	common.SetReadyAnnotation(resourceCopy.Annotations)
+	common.ApplyReplicaMetadata(resourceCopy, target)
+
+	if targetObject != nil {
+		ignoredKeys := common.IgnoredKeysFor(source.Annotations)
+		ignoredKeys.KeepStringValues(targetObject.Data, resourceCopy.Data)
+		ignoredKeys.KeepBinaryValues(targetObject.BinaryData, resourceCopy.BinaryData)
+		if len(ignoredKeys) > 0 && ignoredKeys.StringMapsEqual(targetObject.Data, resourceCopy.Data) &&
+			ignoredKeys.BinaryMapsEqual(targetObject.BinaryData, resourceCopy.BinaryData) &&
+			reflect.DeepEqual(targetObject.Labels, resourceCopy.Labels) && common.ReplicaMetadataUpToDate(targetObject, target) {
+			logger.Debugf("ConfigMap %s only differs from its source in ignored keys", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
+			return nil
+		}
+
+		if !common.ReplicaChanged(targetObject, resourceCopy) {
+			logger.Debugf("ConfigMap %s would only get a new timestamp", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
+			return nil
+		}
+	}
+
+	common.TraceKeyDiff(logger, dataDiff(targetObject, resourceCopy))
+
+	if err := r.TransformReplica(source, target.Name, resourceCopy); err != nil {
+		return err
+	}
+
+	if err := r.RefuseOversizedReplica(source, targetLocation, resourceCopy); err != nil {
+		return err
+	}
 
 	var obj interface{}
-	if exists {
+	if exists && targetObject.Immutable != nil && *targetObject.Immutable {
+		logger.Debugf("Recreating immutable config map %s/%s", target.Name, resourceCopy.Name)
+		obj, err = r.recreateTarget(targetObject, resourceCopy)
+	} else if exists {
 		logger.Debugf("Updating existing secret %s/%s", target.Name, resourceCopy.Name)
-		obj, err = r.Client.CoreV1().ConfigMaps(target.Name).Update(context.TODO(), resourceCopy, metav1.UpdateOptions{})
+		obj, err = r.updateTarget(targetObject, resourceCopy)
 	} else {
 		logger.Debugf("Creating a new secret secret %s/%s", target.Name, resourceCopy.Name)
-		obj, err = r.Client.CoreV1().ConfigMaps(target.Name).Create(context.TODO(), resourceCopy, metav1.CreateOptions{})
+		common.SanitizeForCopy(resourceCopy)
+		if common.UsesApply(&resourceCopy.ObjectMeta) {
+			resourceCopy.Namespace = target.Name
+			obj, err = r.applyTarget(resourceCopy)
+		} else {
+			obj, err = r.Client.CoreV1().ConfigMaps(target.Name).Create(context.TODO(), resourceCopy, metav1.CreateOptions{})
+		}
 	}
 	if err != nil {
 		return errors.Wrapf(err, "Failed to update secret %s/%s", target.Name, resourceCopy.Name)
@@ -227,9 +369,147 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, resourceCopy)
 	}
 
+	if r.VerifyWrite(source, resourceCopy, func() (interface{}, error) {
+		return r.Client.CoreV1().ConfigMaps(target.Name).Get(context.TODO(), resourceCopy.Name, metav1.GetOptions{})
+	}, replicaContent) {
+		r.MarkReady(resourceCopy, func(patch []byte) (interface{}, error) {
+			return r.Client.CoreV1().ConfigMaps(target.Name).Patch(context.TODO(), resourceCopy.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		})
+	}
+
+	return nil
+}
+
+// replicaContent returns the content of a replica that is compared when its
+// write is verified
+func replicaContent(object interface{}) []interface{} {
+	configMap := object.(*v1.ConfigMap)
+	return []interface{}{configMap.Data, configMap.BinaryData}
+}
+
+// updateTarget writes the modified copy of an existing target. Targets that
+// use the merge patch write strategy only receive the fields that differ from
+// target, so that changes made by others in the meantime are kept. Updates
+// that conflict with such changes are written again onto the latest version
+// of the target.
+func (r *Replicator) updateTarget(target *v1.ConfigMap, targetCopy *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if common.UsesApply(&target.ObjectMeta) {
+		return r.applyTarget(targetCopy)
+	}
+	if !common.UsesMergePatch(&target.ObjectMeta) {
+		updated, err := r.Client.CoreV1().ConfigMaps(target.Namespace).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
+		if !apierrors.IsConflict(err) {
+			return updated, err
+		}
+
+		// the target was changed by someone else since it was cached, so only
+		// the changes of the replicator are written onto its latest version
+		latest, err := r.Client.CoreV1().ConfigMaps(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get config map %s after a conflict: %v", common.MustGetKey(target), err)
+		}
+		rebased := new(v1.ConfigMap)
+		if err := common.RebaseUpdate(target, targetCopy, latest, rebased); err != nil {
+			return nil, errors.Wrapf(err, "Failed to rebase update of config map %s: %v", common.MustGetKey(target), err)
+		}
+
+		return r.Client.CoreV1().ConfigMaps(target.Namespace).Update(context.TODO(), rebased, metav1.UpdateOptions{})
+	}
+
+	patch, err := common.MergePatch(target, targetCopy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create merge patch for config map %s: %v", common.MustGetKey(target), err)
+	}
+
+	return r.Client.CoreV1().ConfigMaps(target.Namespace).Patch(context.TODO(), target.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+// recreateTarget replaces an immutable target, which can't be updated, by
+// deleting it and creating the modified copy. The target is only deleted if
+// it was not changed since it was cached. If the copy can't be created, the
+// error is returned, so that the target is created again when the source is
+// retried; a target that is already gone is not an error.
+func (r *Replicator) recreateTarget(target *v1.ConfigMap, targetCopy *v1.ConfigMap) (*v1.ConfigMap, error) {
+	err := r.Client.CoreV1().ConfigMaps(target.Namespace).Delete(context.TODO(), target.Name, common.ReplicaDeleteOptions(target))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "Failed to delete immutable config map %s: %v", common.MustGetKey(target), err)
+	}
+
+	common.SanitizeForCopy(targetCopy)
+	targetCopy.Namespace = target.Namespace
+	if common.UsesApply(&targetCopy.ObjectMeta) {
+		return r.applyTarget(targetCopy)
+	}
+
+	return r.Client.CoreV1().ConfigMaps(target.Namespace).Create(context.TODO(), targetCopy, metav1.CreateOptions{})
+}
+
+// applyTarget writes a target with a server-side apply, which creates it if
+// it does not exist yet. Only the fields that the replicator manages are
+// applied, so forcing conflicts with other field managers cannot take over
+// fields that others own.
+func (r *Replicator) applyTarget(target *v1.ConfigMap) (*v1.ConfigMap, error) {
+	patch, err := common.ApplyPatch(target, v1.SchemeGroupVersion.WithKind("ConfigMap"), "data", "binaryData", "immutable")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create apply patch for config map %s: %v", common.MustGetKey(target), err)
+	}
+
+	force := true
+	return r.Client.CoreV1().ConfigMaps(target.Namespace).Patch(context.TODO(), target.Name, types.ApplyPatchType, patch, metav1.PatchOptions{
+		FieldManager: common.FieldManager,
+		Force:        &force,
+	})
+}
+
+// compressKeys gzips the values of the replicated keys if requested by the
+// source. Compressed values are binary, so they are moved into BinaryData.
+// Without compression, values that were compressed before are removed from
+// BinaryData, since a key must not be contained in Data and BinaryData.
+func compressKeys(source *v1.ConfigMap, target *v1.ConfigMap, keys []string) error {
+	compression, err := common.CompressionFor(source.Annotations)
+	if err != nil {
+		return err
+	}
+	common.SetCompressedAnnotation(target.Annotations, compression)
+
+	for _, key := range keys {
+		stringValue, isString := source.Data[key]
+		if compression == "" {
+			if isString {
+				delete(target.BinaryData, key)
+			}
+			continue
+		}
+
+		value := source.BinaryData[key]
+		if isString {
+			value = []byte(stringValue)
+		}
+
+		compressed, err := common.CompressValue(value)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to compress key %s: %v", key, err)
+		}
+
+		if target.BinaryData == nil {
+			target.BinaryData = make(map[string][]byte)
+		}
+		delete(target.Data, key)
+		target.BinaryData[key] = compressed
+	}
+
 	return nil
 }
 
+// dataDiff compares the data and binary data of a replica before and after an
+// update. A nil previous version means that the replica is created.
+func dataDiff(previous *v1.ConfigMap, updated *v1.ConfigMap) common.KeyDiff {
+	if previous == nil {
+		previous = new(v1.ConfigMap)
+	}
+	return common.DiffStringMaps(previous.Data, updated.Data).Merge(common.DiffBinaryMaps(previous.BinaryData, updated.BinaryData))
+}
+
 func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
 	dependentKey := common.MustGetKey(target)
 	logger := log.WithFields(log.Fields{
@@ -264,6 +544,41 @@ func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{})
 	return s, nil
 }
 
+// PatchOrphanDependent removes the annotations that tie a replica to its
+// source, leaving its content intact
+func (r *Replicator) PatchOrphanDependent(target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*v1.ConfigMap)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	patch := common.OrphanPatch(targetObject)
+	if len(patch) == 0 {
+		return targetObject, nil
+	}
+
+	patchBody, err := json.Marshal(&patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while building patch body for configmap %s: %v", dependentKey, err)
+	}
+
+	logger.Debugf("orphaning dependent configmap %s", dependentKey)
+	logger.Tracef("patch body: %s", string(patchBody))
+
+	s, err := r.Client.CoreV1().ConfigMaps(targetObject.Namespace).Patch(context.TODO(), targetObject.Name, types.JSONPatchType, patchBody, metav1.PatchOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while patching configmap %s: %v", dependentKey, err)
+	}
+	return s, nil
+}
+
 // DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
 func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
 	targetLocation := common.MustGetKey(targetResource)
@@ -280,7 +595,7 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	if strings.Join(resourceKeys, ",") == object.Annotations[common.ReplicatedKeysAnnotation] {
 		logger.Debugf("Deleting %s", targetLocation)
-		if err := r.Client.CoreV1().ConfigMaps(object.Namespace).Delete(context.TODO(), object.Name, metav1.DeleteOptions{}); err != nil {
+		if err := r.Client.CoreV1().ConfigMaps(object.Namespace).Delete(context.TODO(), object.Name, common.ReplicaDeleteOptions(object)); err != nil {
 			return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
 		}
 	} else {
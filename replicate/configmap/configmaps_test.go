@@ -0,0 +1,194 @@
+package configmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCompressKeys(t *testing.T) {
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{common.Compress: common.CompressionGzip},
+		},
+		Data:       map[string]string{"text": "Hello World"},
+		BinaryData: map[string][]byte{"binary": []byte("Hello Binary")},
+	}
+
+	compressedText, err := common.CompressValue([]byte("Hello World"))
+	require.NoError(t, err)
+	compressedBinary, err := common.CompressValue([]byte("Hello Binary"))
+	require.NoError(t, err)
+
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Annotations: make(map[string]string)},
+		Data:       map[string]string{"text": "Hello World", "foreign": "kept"},
+		BinaryData: map[string][]byte{"binary": []byte("Hello Binary")},
+	}
+
+	t.Run("compressed values are moved to binary data", func(t *testing.T) {
+		require.NoError(t, compressKeys(source, target, []string{"text", "binary"}))
+		assert.Equal(t, map[string]string{"foreign": "kept"}, target.Data)
+		assert.Equal(t, map[string][]byte{"text": compressedText, "binary": compressedBinary}, target.BinaryData)
+		assert.Equal(t, common.CompressionGzip, target.Annotations[common.CompressedAnnotation])
+	})
+
+	t.Run("disabling compression removes compressed values", func(t *testing.T) {
+		delete(source.Annotations, common.Compress)
+		target.Data["text"] = "Hello World"
+		target.BinaryData["binary"] = []byte("Hello Binary")
+
+		require.NoError(t, compressKeys(source, target, []string{"text", "binary"}))
+		assert.Equal(t, map[string]string{"text": "Hello World", "foreign": "kept"}, target.Data)
+		assert.Equal(t, map[string][]byte{"binary": []byte("Hello Binary")}, target.BinaryData)
+		assert.NotContains(t, target.Annotations, common.CompressedAnnotation)
+	})
+
+	t.Run("unknown compression is an error", func(t *testing.T) {
+		source.Annotations[common.Compress] = "zstd"
+		assert.Error(t, compressKeys(source, target, []string{"text"}))
+	})
+}
+
+func TestReplicateDataFromPullsSingleKey(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+	recorder := record.NewFakeRecorder(10)
+	repl.Recorder = recorder
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "source", Name: "settings", ResourceVersion: "1"},
+		Data:       map[string]string{"color": "blue", "size": "large"},
+	}
+
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "settings", Annotations: map[string]string{
+			common.ReplicateFromAnnotation: "source/settings:color",
+		}},
+		Data: map[string]string{"local": "kept"},
+	}
+	_, err := client.CoreV1().ConfigMaps(target.Namespace).Create(context.TODO(), target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, repl.ReplicateDataFrom(source, target))
+
+	replica, err := client.CoreV1().ConfigMaps(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"color": "blue", "local": "kept"}, replica.Data)
+	assert.Equal(t, "color", replica.Annotations[common.ReplicatedKeysAnnotation])
+
+	t.Run("missing keys are reported without failing", func(t *testing.T) {
+		source := source.DeepCopy()
+		source.ResourceVersion = "2"
+		delete(source.Data, "color")
+
+		require.NoError(t, repl.ReplicateDataFrom(source, replica))
+		assert.Contains(t, <-recorder.Events, "SourceKeyMissing")
+
+		unchanged, err := client.CoreV1().ConfigMaps(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, replica.Data, unchanged.Data)
+	})
+}
+
+func TestImmutableReplicasAreRecreated(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}}
+
+	// the fake API server does not reject updates of immutable objects
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updated := action.(k8stesting.UpdateAction).GetObject().(*v1.ConfigMap)
+		existing, err := client.Tracker().Get(v1.SchemeGroupVersion.WithResource("configmaps"), updated.Namespace, updated.Name)
+		if err == nil && existing.(*v1.ConfigMap).Immutable != nil && *existing.(*v1.ConfigMap).Immutable {
+			return true, nil, errors.New("field is immutable")
+		}
+		return false, nil, nil
+	})
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "source", Name: "settings", ResourceVersion: "1", Annotations: map[string]string{
+			common.ReplicateTo: "target",
+			common.Immutable:   "true",
+		}},
+		Data: map[string]string{"color": "blue"},
+	}
+
+	replicaOf := func() *v1.ConfigMap {
+		replica, err := client.CoreV1().ConfigMaps("target").Get(context.TODO(), "settings", metav1.GetOptions{})
+		require.NoError(t, err)
+		return replica
+	}
+
+	t.Run("existing mutable replicas are converted", func(t *testing.T) {
+		mutable := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "settings", Labels: map[string]string{
+				common.SourceNamespaceLabel: "source",
+				common.SourceNameLabel:      "settings",
+			}},
+			Data: map[string]string{"color": "red"},
+		}
+		_, err := client.CoreV1().ConfigMaps("target").Create(context.TODO(), mutable, metav1.CreateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Add(mutable))
+
+		require.NoError(t, repl.ReplicateObjectTo(source, namespace))
+		replica := replicaOf()
+		require.NotNil(t, replica.Immutable)
+		assert.True(t, *replica.Immutable)
+		assert.Equal(t, map[string]string{"color": "blue"}, replica.Data)
+	})
+
+	t.Run("changes of the source recreate the replica", func(t *testing.T) {
+		source.ResourceVersion = "2"
+		source.Data["color"] = "green"
+		client.ClearActions()
+
+		require.NoError(t, repl.ReplicateObjectTo(source, namespace))
+		replica := replicaOf()
+		assert.Equal(t, map[string]string{"color": "green"}, replica.Data)
+		assert.True(t, *replica.Immutable)
+		assert.Equal(t, "2", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+
+		verbs := make([]string, 0)
+		for _, action := range client.Actions() {
+			if action.GetVerb() != "get" {
+				verbs = append(verbs, action.GetVerb())
+			}
+		}
+		assert.Equal(t, []string{"delete", "create"}, verbs)
+	})
+
+	t.Run("failed creates are retried without losing data", func(t *testing.T) {
+		source.ResourceVersion = "3"
+		source.Data["color"] = "yellow"
+
+		failures := 1
+		client.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if failures > 0 {
+				failures--
+				return true, nil, errors.New("API server unavailable")
+			}
+			return false, nil, nil
+		})
+
+		require.Error(t, repl.ReplicateObjectTo(source, namespace))
+
+		// the cache still holds the deleted replica, so the retry recreates it
+		require.NoError(t, repl.ReplicateObjectTo(source, namespace))
+		replica := replicaOf()
+		assert.Equal(t, map[string]string{"color": "yellow"}, replica.Data)
+		assert.Equal(t, "3", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+	})
+}
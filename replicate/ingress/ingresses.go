@@ -0,0 +1,352 @@
+package ingress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Replicator struct {
+	*common.GenericReplicator
+}
+
+// hostTemplateData is passed to the template of the HostTemplate annotation
+type hostTemplateData struct {
+	Namespace string
+	Host      string
+}
+
+// NewReplicator creates a new ingress replicator
+func NewReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allowAll bool) common.Replicator {
+	repl := Replicator{
+		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
+			Kind:         "Ingress",
+			ObjType:      &networkingv1.Ingress{},
+			AllowAll:     allowAll,
+			ResyncPeriod: resyncPeriod,
+			Client:       client,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.NetworkingV1().Ingresses("").List(context.TODO(), lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.NetworkingV1().Ingresses("").Watch(context.TODO(), lo)
+			},
+		}),
+	}
+	// ingresses need rules or a default backend, so they can't be cleared
+	// and replicas are deleted instead
+	repl.UpdateFuncs = common.UpdateFuncs{
+		ReplicateDataFrom:        repl.ReplicateDataFrom,
+		ReplicateObjectTo:        repl.ReplicateObjectTo,
+		PatchOrphanDependent:     repl.PatchOrphanDependent,
+		DeleteReplicatedResource: repl.DeleteReplicatedResource,
+	}
+
+	return &repl
+}
+
+func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
+	source := sourceObj.(*networkingv1.Ingress)
+	target := targetObj.(*networkingv1.Ingress)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", common.MustGetKey(target))
+
+	// make sure replication is allowed
+	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+		r.SkipReconcile(common.SkipReasonNotPermitted)
+		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+	}
+
+	if r.RefusePinnedReplica(target, source) {
+		return nil
+	}
+
+	if common.ReplicaUpToDate(target, source) {
+		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
+	spec, err := replicatedSpec(source, target.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to build spec for %s/%s", target.Namespace, target.Name)
+	}
+
+	targetCopy := target.DeepCopy()
+	targetCopy.Spec = spec
+	targetCopy.Status = networkingv1.IngressStatus{}
+
+	logger.Infof("updating target %s/%s", target.Namespace, target.Name)
+
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(targetCopy.Annotations, source)
+	common.SetSourceHash(targetCopy.Annotations, source.Spec)
+	common.SetReplicationChain(targetCopy.Annotations, source)
+
+	if !common.ReplicaChanged(withoutStatus(target), targetCopy) {
+		logger.Debugf("target %s would only get a new timestamp", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
+	s, err := r.Client.NetworkingV1().Ingresses(target.Namespace).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
+	} else if err = r.Store.Update(s); err != nil {
+		err = errors.Wrapf(err, "Failed to update cache for %s/%s: %v", target.Namespace, targetCopy, err)
+	}
+
+	return err
+}
+
+// ReplicateObjectTo copies the whole object to target namespace
+func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
+	source := sourceObj.(*networkingv1.Ingress)
+	targetName, skip, err := r.ReplicaName(source, target.Name)
+	if err != nil || skip {
+		return err
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", targetLocation)
+
+	targetResource, exists, err := r.Store.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
+	}
+	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
+
+	if !exists && common.IsUpdateOnly(&source.ObjectMeta) {
+		logger.Debugf("%s does not exist and source is update-only, skipping", targetLocation)
+		r.SkipReconcile(common.SkipReasonUpdateOnly)
+		return nil
+	}
+
+	if exists && r.RefuseSourceConflict(source, targetResource) {
+		return nil
+	}
+
+	var targetCopy *networkingv1.Ingress
+	var targetObject *networkingv1.Ingress
+	if exists {
+		targetObject = targetResource.(*networkingv1.Ingress)
+		if common.ReplicaUpToDate(targetObject, source) && common.ReplicaMetadataUpToDate(targetObject, target) {
+			logger.Debugf("Ingress %s is already up-to-date", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
+			return nil
+		}
+
+		targetCopy = targetObject.DeepCopy()
+	} else {
+		targetCopy = new(networkingv1.Ingress)
+	}
+
+	keepOwnerReferences, ok := source.Annotations[common.KeepOwnerReferences]
+	if ok && keepOwnerReferences == "true" {
+		targetCopy.OwnerReferences = source.OwnerReferences
+	}
+
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	labelsCopy := make(map[string]string)
+
+	stripLabels, ok := source.Annotations[common.StripLabels]
+	if !ok && stripLabels != "true" {
+		if source.Labels != nil {
+			for key, value := range source.Labels {
+				if !common.IsDeniedMetadataKey(key) {
+					labelsCopy[key] = value
+				}
+			}
+		}
+	}
+
+	spec, err := replicatedSpec(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to build spec for %s", targetLocation)
+	}
+
+	targetCopy.Name = targetName
+	common.SetSourceLabels(labelsCopy, source)
+	targetCopy.Labels = labelsCopy
+	targetCopy.Spec = spec
+	targetCopy.Status = networkingv1.IngressStatus{}
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(targetCopy.Annotations, source)
+	common.SetSourceHash(targetCopy.Annotations, source.Spec)
+	common.SetReplicationChain(targetCopy.Annotations, source)
+	common.SetExtraAnnotations(targetCopy.Annotations)
+	common.SetReadyAnnotation(targetCopy.Annotations)
+	common.ApplyReplicaMetadata(targetCopy, target)
+
+	if exists && !common.ReplicaChanged(withoutStatus(targetObject), targetCopy) {
+		logger.Debugf("Ingress %s would only get a new timestamp", common.MustGetKey(targetObject))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
+	if err := r.TransformReplica(source, target.Name, targetCopy); err != nil {
+		return err
+	}
+
+	var obj interface{}
+	if exists {
+		logger.Debugf("Updating existing ingress %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.NetworkingV1().Ingresses(target.Name).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
+	} else {
+		logger.Debugf("Creating a new ingress %s/%s", target.Name, targetCopy.Name)
+		common.SanitizeForCopy(targetCopy)
+		obj, err = r.Client.NetworkingV1().Ingresses(target.Name).Create(context.TODO(), targetCopy, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update ingress %s/%s", target.Name, targetCopy.Name)
+	}
+
+	if err := r.Store.Update(obj); err != nil {
+		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, targetCopy)
+	}
+
+	if r.VerifyWrite(source, targetCopy, func() (interface{}, error) {
+		return r.Client.NetworkingV1().Ingresses(target.Name).Get(context.TODO(), targetCopy.Name, metav1.GetOptions{})
+	}, replicaContent) {
+		r.MarkReady(targetCopy, func(patch []byte) (interface{}, error) {
+			return r.Client.NetworkingV1().Ingresses(target.Name).Patch(context.TODO(), targetCopy.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		})
+	}
+
+	return nil
+}
+
+// replicaContent returns the content of a replica that is compared when its
+// write is verified
+func replicaContent(object interface{}) []interface{} {
+	ingress := object.(*networkingv1.Ingress)
+	return []interface{}{ingress.Spec}
+}
+
+// withoutStatus returns a copy of an ingress without its status, which is
+// never replicated
+func withoutStatus(ingress *networkingv1.Ingress) *networkingv1.Ingress {
+	ingressCopy := ingress.DeepCopy()
+	ingressCopy.Status = networkingv1.IngressStatus{}
+	return ingressCopy
+}
+
+// replicatedSpec copies the spec of the source and rewrites all host names
+// using the source's HostTemplate annotation, if present.
+func replicatedSpec(source *networkingv1.Ingress, targetNamespace string) (networkingv1.IngressSpec, error) {
+	spec := *source.Spec.DeepCopy()
+
+	hostTemplate, ok := source.Annotations[common.HostTemplate]
+	if !ok {
+		return spec, nil
+	}
+
+	tmpl, err := template.New("host").Option("missingkey=error").Parse(hostTemplate)
+	if err != nil {
+		return spec, errors.Wrapf(err, "invalid host template '%s'", hostTemplate)
+	}
+
+	rewrite := func(host string) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, hostTemplateData{Namespace: targetNamespace, Host: host}); err != nil {
+			return "", errors.Wrapf(err, "could not rewrite host '%s'", host)
+		}
+		return buf.String(), nil
+	}
+
+	for i := range spec.Rules {
+		if spec.Rules[i].Host == "" {
+			continue
+		}
+		if spec.Rules[i].Host, err = rewrite(spec.Rules[i].Host); err != nil {
+			return spec, err
+		}
+	}
+
+	for i := range spec.TLS {
+		for j := range spec.TLS[i].Hosts {
+			if spec.TLS[i].Hosts[j], err = rewrite(spec.TLS[i].Hosts[j]); err != nil {
+				return spec, err
+			}
+		}
+	}
+
+	return spec, nil
+}
+
+// PatchOrphanDependent removes the annotations that tie a replica to its
+// source, leaving its content intact
+func (r *Replicator) PatchOrphanDependent(target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*networkingv1.Ingress)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	patch := common.OrphanPatch(targetObject)
+	if len(patch) == 0 {
+		return targetObject, nil
+	}
+
+	patchBody, err := json.Marshal(&patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while building patch body for ingress %s: %v", dependentKey, err)
+	}
+
+	logger.Debugf("orphaning dependent ingress %s", dependentKey)
+	logger.Tracef("patch body: %s", string(patchBody))
+
+	s, err := r.Client.NetworkingV1().Ingresses(targetObject.Namespace).Patch(context.TODO(), targetObject.Name, types.JSONPatchType, patchBody, metav1.PatchOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while patching ingress %s: %v", dependentKey, err)
+	}
+	return s, nil
+}
+
+// DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
+func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": targetLocation,
+	})
+
+	object := targetResource.(*networkingv1.Ingress)
+	logger.Debugf("Deleting %s", targetLocation)
+	if err := r.Client.NetworkingV1().Ingresses(object.Namespace).Delete(context.TODO(), object.Name, common.ReplicaDeleteOptions(object)); err != nil {
+		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
+	}
+	return nil
+}
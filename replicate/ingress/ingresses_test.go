@@ -0,0 +1,89 @@
+package ingress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIngressReplicator(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+
+	target := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}}
+	pathType := networkingv1.PathTypePrefix
+
+	source := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "common",
+			Namespace:       "platform",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo:  target.Name,
+				common.HostTemplate: "{{ .Namespace }}.{{ .Host }}",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{{
+				Hosts:      []string{"apps.example.com"},
+				SecretName: "wildcard-tls",
+			}},
+			Rules: []networkingv1.IngressRule{{
+				Host: "apps.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: "web",
+							Port: networkingv1.ServiceBackendPort{Number: 80},
+						}},
+					}},
+				}},
+			}},
+		},
+		Status: networkingv1.IngressStatus{LoadBalancer: corev1.LoadBalancerStatus{
+			Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}},
+		}},
+	}
+
+	t.Run("ingress is replicated with rewritten host", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+
+		replica, err := client.NetworkingV1().Ingresses(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "tenant-a.apps.example.com", replica.Spec.Rules[0].Host)
+		require.Equal(t, []string{"tenant-a.apps.example.com"}, replica.Spec.TLS[0].Hosts)
+		require.Equal(t, "wildcard-tls", replica.Spec.TLS[0].SecretName)
+		require.Equal(t, "web", replica.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name)
+		require.Empty(t, replica.Status.LoadBalancer.Ingress)
+		require.Equal(t, "1", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+
+		// the source must not be modified by the rewrite
+		require.Equal(t, "apps.example.com", source.Spec.Rules[0].Host)
+	})
+
+	t.Run("replicated ingress is deleted", func(t *testing.T) {
+		replica, err := repl.ObjectFromStore(target.Name + "/" + source.Name)
+		require.NoError(t, err)
+		require.NoError(t, repl.DeleteReplicatedResource(replica))
+
+		_, err = client.NetworkingV1().Ingresses(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.Error(t, err)
+	})
+
+	t.Run("invalid host template is rejected", func(t *testing.T) {
+		invalid := source.DeepCopy()
+		invalid.Name = "invalid"
+		invalid.Annotations[common.HostTemplate] = "{{ .Namespace"
+
+		require.Error(t, repl.ReplicateObjectTo(invalid, &target))
+	})
+}
@@ -0,0 +1,261 @@
+//go:build integration
+// +build integration
+
+package replicate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// The integration tests run the replicators against a real API server and
+// etcd started by envtest, so that validation and admission behave like in a
+// cluster. They are skipped unless KUBEBUILDER_ASSETS points to the envtest
+// binaries, e.g. as installed by
+// `setup-envtest use -p path 1.25.x` from sigs.k8s.io/controller-runtime/tools/setup-envtest.
+
+const (
+	integrationResyncPeriod = 2 * time.Second
+	integrationTimeout      = 10 * time.Second
+	integrationInterval     = 100 * time.Millisecond
+)
+
+var integrationClient kubernetes.Interface
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		os.Exit(m.Run())
+	}
+
+	env := &envtest.Environment{}
+	config, err := env.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not start test environment: %v\n", err)
+		os.Exit(1)
+	}
+
+	enabled := make(map[string]bool)
+	for _, k := range Kinds {
+		enabled[k.Kind] = true
+	}
+
+	replicators, err := StartForConfig(config, enabled, integrationResyncPeriod, false)
+	if err == nil {
+		integrationClient, err = kubernetes.NewForConfig(config)
+	}
+	if err != nil {
+		_ = env.Stop()
+		fmt.Fprintf(os.Stderr, "could not start replicators: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, repl := range replicators {
+		for !repl.Synced() {
+			time.Sleep(integrationInterval)
+		}
+	}
+
+	code := m.Run()
+	_ = env.Stop()
+	os.Exit(code)
+}
+
+// integrationNamespaces creates namespaces with unique names for a test.
+// envtest runs no namespace controller, so they are not cleaned up.
+func integrationNamespaces(t *testing.T, names ...string) []string {
+	if integrationClient == nil {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	prefix := fmt.Sprintf("it-%d-", time.Now().UnixNano())
+	namespaces := make([]string, 0, len(names))
+	for _, name := range names {
+		ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: prefix + name}}
+		_, err := integrationClient.CoreV1().Namespaces().Create(context.TODO(), &ns, metav1.CreateOptions{})
+		require.NoError(t, err)
+		namespaces = append(namespaces, ns.Name)
+	}
+
+	return namespaces
+}
+
+func eventuallySecret(t *testing.T, namespace, name string, condition func(secret *corev1.Secret, err error) bool) {
+	require.Eventually(t, func() bool {
+		secret, err := integrationClient.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		return condition(secret, err)
+	}, integrationTimeout, integrationInterval)
+}
+
+func TestIntegrationPushReplication(t *testing.T) {
+	namespaces := integrationNamespaces(t, "source", "target")
+	secrets := integrationClient.CoreV1().Secrets(namespaces[0])
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pushed",
+			Namespace:   namespaces[0],
+			Annotations: map[string]string{common.ReplicateTo: namespaces[1]},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+	_, err := secrets.Create(context.TODO(), &source, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	eventuallySecret(t, namespaces[1], source.Name, func(replica *corev1.Secret, err error) bool {
+		return err == nil && string(replica.Data["foo"]) == "Hello Foo"
+	})
+
+	t.Run("replicates updates of the source", func(t *testing.T) {
+		updated, err := secrets.Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		updated.Data["foo"] = []byte("Hello Bar")
+		_, err = secrets.Update(context.TODO(), updated, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		eventuallySecret(t, namespaces[1], source.Name, func(replica *corev1.Secret, err error) bool {
+			return err == nil && string(replica.Data["foo"]) == "Hello Bar"
+		})
+	})
+
+	t.Run("recreates deleted replicas", func(t *testing.T) {
+		err := integrationClient.CoreV1().Secrets(namespaces[1]).Delete(context.TODO(), source.Name, metav1.DeleteOptions{})
+		require.NoError(t, err)
+
+		eventuallySecret(t, namespaces[1], source.Name, func(replica *corev1.Secret, err error) bool {
+			return err == nil && string(replica.Data["foo"]) == "Hello Bar"
+		})
+	})
+
+	t.Run("deletes replicas with their source", func(t *testing.T) {
+		require.NoError(t, secrets.Delete(context.TODO(), source.Name, metav1.DeleteOptions{}))
+
+		eventuallySecret(t, namespaces[1], source.Name, func(_ *corev1.Secret, err error) bool {
+			return errors.IsNotFound(err)
+		})
+	})
+}
+
+func TestIntegrationPullReplication(t *testing.T) {
+	namespaces := integrationNamespaces(t, "source", "target")
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tls",
+			Namespace: namespaces[0],
+			Annotations: map[string]string{
+				common.ReplicationAllowed:           "true",
+				common.ReplicationAllowedNamespaces: namespaces[1],
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+	_, err := integrationClient.CoreV1().Secrets(namespaces[0]).Create(context.TODO(), &source, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// the API server requires the keys of TLS secrets, but allows them to be empty
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "tls",
+			Namespace:   namespaces[1],
+			Annotations: map[string]string{common.ReplicateFromAnnotation: common.MustGetKey(&source)},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       nil,
+			corev1.TLSPrivateKeyKey: nil,
+		},
+	}
+	targets := integrationClient.CoreV1().Secrets(namespaces[1])
+	_, err = targets.Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	eventuallySecret(t, namespaces[1], target.Name, func(replica *corev1.Secret, err error) bool {
+		return err == nil && string(replica.Data[corev1.TLSCertKey]) == "certificate"
+	})
+
+	t.Run("reverts changes of the target", func(t *testing.T) {
+		modified, err := targets.Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		modified.Data[corev1.TLSCertKey] = []byte("modified")
+		_, err = targets.Update(context.TODO(), modified, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		eventuallySecret(t, namespaces[1], target.Name, func(replica *corev1.Secret, err error) bool {
+			return err == nil && string(replica.Data[corev1.TLSCertKey]) == "certificate"
+		})
+	})
+}
+
+func TestIntegrationPullTargetIsClearedWithSource(t *testing.T) {
+	namespaces := integrationNamespaces(t, "source", "target")
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pulled",
+			Namespace: namespaces[0],
+			Annotations: map[string]string{
+				common.ReplicationAllowed:           "true",
+				common.ReplicationAllowedNamespaces: namespaces[1],
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+	_, err := integrationClient.CoreV1().Secrets(namespaces[0]).Create(context.TODO(), &source, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pulled",
+			Namespace:   namespaces[1],
+			Annotations: map[string]string{common.ReplicateFromAnnotation: common.MustGetKey(&source)},
+		},
+	}
+	_, err = integrationClient.CoreV1().Secrets(namespaces[1]).Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	eventuallySecret(t, namespaces[1], target.Name, func(replica *corev1.Secret, err error) bool {
+		return err == nil && string(replica.Data["foo"]) == "Hello Foo"
+	})
+
+	require.NoError(t, integrationClient.CoreV1().Secrets(namespaces[0]).Delete(context.TODO(), source.Name, metav1.DeleteOptions{}))
+
+	eventuallySecret(t, namespaces[1], target.Name, func(replica *corev1.Secret, err error) bool {
+		return err == nil && len(replica.Data) == 0
+	})
+}
+
+func TestIntegrationConfigMapPushReplication(t *testing.T) {
+	namespaces := integrationNamespaces(t, "source", "target")
+
+	source := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pushed",
+			Namespace:   namespaces[0],
+			Annotations: map[string]string{common.ReplicateTo: namespaces[1]},
+		},
+		Data:       map[string]string{"foo": "Hello Foo"},
+		BinaryData: map[string][]byte{"bar": {0, 1, 2}},
+	}
+	_, err := integrationClient.CoreV1().ConfigMaps(namespaces[0]).Create(context.TODO(), &source, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		replica, err := integrationClient.CoreV1().ConfigMaps(namespaces[1]).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		return err == nil && replica.Data["foo"] == "Hello Foo" && len(replica.BinaryData["bar"]) == 3
+	}, integrationTimeout, integrationInterval)
+}
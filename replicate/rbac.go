@@ -0,0 +1,99 @@
+package replicate
+
+import (
+	"sort"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Verbs that the replicators and the watches of their configuration use
+var (
+	readVerbs      = []string{"get", "list", "watch"}
+	replicateVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+	eventVerbs     = []string{"create", "patch"}
+	statusVerbs    = []string{"get", "create", "update"}
+)
+
+// RBACConfig contains the settings that determine which permissions the
+// replicator needs
+type RBACConfig struct {
+	// Enabled are the kinds that are replicated
+	Enabled map[string]bool
+
+	// FeatureGatesNamespace and NamespaceMappingNamespace are the namespaces
+	// of the feature gates and namespace mapping config maps, if configured
+	FeatureGatesNamespace     string
+	NamespaceMappingNamespace string
+
+	// FailureStatus is true if failures are written to config maps in the
+	// namespaces of the sources
+	FailureStatus bool
+
+	// ReplicationRuleCRD is true if ReplicationRule resources are watched
+	ReplicationRuleCRD bool
+
+	// ConsumerDiscovery is true if deployments are watched for the secrets
+	// they reference
+	ConsumerDiscovery bool
+}
+
+// RBACRules returns the minimal rules the replicator needs with the given
+// configuration. Cluster rules apply to all namespaces; namespaced rules are
+// only needed in a single namespace, by namespace.
+func RBACRules(config RBACConfig) (cluster []rbacv1.PolicyRule, namespaced map[string][]rbacv1.PolicyRule) {
+	cluster = []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: readVerbs},
+	}
+	namespaced = make(map[string][]rbacv1.PolicyRule)
+
+	replicated := make(map[string][]string)
+	for _, k := range Kinds {
+		if config.Enabled[k.Kind] {
+			replicated[k.Resource.Group] = append(replicated[k.Resource.Group], k.Resource.Resource)
+		}
+	}
+	for _, group := range sortedKeys(replicated) {
+		cluster = append(cluster, rbacv1.PolicyRule{APIGroups: []string{group}, Resources: replicated[group], Verbs: replicateVerbs})
+	}
+
+	configMapsReplicated := config.Enabled["ConfigMap"]
+	if config.FailureStatus && !configMapsReplicated {
+		cluster = append(cluster, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: statusVerbs})
+	}
+
+	if config.ReplicationRuleCRD {
+		cluster = append(cluster, rbacv1.PolicyRule{
+			APIGroups: []string{common.ReplicationRuleResources.Group},
+			Resources: []string{common.ReplicationRuleResources.Resource},
+			Verbs:     readVerbs,
+		})
+	}
+
+	if config.ConsumerDiscovery {
+		cluster = append(cluster, rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: readVerbs})
+	}
+
+	cluster = append(cluster, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: eventVerbs})
+
+	// config maps with the configuration of the replicator are covered by the
+	// cluster rules if config maps are replicated
+	if !configMapsReplicated {
+		for _, namespace := range []string{config.FeatureGatesNamespace, config.NamespaceMappingNamespace} {
+			if namespace != "" && len(namespaced[namespace]) == 0 {
+				namespaced[namespace] = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: readVerbs}}
+			}
+		}
+	}
+
+	return cluster, namespaced
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
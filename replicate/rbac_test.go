@@ -0,0 +1,47 @@
+package replicate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestRBACRulesContainOnlyEnabledKinds(t *testing.T) {
+	cluster, namespaced := RBACRules(RBACConfig{
+		Enabled:               map[string]bool{"Secret": true, "Role": true, "RoleBinding": true},
+		FeatureGatesNamespace: "kube-system",
+		FailureStatus:         true,
+	})
+
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: readVerbs},
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: replicateVerbs},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles", "rolebindings"}, Verbs: replicateVerbs},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: statusVerbs},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: eventVerbs},
+	}, cluster)
+	assert.Equal(t, map[string][]rbacv1.PolicyRule{
+		"kube-system": {{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: readVerbs}},
+	}, namespaced)
+}
+
+func TestRBACRulesWithReplicatedConfigMaps(t *testing.T) {
+	cluster, namespaced := RBACRules(RBACConfig{
+		Enabled:                   map[string]bool{"ConfigMap": true},
+		FeatureGatesNamespace:     "kube-system",
+		NamespaceMappingNamespace: "vcluster",
+		FailureStatus:             true,
+		ReplicationRuleCRD:        true,
+		ConsumerDiscovery:         true,
+	})
+
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: readVerbs},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: replicateVerbs},
+		{APIGroups: []string{"replicator.mittwald.de"}, Resources: []string{"replicationrules"}, Verbs: readVerbs},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: readVerbs},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: eventVerbs},
+	}, cluster)
+	assert.Empty(t, namespaced, "config maps are already covered by the cluster rules")
+}
@@ -0,0 +1,68 @@
+package replicate
+
+import (
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/mittwald/kubernetes-replicator/replicate/configmap"
+	"github.com/mittwald/kubernetes-replicator/replicate/ingress"
+	"github.com/mittwald/kubernetes-replicator/replicate/role"
+	"github.com/mittwald/kubernetes-replicator/replicate/rolebinding"
+	"github.com/mittwald/kubernetes-replicator/replicate/secret"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Kind is a kind of objects that can be replicated
+type Kind struct {
+	Kind          string
+	NewReplicator func(kubernetes.Interface, time.Duration, bool) common.Replicator
+
+	// Resource is the API resource that the replicator watches and writes
+	Resource schema.GroupVersionResource
+}
+
+// Kinds are all kinds that can be replicated, in the order they are started
+var Kinds = []Kind{
+	{"Secret", secret.NewReplicator, corev1.SchemeGroupVersion.WithResource("secrets")},
+	{"ConfigMap", configmap.NewReplicator, corev1.SchemeGroupVersion.WithResource("configmaps")},
+	{"Role", role.NewReplicator, rbacv1.SchemeGroupVersion.WithResource("roles")},
+	{"RoleBinding", rolebinding.NewReplicator, rbacv1.SchemeGroupVersion.WithResource("rolebindings")},
+	{"Ingress", ingress.NewReplicator, networkingv1.SchemeGroupVersion.WithResource("ingresses")},
+}
+
+// Start creates and runs a replicator for every kind that is enabled. The
+// running replicators are returned by kind.
+func Start(client kubernetes.Interface, enabled map[string]bool, resyncPeriod time.Duration, allowAll bool) map[string]common.Replicator {
+	replicators := make(map[string]common.Replicator)
+
+	for _, k := range Kinds {
+		if !enabled[k.Kind] {
+			log.Infof("replication of %s is disabled", k.Kind)
+			continue
+		}
+
+		repl := k.NewReplicator(client, resyncPeriod, allowAll)
+		go repl.Run()
+
+		replicators[k.Kind] = repl
+	}
+
+	return replicators
+}
+
+// StartForConfig is like Start, but creates the client from a REST config
+func StartForConfig(config *rest.Config, enabled map[string]bool, resyncPeriod time.Duration, allowAll bool) (map[string]common.Replicator, error) {
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create client: %v", err)
+	}
+
+	return Start(client, enabled, resyncPeriod, allowAll), nil
+}
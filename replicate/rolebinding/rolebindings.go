@@ -46,6 +46,7 @@ func NewReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allo
 		ReplicateDataFrom:        repl.ReplicateDataFrom,
 		ReplicateObjectTo:        repl.ReplicateObjectTo,
 		PatchDeleteDependent:     repl.PatchDeleteDependent,
+		PatchOrphanDependent:     repl.PatchOrphanDependent,
 		DeleteReplicatedResource: repl.DeleteReplicatedResource,
 	}
 
@@ -63,14 +64,17 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 
 	// make sure replication is allowed
 	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+		r.SkipReconcile(common.SkipReasonNotPermitted)
 		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
 	}
 
-	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	if r.RefusePinnedReplica(target, source) {
+		return nil
+	}
 
-	if ok && targetVersion == sourceVersion {
+	if common.ReplicaUpToDate(target, source) {
 		logger.Debugf("target %s/%s is already up-to-date", target.Namespace, target.Name)
+		r.SkipReconcile(common.SkipReasonUpToDate)
 		return nil
 	}
 
@@ -81,6 +85,15 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
 	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(targetCopy.Annotations, source)
+	common.SetSourceHash(targetCopy.Annotations, source.RoleRef, source.Subjects)
+	common.SetReplicationChain(targetCopy.Annotations, source)
+
+	if !common.ReplicaChanged(target, targetCopy) {
+		logger.Debugf("target %s would only get a new timestamp", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
 
 	s, err := r.Client.RbacV1().RoleBindings(target.Namespace).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
 	if err != nil {
@@ -95,7 +108,11 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*rbacv1.RoleBinding)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, skip, err := r.ReplicaName(source, target.Name)
+	if err != nil || skip {
+		return err
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
@@ -108,14 +125,23 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	}
 	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
 
+	if !exists && common.IsUpdateOnly(&source.ObjectMeta) {
+		logger.Debugf("%s does not exist and source is update-only, skipping", targetLocation)
+		r.SkipReconcile(common.SkipReasonUpdateOnly)
+		return nil
+	}
+
+	if exists && r.RefuseSourceConflict(source, targetResource) {
+		return nil
+	}
+
 	var targetCopy *rbacv1.RoleBinding
+	var targetObject *rbacv1.RoleBinding
 	if exists {
-		targetObject := targetResource.(*rbacv1.RoleBinding)
-		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
-
-		if ok && targetVersion == sourceVersion {
+		targetObject = targetResource.(*rbacv1.RoleBinding)
+		if common.ReplicaUpToDate(targetObject, source) && common.ReplicaMetadataUpToDate(targetObject, target) {
 			logger.Debugf("RoleBinding %s is already up-to-date", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
 			return nil
 		}
 
@@ -139,18 +165,37 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	if !ok && stripLabels != "true" {
 		if source.Labels != nil {
 			for key, value := range source.Labels {
-				labelsCopy[key] = value
+				if !common.IsDeniedMetadataKey(key) {
+					labelsCopy[key] = value
+				}
 			}
 		}
 
 	}
 
-	targetCopy.Name = source.Name
+	targetCopy.Name = targetName
+	common.SetSourceLabels(labelsCopy, source)
 	targetCopy.Labels = labelsCopy
 	targetCopy.Subjects = source.Subjects
 	targetCopy.RoleRef = source.RoleRef
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
 	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(targetCopy.Annotations, source)
+	common.SetSourceHash(targetCopy.Annotations, source.RoleRef, source.Subjects)
+	common.SetReplicationChain(targetCopy.Annotations, source)
+	common.SetExtraAnnotations(targetCopy.Annotations)
+	common.SetReadyAnnotation(targetCopy.Annotations)
+	common.ApplyReplicaMetadata(targetCopy, target)
+
+	if exists && !common.ReplicaChanged(targetObject, targetCopy) {
+		logger.Debugf("RoleBinding %s would only get a new timestamp", common.MustGetKey(targetObject))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
+	if err := r.TransformReplica(source, target.Name, targetCopy); err != nil {
+		return err
+	}
 
 	var obj interface{}
 	if targetCopy.RoleRef.Kind == "Role" {
@@ -164,6 +209,7 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	} else {
 		if err == nil {
 			logger.Debugf("Creating a new roleBinding %s/%s", target.Name, targetCopy.Name)
+			common.SanitizeForCopy(targetCopy)
 			obj, err = r.Client.RbacV1().RoleBindings(target.Name).Create(context.TODO(), targetCopy, metav1.CreateOptions{})
 		}
 	}
@@ -175,9 +221,24 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, targetCopy)
 	}
 
+	if r.VerifyWrite(source, targetCopy, func() (interface{}, error) {
+		return r.Client.RbacV1().RoleBindings(target.Name).Get(context.TODO(), targetCopy.Name, metav1.GetOptions{})
+	}, replicaContent) {
+		r.MarkReady(targetCopy, func(patch []byte) (interface{}, error) {
+			return r.Client.RbacV1().RoleBindings(target.Name).Patch(context.TODO(), targetCopy.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		})
+	}
+
 	return nil
 }
 
+// replicaContent returns the content of a replica that is compared when its
+// write is verified
+func replicaContent(object interface{}) []interface{} {
+	roleBinding := object.(*rbacv1.RoleBinding)
+	return []interface{}{roleBinding.RoleRef, roleBinding.Subjects}
+}
+
 //Checks if Role required for RoleBinding exists. Retries a few times before returning error to allow replication to catch up
 func (r *Replicator) canReplicate(targetNameSpace string, roleRef string) (err error) {
 	for i := 0; i < 5; i++ {
@@ -223,6 +284,41 @@ func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{})
 	return s, nil
 }
 
+// PatchOrphanDependent removes the annotations that tie a replica to its
+// source, leaving its content intact
+func (r *Replicator) PatchOrphanDependent(target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*rbacv1.RoleBinding)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	patch := common.OrphanPatch(targetObject)
+	if len(patch) == 0 {
+		return targetObject, nil
+	}
+
+	patchBody, err := json.Marshal(&patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while building patch body for roleBinding %s: %v", dependentKey, err)
+	}
+
+	logger.Debugf("orphaning dependent roleBinding %s", dependentKey)
+	logger.Tracef("patch body: %s", string(patchBody))
+
+	s, err := r.Client.RbacV1().RoleBindings(targetObject.Namespace).Patch(context.TODO(), targetObject.Name, types.JSONPatchType, patchBody, metav1.PatchOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while patching roleBinding %s: %v", dependentKey, err)
+	}
+	return s, nil
+}
+
 // DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
 func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
 	targetLocation := common.MustGetKey(targetResource)
@@ -233,7 +329,7 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	object := targetResource.(*rbacv1.RoleBinding)
 	logger.Debugf("Deleting %s", targetLocation)
-	if err := r.Client.RbacV1().RoleBindings(object.Namespace).Delete(context.TODO(), object.Name, metav1.DeleteOptions{}); err != nil {
+	if err := r.Client.RbacV1().RoleBindings(object.Namespace).Delete(context.TODO(), object.Name, common.ReplicaDeleteOptions(object)); err != nil {
 		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
 	}
 	return nil
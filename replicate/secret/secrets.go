@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
@@ -30,6 +32,7 @@ func NewReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allo
 		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
 			Kind:         "Secret",
 			ObjType:      &v1.Secret{},
+			KeyedData:    true,
 			AllowAll:     allowAll,
 			ResyncPeriod: resyncPeriod,
 			Client:       client,
@@ -45,6 +48,7 @@ func NewReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allo
 		ReplicateDataFrom:        repl.ReplicateDataFrom,
 		ReplicateObjectTo:        repl.ReplicateObjectTo,
 		PatchDeleteDependent:     repl.PatchDeleteDependent,
+		PatchOrphanDependent:     repl.PatchOrphanDependent,
 		DeleteReplicatedResource: repl.DeleteReplicatedResource,
 	}
 
@@ -63,14 +67,43 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 		WithField("target", common.MustGetKey(target))
 
 	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+		r.SkipReconcile(common.SkipReasonNotPermitted)
 		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
 	}
 
-	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	if r.refuseServiceAccountToken(source, common.MustGetKey(target), target) {
+		return nil
+	}
+
+	if r.refuseInvalidData(source, common.MustGetKey(target), target) {
+		return nil
+	}
+
+	if r.RefusePinnedReplica(target, source) {
+		return nil
+	}
+
+	generatedKeys, err := common.GeneratedKeysFor(source.Annotations)
+	if err != nil {
+		return err
+	}
+
+	pulledKey := common.PulledKey(target)
+	if pulledKey != "" {
+		_, exists := source.Data[pulledKey]
+		if _, generated := generatedKeys[pulledKey]; !exists && !generated {
+			r.ReportMissingPulledKey(source, target, pulledKey)
+			return nil
+		}
+	}
+
+	if common.IsAugmented(&target.ObjectMeta) {
+		return r.augmentDataFrom(source, target)
+	}
 
-	if ok && targetVersion == sourceVersion {
+	if common.ReplicaUpToDate(target, source) {
 		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
 		return nil
 	}
 
@@ -79,34 +112,103 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 		targetCopy.Data = make(map[string][]byte)
 	}
 
-	prevKeys, hasPrevKeys := common.PreviouslyPresentKeys(&targetCopy.ObjectMeta)
-	replicatedKeys := make([]string, 0)
+	replicatedKeys, err := r.extractReplicatedKeys(source, common.MustGetKey(target), targetCopy, generatedKeys, func(key string) bool {
+		return pulledKey == "" || key == pulledKey
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(replicatedKeys)
+
+	ignoredKeys := common.IgnoredKeysFor(source.Annotations)
+	ignoredKeys.KeepBinaryValues(target.Data, targetCopy.Data)
+	if len(ignoredKeys) > 0 && ignoredKeys.BinaryMapsEqual(target.Data, targetCopy.Data) {
+		logger.Debugf("target %s only differs from its source in ignored keys", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
+	logger.Infof("updating target %s", common.MustGetKey(target))
+	common.TraceKeyDiff(logger, dataDiff(target, targetCopy))
+
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(targetCopy.Annotations, source)
+	targetCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	common.SetSourceHash(targetCopy.Annotations, source.Type, common.BinaryMapSubset(source.Data, replicatedKeys))
+	common.SetReplicationChain(targetCopy.Annotations, source)
+
+	if !common.ReplicaChanged(target, targetCopy) {
+		logger.Debugf("target %s would only get a new timestamp", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
+
+	if err := r.RefuseOversizedReplica(source, common.MustGetKey(target), targetCopy); err != nil {
+		return err
+	}
+
+	s, err := r.updateTarget(target, targetCopy)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
+	} else if err = r.Store.Update(s); err != nil {
+		err = errors.Wrapf(err, "Failed to update cache for %s/%s: %v", target.Namespace, targetCopy, err)
+	}
+	return err
+}
+
+// augmentDataFrom adds the keys of the source to a target that is owned by
+// someone else. Only keys that were added by the replicator are ever removed.
+func (r *Replicator) augmentDataFrom(source *v1.Secret, target *v1.Secret) error {
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", common.MustGetKey(target))
+
+	targetCopy := target.DeepCopy()
+	if targetCopy.Data == nil {
+		targetCopy.Data = make(map[string][]byte)
+	}
+
+	prevKeys := common.PreviouslyAugmentedKeys(&targetCopy.ObjectMeta)
+	augmentedKeys := make([]string, 0)
+	pulledKey := common.PulledKey(target)
 
 	for key, value := range source.Data {
+		if pulledKey != "" && key != pulledKey {
+			continue
+		}
 		newValue := make([]byte, len(value))
 		copy(newValue, value)
 		targetCopy.Data[key] = newValue
 
-		replicatedKeys = append(replicatedKeys, key)
+		augmentedKeys = append(augmentedKeys, key)
 		delete(prevKeys, key)
 	}
 
-	if hasPrevKeys {
-		for k := range prevKeys {
-			logger.Debugf("removing previously present key %s: not present in source any more", k)
-			delete(targetCopy.Data, k)
-		}
+	for k := range prevKeys {
+		logger.Debugf("removing previously augmented key %s: not present in source any more", k)
+		delete(targetCopy.Data, k)
 	}
 
-	sort.Strings(replicatedKeys)
+	sort.Strings(augmentedKeys)
+	targetCopy.Annotations[common.AugmentedKeysAnnotation] = strings.Join(augmentedKeys, ",")
 
-	logger.Infof("updating target %s", common.MustGetKey(target))
+	if reflect.DeepEqual(target.Data, targetCopy.Data) && reflect.DeepEqual(target.Annotations, targetCopy.Annotations) {
+		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		r.SkipReconcile(common.SkipReasonUpToDate)
+		return nil
+	}
 
-	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
-	targetCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	logger.Infof("augmenting target %s", common.MustGetKey(target))
+	common.TraceKeyDiff(logger, dataDiff(target, targetCopy))
+
+	if err := r.RefuseOversizedReplica(source, common.MustGetKey(target), targetCopy); err != nil {
+		return err
+	}
 
-	s, err := r.Client.CoreV1().Secrets(target.Namespace).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
+	s, err := r.updateTarget(target, targetCopy)
 	if err != nil {
 		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
 	} else if err = r.Store.Update(s); err != nil {
@@ -118,37 +220,75 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*v1.Secret)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, skip, err := r.ReplicaName(source, target.Name)
+	if err != nil || skip {
+		return err
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
 		WithField("source", common.MustGetKey(source)).
 		WithField("target", targetLocation)
 
-	targetResourceType := source.Type
+	if r.refuseServiceAccountToken(source, targetLocation, source) {
+		return nil
+	}
+
+	if r.refuseInvalidData(source, targetLocation, source) {
+		return nil
+	}
+
 	targetResource, exists, err := r.Store.GetByKey(targetLocation)
 	if err != nil {
 		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
 	}
 	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
 
+	placeholder := common.IsPlaceholderOnly(&source.ObjectMeta)
+	if placeholder && common.IsUpdateOnly(&source.ObjectMeta) {
+		return errors.Errorf("the %s and %s annotations can't be combined", common.PlaceholderOnly, common.UpdateOnly)
+	}
+
+	if !exists && common.IsUpdateOnly(&source.ObjectMeta) {
+		logger.Debugf("%s does not exist and source is update-only, skipping", targetLocation)
+		r.SkipReconcile(common.SkipReasonUpdateOnly)
+		return nil
+	}
+
+	if exists && placeholder {
+		logger.Debugf("%s already exists and source only creates placeholders, skipping", targetLocation)
+		r.SkipReconcile(common.SkipReasonPlaceholderOnly)
+		return nil
+	}
+
+	if exists && r.RefuseSourceConflict(source, targetResource) {
+		return nil
+	}
+
 	var resourceCopy *v1.Secret
+	var targetObject *v1.Secret
 	if exists {
-		targetObject := targetResource.(*v1.Secret)
-		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
-
-		if ok && targetVersion == sourceVersion {
+		targetObject = targetResource.(*v1.Secret)
+		if common.ReplicaUpToDate(targetObject, source) && common.ReplicaMetadataUpToDate(targetObject, target) {
 			logger.Debugf("Secret %s is already up-to-date", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
 			return nil
 		}
 
-		targetResourceType = targetObject.Type
 		resourceCopy = targetObject.DeepCopy()
 	} else {
 		resourceCopy = new(v1.Secret)
 	}
 
+	// immutable replicas are recreated on every change, so they only stay
+	// immutable as long as their source is
+	resourceCopy.Immutable = nil
+	if source.Immutable != nil && *source.Immutable {
+		immutable := true
+		resourceCopy.Immutable = &immutable
+	}
+
 	keepOwnerReferences, ok := source.Annotations[common.KeepOwnerReferences]
 	if ok && keepOwnerReferences == "true" {
 		resourceCopy.OwnerReferences = source.OwnerReferences
@@ -161,70 +301,348 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		resourceCopy.Annotations = make(map[string]string)
 	}
 
-	replicatedKeys := r.extractReplicatedKeys(source, targetLocation, resourceCopy)
+	keyNamespaces, err := common.KeyNamespacesFor(source.Annotations)
+	if err != nil {
+		return err
+	}
+
+	generatedKeys, err := common.GeneratedKeysFor(source.Annotations)
+	if err != nil {
+		return err
+	}
+
+	replicatedKeys := make([]string, 0)
+	if placeholder {
+		resourceCopy.Data = placeholderData(targetSecretType(source, nil))
+	} else if replicatedKeys, err = r.extractReplicatedKeys(source, targetLocation, resourceCopy, generatedKeys, func(key string) bool {
+		return keyNamespaces.Allows(key, target.Name)
+	}); err != nil {
+		return err
+	}
 
 	sort.Strings(replicatedKeys)
 
 	labelsCopy := make(map[string]string)
+	if targetObject != nil && common.UsesMergePatch(&targetObject.ObjectMeta) {
+		// labels that were added by others are kept when merging
+		for key, value := range targetObject.Labels {
+			labelsCopy[key] = value
+		}
+	}
 
 	stripLabels, ok := source.Annotations[common.StripLabels]
 	if !ok && stripLabels != "true" {
 		if source.Labels != nil {
 			for key, value := range source.Labels {
-				labelsCopy[key] = value
+				if !common.IsDeniedMetadataKey(key) {
+					labelsCopy[key] = value
+				}
 			}
 		}
 	}
 
-	resourceCopy.Name = source.Name
+	resourceCopy.Name = targetName
+	common.SetSourceLabels(labelsCopy, source)
 	resourceCopy.Labels = labelsCopy
-	resourceCopy.Type = targetResourceType
+	resourceCopy.Type = targetSecretType(source, targetObject)
 	resourceCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
 	resourceCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	common.SetSourceUID(resourceCopy.Annotations, source)
 	resourceCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	common.SetSourceHash(resourceCopy.Annotations, source.Type, common.BinaryMapSubset(source.Data, replicatedKeys))
+	common.SetReplicationChain(resourceCopy.Annotations, source)
+	common.SetExtraAnnotations(resourceCopy.Annotations)
+	common.SetReadyAnnotation(resourceCopy.Annotations)
+	common.ApplyReplicaMetadata(resourceCopy, target)
+
+	if targetObject != nil {
+		ignoredKeys := common.IgnoredKeysFor(source.Annotations)
+		ignoredKeys.KeepBinaryValues(targetObject.Data, resourceCopy.Data)
+		if len(ignoredKeys) > 0 && ignoredKeys.BinaryMapsEqual(targetObject.Data, resourceCopy.Data) &&
+			reflect.DeepEqual(targetObject.Labels, resourceCopy.Labels) && common.ReplicaMetadataUpToDate(targetObject, target) {
+			logger.Debugf("Secret %s only differs from its source in ignored keys", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
+			return nil
+		}
+
+		if !common.ReplicaChanged(targetObject, resourceCopy) {
+			logger.Debugf("Secret %s would only get a new timestamp", common.MustGetKey(targetObject))
+			r.SkipReconcile(common.SkipReasonUpToDate)
+			return nil
+		}
+	}
+
+	common.TraceKeyDiff(logger, dataDiff(targetObject, resourceCopy))
+
+	if err := r.TransformReplica(source, target.Name, resourceCopy); err != nil {
+		return err
+	}
+
+	if err := r.RefuseOversizedReplica(source, targetLocation, resourceCopy); err != nil {
+		return err
+	}
 
 	var obj interface{}
-	if exists {
+	if exists && targetObject.Immutable != nil && *targetObject.Immutable {
+		logger.Debugf("Recreating immutable secret %s/%s", target.Name, resourceCopy.Name)
+		obj, err = r.recreateTarget(targetObject, resourceCopy)
+	} else if exists {
 		logger.Debugf("Updating existing secret %s/%s", target.Name, resourceCopy.Name)
-		obj, err = r.Client.CoreV1().Secrets(target.Name).Update(context.TODO(), resourceCopy, metav1.UpdateOptions{})
+		obj, err = r.updateTarget(targetObject, resourceCopy)
 	} else {
 		logger.Debugf("Creating a new secret secret %s/%s", target.Name, resourceCopy.Name)
-		obj, err = r.Client.CoreV1().Secrets(target.Name).Create(context.TODO(), resourceCopy, metav1.CreateOptions{})
+		common.SanitizeForCopy(resourceCopy)
+		if common.UsesApply(&resourceCopy.ObjectMeta) {
+			resourceCopy.Namespace = target.Name
+			obj, err = r.applyTarget(resourceCopy)
+		} else {
+			obj, err = r.Client.CoreV1().Secrets(target.Name).Create(context.TODO(), resourceCopy, metav1.CreateOptions{})
+		}
 	}
 	if err != nil {
 		err = errors.Wrapf(err, "Failed to update secret %s/%s", target.Name, resourceCopy.Name)
 	} else if err = r.Store.Update(obj); err != nil {
 		err = errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, resourceCopy)
+	} else {
+		if r.VerifyWrite(source, resourceCopy, func() (interface{}, error) {
+			return r.Client.CoreV1().Secrets(target.Name).Get(context.TODO(), resourceCopy.Name, metav1.GetOptions{})
+		}, replicaContent) {
+			r.MarkReady(resourceCopy, func(patch []byte) (interface{}, error) {
+				return r.Client.CoreV1().Secrets(target.Name).Patch(context.TODO(), resourceCopy.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			})
+		}
+		r.ExportReplica(target.Name, resourceCopy.Name, obj.(*v1.Secret).Data)
 	}
 
 	return err
 }
 
-func (r *Replicator) extractReplicatedKeys(source *v1.Secret, targetLocation string, resourceCopy *v1.Secret) []string {
+// replicaContent returns the content of a replica that is compared when its
+// write is verified
+func replicaContent(object interface{}) []interface{} {
+	secret := object.(*v1.Secret)
+	return []interface{}{secret.Type, secret.Data}
+}
+
+// targetSecretType returns the type of a replicated secret. The type of an
+// existing secret can't be changed, so it is kept. New secrets get the type of
+// their source.
+func targetSecretType(source *v1.Secret, existing *v1.Secret) v1.SecretType {
+	if existing != nil {
+		return existing.Type
+	}
+
+	if source.Type == "" {
+		return v1.SecretTypeOpaque
+	}
+
+	return source.Type
+}
+
+// refuseServiceAccountToken checks if source is a service account token that
+// must not be replicated. Its token and CA certificate belong to a service
+// account in the namespace of the source, so a copy in another namespace is
+// useless at best. Tokens are only replicated if the source explicitly allows
+// it with the AllowServiceAccountToken annotation. Refused replications are
+// reported with a warning event on eventObject.
+func (r *Replicator) refuseServiceAccountToken(source *v1.Secret, targetKey string, eventObject runtime.Object) bool {
+	if source.Type != v1.SecretTypeServiceAccountToken || source.Annotations[common.AllowServiceAccountToken] == "true" {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", common.MustGetKey(source)).WithField("target", targetKey).
+		Warnf("refusing to replicate service account token %s to %s", common.MustGetKey(source), targetKey)
+	r.SkipReconcile(common.SkipReasonServiceAccount)
+	r.Recorder.Eventf(eventObject, v1.EventTypeWarning, "ServiceAccountToken",
+		"Not replicated to %s: %s is a service account token that is bound to its namespace; set the %s annotation to \"true\" on the source to replicate it anyway",
+		targetKey, common.MustGetKey(source), common.AllowServiceAccountToken)
+
+	return true
+}
+
+// refuseInvalidData checks the data of the source with the validations of
+// its Validate annotation. Invalid data is not replicated, so that broken
+// material isn't propagated to all targets; the targets keep their data.
+// The refusal is reported with an event on eventObject.
+func (r *Replicator) refuseInvalidData(source *v1.Secret, targetKey string, eventObject runtime.Object) bool {
+	err := validateData(source)
+	if err == nil {
+		return false
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", common.MustGetKey(source)).WithField("target", targetKey).WithError(err).
+		Warnf("refusing to replicate invalid data of %s to %s", common.MustGetKey(source), targetKey)
+	r.SkipReconcile(common.SkipReasonInvalidData)
+	r.Recorder.Eventf(eventObject, v1.EventTypeWarning, "InvalidData",
+		"Not replicated to %s: data of %s failed validation: %v", targetKey, common.MustGetKey(source), err)
+
+	return true
+}
+
+// placeholderData returns the data of a placeholder secret of the given type.
+// Types that require certain keys get them with empty values, so that the
+// placeholder passes validation.
+func placeholderData(secretType v1.SecretType) map[string][]byte {
+	switch secretType {
+	case v1.SecretTypeTLS:
+		return map[string][]byte{v1.TLSCertKey: {}, v1.TLSPrivateKeyKey: {}}
+	case v1.SecretTypeSSHAuth:
+		return map[string][]byte{v1.SSHAuthPrivateKey: {}}
+	case v1.SecretTypeDockerConfigJson:
+		return map[string][]byte{v1.DockerConfigJsonKey: []byte(`{"auths":{}}`)}
+	case v1.SecretTypeDockercfg:
+		return map[string][]byte{v1.DockerConfigKey: []byte(`{}`)}
+	}
+
+	return map[string][]byte{}
+}
+
+// updateTarget writes the modified copy of an existing target. Targets that
+// use the merge patch write strategy only receive the fields that differ from
+// target, so that changes made by others in the meantime are kept. Updates
+// that conflict with such changes are written again onto the latest version
+// of the target.
+func (r *Replicator) updateTarget(target *v1.Secret, targetCopy *v1.Secret) (*v1.Secret, error) {
+	if common.UsesApply(&target.ObjectMeta) {
+		return r.applyTarget(targetCopy)
+	}
+	if !common.UsesMergePatch(&target.ObjectMeta) {
+		updated, err := r.Client.CoreV1().Secrets(target.Namespace).Update(context.TODO(), targetCopy, metav1.UpdateOptions{})
+		if !apierrors.IsConflict(err) {
+			return updated, err
+		}
+
+		// the target was changed by someone else since it was cached, so only
+		// the changes of the replicator are written onto its latest version
+		latest, err := r.Client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get secret %s after a conflict: %v", common.MustGetKey(target), err)
+		}
+		rebased := new(v1.Secret)
+		if err := common.RebaseUpdate(target, targetCopy, latest, rebased); err != nil {
+			return nil, errors.Wrapf(err, "Failed to rebase update of secret %s: %v", common.MustGetKey(target), err)
+		}
+
+		return r.Client.CoreV1().Secrets(target.Namespace).Update(context.TODO(), rebased, metav1.UpdateOptions{})
+	}
+
+	patch, err := common.MergePatch(target, targetCopy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create merge patch for secret %s: %v", common.MustGetKey(target), err)
+	}
+
+	return r.Client.CoreV1().Secrets(target.Namespace).Patch(context.TODO(), target.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+// recreateTarget replaces an immutable target, which can't be updated, by
+// deleting it and creating the modified copy. The target is only deleted if
+// it was not changed since it was cached. If the copy can't be created, the
+// error is returned, so that the target is created again when the source is
+// retried; a target that is already gone is not an error.
+func (r *Replicator) recreateTarget(target *v1.Secret, targetCopy *v1.Secret) (*v1.Secret, error) {
+	err := r.Client.CoreV1().Secrets(target.Namespace).Delete(context.TODO(), target.Name, common.ReplicaDeleteOptions(target))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "Failed to delete immutable secret %s: %v", common.MustGetKey(target), err)
+	}
+
+	common.SanitizeForCopy(targetCopy)
+	targetCopy.Namespace = target.Namespace
+	if common.UsesApply(&targetCopy.ObjectMeta) {
+		return r.applyTarget(targetCopy)
+	}
+
+	return r.Client.CoreV1().Secrets(target.Namespace).Create(context.TODO(), targetCopy, metav1.CreateOptions{})
+}
+
+// applyTarget writes a target with a server-side apply, which creates it if
+// it does not exist yet. Only the fields that the replicator manages are
+// applied, so forcing conflicts with other field managers cannot take over
+// fields that others own.
+func (r *Replicator) applyTarget(target *v1.Secret) (*v1.Secret, error) {
+	patch, err := common.ApplyPatch(target, v1.SchemeGroupVersion.WithKind("Secret"), "data", "type", "immutable")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create apply patch for secret %s: %v", common.MustGetKey(target), err)
+	}
+
+	force := true
+	return r.Client.CoreV1().Secrets(target.Namespace).Patch(context.TODO(), target.Name, types.ApplyPatchType, patch, metav1.PatchOptions{
+		FieldManager: common.FieldManager,
+		Force:        &force,
+	})
+}
+
+// dataDiff compares the data of a replica before and after an update. A nil
+// previous version means that the replica is created.
+func dataDiff(previous *v1.Secret, updated *v1.Secret) common.KeyDiff {
+	if previous == nil {
+		return common.DiffBinaryMaps(nil, updated.Data)
+	}
+	return common.DiffBinaryMaps(previous.Data, updated.Data)
+}
+
+// extractReplicatedKeys copies the keys of the source into resourceCopy and
+// removes keys that were replicated before, but are not present in the source
+// any more. Generated keys are not copied; they keep their value in
+// resourceCopy, or get a new random value if they don't exist yet. Copied
+// values are compressed if requested by the source.
+func (r *Replicator) extractReplicatedKeys(source *v1.Secret, targetLocation string, resourceCopy *v1.Secret, generatedKeys common.GeneratedKeys, allowed func(key string) bool) ([]string, error) {
 	logger := log.
 		WithField("kind", r.Kind).
 		WithField("source", common.MustGetKey(source)).
 		WithField("target", targetLocation)
 
+	compression, err := common.CompressionFor(source.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
 	prevKeys, hasPrevKeys := common.PreviouslyPresentKeys(&resourceCopy.ObjectMeta)
 	replicatedKeys := make([]string, 0)
 
 	for key, value := range source.Data {
+		if _, ok := generatedKeys[key]; ok || !allowed(key) {
+			continue
+		}
 		newValue := make([]byte, len(value))
 		copy(newValue, value)
+		if compression != "" {
+			newValue, err = common.CompressValue(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to compress key %s: %v", key, err)
+			}
+		}
 		resourceCopy.Data[key] = newValue
 
 		replicatedKeys = append(replicatedKeys, key)
 		delete(prevKeys, key)
 	}
 
+	for key := range generatedKeys {
+		if !allowed(key) {
+			continue
+		}
+		if _, ok := resourceCopy.Data[key]; !ok {
+			logger.Debugf("generating value for key %s", key)
+			value, err := generatedKeys.GenerateValue(key)
+			if err != nil {
+				return nil, err
+			}
+			resourceCopy.Data[key] = value
+		}
+
+		replicatedKeys = append(replicatedKeys, key)
+		delete(prevKeys, key)
+	}
+
 	if hasPrevKeys {
 		for k := range prevKeys {
 			logger.Debugf("removing previously present key %s: not present in source secret any more", k)
 			delete(resourceCopy.Data, k)
 		}
 	}
-	return replicatedKeys
+
+	common.SetCompressedAnnotation(resourceCopy.Annotations, compression)
+	return replicatedKeys, nil
 }
 
 func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
@@ -242,6 +660,9 @@ func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{})
 	}
 
 	patch := []common.JSONPatchOperation{{Operation: "remove", Path: "/data"}}
+	if common.IsAugmented(&targetObject.ObjectMeta) {
+		patch = augmentedKeysPatch(targetObject)
+	}
 	patchBody, err := json.Marshal(&patch)
 
 	if err != nil {
@@ -258,6 +679,60 @@ func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{})
 	return s, nil
 }
 
+// augmentedKeysPatch builds a patch that only removes the keys that were added
+// to an augmented target.
+func augmentedKeysPatch(object *v1.Secret) []common.JSONPatchOperation {
+	patch := make([]common.JSONPatchOperation, 0)
+
+	augmentedKeys := common.PreviouslyAugmentedKeys(&object.ObjectMeta)
+	for _, key := range common.GetKeysFromBinaryMap(object.Data) {
+		if _, ok := augmentedKeys[key]; ok {
+			patch = append(patch, common.JSONPatchOperation{Operation: "remove", Path: fmt.Sprintf("/data/%s", key)})
+		}
+	}
+
+	if _, ok := object.Annotations[common.AugmentedKeysAnnotation]; ok {
+		patch = append(patch, common.JSONPatchOperation{Operation: "remove", Path: fmt.Sprintf("/metadata/annotations/%s", common.JSONPatchPathEscape(common.AugmentedKeysAnnotation))})
+	}
+
+	return patch
+}
+
+// PatchOrphanDependent removes the annotations that tie a replica to its
+// source, leaving its content intact
+func (r *Replicator) PatchOrphanDependent(target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*v1.Secret)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	patch := common.OrphanPatch(targetObject)
+	if len(patch) == 0 {
+		return targetObject, nil
+	}
+
+	patchBody, err := json.Marshal(&patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while building patch body for secret %s: %v", dependentKey, err)
+	}
+
+	logger.Debugf("orphaning dependent secret %s", dependentKey)
+	logger.Tracef("patch body: %s", string(patchBody))
+
+	s, err := r.Client.CoreV1().Secrets(targetObject.Namespace).Patch(context.TODO(), targetObject.Name, types.JSONPatchType, patchBody, metav1.PatchOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while patching secret %s: %v", dependentKey, err)
+	}
+	return s, nil
+}
+
 // DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
 func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
 	targetLocation := common.MustGetKey(targetResource)
@@ -270,7 +745,7 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 	resourceKeys := strings.Join(common.GetKeysFromBinaryMap(object.Data), ",")
 	if resourceKeys == object.Annotations[common.ReplicatedKeysAnnotation] {
 		logger.Debugf("Deleting %s", targetLocation)
-		if err := r.Client.CoreV1().Secrets(object.Namespace).Delete(context.TODO(), object.Name, metav1.DeleteOptions{}); err != nil {
+		if err := r.Client.CoreV1().Secrets(object.Namespace).Delete(context.TODO(), object.Name, common.ReplicaDeleteOptions(object)); err != nil {
 			return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
 		}
 	} else {
@@ -3,7 +3,15 @@ package secret
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -19,11 +27,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 )
 
 func namespacePrefix() string {
@@ -1277,6 +1289,838 @@ func TestSecretReplicator(t *testing.T) {
 
 }
 
+func TestReplicateObjectToPropagatesType(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+
+	target := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}}
+
+	secretTypes := []corev1.SecretType{
+		corev1.SecretTypeOpaque,
+		corev1.SecretTypeTLS,
+		corev1.SecretTypeDockerConfigJson,
+		corev1.SecretTypeBasicAuth,
+	}
+
+	for i, secretType := range secretTypes {
+		secretType := secretType
+		name := fmt.Sprintf("source-type-%d", i)
+
+		t.Run(fmt.Sprintf("new target is created with type %s", secretType), func(t *testing.T) {
+			source := corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            name,
+					Namespace:       "source",
+					ResourceVersion: "1",
+					Annotations: map[string]string{
+						common.ReplicateTo: target.Name,
+					},
+				},
+				Type: secretType,
+				Data: map[string][]byte{
+					"foo": []byte("Hello Foo"),
+				},
+			}
+
+			require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+
+			replica, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), name, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, secretType, replica.Type)
+			require.Equal(t, []byte("Hello Foo"), replica.Data["foo"])
+		})
+	}
+
+	t.Run("new target from source without type is opaque", func(t *testing.T) {
+		source := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "source-untyped",
+				Namespace:       "source",
+				ResourceVersion: "1",
+			},
+		}
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+
+		replica, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, corev1.SecretTypeOpaque, replica.Type)
+	})
+
+	t.Run("new target of immutable source is immutable", func(t *testing.T) {
+		immutable := true
+		source := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "source-immutable",
+				Namespace:       "source",
+				ResourceVersion: "1",
+			},
+			Type:      corev1.SecretTypeTLS,
+			Immutable: &immutable,
+		}
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+
+		replica, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, corev1.SecretTypeTLS, replica.Type)
+		require.NotNil(t, replica.Immutable)
+		require.True(t, *replica.Immutable)
+	})
+
+	t.Run("existing target keeps its type", func(t *testing.T) {
+		existing := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "source-existing",
+				Namespace: target.Name,
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+		_, err := client.CoreV1().Secrets(target.Name).Create(context.TODO(), &existing, metav1.CreateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Add(&existing))
+
+		source := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            existing.Name,
+				Namespace:       "source",
+				ResourceVersion: "1",
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+
+		replica, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, corev1.SecretTypeOpaque, replica.Type)
+	})
+}
+
+func TestImmutableReplicasAreRecreated(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+	target := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}}
+
+	// the fake API server does not reject updates of immutable objects
+	client.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updated := action.(k8stesting.UpdateAction).GetObject().(*corev1.Secret)
+		existing, err := client.Tracker().Get(corev1.SchemeGroupVersion.WithResource("secrets"), updated.Namespace, updated.Name)
+		if err == nil && existing.(*corev1.Secret).Immutable != nil && *existing.(*corev1.Secret).Immutable {
+			return true, nil, pkgerrors.New("field is immutable")
+		}
+		return false, nil, nil
+	})
+
+	immutable := true
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "registry",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.ReplicateTo: target.Name},
+		},
+		Immutable: &immutable,
+		Data:      map[string][]byte{"password": []byte("first")},
+	}
+
+	replicaOf := func() *corev1.Secret {
+		replica, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Update(replica))
+		return replica
+	}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+	require.True(t, *replicaOf().Immutable)
+
+	t.Run("changes of the source recreate the replica", func(t *testing.T) {
+		source.ResourceVersion = "2"
+		source.Data["password"] = []byte("second")
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+		replica := replicaOf()
+		require.Equal(t, []byte("second"), replica.Data["password"])
+		require.True(t, *replica.Immutable)
+		require.Equal(t, "2", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+	})
+
+	t.Run("replicas of sources that became mutable are mutable", func(t *testing.T) {
+		source.ResourceVersion = "3"
+		source.Immutable = nil
+		source.Data["password"] = []byte("third")
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+		replica := replicaOf()
+		require.Equal(t, []byte("third"), replica.Data["password"])
+		require.Nil(t, replica.Immutable)
+	})
+}
+
+func TestReplicateDataFromAugmentsTarget(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "extra",
+			Namespace:       "source",
+			ResourceVersion: "1",
+		},
+		Data: map[string][]byte{
+			"extra": []byte("Hello Extra"),
+		},
+	}
+
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foreign",
+			Namespace: "target",
+			Annotations: map[string]string{
+				common.ReplicateFromAnnotation: common.MustGetKey(&source),
+				common.Augment:                 "true",
+			},
+		},
+		Data: map[string][]byte{
+			"owned": []byte("Hello Owner"),
+		},
+	}
+	_, err := client.CoreV1().Secrets(target.Namespace).Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	t.Run("keys are added without taking ownership", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateDataFrom(&source, &target))
+
+		augmented, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []byte("Hello Owner"), augmented.Data["owned"])
+		require.Equal(t, []byte("Hello Extra"), augmented.Data["extra"])
+		require.Equal(t, "extra", augmented.Annotations[common.AugmentedKeysAnnotation])
+		require.NotContains(t, augmented.Annotations, common.ReplicatedKeysAnnotation)
+		require.NotContains(t, augmented.Annotations, common.ReplicatedFromVersionAnnotation)
+	})
+
+	t.Run("only augmented keys are removed when the source is deleted", func(t *testing.T) {
+		augmented, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		_, err = repl.PatchDeleteDependent(common.MustGetKey(&source), augmented)
+		require.NoError(t, err)
+
+		patched, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{"owned": []byte("Hello Owner")}, patched.Data)
+		require.NotContains(t, patched.Annotations, common.AugmentedKeysAnnotation)
+	})
+}
+
+func TestReplicateObjectToFiltersKeysByNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+
+	prod := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod-eu"}}
+	staging := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging"}}
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "credentials",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo:             "prod-eu,staging",
+				common.KeyNamespacesAnnotation: `{"prod-password": "prod-.*"}`,
+			},
+		},
+		Data: map[string][]byte{
+			"prod-password": []byte("secret"),
+			"common-config": []byte("config"),
+		},
+	}
+
+	replicaKeys := func(namespace string) []string {
+		replica, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Update(replica))
+		return common.GetKeysFromBinaryMap(replica.Data)
+	}
+
+	t.Run("each target only receives its permitted keys", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateObjectTo(&source, &prod))
+		require.NoError(t, repl.ReplicateObjectTo(&source, &staging))
+
+		require.Equal(t, []string{"common-config", "prod-password"}, replicaKeys(prod.Name))
+		require.Equal(t, []string{"common-config"}, replicaKeys(staging.Name))
+	})
+
+	t.Run("keys are removed when their targeting changes", func(t *testing.T) {
+		source.ResourceVersion = "2"
+		source.Annotations[common.KeyNamespacesAnnotation] = `{"prod-password": "prod-us"}`
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &prod))
+		require.Equal(t, []string{"common-config"}, replicaKeys(prod.Name))
+	})
+
+	t.Run("invalid annotation is rejected", func(t *testing.T) {
+		source.ResourceVersion = "3"
+		source.Annotations[common.KeyNamespacesAnnotation] = "prod-password: prod"
+
+		require.Error(t, repl.ReplicateObjectTo(&source, &prod))
+	})
+}
+
+func TestReplicasAreAnnotatedWithSourceHash(t *testing.T) {
+	defer func(enabled bool) { common.Options.SourceHash = enabled }(common.Options.SourceHash)
+	common.Options.SourceHash = true
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "hashed",
+			Namespace:       "source",
+			ResourceVersion: "1",
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"foo": []byte("Hello Foo"),
+			"bar": []byte("Hello Bar"),
+		},
+	}
+
+	pulled := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pulled",
+			Namespace:   "pull-target",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: common.MustGetKey(&source)},
+		},
+	}
+	_, err := client.CoreV1().Secrets(pulled.Namespace).Create(context.TODO(), &pulled, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "push-target"}}))
+	require.NoError(t, repl.ReplicateDataFrom(&source, &pulled))
+
+	pushedReplica, err := client.CoreV1().Secrets("push-target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	pulledReplica, err := client.CoreV1().Secrets(pulled.Namespace).Get(context.TODO(), pulled.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	expected, err := common.SourceHash(source.Type, source.Data)
+	require.NoError(t, err)
+	require.Equal(t, expected, pushedReplica.Annotations[common.SourceHashAnnotation])
+	require.Equal(t, expected, pulledReplica.Annotations[common.SourceHashAnnotation])
+}
+
+func TestPushedReplicasGetExtraAnnotations(t *testing.T) {
+	defer func(extra map[string]string) { common.Options.ReplicaExtraAnnotations = extra }(common.Options.ReplicaExtraAnnotations)
+	common.Options.ReplicaExtraAnnotations = map[string]string{"sidecar.istio.io/inject": "false"}
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "stamped",
+			Namespace:       "source",
+			ResourceVersion: "1",
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "push-target"}}))
+
+	replica, err := client.CoreV1().Secrets("push-target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "false", replica.Annotations["sidecar.istio.io/inject"])
+	require.Equal(t, "1", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+}
+
+func TestReplicaAnnotationsAreIndependentOfKeyOrder(t *testing.T) {
+	defer func(enabled bool) { common.Options.SourceHash = enabled }(common.Options.SourceHash)
+	common.Options.SourceHash = true
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	data := make(map[string][]byte)
+	for _, key := range []string{"h", "g", "f", "e", "d", "c", "b", "a"} {
+		data[key] = []byte("value-" + key)
+	}
+
+	var hash string
+	for i := 0; i < 10; i++ {
+		source := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ordered", Namespace: "source", ResourceVersion: "1"},
+			Data:       make(map[string][]byte),
+		}
+		for key, value := range data {
+			source.Data[key] = value
+		}
+
+		target := fmt.Sprintf("target-%d", i)
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: target}}))
+
+		replica, err := client.CoreV1().Secrets(target).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		if i == 0 {
+			hash = replica.Annotations[common.SourceHashAnnotation]
+		}
+		require.Equal(t, "a,b,c,d,e,f,g,h", replica.Annotations[common.ReplicatedKeysAnnotation])
+		require.Equal(t, hash, replica.Annotations[common.SourceHashAnnotation])
+	}
+}
+
+func TestPushedReplicasDoNotGetDeniedLabels(t *testing.T) {
+	defer func(denylist []string) { common.Options.ReplicaMetadataDenylist = denylist }(common.Options.ReplicaMetadataDenylist)
+	common.Options.ReplicaMetadataDenylist = common.ParseMetadataDenylist(common.DefaultReplicaMetadataDenylist)
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "certificate",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Labels: map[string]string{
+				"controller.cert-manager.io/fao": "true",
+				"app.kubernetes.io/name":         "ingress",
+			},
+		},
+		Data: map[string][]byte{"tls.crt": []byte("cert")},
+	}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "push-target"}}))
+
+	replica, err := client.CoreV1().Secrets("push-target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "ingress", replica.Labels["app.kubernetes.io/name"])
+	require.NotContains(t, replica.Labels, "controller.cert-manager.io/fao")
+}
+
+func TestReplicateObjectToHonoursUpdateOnly(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "placeholder",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo: "opted-in,opted-out",
+				common.UpdateOnly:  "true",
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+
+	t.Run("missing target is not created", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "opted-out"}}))
+
+		_, err := client.CoreV1().Secrets("opted-out").Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("existing target is updated", func(t *testing.T) {
+		placeholder := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      source.Name,
+				Namespace: "opted-in",
+			},
+		}
+		_, err := client.CoreV1().Secrets(placeholder.Namespace).Create(context.TODO(), &placeholder, metav1.CreateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Add(&placeholder))
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "opted-in"}}))
+
+		replica, err := client.CoreV1().Secrets(placeholder.Namespace).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []byte("Hello Foo"), replica.Data["foo"])
+		require.Equal(t, "1", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+	})
+}
+
+func TestReplicateObjectToCreatesPlaceholders(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "certificate",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo:     "tenant-.*",
+				common.PlaceholderOnly: "true",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+	}
+
+	t.Run("missing target is created without data", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}}))
+
+		replica, err := client.CoreV1().Secrets("tenant-a").Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, corev1.SecretTypeTLS, replica.Type)
+		require.Equal(t, map[string][]byte{corev1.TLSCertKey: {}, corev1.TLSPrivateKeyKey: {}}, replica.Data)
+		require.Equal(t, "", replica.Annotations[common.ReplicatedKeysAnnotation])
+	})
+
+	t.Run("existing target is left alone", func(t *testing.T) {
+		filled := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: source.Name, Namespace: "tenant-b"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("tenant cert"), corev1.TLSPrivateKeyKey: []byte("tenant key")},
+		}
+		_, err := client.CoreV1().Secrets(filled.Namespace).Create(context.TODO(), &filled, metav1.CreateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Add(&filled))
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}}))
+
+		replica, err := client.CoreV1().Secrets(filled.Namespace).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, filled.Data, replica.Data)
+	})
+
+	t.Run("update-only can't be combined", func(t *testing.T) {
+		invalid := source.DeepCopy()
+		invalid.Annotations[common.UpdateOnly] = "true"
+
+		require.Error(t, repl.ReplicateObjectTo(invalid, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-c"}}))
+	})
+}
+
+func TestMergePatchKeepsForeignModifications(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "shared",
+			Namespace:       "source",
+			ResourceVersion: "2",
+			Labels:          map[string]string{"from": "source"},
+			Annotations: map[string]string{
+				common.ReplicationAllowed:           "true",
+				common.ReplicationAllowedNamespaces: "*",
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+
+	for _, pull := range []bool{false, true} {
+		pull := pull
+		namespace := fmt.Sprintf("merge-pull-%t", pull)
+
+		t.Run(fmt.Sprintf("pull=%t", pull), func(t *testing.T) {
+			cached := corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      source.Name,
+					Namespace: namespace,
+					Labels:    map[string]string{"owner": "tenant"},
+					Annotations: map[string]string{
+						common.WriteStrategy:            common.WriteStrategyMergePatch,
+						common.ReplicatedKeysAnnotation: "foo,old",
+						"tenant/annotation":             "kept",
+					},
+				},
+				Data: map[string][]byte{
+					"old":    []byte("removed from source"),
+					"tenant": []byte("kept"),
+				},
+			}
+			if pull {
+				cached.Annotations[common.ReplicateFromAnnotation] = common.MustGetKey(&source)
+			}
+			require.NoError(t, repl.Store.Add(&cached))
+
+			// the target was modified by someone else after it was cached
+			current := cached.DeepCopy()
+			current.Labels["added"] = "later"
+			current.Annotations["added/later"] = "kept"
+			current.Data["later"] = []byte("kept")
+			_, err := client.CoreV1().Secrets(namespace).Create(context.TODO(), current, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			if pull {
+				require.NoError(t, repl.ReplicateDataFrom(&source, &cached))
+			} else {
+				require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}))
+			}
+
+			replica, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), source.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, map[string][]byte{
+				"foo":    []byte("Hello Foo"),
+				"tenant": []byte("kept"),
+				"later":  []byte("kept"),
+			}, replica.Data)
+			require.Equal(t, "tenant", replica.Labels["owner"])
+			require.Equal(t, "later", replica.Labels["added"])
+			require.Equal(t, "kept", replica.Annotations["tenant/annotation"])
+			require.Equal(t, "kept", replica.Annotations["added/later"])
+			require.Equal(t, "2", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+			require.Equal(t, "foo", replica.Annotations[common.ReplicatedKeysAnnotation])
+		})
+	}
+}
+
+func TestApplyWriteStrategyUsesServerSideApply(t *testing.T) {
+	defer func(strategy string) { common.Options.WriteStrategy = strategy }(common.Options.WriteStrategy)
+	common.Options.WriteStrategy = common.WriteStrategyApply
+
+	existing := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "applied",
+			Namespace:       "existing",
+			ResourceVersion: "7",
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			Labels:          map[string]string{"owner": "tenant"},
+			Annotations:     map[string]string{"tenant/annotation": "kept"},
+			Finalizers:      []string{"tenant/finalizer"},
+		},
+		Data: map[string][]byte{"foo": []byte("outdated")},
+	}
+
+	// the fake clientset does not support server-side apply
+	client := fake.NewSimpleClientset(&existing)
+	applied := make(map[string]*corev1.Secret)
+	client.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		require.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+
+		body := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(patch.GetPatch(), &body))
+		require.Subset(t, []string{"apiVersion", "kind", "metadata", "data", "type", "immutable"}, keys(body))
+		require.Subset(t, []string{"name", "namespace", "labels", "annotations"}, keys(body["metadata"].(map[string]interface{})))
+
+		secret := corev1.Secret{}
+		require.NoError(t, json.Unmarshal(patch.GetPatch(), &secret))
+		applied[patch.GetNamespace()] = &secret
+		return true, &secret, nil
+	})
+
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+	require.NoError(t, repl.Store.Add(&existing))
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "applied",
+			Namespace:       "source",
+			ResourceVersion: "2",
+			Labels:          map[string]string{"team": "source"},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+
+	for _, namespace := range []string{"existing", "new"} {
+		require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}))
+
+		secret, ok := applied[namespace]
+		require.True(t, ok, "no apply in namespace %s", namespace)
+		require.Equal(t, "v1", secret.APIVersion)
+		require.Equal(t, "Secret", secret.Kind)
+		require.Empty(t, secret.ResourceVersion)
+		require.Empty(t, secret.ManagedFields)
+		require.NotContains(t, secret.Labels, "owner")
+		require.NotContains(t, secret.Labels, "team")
+		require.Empty(t, secret.Finalizers)
+		require.Equal(t, "2", secret.Annotations[common.ReplicatedFromVersionAnnotation])
+		require.NotContains(t, secret.Annotations, "tenant/annotation")
+		require.Equal(t, []byte("Hello Foo"), secret.Data["foo"])
+	}
+
+	for _, action := range client.Actions() {
+		require.NotContains(t, []string{"create", "update"}, action.GetVerb())
+	}
+}
+
+// keys returns the keys of a decoded JSON object
+func keys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestReplicateObjectToGeneratesKeys(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+
+	tenantA := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}}
+	tenantB := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}}
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "signing",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo:  "tenant-a,tenant-b",
+				common.GenerateKeys: "hmac-key=32",
+			},
+		},
+		Data: map[string][]byte{
+			"hmac-key": []byte("not copied"),
+			"issuer":   []byte("example.com"),
+		},
+	}
+
+	replica := func(namespace string) *corev1.Secret {
+		replica, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Update(replica))
+		return replica
+	}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, &tenantA))
+	require.NoError(t, repl.ReplicateObjectTo(&source, &tenantB))
+
+	a := replica(tenantA.Name)
+	b := replica(tenantB.Name)
+
+	t.Run("each target gets its own value", func(t *testing.T) {
+		require.Len(t, a.Data["hmac-key"], 32)
+		require.Len(t, b.Data["hmac-key"], 32)
+		require.NotEqual(t, a.Data["hmac-key"], b.Data["hmac-key"])
+		require.Equal(t, []byte("example.com"), a.Data["issuer"])
+		require.Equal(t, "hmac-key,issuer", a.Annotations[common.ReplicatedKeysAnnotation])
+	})
+
+	t.Run("generated values are kept on updates", func(t *testing.T) {
+		source.ResourceVersion = "2"
+		source.Data["issuer"] = []byte("example.org")
+
+		require.NoError(t, repl.ReplicateObjectTo(&source, &tenantA))
+
+		updated := replica(tenantA.Name)
+		require.Equal(t, a.Data["hmac-key"], updated.Data["hmac-key"])
+		require.Equal(t, []byte("example.org"), updated.Data["issuer"])
+	})
+
+	t.Run("invalid annotation is rejected", func(t *testing.T) {
+		source.ResourceVersion = "3"
+		source.Annotations[common.GenerateKeys] = "hmac-key"
+
+		require.Error(t, repl.ReplicateObjectTo(&source, &tenantA))
+	})
+}
+
+func TestResourceDeletedHonoursOnSourceDelete(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	target := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}}
+	_, err := client.CoreV1().Namespaces().Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "standalone",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo:    target.Name,
+				common.OnSourceDelete: common.OnSourceDeleteOrphan,
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+
+	t.Run("orphaned replicas keep their content", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateObjectTo(&source, &target))
+		replica, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Update(replica))
+
+		repl.ResourceDeleted(&source)
+
+		orphan, err := client.CoreV1().Secrets(target.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, source.Data, orphan.Data)
+		require.NotContains(t, orphan.Annotations, common.ReplicatedFromVersionAnnotation)
+		require.NotContains(t, orphan.Annotations, common.ReplicatedKeysAnnotation)
+	})
+
+	t.Run("cascade deletes pulling targets", func(t *testing.T) {
+		source.Name = "cascading"
+		source.Annotations = map[string]string{
+			common.ReplicationAllowed: "true",
+			common.OnSourceDelete:     common.OnSourceDeleteCascade,
+		}
+
+		pulled := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pulled",
+				Namespace:   target.Name,
+				Annotations: map[string]string{common.ReplicateFromAnnotation: common.MustGetKey(&source)},
+			},
+		}
+		_, err := client.CoreV1().Secrets(pulled.Namespace).Create(context.TODO(), &pulled, metav1.CreateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.ReplicateDataFrom(&source, &pulled))
+
+		replica, err := client.CoreV1().Secrets(pulled.Namespace).Get(context.TODO(), pulled.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Update(replica))
+		repl.DependencyMap[common.MustGetKey(&source)] = map[string]interface{}{common.MustGetKey(replica): nil}
+
+		repl.ResourceDeleted(&source)
+
+		_, err = client.CoreV1().Secrets(pulled.Namespace).Get(context.TODO(), pulled.Name, metav1.GetOptions{})
+		require.True(t, errors.IsNotFound(err))
+	})
+}
+
+func TestSourcesOutsideOfEnvironmentAreIgnored(t *testing.T) {
+	defer func(environment string) { common.Options.Environment = environment }(common.Options.Environment)
+	common.Options.Environment = "prod"
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	for _, environment := range []string{"staging", "prod"} {
+		source := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            environment,
+				Namespace:       "source",
+				ResourceVersion: "1",
+				Annotations:     map[string]string{common.EnvironmentAnnotation: environment},
+			},
+			Data: map[string][]byte{"foo": []byte("Hello Foo")},
+		}
+		require.NoError(t, repl.Store.Add(&source))
+
+		target := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        environment,
+				Namespace:   "target",
+				Annotations: map[string]string{common.ReplicateFromAnnotation: common.MustGetKey(&source)},
+			},
+		}
+		_, err := client.CoreV1().Secrets(target.Namespace).Create(context.TODO(), &target, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		repl.ResourceAdded(&target)
+	}
+
+	staging, err := client.CoreV1().Secrets("target").Get(context.TODO(), "staging", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, staging.Data)
+
+	prod, err := client.CoreV1().Secrets("target").Get(context.TODO(), "prod", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("Hello Foo"), prod.Data["foo"])
+}
+
 func waitForNamespaces(client *kubernetes.Clientset, count int, eventHandlers EventHandlerFuncs) (wg *sync.WaitGroup, stop chan struct{}) {
 	wg = &sync.WaitGroup{}
 	wg.Add(count)
@@ -1359,3 +2203,553 @@ func homeDir() string {
 	}
 	return os.Getenv("USERPROFILE") // windows
 }
+
+func TestReplicateDataFromPullsSingleKey(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "credentials",
+			Namespace:       "source",
+			ResourceVersion: "1",
+		},
+		Data: map[string][]byte{
+			"username": []byte("admin"),
+			"password": []byte("secret"),
+		},
+	}
+
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "password",
+			Namespace:   "target",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: "source/credentials:password"},
+		},
+	}
+	_, err := client.CoreV1().Secrets(target.Namespace).Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, repl.ReplicateDataFrom(&source, &target))
+
+	replica, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"password": []byte("secret")}, replica.Data)
+	require.Equal(t, "password", replica.Annotations[common.ReplicatedKeysAnnotation])
+}
+
+func TestPushedReplicasFollowReplicaMetadataTemplate(t *testing.T) {
+	defer func(tmpl *common.ReplicaMetadataTemplate) { common.Options.ReplicaMetadataTemplate = tmpl }(common.Options.ReplicaMetadataTemplate)
+
+	path := filepath.Join(t.TempDir(), "replica-metadata.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`labels: {cost-center: '{{ index .Labels "cost-center" }}'}`), 0644))
+
+	var err error
+	common.Options.ReplicaMetadataTemplate, err = common.LoadReplicaMetadataTemplate(path)
+	require.NoError(t, err)
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "labelled",
+			Namespace:       "source",
+			ResourceVersion: "1",
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+	target := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "push-target", Labels: map[string]string{"cost-center": "1234"}}}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, target))
+	replica, err := client.CoreV1().Secrets("push-target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "1234", replica.Labels["cost-center"])
+
+	// the source is unchanged, but the replica is updated to follow the namespace
+	target.Labels["cost-center"] = "5678"
+	require.NoError(t, repl.ReplicateObjectTo(&source, target))
+	replica, err = client.CoreV1().Secrets("push-target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "5678", replica.Labels["cost-center"])
+}
+
+func TestRecreatedSourceIsReplicatedAgain(t *testing.T) {
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "recreated",
+			Namespace:       "source",
+			UID:             "first",
+			ResourceVersion: "5",
+			Annotations: map[string]string{
+				common.ReplicationAllowed:           "true",
+				common.ReplicationAllowedNamespaces: "target",
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "recreated",
+			Namespace:   "target",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: "source/recreated"},
+		},
+	}
+
+	client := fake.NewSimpleClientset(&source, &target)
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+	require.NoError(t, repl.Store.Add(&source))
+	require.NoError(t, repl.Store.Add(&target))
+
+	require.NoError(t, repl.ReplicateDataFrom(&source, &target))
+	replicated, err := client.CoreV1().Secrets("target").Get(context.TODO(), "recreated", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "first", replicated.Annotations[common.ReplicatedFromUIDAnnotation])
+	require.Equal(t, []byte("Hello Foo"), replicated.Data["foo"])
+
+	// the recreated source has the version the target was written from, e.g.
+	// after etcd was restored from a backup
+	recreated := source.DeepCopy()
+	recreated.UID = "second"
+	recreated.Data = map[string][]byte{"foo": []byte("Hello Bar")}
+	require.NoError(t, repl.Store.Update(recreated))
+
+	require.NoError(t, repl.ReplicateDataFrom(recreated, replicated))
+	replicated, err = client.CoreV1().Secrets("target").Get(context.TODO(), "recreated", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "second", replicated.Annotations[common.ReplicatedFromUIDAnnotation])
+	require.Equal(t, []byte("Hello Bar"), replicated.Data["foo"])
+
+	// unchanged sources are not written again
+	require.NoError(t, repl.ReplicateDataFrom(recreated, replicated))
+	updates := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	require.Equal(t, 2, updates)
+}
+
+func TestGrantingPermissionToSourceEnablesPull(t *testing.T) {
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "granted",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicationAllowed:           "true",
+				common.ReplicationAllowedNamespaces: "nobody",
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+	targets := []string{"allowed", "denied-1", "denied-2", "denied-3", "denied-4", "denied-5"}
+
+	client := fake.NewSimpleClientset(&source)
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+	require.NoError(t, repl.Store.Add(&source))
+
+	for _, namespace := range targets {
+		target := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "granted",
+				Namespace:   namespace,
+				Annotations: map[string]string{common.ReplicateFromAnnotation: "source/granted"},
+			},
+		}
+		_, err := client.CoreV1().Secrets(namespace).Create(context.TODO(), target, metav1.CreateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, repl.Store.Add(target))
+
+		repl.ResourceAdded(target)
+	}
+
+	target, err := client.CoreV1().Secrets("allowed").Get(context.TODO(), "granted", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, target.Data)
+
+	// the source grants access to one of its targets, but not the others
+	granted := source.DeepCopy()
+	granted.ResourceVersion = "2"
+	granted.Annotations[common.ReplicationAllowedNamespaces] = "allowed"
+	require.NoError(t, repl.Store.Update(granted))
+
+	repl.ResourceAdded(granted)
+
+	target, err = client.CoreV1().Secrets("allowed").Get(context.TODO(), "granted", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("Hello Foo"), target.Data["foo"])
+
+	for _, namespace := range targets[1:] {
+		denied, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), "granted", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Empty(t, denied.Data, namespace)
+	}
+}
+
+func TestChangesOfIgnoredKeysAreNotReplicated(t *testing.T) {
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "rotating",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				common.ReplicateTo:          "target",
+				common.ComparisonIgnoreKeys: "token,tmp-*",
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo"), "token": []byte("initial")},
+	}
+
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, false).(*Replicator)
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, namespace))
+	replica, err := client.CoreV1().Secrets("target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("initial"), replica.Data["token"], "new replicas get the ignored keys of their source")
+
+	// another controller rotates the token of the replica and adds a
+	// dynamically named key
+	replica.Data["token"] = []byte("rotated")
+	replica.Data["tmp-abc123"] = []byte("external")
+	replica, err = client.CoreV1().Secrets("target").Update(context.TODO(), replica, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repl.Store.Update(replica))
+
+	// the source only changes in the ignored key
+	changed := source.DeepCopy()
+	changed.ResourceVersion = "2"
+	changed.Data["token"] = []byte("changed")
+	client.ClearActions()
+
+	require.NoError(t, repl.ReplicateObjectTo(changed, namespace))
+	for _, action := range client.Actions() {
+		require.NotEqual(t, "update", action.GetVerb(), "replica is not written again")
+	}
+
+	// other changes are replicated, but keep the value of the ignored key
+	changed.ResourceVersion = "3"
+	changed.Data["foo"] = []byte("Hello Bar")
+
+	require.NoError(t, repl.ReplicateObjectTo(changed, namespace))
+	replica, err = client.CoreV1().Secrets("target").Get(context.TODO(), source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("Hello Bar"), replica.Data["foo"])
+	require.Equal(t, []byte("rotated"), replica.Data["token"])
+	require.Equal(t, []byte("external"), replica.Data["tmp-abc123"])
+	require.Equal(t, "3", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+}
+
+func TestResyncWithoutChangesDoesNotWriteReplicas(t *testing.T) {
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "credentials",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.ReplicateTo: "pushed"},
+		},
+		Data: map[string][]byte{"password": []byte("secret")},
+	}
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "credentials",
+			Namespace:   "pulled",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: "source/credentials"},
+		},
+	}
+
+	client := fake.NewSimpleClientset(&target)
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pushed"}}
+
+	require.NoError(t, repl.Store.Add(&target))
+	require.NoError(t, repl.ReplicateObjectTo(&source, namespace))
+	require.NoError(t, repl.ReplicateDataFrom(&source, &target))
+
+	pulled, err := client.CoreV1().Secrets("pulled").Get(context.TODO(), target.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	client.ClearActions()
+
+	// a resync delivers the unchanged source and targets again
+	require.NoError(t, repl.ReplicateObjectTo(&source, namespace))
+	require.NoError(t, repl.ReplicateDataFrom(&source, pulled))
+
+	for _, action := range client.Actions() {
+		require.Equal(t, "get", action.GetVerb(), "no replica is written during a resync")
+	}
+}
+
+func TestUpdateKeepsForeignMetadataOfTarget(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	// the fake clientset doesn't check resource versions on its own
+	client.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updated := action.(k8stesting.UpdateAction).GetObject().(*corev1.Secret)
+		current, err := client.Tracker().Get(corev1.SchemeGroupVersion.WithResource("secrets"), updated.Namespace, updated.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		if current.(*corev1.Secret).ResourceVersion != updated.ResourceVersion {
+			return true, nil, errors.NewConflict(corev1.Resource("secrets"), updated.Name, fmt.Errorf("the object has been modified"))
+		}
+		updated = updated.DeepCopy()
+		updated.ResourceVersion = updated.ResourceVersion + "1"
+		return true, updated, client.Tracker().Update(corev1.SchemeGroupVersion.WithResource("secrets"), updated, updated.Namespace)
+	})
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "shared",
+			Namespace:       "source",
+			ResourceVersion: "2",
+			Annotations: map[string]string{
+				common.ReplicationAllowed:           "true",
+				common.ReplicationAllowedNamespaces: "*",
+			},
+		},
+		Data: map[string][]byte{"foo": []byte("Hello Foo")},
+	}
+
+	for _, pull := range []bool{false, true} {
+		pull := pull
+		namespace := fmt.Sprintf("foreign-pull-%t", pull)
+
+		t.Run(fmt.Sprintf("pull=%t", pull), func(t *testing.T) {
+			cached := corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            source.Name,
+					Namespace:       namespace,
+					ResourceVersion: "1",
+					Annotations:     map[string]string{common.ReplicatedKeysAnnotation: "foo,old"},
+				},
+				Data: map[string][]byte{"old": []byte("removed from source")},
+			}
+			if pull {
+				cached.Annotations[common.ReplicateFromAnnotation] = common.MustGetKey(&source)
+			}
+			require.NoError(t, repl.Store.Add(&cached))
+
+			// a finalizer, an owner and an annotation were added by others
+			// after the target was cached
+			current := cached.DeepCopy()
+			current.ResourceVersion = "5"
+			current.Finalizers = []string{"example.com/protect"}
+			current.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "1234"}}
+			current.Annotations["added/later"] = "kept"
+			require.NoError(t, client.Tracker().Add(current))
+
+			if pull {
+				require.NoError(t, repl.ReplicateDataFrom(&source, &cached))
+			} else {
+				require.NoError(t, repl.ReplicateObjectTo(&source, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}))
+			}
+
+			replica, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), source.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, map[string][]byte{"foo": []byte("Hello Foo")}, replica.Data)
+			require.Equal(t, []string{"example.com/protect"}, replica.Finalizers)
+			require.Equal(t, current.OwnerReferences, replica.OwnerReferences)
+			require.Equal(t, "kept", replica.Annotations["added/later"])
+			require.Equal(t, "2", replica.Annotations[common.ReplicatedFromVersionAnnotation])
+			require.Equal(t, "foo", replica.Annotations[common.ReplicatedKeysAnnotation])
+		})
+	}
+}
+
+func TestServiceAccountTokensAreNotReplicated(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+	recorder := record.NewFakeRecorder(10)
+	repl.Recorder = recorder
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "default-token",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{corev1.ServiceAccountNameKey: "default"},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+		Data: map[string][]byte{"token": []byte("eyJhbGciOi"), "ca.crt": []byte("cert")},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pushed"}}
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "token",
+			Namespace:   "pulled",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: common.MustGetKey(&source)},
+		},
+	}
+	_, err := client.CoreV1().Secrets(target.Namespace).Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	t.Run("tokens are refused by default", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateObjectTo(&source, namespace))
+		require.Contains(t, <-recorder.Events, "ServiceAccountToken")
+		_, err := client.CoreV1().Secrets(namespace.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.True(t, errors.IsNotFound(err))
+
+		require.NoError(t, repl.ReplicateDataFrom(&source, &target))
+		require.Contains(t, <-recorder.Events, "ServiceAccountToken")
+		pulled, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Empty(t, pulled.Data)
+	})
+
+	t.Run("tokens are replicated if the source allows it", func(t *testing.T) {
+		allowed := source.DeepCopy()
+		allowed.Annotations[common.AllowServiceAccountToken] = "true"
+
+		require.NoError(t, repl.ReplicateObjectTo(allowed, namespace))
+		replica, err := client.CoreV1().Secrets(namespace.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, corev1.SecretTypeServiceAccountToken, replica.Type)
+		require.Equal(t, source.Data, replica.Data)
+
+		require.NoError(t, repl.ReplicateDataFrom(allowed, &target))
+		pulled, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, source.Data, pulled.Data)
+		require.Empty(t, recorder.Events)
+	})
+}
+
+func TestPinnedReplicaIsNotUpdated(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "source",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.ReplicationAllowed: "true", common.ReplicationAllowedNamespaces: "target"},
+		},
+		Data: map[string][]byte{"password": []byte("old")},
+	}
+	target := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "target",
+			Namespace: "target",
+			Annotations: map[string]string{
+				common.ReplicateFromAnnotation: common.MustGetKey(&source),
+				common.PinVersion:              "1",
+			},
+		},
+	}
+	_, err := client.CoreV1().Secrets(target.Namespace).Create(context.TODO(), &target, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	getTarget := func() *corev1.Secret {
+		replica, err := client.CoreV1().Secrets(target.Namespace).Get(context.TODO(), target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		return replica
+	}
+
+	require.NoError(t, repl.ReplicateDataFrom(&source, &target))
+	replica := getTarget()
+	require.Equal(t, []byte("old"), replica.Data["password"])
+
+	changed := source.DeepCopy()
+	changed.ResourceVersion = "2"
+	changed.Data["password"] = []byte("new")
+
+	t.Run("pinned target keeps the pinned version", func(t *testing.T) {
+		require.NoError(t, repl.ReplicateDataFrom(changed, replica))
+		require.Equal(t, []byte("old"), getTarget().Data["password"])
+	})
+
+	t.Run("bumping the pin updates the target", func(t *testing.T) {
+		bumped := getTarget()
+		bumped.Annotations[common.PinVersion] = "2"
+		require.NoError(t, repl.ReplicateDataFrom(changed, bumped))
+		require.Equal(t, []byte("new"), getTarget().Data["password"])
+		require.Equal(t, "2", getTarget().Annotations[common.PinVersion])
+	})
+
+	t.Run("removing the pin resumes replication", func(t *testing.T) {
+		unpinned := getTarget()
+		delete(unpinned.Annotations, common.PinVersion)
+		latest := changed.DeepCopy()
+		latest.ResourceVersion = "3"
+		latest.Data["password"] = []byte("latest")
+		require.NoError(t, repl.ReplicateDataFrom(latest, unpinned))
+		require.Equal(t, []byte("latest"), getTarget().Data["password"])
+	})
+}
+
+func TestInvalidDataIsNotReplicated(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, 60*time.Second, true).(*Replicator)
+	recorder := record.NewFakeRecorder(10)
+	repl.Recorder = recorder
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	source := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "tls",
+			Namespace:       "source",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.Validate: "tls"},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}}
+
+	getReplica := func() *corev1.Secret {
+		replica, err := client.CoreV1().Secrets(namespace.Name).Get(context.TODO(), source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		return replica
+	}
+
+	require.NoError(t, repl.ReplicateObjectTo(&source, namespace))
+	require.Equal(t, certPEM, getReplica().Data[corev1.TLSCertKey])
+	require.Empty(t, recorder.Events)
+
+	for name, data := range map[string]map[string][]byte{
+		"double-encoded certificate": {corev1.TLSCertKey: []byte(base64.StdEncoding.EncodeToString(certPEM)), corev1.TLSPrivateKeyKey: keyPEM},
+		"malformed certificate":      {corev1.TLSCertKey: certPEM[:len(certPEM)/2], corev1.TLSPrivateKeyKey: keyPEM},
+		"missing key":                {corev1.TLSCertKey: certPEM},
+	} {
+		t.Run(name, func(t *testing.T) {
+			broken := source.DeepCopy()
+			broken.ResourceVersion = "2"
+			broken.Data = data
+
+			require.NoError(t, repl.ReplicateObjectTo(broken, namespace))
+			require.Contains(t, <-recorder.Events, "InvalidData")
+			require.Equal(t, certPEM, getReplica().Data[corev1.TLSCertKey], "the replica keeps its valid data")
+		})
+	}
+
+	t.Run("unknown validations are refused", func(t *testing.T) {
+		typo := source.DeepCopy()
+		typo.Annotations[common.Validate] = "tsl"
+		require.Error(t, validateData(typo))
+	})
+
+	t.Run("registry credentials must be JSON", func(t *testing.T) {
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{common.Validate: "dockerconfigjson"}},
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+		}
+		require.NoError(t, validateData(credentials))
+
+		credentials.Data[corev1.DockerConfigJsonKey] = []byte(base64.StdEncoding.EncodeToString([]byte(`{"auths":{}}`)))
+		require.EqualError(t, validateData(credentials), ".dockerconfigjson is base64-encoded twice")
+	})
+}
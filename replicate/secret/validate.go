@@ -0,0 +1,99 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Values of the Validate annotation
+const (
+	validateTLS              = "tls"
+	validateDockerConfigJSON = "dockerconfigjson"
+)
+
+// validateData checks the data of a secret with all validations listed in
+// its Validate annotation, separated by comma. Unknown validations fail, so
+// that a typo doesn't silently disable the validation.
+func validateData(secret *v1.Secret) error {
+	value, ok := secret.Annotations[common.Validate]
+	if !ok {
+		return nil
+	}
+
+	for _, validation := range strings.Split(value, ",") {
+		var err error
+
+		switch strings.TrimSpace(validation) {
+		case "":
+			continue
+		case validateTLS:
+			err = validateTLSData(secret.Data)
+		case validateDockerConfigJSON:
+			err = validateDockerConfigJSONData(secret.Data)
+		default:
+			err = errors.Errorf("unknown validation '%s'", validation)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateTLSData checks that the certificate and the private key of a TLS
+// secret can be parsed and belong together
+func validateTLSData(data map[string][]byte) error {
+	for _, key := range []string{v1.TLSCertKey, v1.TLSPrivateKeyKey} {
+		if len(data[key]) == 0 {
+			return errors.Errorf("%s is missing", key)
+		}
+		if encodedTwice(data[key], []byte("-----BEGIN")) {
+			return errors.Errorf("%s is base64-encoded twice", key)
+		}
+	}
+
+	if _, err := tls.X509KeyPair(data[v1.TLSCertKey], data[v1.TLSPrivateKeyKey]); err != nil {
+		return errors.Wrapf(err, "invalid %s or %s", v1.TLSCertKey, v1.TLSPrivateKeyKey)
+	}
+
+	return nil
+}
+
+// validateDockerConfigJSONData checks that the registry credentials of a
+// secret are a JSON object
+func validateDockerConfigJSONData(data map[string][]byte) error {
+	value, ok := data[v1.DockerConfigJsonKey]
+	if !ok {
+		return errors.Errorf("%s is missing", v1.DockerConfigJsonKey)
+	}
+	if encodedTwice(value, []byte("{")) {
+		return errors.Errorf("%s is base64-encoded twice", v1.DockerConfigJsonKey)
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(value, &config); err != nil {
+		return errors.Wrapf(err, "invalid %s", v1.DockerConfigJsonKey)
+	}
+
+	return nil
+}
+
+// encodedTwice checks if a value is the base64 encoding of content that
+// starts with the given prefix, i.e. if it was encoded once more than needed
+func encodedTwice(value []byte, prefix []byte) bool {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value)))
+	if err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(bytes.TrimSpace(decoded), prefix)
+}
@@ -0,0 +1,49 @@
+// Package transform contains the API of external transformation plugins,
+// which may change replicas before they are written. The API is defined in
+// transform.proto; transform.pb.go is generated from it with protoc-gen-go.
+package transform
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TransformMethod is the full name of the Transform method of the Transformer
+// service
+const TransformMethod = "/replicator.transform.v1.Transformer/Transform"
+
+// Client calls the Transformer service of a plugin
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// Dial creates a client for the plugin at the given address, e.g.
+// "localhost:9200" or "unix:///var/run/transform.sock". The connection is not
+// encrypted, so the plugin should run in the same pod as the replicator. The
+// connection is established in the background; calls fail while the plugin is
+// not reachable.
+func Dial(addr string) (*Client, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to transformation plugin at %s", addr)
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+// Transform sends a replica to the plugin and returns the plugin's response
+func (c *Client) Transform(ctx context.Context, req *TransformRequest) (*TransformResponse, error) {
+	resp := new(TransformResponse)
+	if err := c.conn.Invoke(ctx, TransformMethod, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Close closes the connection to the plugin
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,54 @@
+package transform
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type transformerServer interface {
+	Transform(ctx context.Context, req *TransformRequest) (*TransformResponse, error)
+}
+
+type prefixServer struct{}
+
+func (prefixServer) Transform(_ context.Context, req *TransformRequest) (*TransformResponse, error) {
+	return &TransformResponse{Replica: []byte(req.TargetNamespace + ":" + string(req.Replica))}, nil
+}
+
+// serviceDesc registers a Transformer server without generated gRPC code
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "replicator.transform.v1.Transformer",
+	HandlerType: (*transformerServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Transform",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(TransformRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return srv.(transformerServer).Transform(ctx, req)
+		},
+	}},
+}
+
+func TestClientCallsPlugin(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	server.RegisterService(&serviceDesc, prefixServer{})
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	client, err := Dial(listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	resp, err := client.Transform(context.Background(), &TransformRequest{Kind: "Secret", TargetNamespace: "team-a", Replica: []byte("{}")})
+	require.NoError(t, err)
+	require.Equal(t, "team-a:{}", string(resp.Replica))
+}
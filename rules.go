@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	log "github.com/sirupsen/logrus"
+)
+
+const replicationRulesCheckInterval = 30 * time.Second
+
+func loadReplicationRules(path string) error {
+	rules, err := common.LoadReplicationRules(path)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("loaded %d replication rules from %s", len(rules), path)
+	common.SetReplicationRules(rules)
+
+	return nil
+}
+
+// watchReplicationRules reloads the replication rules whenever the file's
+// content changes or the process receives a SIGHUP. If the file can't be
+// loaded, the previous rules stay active.
+func watchReplicationRules(path string, interval time.Duration) {
+	logger := log.WithField("file", path)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	content, _ := os.ReadFile(path)
+
+	for {
+		select {
+		case <-hup:
+			logger.Info("received SIGHUP, reloading replication rules")
+		case <-ticker.C:
+			current, err := os.ReadFile(path)
+			if err != nil || bytes.Equal(current, content) {
+				continue
+			}
+			logger.Info("replication rules changed, reloading")
+		}
+
+		content, _ = os.ReadFile(path)
+		if err := loadReplicationRules(path); err != nil {
+			logger.WithError(err).Error("could not reload replication rules, keeping previous rules")
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/mittwald/kubernetes-replicator/replicate/configmap"
+	"github.com/mittwald/kubernetes-replicator/replicate/ingress"
+	"github.com/mittwald/kubernetes-replicator/replicate/role"
+	"github.com/mittwald/kubernetes-replicator/replicate/rolebinding"
+	"github.com/mittwald/kubernetes-replicator/replicate/secret"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// simulator is implemented by all replicators, since they embed the generic replicator
+type simulator interface {
+	Simulate(source interface{}, existing []interface{}) error
+}
+
+// simulatedKind describes how a source of a kind is simulated
+type simulatedKind struct {
+	Kind          string
+	Resource      string
+	NewReplicator func(kubernetes.Interface, time.Duration, bool) common.Replicator
+}
+
+func simulatedKindOf(obj runtime.Object) (simulatedKind, bool) {
+	switch obj.(type) {
+	case *v1.Secret:
+		return simulatedKind{"Secret", "secrets", secret.NewReplicator}, true
+	case *v1.ConfigMap:
+		return simulatedKind{"ConfigMap", "configmaps", configmap.NewReplicator}, true
+	case *rbacv1.Role:
+		return simulatedKind{"Role", "roles", role.NewReplicator}, true
+	case *rbacv1.RoleBinding:
+		return simulatedKind{"RoleBinding", "rolebindings", rolebinding.NewReplicator}, true
+	case *networkingv1.Ingress:
+		return simulatedKind{"Ingress", "ingresses", ingress.NewReplicator}, true
+	}
+
+	return simulatedKind{}, false
+}
+
+// simulate replicates the source from the first file into the namespaces from
+// the second file, using a fake clientset that also contains the objects of
+// all further files (e.g. existing replicas). It writes all API calls that
+// modify objects of the source's kind to w, grouped by namespace.
+func simulate(w io.Writer, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: simulate <source.yaml> <namespaces.yaml> [<existing.yaml>...]")
+	}
+
+	sources, err := readObjects(args[0])
+	if err != nil {
+		return err
+	}
+	if len(sources) != 1 {
+		return errors.Errorf("%s must contain exactly one source, got %d objects", args[0], len(sources))
+	}
+	source := sources[0]
+
+	kind, ok := simulatedKindOf(source)
+	if !ok {
+		return errors.Errorf("cannot replicate objects of type %T", source)
+	}
+
+	namespaceObjects, err := readObjects(args[1])
+	if err != nil {
+		return err
+	}
+	namespaces := make([]v1.Namespace, 0, len(namespaceObjects))
+	for _, obj := range namespaceObjects {
+		ns, ok := obj.(*v1.Namespace)
+		if !ok {
+			return errors.Errorf("%s must only contain namespaces, got %T", args[1], obj)
+		}
+		namespaces = append(namespaces, *ns)
+	}
+
+	objects := append([]runtime.Object{source}, namespaceObjects...)
+	existing := make([]interface{}, 0)
+	for _, path := range args[2:] {
+		existingObjects, err := readObjects(path)
+		if err != nil {
+			return err
+		}
+		for _, obj := range existingObjects {
+			objects = append(objects, obj)
+			if other, ok := simulatedKindOf(obj); ok && other.Kind == kind.Kind {
+				existing = append(existing, obj)
+			}
+		}
+	}
+
+	common.UseStaticNamespaces(namespaces)
+	client := fake.NewSimpleClientset(objects...)
+	client.ClearActions()
+
+	repl := kind.NewReplicator(client, 0, f.AllowAll)
+	failure := repl.(simulator).Simulate(source, existing)
+
+	if err := writeSimulatedActions(w, kind, client.Actions()); err != nil {
+		return err
+	}
+
+	return errors.Wrap(failure, "replication failed")
+}
+
+// readObjects decodes all objects of a multi-document YAML or JSON file
+func readObjects(path string) ([]runtime.Object, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", path)
+	}
+	defer file.Close()
+
+	objects := make([]runtime.Object, 0)
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "could not parse %s", path)
+		}
+		if len(raw.Raw) == 0 {
+			continue
+		}
+
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw.Raw, nil, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode object in %s", path)
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// writeSimulatedActions writes one line per create, update, patch or delete
+// call on the simulated kind. The calls are grouped by namespace, since the
+// replicator visits namespaces in random order; within a namespace, calls are
+// listed in the order they were made.
+func writeSimulatedActions(w io.Writer, kind simulatedKind, actions []k8stesting.Action) error {
+	writes := make([]k8stesting.Action, 0)
+	for _, action := range actions {
+		if action.GetResource().Resource != kind.Resource {
+			continue
+		}
+		switch action.GetVerb() {
+		case "create", "update", "patch", "delete":
+			writes = append(writes, action)
+		}
+	}
+
+	sort.SliceStable(writes, func(i, j int) bool {
+		return writes[i].GetNamespace() < writes[j].GetNamespace()
+	})
+
+	out := bufio.NewWriter(w)
+	if len(writes) == 0 {
+		fmt.Fprintln(out, "no changes")
+	}
+
+	for _, action := range writes {
+		var name, details string
+		switch a := action.(type) {
+		case k8stesting.CreateAction:
+			name = common.MustGetObject(a.GetObject()).GetName()
+		case k8stesting.UpdateAction:
+			name = common.MustGetObject(a.GetObject()).GetName()
+		case k8stesting.PatchAction:
+			name = a.GetName()
+			details = fmt.Sprintf(" %s", a.GetPatch())
+		case k8stesting.DeleteAction:
+			name = a.GetName()
+		}
+
+		fmt.Fprintf(out, "%-6s %s %s/%s%s\n", action.GetVerb(), kind.Kind, action.GetNamespace(), name, details)
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the simulate command")
+
+// writeDocuments writes the given YAML documents of a fixture into a file in
+// dir, in the given order
+func writeDocuments(t *testing.T, dir string, name string, documents []string, order []int) string {
+	t.Helper()
+
+	ordered := make([]string, 0, len(order))
+	for _, i := range order {
+		ordered = append(ordered, documents[i])
+	}
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(ordered, "---\n")), 0o644))
+	return path
+}
+
+func readDocuments(t *testing.T, path string) []string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(string(content), "---\n")
+}
+
+func TestSimulateOutputIsStable(t *testing.T) {
+	namespaces := readDocuments(t, "testdata/simulate/namespaces.yaml")
+	replicas := readDocuments(t, "testdata/simulate/replicas.yaml")
+	require.Len(t, namespaces, 5)
+	require.Len(t, replicas, 2)
+
+	golden := "testdata/simulate/output.golden"
+	if *updateGolden {
+		var out bytes.Buffer
+		require.NoError(t, simulate(&out, []string{"testdata/simulate/source.yaml", "testdata/simulate/namespaces.yaml", "testdata/simulate/replicas.yaml"}))
+		require.NoError(t, os.WriteFile(golden, out.Bytes(), 0o644))
+	}
+
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name       string
+		namespaces []int
+		replicas   [][]int
+	}{
+		{"fixture order", []int{0, 1, 2, 3, 4}, [][]int{{0, 1}}},
+		{"reversed", []int{4, 3, 2, 1, 0}, [][]int{{1, 0}}},
+		{"shuffled", []int{2, 0, 4, 1, 3}, [][]int{{1, 0}}},
+		{"replicas in separate files", []int{1, 3, 0, 4, 2}, [][]int{{1}, {0}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			args := []string{
+				"testdata/simulate/source.yaml",
+				writeDocuments(t, dir, "namespaces.yaml", namespaces, c.namespaces),
+			}
+			for i, order := range c.replicas {
+				args = append(args, writeDocuments(t, dir, "replicas-"+string(rune('a'+i))+".yaml", replicas, order))
+			}
+
+			// the replicator visits namespaces in random order, so every
+			// ordering is simulated several times
+			for i := 0; i < 5; i++ {
+				var out bytes.Buffer
+				require.NoError(t, simulate(&out, args))
+				assert.Equal(t, string(expected), out.String())
+			}
+		})
+	}
+}
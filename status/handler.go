@@ -0,0 +1,57 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler implements a HTTP response handler that reports the replication
+// status of a single source, given by the "kind", "namespace" and "name" query
+// parameters.
+type Handler struct {
+	Replicators map[string]common.Replicator
+}
+
+func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJSON(res, http.StatusMethodNotAllowed, errorResponse{Error: "only GET is supported"})
+		return
+	}
+
+	query := req.URL.Query()
+	kind, namespace, name := query.Get("kind"), query.Get("namespace"), query.Get("name")
+
+	if kind == "" || namespace == "" || name == "" {
+		writeJSON(res, http.StatusBadRequest, errorResponse{Error: "kind, namespace and name are required"})
+		return
+	}
+
+	replicator, ok := h.Replicators[kind]
+	if !ok {
+		writeJSON(res, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unknown kind %s", kind)})
+		return
+	}
+
+	status, ok := replicator.Status(namespace, name)
+	if !ok {
+		writeJSON(res, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("%s %s/%s not found", kind, namespace, name)})
+		return
+	}
+
+	writeJSON(res, http.StatusOK, status)
+}
+
+func writeJSON(res http.ResponseWriter, code int, body interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(code)
+
+	enc := json.NewEncoder(res)
+	_ = enc.Encode(body)
+}
@@ -0,0 +1,91 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type MockReplicator struct {
+	sources map[string]*common.SourceStatus
+}
+
+func (r *MockReplicator) Run() {
+}
+
+func (r *MockReplicator) Synced() bool {
+	return true
+}
+
+func (r *MockReplicator) Stalled() (time.Duration, bool) {
+	return 0, false
+}
+
+func (r *MockReplicator) NamespaceAdded(ns *v1.Namespace) {
+	// Do nothing
+}
+
+func (r *MockReplicator) Status(namespace string, name string) (*common.SourceStatus, bool) {
+	status, ok := r.sources[namespace+"/"+name]
+	return status, ok
+}
+
+func serve(t *testing.T, url string) *httptest.ResponseRecorder {
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "foo", ResourceVersion: "2"}}
+	targets := []metav1.Object{
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "a", Annotations: map[string]string{
+			common.ReplicatedFromVersionAnnotation: "2",
+		}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "b", Annotations: map[string]string{
+			common.ReplicatedFromVersionAnnotation: "1",
+		}}},
+	}
+
+	handler := Handler{
+		Replicators: map[string]common.Replicator{
+			"Secret": &MockReplicator{sources: map[string]*common.SourceStatus{
+				"foo/bar": common.NewSourceStatus("Secret", source, targets),
+			}},
+		},
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	res := httptest.NewRecorder()
+
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestReturnsStatusOfSource(t *testing.T) {
+	res := serve(t, "/status?kind=Secret&namespace=foo&name=bar")
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var status common.SourceStatus
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+
+	assert.Equal(t, "foo/bar", status.Source)
+	assert.Equal(t, "2", status.Version)
+	assert.Equal(t, []common.TargetStatus{
+		{Target: "a/bar", ReplicatedVersion: "2", InSync: true},
+		{Target: "b/bar", ReplicatedVersion: "1", InSync: false},
+	}, status.Targets)
+}
+
+func TestReturns404IfSourceIsNotFound(t *testing.T) {
+	res := serve(t, "/status?kind=Secret&namespace=foo&name=baz")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestReturns400ForInvalidQueries(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, serve(t, "/status?kind=Secret&namespace=foo").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(t, "/status?kind=Unknown&namespace=foo&name=bar").Code)
+}
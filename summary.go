@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// summarizer is implemented by all replicators, since they embed the generic replicator
+type summarizer interface {
+	Summary() *common.Summary
+}
+
+// logSummaries logs a summary of each replicator, including its recent errors
+func logSummaries(replicators map[string]common.Replicator) {
+	for _, k := range replicate.Kinds {
+		repl, ok := replicators[k.Kind].(summarizer)
+		if !ok {
+			continue
+		}
+
+		summary := repl.Summary()
+		logger := log.WithField("kind", summary.Kind)
+		logger.
+			WithField("cachedObjects", summary.CachedObjects).
+			WithField("sources", summary.Sources).
+			WithField("targets", summary.Targets).
+			WithField("queuedEvents", summary.QueuedEvents).
+			WithField("delayedReplications", summary.DelayedReplications).
+			WithField("quarantined", summary.Quarantined).
+			WithField("recentErrors", len(summary.RecentErrors)).
+			Info("summary")
+
+		for _, recent := range summary.RecentErrors {
+			logger.
+				WithField("resource", recent.Resource).
+				WithField("failedAt", recent.Time).
+				Infof("recent error: %s", recent.Error)
+		}
+	}
+}
+
+// watchSummarySignal logs a summary of all replicators whenever the process
+// receives a SIGUSR1
+func watchSummarySignal(replicators map[string]common.Replicator) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+
+	for range usr1 {
+		log.Info("received SIGUSR1, logging summary")
+		logSummaries(replicators)
+	}
+}
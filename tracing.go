@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// startTracing exports the spans of reconciles via OTLP/gRPC to the collector
+// at endpoint. The endpoint is either host:port, which is contacted via TLS,
+// or a URL; http:// URLs are contacted without TLS. Without a call to
+// startTracing, spans are not recorded at all.
+func startTracing(ctx context.Context, endpoint string) error {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if strings.HasPrefix(endpoint, "http://") {
+		options = []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(strings.TrimPrefix(endpoint, "http://")), otlptracegrpc.WithInsecure()}
+	} else if strings.HasPrefix(endpoint, "https://") {
+		options = []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(strings.TrimPrefix(endpoint, "https://"))}
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return err
+	}
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("kubernetes-replicator"))),
+	))
+
+	log.Infof("exporting traces to %s", endpoint)
+	return nil
+}